@@ -0,0 +1,59 @@
+package backfill
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/cmd/common"
+	"git.zam.io/wallet-backend/wallet-api/config"
+	"git.zam.io/wallet-backend/wallet-api/internal/fiatvalues"
+	"git.zam.io/wallet-backend/wallet-api/internal/providers"
+	"git.zam.io/wallet-backend/web-api/cmd/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/dig"
+)
+
+// Create and initialize backfill command for given viper instance
+func Create(v *viper.Viper, cfg *config.RootScheme) cobra.Command {
+	command := cobra.Command{
+		Use:   "backfill-fiat",
+		Short: "Captures fiat values of old txs using historical rates",
+		RunE: func(_ *cobra.Command, args []string) error {
+			return backfillMain(*cfg)
+		},
+	}
+	// add common flags
+	command.Flags().String(
+		"db.uri",
+		v.GetString("db.uri"),
+		"postgres connection uri",
+	)
+	v.BindPFlags(command.Flags())
+
+	return command
+}
+
+// backfillMain captures fiat values of all txs created before the capture window which values are missing
+func backfillMain(cfg config.RootScheme) (err error) {
+	// create DI container and populate it with providers
+	c := dig.New()
+
+	// provide basic stuff
+	common.ProvideBasic(c, cfg)
+
+	// provide coin converter, required by capturer
+	utils.MustProvide(c, providers.CoinConverter)
+
+	return c.Invoke(func(logger logrus.FieldLogger, capturer fiatvalues.ICapturer) error {
+		l := logger.WithField("module", "wallets.backfill_fiat")
+
+		capturedNum, err := capturer.Backfill(context.Background())
+		l = l.WithField("captured_num", capturedNum)
+		if err != nil {
+			l.WithError(err).Error("error occurs while backfilling txs fiat values")
+			return err
+		}
+		l.Info("txs fiat values backfilled")
+		return nil
+	})
+}
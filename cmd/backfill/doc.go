@@ -0,0 +1,2 @@
+// Package backfill defines txs fiat values backfill entry-point
+package backfill
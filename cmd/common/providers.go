@@ -7,6 +7,12 @@ import (
 	serverconf "git.zam.io/wallet-backend/wallet-api/config/server"
 	walletsconf "git.zam.io/wallet-backend/wallet-api/config/wallets"
 	internalproviders "git.zam.io/wallet-backend/wallet-api/internal/providers"
+	// register converters types used by coin converter provider
+	_ "git.zam.io/wallet-backend/wallet-api/pkg/services/convert/coingecko"
+	_ "git.zam.io/wallet-backend/wallet-api/pkg/services/convert/cryptocompare"
+	_ "git.zam.io/wallet-backend/wallet-api/pkg/services/convert/icex"
+	_ "git.zam.io/wallet-backend/wallet-api/pkg/services/convert/median"
+	_ "git.zam.io/wallet-backend/wallet-api/pkg/services/convert/static"
 	"git.zam.io/wallet-backend/web-api/cmd/utils"
 	dbconf "git.zam.io/wallet-backend/web-api/config/db"
 	iscconf "git.zam.io/wallet-backend/web-api/config/isc"
@@ -90,6 +96,12 @@ func ProvideBasic(c *dig.Container, cfg config.RootScheme) {
 	// provides txs event notificator
 	utils.MustProvide(c, internalproviders.TxsEventNotificator)
 
+	// provides payment requests event notificator
+	utils.MustProvide(c, internalproviders.RequestsEventNotificator)
+
+	// provides merchant invoices event notificator
+	utils.MustProvide(c, internalproviders.InvoicesEventNotificator)
+
 	// provide wallet nodes
 	utils.MustProvide(c, internalproviders.Coordinator)
 
@@ -101,4 +113,31 @@ func ProvideBasic(c *dig.Container, cfg config.RootScheme) {
 
 	// provide txs api
 	utils.MustProvide(c, internalproviders.TxsApi)
+
+	// provide proof-of-reserves generator
+	utils.MustProvide(c, internalproviders.Reserves)
+
+	// provide recurring payments manager
+	utils.MustProvide(c, internalproviders.Recurring)
+
+	// provide payment requests manager, requires coin converter
+	utils.MustProvide(c, internalproviders.Requests)
+
+	// provide merchant invoices manager, requires coin converter
+	utils.MustProvide(c, internalproviders.Invoices)
+
+	// provide fiat quotes manager, requires coin converter
+	utils.MustProvide(c, internalproviders.Quotes)
+
+	// provide locally collected rates history, requires coin converter
+	utils.MustProvide(c, internalproviders.RatesHistory)
+
+	// provide historical rates provider backed by rates history
+	utils.MustProvide(c, internalproviders.HistoricalRates)
+
+	// provide txs fiat values capturer, requires coin converter
+	utils.MustProvide(c, internalproviders.FiatValuesCapturer)
+
+	// provide user settings store, requires coin converter
+	utils.MustProvide(c, internalproviders.UserSettings)
 }
@@ -4,6 +4,7 @@ import (
 	"git.zam.io/wallet-backend/wallet-api/cmd/common"
 	"git.zam.io/wallet-backend/wallet-api/config"
 	"git.zam.io/wallet-backend/wallet-api/internal/isc/handlers/users"
+	"git.zam.io/wallet-backend/wallet-api/internal/providers"
 	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/btc"
 	"git.zam.io/wallet-backend/web-api/cmd/utils"
 	"git.zam.io/wallet-backend/web-api/pkg/services/broker"
@@ -40,6 +41,9 @@ func serverMain(cfg config.RootScheme) (err error) {
 	// provide basic stuff
 	common.ProvideBasic(c, cfg)
 
+	// provide coin converter, required by user settings
+	utils.MustProvide(c, providers.CoinConverter)
+
 	// register worker event handlers
 	utils.MustInvoke(c, users.Register)
 
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"git.zam.io/wallet-backend/wallet-api/cmd/backfill"
 	"git.zam.io/wallet-backend/wallet-api/cmd/listener"
+	"git.zam.io/wallet-backend/wallet-api/cmd/reconcile"
+	"git.zam.io/wallet-backend/wallet-api/cmd/reserves"
 	"git.zam.io/wallet-backend/wallet-api/cmd/root"
 	"git.zam.io/wallet-backend/wallet-api/cmd/server"
 	"git.zam.io/wallet-backend/wallet-api/cmd/watcher"
@@ -43,7 +46,12 @@ Built in pipeline: %s
 	watcherCmd := watcher.Create(v, &cfg)
 	listenerCmd := listener.Create(v, &cfg)
 	workerCmd := worker.Create(v, &cfg)
-	rootCmd.AddCommand(&serverCmd, &workerCmd, &listenerCmd, &watcherCmd)
+	reconcileCmd := reconcile.Create(v, &cfg)
+	reservesCmd := reserves.Create(v, &cfg)
+	backfillCmd := backfill.Create(v, &cfg)
+	rootCmd.AddCommand(
+		&serverCmd, &workerCmd, &listenerCmd, &watcherCmd, &reconcileCmd, &reservesCmd, &backfillCmd,
+	)
 
 	err := rootCmd.Execute()
 	if err != nil {
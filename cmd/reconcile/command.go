@@ -0,0 +1,95 @@
+package reconcile
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/cmd/common"
+	"git.zam.io/wallet-backend/wallet-api/config"
+	"git.zam.io/wallet-backend/wallet-api/internal/providers"
+	"git.zam.io/wallet-backend/wallet-api/internal/reconciliation"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/btc"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/eth"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/zam"
+	"git.zam.io/wallet-backend/web-api/cmd/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/dig"
+)
+
+// Create and initialize reconcile command for given viper instance
+func Create(v *viper.Viper, cfg *config.RootScheme) cobra.Command {
+	command := cobra.Command{
+		Use:   "reconcile [coin_name]",
+		Short: "Reconciles nodes balances with wallets balances and stores discrepancy reports",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			coinName := ""
+			if len(args) > 0 {
+				coinName = args[0]
+			}
+			return reconcileMain(*cfg, coinName)
+		},
+	}
+	// add common flags
+	command.Flags().String(
+		"db.uri",
+		v.GetString("db.uri"),
+		"postgres connection uri",
+	)
+	v.BindPFlags(command.Flags())
+
+	return command
+}
+
+// reconcileMain reconciles specified coin or all dialed coins if coin name is empty
+func reconcileMain(cfg config.RootScheme, coinName string) (err error) {
+	// create DI container and populate it with providers
+	c := dig.New()
+
+	// provide basic stuff
+	common.ProvideBasic(c, cfg)
+
+	// provide reconciler
+	utils.MustProvide(c, providers.Reconciler)
+
+	return c.Invoke(func(logger logrus.FieldLogger, reconciler reconciliation.IReconciler) (err error) {
+		ctx := context.Background()
+		l := logger.WithField("module", "wallets.reconcile")
+
+		var reports []reconciliation.Report
+		if coinName != "" {
+			var report *reconciliation.Report
+			report, err = reconciler.ReconcileCoin(ctx, coinName)
+			if report != nil {
+				reports = append(reports, *report)
+			}
+		} else {
+			reports, err = reconciler.Reconcile(ctx)
+		}
+
+		for _, report := range reports {
+			logReport(l, &report)
+		}
+		return
+	})
+}
+
+// logReport writes reconciliation report summary into the log
+func logReport(logger logrus.FieldLogger, report *reconciliation.Report) {
+	l := logger.WithFields(logrus.Fields{
+		"report_id":             report.ID,
+		"coin":                  report.Coin.ShortName,
+		"node_balance":          report.NodeBalance.V,
+		"wallets_balance":       report.WalletsBalance.V,
+		"pending":               report.PendingAmount.V,
+		"in_flight_amount":      report.InFlightAmount.V,
+		"in_flight_fee":         report.InFlightFee.V,
+		"discrepancy":           report.Discrepancy.V,
+		"address_discrepancies": len(report.Addresses),
+	})
+	if report.Exceeded {
+		l.Warn("reconciliation tolerance exceeded")
+		return
+	}
+	l.Info("reconciliation passed")
+}
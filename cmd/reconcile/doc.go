@@ -0,0 +1,2 @@
+// Package reconcile defines nodes balances reconciliation entry-point
+package reconcile
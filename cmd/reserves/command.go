@@ -0,0 +1,85 @@
+package reserves
+
+import (
+	"context"
+	"encoding/json"
+	"git.zam.io/wallet-backend/wallet-api/cmd/common"
+	"git.zam.io/wallet-backend/wallet-api/config"
+	"git.zam.io/wallet-backend/wallet-api/internal/reserves"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/btc"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/eth"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/zam"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/dig"
+	"io"
+	"os"
+)
+
+// Create and initialize reserves command for given viper instance
+func Create(v *viper.Viper, cfg *config.RootScheme) cobra.Command {
+	var output string
+	command := cobra.Command{
+		Use:   "reserves",
+		Short: "Generates proof-of-reserves report and exports liabilities roots and users inclusion proofs",
+		RunE: func(_ *cobra.Command, args []string) error {
+			return reservesMain(*cfg, output)
+		},
+	}
+	// add common flags
+	command.Flags().String(
+		"db.uri",
+		v.GetString("db.uri"),
+		"postgres connection uri",
+	)
+	v.BindPFlags(command.Flags())
+
+	command.Flags().StringVarP(&output, "output", "o", "", "exported report file, stdout is used if empty")
+
+	return command
+}
+
+// reservesMain generates report and writes it's export into given file
+func reservesMain(cfg config.RootScheme, output string) (err error) {
+	// create DI container and populate it with providers
+	c := dig.New()
+
+	// provide basic stuff
+	common.ProvideBasic(c, cfg)
+
+	return c.Invoke(func(logger logrus.FieldLogger, reservesApi reserves.IReserves) (err error) {
+		l := logger.WithField("module", "wallets.reserves")
+
+		report, err := reservesApi.Generate(context.Background())
+		if err != nil {
+			return
+		}
+		for _, cr := range report.Coins {
+			l.WithFields(logrus.Fields{
+				"report_id":         report.ID,
+				"coin":              cr.Coin.ShortName,
+				"root_hash":         cr.RootHash,
+				"liabilities":       cr.Liabilities.V,
+				"node_balance":      cr.NodeBalance.V,
+				"addresses_balance": cr.AddressesBalance.V,
+			}).Info("coin reserves report generated")
+		}
+
+		export := reserves.NewExport(report)
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	})
+}
@@ -0,0 +1,2 @@
+// Package reserves defines proof-of-reserves report generation entry-point
+package reserves
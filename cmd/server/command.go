@@ -5,7 +5,12 @@ import (
 	"git.zam.io/wallet-backend/wallet-api/cmd/common"
 	"git.zam.io/wallet-backend/wallet-api/config"
 	internalproviders "git.zam.io/wallet-backend/wallet-api/internal/providers"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/invoices"
 	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/isc"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/quotes"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/recurring"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/requests"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/txs"
 	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/wallets"
 	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/btc"
@@ -60,7 +65,7 @@ func serverMain(cfg config.RootScheme) (err error) {
 	utils.MustProvide(c, internalproviders.ApiRoutes, dig.Name("api_routes"))
 	utils.MustProvide(c, internalproviders.InternalApiRoutes, dig.Name("internal_api_routes"))
 
-	// provide icex converter
+	// provide configured converter
 	utils.MustProvide(c, internalproviders.CoinConverter)
 
 	// provide middlewares
@@ -71,6 +76,11 @@ func serverMain(cfg config.RootScheme) (err error) {
 	utils.MustInvoke(c, static.Register)
 	utils.MustInvoke(c, wallets.Register)
 	utils.MustInvoke(c, txs.Register)
+	utils.MustInvoke(c, recurring.Register)
+	utils.MustInvoke(c, requests.Register)
+	utils.MustInvoke(c, invoices.Register)
+	utils.MustInvoke(c, quotes.Register)
+	utils.MustInvoke(c, settings.Register)
 	utils.MustInvoke(c, isc.Register)
 
 	// Run server!
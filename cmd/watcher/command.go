@@ -4,6 +4,7 @@ import (
 	"context"
 	"git.zam.io/wallet-backend/wallet-api/cmd/common"
 	"git.zam.io/wallet-backend/wallet-api/config"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
 	"git.zam.io/wallet-backend/wallet-api/internal/processing"
 	"git.zam.io/wallet-backend/wallet-api/internal/providers"
 	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
@@ -63,18 +64,60 @@ func watcherMain(cfg config.RootScheme, coinName string) (err error) {
 	})
 
 	// Run worker
-	utils.MustInvoke(c, func(coordinator nodes.ICoordinator, notifier processing.IConfirmationNotifier) error {
+	utils.MustInvoke(c, func(
+		coordinator nodes.ICoordinator,
+		notifier processing.IConfirmationNotifier,
+		balanceCache helpers.IBalanceCache,
+	) error {
 		ctx := context.Background()
 
 		loop, err := coordinator.WatcherLoop(coinName)
 		if err != nil {
 			return err
 		}
+		// height of the last block which changed balances have been refreshed for, zero until the first block
+		lastHeight := 0
 		loop.OnNewBlockReleased(func(ctx context.Context, blockHeight int) error {
-			return notifier.OnNewConfirmation(ctx, coinName)
+			err := notifier.OnNewConfirmation(ctx, coinName)
+			if err != nil {
+				return err
+			}
+
+			err = refreshBalances(ctx, coordinator, balanceCache, coinName, lastHeight, blockHeight)
+			if err != nil {
+				return err
+			}
+			lastHeight = blockHeight
+			return nil
 		})
 		return loop.Run(ctx)
 	})
 
 	return
 }
+
+// refreshBalances refreshes balances of wallets which addresses are changed by blocks released since last height.
+// Balances of all coin wallets are refreshed if coin node can't report changed addresses, blocks range is too wide or
+// there is no last height, since balances may have changed while watcher was stopped. Wallets changed by txs sent by
+// the service are invalidated once such txs are committed.
+func refreshBalances(
+	ctx context.Context,
+	coordinator nodes.ICoordinator,
+	balanceCache helpers.IBalanceCache,
+	coinName string,
+	lastHeight, blockHeight int,
+) error {
+	observer := coordinator.BlocksObserver(coinName)
+	if observer == nil || lastHeight == 0 {
+		return balanceCache.RefreshCoin(ctx, coinName)
+	}
+
+	addresses, err := observer.ChangedAddresses(ctx, lastHeight, blockHeight)
+	if err == nodes.ErrTooManyBlocks {
+		return balanceCache.RefreshCoin(ctx, coinName)
+	}
+	if err != nil {
+		return err
+	}
+	return balanceCache.RefreshAddresses(ctx, coinName, addresses)
+}
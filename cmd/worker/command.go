@@ -1,15 +1,29 @@
 package worker
 
 import (
+	"context"
 	"git.zam.io/wallet-backend/wallet-api/cmd/common"
 	"git.zam.io/wallet-backend/wallet-api/config"
+	"git.zam.io/wallet-backend/wallet-api/internal/fiatvalues"
+	"git.zam.io/wallet-backend/wallet-api/internal/invoices"
 	"git.zam.io/wallet-backend/wallet-api/internal/processing"
 	"git.zam.io/wallet-backend/wallet-api/internal/providers"
+	"git.zam.io/wallet-backend/wallet-api/internal/rateshistory"
+	"git.zam.io/wallet-backend/wallet-api/internal/reconciliation"
+	"git.zam.io/wallet-backend/wallet-api/internal/recurring"
+	"git.zam.io/wallet-backend/wallet-api/internal/requests"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/btc"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/eth"
+	_ "git.zam.io/wallet-backend/wallet-api/internal/services/nodes/zam"
 	"git.zam.io/wallet-backend/web-api/cmd/utils"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/dig"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -44,22 +58,216 @@ func serverMain(cfg config.RootScheme) (err error) {
 	// provide notifier
 	utils.MustProvide(c, providers.CheckOutdatedNotifier)
 
-	// Run worker
-	utils.MustInvoke(c, func(logger logrus.FieldLogger, notifier processing.ICheckOutdatedNotifier) error {
-		sleepTimeout := time.Hour
+	// provide reconciler
+	utils.MustProvide(c, providers.Reconciler)
+
+	// provide configured converter, required by payment requests and invoices managers
+	utils.MustProvide(c, providers.CoinConverter)
+
+	// jobs are stopped once termination signal is received
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+		cancel()
+	}()
+	var jobs sync.WaitGroup
+
+	// Run reconciliation job
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, reconciler reconciliation.IReconciler) {
+		l := logger.WithField("module", "wallets.worker.reconciliation")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "reconciling", cfg.Processing.Reconciliation.Interval, func(ctx context.Context) error {
+				reports, err := reconciler.Reconcile(ctx)
+				for _, report := range reports {
+					if report.Exceeded {
+						l.WithField("coin", report.Coin.ShortName).WithField(
+							"report_id", report.ID,
+						).Warn("reconciliation tolerance exceeded")
+					}
+				}
+				return err
+			})
+		}()
+	})
+
+	// Run scheduled txs job
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, processingApi processing.IApi) {
+		l := logger.WithField("module", "wallets.worker.scheduled")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "executing due scheduled txs", cfg.Processing.ScheduledTxsInterval,
+				func(ctx context.Context) error {
+					executedNum, err := processingApi.ExecuteDueScheduled(ctx)
+					if executedNum > 0 {
+						l.WithField("executed_num", executedNum).Info("scheduled txs executed")
+					}
+					return err
+				},
+			)
+		}()
+	})
+
+	// Run batched withdrawals job
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, processingApi processing.IApi) {
+		l := logger.WithField("module", "wallets.worker.withdrawals")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "sending queued withdrawals", cfg.Processing.WithdrawalBatching.Interval,
+				func(ctx context.Context) error {
+					sentNum, err := processingApi.SendQueuedWithdrawals(ctx)
+					if sentNum > 0 {
+						l.WithField("sent_num", sentNum).Info("queued withdrawals sent")
+					}
+					return err
+				},
+			)
+		}()
+	})
+
+	// Run recurring payments job
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, recurringPayments recurring.IRecurring) {
+		l := logger.WithField("module", "wallets.worker.recurring")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "executing due recurring plans", cfg.Processing.RecurringInterval,
+				func(ctx context.Context) error {
+					executedNum, err := recurringPayments.ExecuteDue(ctx)
+					if executedNum > 0 {
+						l.WithField("executed_num", executedNum).Info("recurring plans executed")
+					}
+					return err
+				},
+			)
+		}()
+	})
+
+	// Run payment requests expiration job
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, paymentRequests requests.IRequests) {
+		l := logger.WithField("module", "wallets.worker.requests")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "expiring outdated payment requests", cfg.Processing.RequestsExpirationInterval,
+				func(ctx context.Context) error {
+					expiredNum, err := paymentRequests.ExpireOutdated(ctx)
+					if expiredNum > 0 {
+						l.WithField("expired_num", expiredNum).Info("payment requests expired")
+					}
+					return err
+				},
+			)
+		}()
+	})
+
+	// Run invoices settlement and webhooks delivery jobs
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, merchantInvoices invoices.IInvoices) {
+		l := logger.WithField("module", "wallets.worker.invoices")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "refreshing pending invoices", cfg.Processing.Invoices.RefreshInterval,
+				func(ctx context.Context) error {
+					changedNum, err := merchantInvoices.Refresh(ctx)
+					if changedNum > 0 {
+						l.WithField("changed_num", changedNum).Info("invoices settled")
+					}
+					return err
+				},
+			)
+		}()
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "delivering invoices webhooks", cfg.Processing.Invoices.WebhooksInterval,
+				func(ctx context.Context) error {
+					deliveredNum, err := merchantInvoices.DeliverWebhooks(ctx)
+					if deliveredNum > 0 {
+						l.WithField("delivered_num", deliveredNum).Info("invoices webhooks delivered")
+					}
+					return err
+				},
+			)
+		}()
+	})
+
+	// Run rates history collecting job
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, history rateshistory.IHistory) {
+		l := logger.WithField("module", "wallets.worker.rates_history")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "collecting rates", cfg.Processing.RatesHistory.CollectInterval,
+				func(ctx context.Context) error {
+					collectedNum, err := history.Collect(ctx)
+					if collectedNum > 0 {
+						l.WithField("collected_num", collectedNum).Debug("rates collected")
+					}
+					return err
+				},
+			)
+		}()
+	})
 
+	// Run txs fiat values capturing job
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, capturer fiatvalues.ICapturer) {
+		l := logger.WithField("module", "wallets.worker.fiat_values")
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runPeriodically(ctx, l, "capturing txs fiat values", cfg.Processing.FiatValues.CaptureInterval,
+				func(ctx context.Context) error {
+					capturedNum, err := capturer.Capture(ctx)
+					if capturedNum > 0 {
+						l.WithField("captured_num", capturedNum).Info("txs fiat values captured")
+					}
+					return err
+				},
+			)
+		}()
+	})
+
+	// Run worker
+	utils.MustInvoke(c, func(logger logrus.FieldLogger, notifier processing.ICheckOutdatedNotifier) {
 		l := logger.WithField("module", "wallets.worker")
-		for {
-			l.Debug("checking outdated")
-			err := notifier.OnCheckOutdated()
-			if err != nil {
-				l.WithError(err).Error("error occurs while updating")
-			}
-
-			l.Debugf("sleeping for %v", sleepTimeout)
-			time.Sleep(sleepTimeout)
-		}
+		runPeriodically(ctx, l, "checking outdated", time.Hour, func(ctx context.Context) error {
+			return notifier.OnCheckOutdated()
+		})
 	})
 
+	// wait for in-flight jobs iterations
+	jobs.Wait()
+	logrus.Info("worker stopped")
+
 	return
 }
+
+// runPeriodically calls fn every interval until ctx is done, fn errors are logged by given logger, blocks until
+// ctx is done and the last fn call returns
+func runPeriodically(
+	ctx context.Context, l logrus.FieldLogger, name string, interval time.Duration, fn func(ctx context.Context) error,
+) {
+	for {
+		l.Debug(name)
+		if err := fn(ctx); err != nil {
+			l.WithError(err).Errorf("error occurs while %s", name)
+		}
+
+		l.Debugf("sleeping for %v", interval)
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			l.Info("stopping")
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -2,10 +2,150 @@ package processing
 
 import "time"
 
+// ReconciliationScheme holds configuration of reconciliation between nodes balances and internal ledger
+type ReconciliationScheme struct {
+	// Interval between periodic reconciliations performed by worker
+	//
+	// Default: 6h
+	Interval time.Duration
+
+	// Tolerance is the max allowed absolute discrepancy in coin units which isn't reported, used for coins which
+	// have no specific tolerance
+	//
+	// Default: 0
+	Tolerance string
+
+	// CoinsTolerances overrides tolerance for specific coins, keys are coins short names
+	CoinsTolerances map[string]string
+}
+
+// WithdrawalBatchingScheme holds configuration of batched on-chain withdrawals
+type WithdrawalBatchingScheme struct {
+	// Coins which external txs are queued and sent by single multi-output tx, only coins which nodes support such txs
+	// (BTC, BCH) are batched
+	Coins []string
+
+	// Interval between worker sends of queued withdrawals
+	//
+	// Default: 10m
+	Interval time.Duration
+}
+
+// InvoicesScheme holds merchant invoices configuration
+type InvoicesScheme struct {
+	// RefreshInterval between worker checks of pending invoices payments
+	//
+	// Default: 1m
+	RefreshInterval time.Duration
+
+	// WebhookTimeout limits merchant webhook call duration
+	//
+	// Default: 10s
+	WebhookTimeout time.Duration
+
+	// WebhooksInterval between worker deliveries of invoices status changes to merchant webhooks
+	//
+	// Default: 30s
+	WebhooksInterval time.Duration
+}
+
+// QuotesScheme holds fiat quotes configuration
+type QuotesScheme struct {
+	// TTL is the time during which quote may be used to send tx
+	//
+	// Default: 1m
+	TTL time.Duration
+
+	// RateTolerance is the max allowed deviation of the current rate from the quoted one relative to the quoted rate,
+	// quote can't be used if it's exceeded
+	//
+	// Default: 0.01
+	RateTolerance string
+}
+
+// FiatValuesScheme holds configuration of txs fiat values capturing
+type FiatValuesScheme struct {
+	// Currencies which rates are captured in addition to USD
+	Currencies []string
+
+	// CaptureInterval between worker captures of rates of recently created and confirmed txs
+	//
+	// Default: 1m
+	CaptureInterval time.Duration
+
+	// CaptureWindow is the period since tx creation or confirmation during which it's rates are captured at the
+	// current rate, older txs are handled by backfill using historical rates
+	//
+	// Default: 1h
+	CaptureWindow time.Duration
+}
+
+// RatesHistoryScheme holds configuration of locally collected rates history
+type RatesHistoryScheme struct {
+	// Currencies which rates are collected
+	//
+	// Default: [USD]
+	Currencies []string
+
+	// CollectInterval between worker collections of current rates
+	//
+	// Default: 5m
+	CollectInterval time.Duration
+
+	// MaxGap is the max period since rate collection during which it's considered actual, rates of moments which
+	// haven't such collected rate are queried from remote service
+	//
+	// Default: 15m
+	MaxGap time.Duration
+}
+
+// ReservesScheme holds proof-of-reserves configuration
+type ReservesScheme struct {
+	// Secret used to derive users nonces, users keys of published reports can be matched with phones by anyone who
+	// knows it, so it must be kept private and shouldn't be changed
+	Secret string
+}
+
 // Scheme holds configuration values for processing module
 type Scheme struct {
 	// TimeToWaitRecipient time before cancelling tx, which awaits wallet creation of recipient
 	//
 	// Default: 72h
 	TimeToWaitRecipient time.Duration
+
+	// ScheduledTxsInterval between worker checks of scheduled txs which execution time has come
+	//
+	// Default: 1m
+	ScheduledTxsInterval time.Duration
+
+	// RecurringInterval between worker checks of recurring payment plans which next occurrence time has come
+	//
+	// Default: 1m
+	RecurringInterval time.Duration
+
+	// RequestsExpirationInterval between worker checks of pending payment requests which expiration time has come
+	//
+	// Default: 10m
+	RequestsExpirationInterval time.Duration
+
+	// Reconciliation configuration
+	Reconciliation ReconciliationScheme
+
+	// WithdrawalBatching configuration, withdrawals are sent immediately if no coins are specified
+	WithdrawalBatching WithdrawalBatchingScheme
+
+	// Invoices configuration
+	Invoices InvoicesScheme
+
+	// Quotes configuration
+	Quotes QuotesScheme
+
+	// FiatValues configuration
+	FiatValues FiatValuesScheme
+
+	// RatesHistory configuration
+	RatesHistory RatesHistoryScheme
+
+	// Reserves configuration
+	Reserves ReservesScheme
 }
@@ -49,6 +49,9 @@ func Init(v *viper.Viper) {
 	v.SetDefault("Server.Port", 9998)
 	v.SetDefault("Server.Storage.Type", "mem://")
 	v.SetDefault("Server.Frontend.RootURL", "https://zam.io/")
+	v.SetDefault("Server.Convert.Cache.TTL", time.Second*30)
+	v.SetDefault("Server.Convert.Cache.RefreshAhead", time.Second*10)
+	v.SetDefault("Server.Convert.Cache.StaleTTL", time.Hour)
 
 	v.SetDefault("JaegerConfig.ServiceName", "wallet-api")
 	v.SetDefault("JaegerConfig.Reporter.LogSpans", true)
@@ -57,8 +60,26 @@ func Init(v *viper.Viper) {
 
 	v.SetDefault("Wallets.BTC.NeedConfirmationsCount", 6)
 	v.SetDefault("Wallets.ETH.NeedConfirmationsCount", 12)
+	v.SetDefault("Wallets.BalanceCache.MemoryTTL", time.Second*15)
+	v.SetDefault("Wallets.BalanceCache.SnapshotTTL", time.Minute*10)
 
 	v.SetDefault("Processing.TimeToWaitRecipient", time.Hour*72)
+	v.SetDefault("Processing.ScheduledTxsInterval", time.Minute)
+	v.SetDefault("Processing.RecurringInterval", time.Minute)
+	v.SetDefault("Processing.RequestsExpirationInterval", time.Minute*10)
+	v.SetDefault("Processing.Reconciliation.Interval", time.Hour*6)
+	v.SetDefault("Processing.Reconciliation.Tolerance", "0")
+	v.SetDefault("Processing.WithdrawalBatching.Interval", time.Minute*10)
+	v.SetDefault("Processing.Invoices.RefreshInterval", time.Minute)
+	v.SetDefault("Processing.Invoices.WebhookTimeout", time.Second*10)
+	v.SetDefault("Processing.Invoices.WebhooksInterval", time.Second*30)
+	v.SetDefault("Processing.Quotes.TTL", time.Minute)
+	v.SetDefault("Processing.Quotes.RateTolerance", "0.01")
+	v.SetDefault("Processing.FiatValues.CaptureInterval", time.Minute)
+	v.SetDefault("Processing.FiatValues.CaptureWindow", time.Hour)
+	v.SetDefault("Processing.RatesHistory.Currencies", []string{"USD"})
+	v.SetDefault("Processing.RatesHistory.CollectInterval", time.Minute*5)
+	v.SetDefault("Processing.RatesHistory.MaxGap", time.Minute*15)
 
 	v.SetDefault("Logging.LogLevel", "info")
 }
@@ -11,9 +11,31 @@ type FrontendScheme struct {
 	RootURL string
 }
 
+// ConvertCacheScheme holds configuration of rates cache
+type ConvertCacheScheme struct {
+	// TTL during which cached rate is served without querying converter, cache is disabled if zero.
+	//
+	// Default: 30s
+	TTL time.Duration
+
+	// PairsTTL overrides TTL for specific pairs, keys are "coin/fiat" pairs, e.g. "zam/usd".
+	PairsTTL map[string]time.Duration
+
+	// RefreshAhead is the period before rate expiration during which it's refreshed in background on request.
+	//
+	// Default: 10s
+	RefreshAhead time.Duration
+
+	// StaleTTL is the max age of cached rate which is served with stale flag if converters fail.
+	//
+	// Default: 1h
+	StaleTTL time.Duration
+}
+
 // ConvertScheme holds configuration settings used to convert
 type ConvertScheme struct {
-	// Type main converter type, either is 'icex' or 'cryptocompare' value.
+	// Type main converter type, any registered converter type: 'icex', 'cryptocompare', 'static', 'coingecko' or
+	// 'median' which aggregates rates of converters of other types.
 	Type string
 
 	// FallbackType same as Type, but specifies type of another converter service, which will be used if answer can't
@@ -22,6 +44,14 @@ type ConvertScheme struct {
 
 	// FallbackTimeout specifies time for which answer from main host will be awaited before fallback.
 	FallbackTimeout time.Duration
+
+	// Cache configuration
+	Cache ConvertCacheScheme
+
+	// Params per-type converters params, e.g. {static: {path: rates.yaml, reload_interval: 10s}}. Params are shared by
+	// main, fallback and median source converters of the same type. Median converter accepts sources (default: [icex,
+	// cryptocompare]), quorum (default: 2) and max_deviation (default: 0.05) params.
+	Params map[string]map[string]interface{}
 }
 
 // Scheme web-server params
@@ -1,5 +1,7 @@
 package wallets
 
+import "time"
+
 // NodeConnection describes node connection params
 type NodeConnection struct {
 	// Host may contains port in format "host:port", in such case Testnet arg will be ignored
@@ -32,6 +34,19 @@ type ETHNodeConfiguration struct {
 	MasterPass string
 }
 
+// BalanceCacheConfiguration defines wallets balances cache params
+type BalanceCacheConfiguration struct {
+	// MemoryTTL time during which balance is served from process memory without touching db snapshot
+	//
+	// Default: 15s
+	MemoryTTL time.Duration
+
+	// SnapshotTTL maximum age of balance db snapshot after which balance will be recalculated on read
+	//
+	// Default: 10m
+	SnapshotTTL time.Duration
+}
+
 type ZAMNodeConfiguration struct {
 	AssetName                string
 	IssuerPublicKey          string
@@ -53,4 +68,7 @@ type Scheme struct {
 	ETH ETHNodeConfiguration
 
 	ZAM ZAMNodeConfiguration
+
+	// BalanceCache holds wallets balances cache configuration values
+	BalanceCache BalanceCacheConfiguration
 }
@@ -28,6 +28,10 @@ isc:
     statsenabled: false
     statspath: /internal/stats
 
+Processing:
+    Reserves:
+        Secret: '$RESERVES_SECRET'
+
 Logging:
     ErrorReporter:
         DSN: '$SENTRY_DSN'
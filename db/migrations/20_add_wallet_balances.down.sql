@@ -0,0 +1 @@
+drop table wallet_balances;
\ No newline at end of file
@@ -0,0 +1,5 @@
+create table wallet_balances (
+  wallet_id  integer references wallets(id) primary key,
+  balance    decimal not null,
+  updated_at timestamp without time zone not null default (now() at time zone 'UTC')
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+drop table reconciliation_address_discrepancies;
+drop table reconciliation_reports;
\ No newline at end of file
@@ -0,0 +1,26 @@
+create table reconciliation_reports (
+  id bigserial primary key,
+  coin_id integer references coins(id) not null,
+  node_balance decimal not null,
+  wallets_balance decimal not null,
+  pending_amount decimal not null,
+  in_flight_amount decimal not null,
+  in_flight_fee decimal not null,
+  discrepancy decimal not null,
+  exceeded boolean not null default false,
+  created_at timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index reconciliation_reports_coin_id_created_at_idx on reconciliation_reports (coin_id, created_at);
+
+create table reconciliation_address_discrepancies (
+  id bigserial primary key,
+  report_id bigint references reconciliation_reports(id) on delete cascade not null,
+  wallet_id integer references wallets(id) not null,
+  address varchar(256) not null,
+  on_chain_received decimal not null,
+  recorded_received decimal not null,
+  difference decimal not null
+);
+
+create index reconciliation_address_discrepancies_report_id_idx on reconciliation_address_discrepancies (report_id);
\ No newline at end of file
@@ -0,0 +1,4 @@
+drop table reserves_leafs;
+drop table reserves_addresses;
+drop table reserves_coin_reports;
+drop table reserves_reports;
\ No newline at end of file
@@ -0,0 +1,36 @@
+create table reserves_reports (
+  id bigserial primary key,
+  salt varchar(64) not null,
+  created_at timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create table reserves_coin_reports (
+  id bigserial primary key,
+  report_id bigint references reserves_reports(id) on delete cascade not null,
+  coin_id integer references coins(id) not null,
+  root_hash varchar(64) not null,
+  liabilities decimal not null,
+  node_balance decimal not null,
+  addresses_balance decimal not null
+);
+
+create index reserves_coin_reports_report_id_idx on reserves_coin_reports (report_id);
+
+create table reserves_addresses (
+  id bigserial primary key,
+  coin_report_id bigint references reserves_coin_reports(id) on delete cascade not null,
+  address varchar(256) not null,
+  balance decimal not null
+);
+
+create index reserves_addresses_coin_report_id_idx on reserves_addresses (coin_report_id);
+
+create table reserves_leafs (
+  id bigserial primary key,
+  coin_report_id bigint references reserves_coin_reports(id) on delete cascade not null,
+  user_key varchar(64) not null,
+  balance decimal not null,
+  proof jsonb not null
+);
+
+create index reserves_leafs_coin_report_id_user_key_idx on reserves_leafs (coin_report_id, user_key);
\ No newline at end of file
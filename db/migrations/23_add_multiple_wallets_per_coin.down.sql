@@ -0,0 +1,4 @@
+alter table wallets drop constraint wallets_unique_user_coin_name_cst;
+drop index wallets_user_coin_default_idx;
+alter table wallets drop column is_default;
+alter table wallets add constraint wallets_unique_user_coin_pair_cst unique (user_phone, coin_id);
\ No newline at end of file
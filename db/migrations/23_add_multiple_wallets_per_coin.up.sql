@@ -0,0 +1,8 @@
+alter table wallets drop constraint wallets_unique_user_coin_pair_cst;
+alter table wallets add column is_default boolean not null default false;
+
+-- before this migration user could have only one wallet per coin
+update wallets set is_default = true;
+
+create unique index wallets_user_coin_default_idx on wallets (user_phone, coin_id) where is_default;
+alter table wallets add constraint wallets_unique_user_coin_name_cst unique (user_phone, coin_id, name);
\ No newline at end of file
@@ -0,0 +1,2 @@
+alter table wallets drop column archived;
+alter table wallets drop column display_order;
\ No newline at end of file
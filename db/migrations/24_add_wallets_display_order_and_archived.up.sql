@@ -0,0 +1,2 @@
+alter table wallets add column display_order integer not null default 0;
+alter table wallets add column archived boolean not null default false;
\ No newline at end of file
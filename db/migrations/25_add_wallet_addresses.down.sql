@@ -0,0 +1 @@
+drop table wallet_addresses;
\ No newline at end of file
@@ -0,0 +1,14 @@
+create table wallet_addresses (
+  id         serial primary key,
+  wallet_id  integer references wallets(id) not null,
+  address    varchar(64) not null,
+  secret     varchar(512),
+  created_at timestamp without time zone not null default (now() at time zone 'UTC'),
+  constraint wallet_addresses_unique_wallet_address_cst unique (wallet_id, address)
+);
+
+create index wallet_addresses_address_idx on wallet_addresses (address);
+
+-- current wallets addresses are the first ones in history
+insert into wallet_addresses (wallet_id, address, secret, created_at)
+select id, address, secret, created_at from wallets where address <> '';
\ No newline at end of file
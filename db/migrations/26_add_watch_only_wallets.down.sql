@@ -0,0 +1 @@
+alter table wallets drop column watch_only;
\ No newline at end of file
@@ -0,0 +1 @@
+alter table wallets add column watch_only boolean not null default false;
\ No newline at end of file
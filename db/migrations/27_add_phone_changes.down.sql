@@ -0,0 +1 @@
+drop table phone_changes;
\ No newline at end of file
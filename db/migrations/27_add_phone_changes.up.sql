@@ -0,0 +1,12 @@
+create table phone_changes (
+  id          serial primary key,
+  old_phone   varchar(255) not null,
+  new_phone   varchar(255) not null,
+  wallets_num integer not null,
+  txs_num     integer not null,
+  source      varchar(32) not null,
+  created_at  timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index phone_changes_old_phone_idx on phone_changes (old_phone);
+create index phone_changes_new_phone_idx on phone_changes (new_phone);
\ No newline at end of file
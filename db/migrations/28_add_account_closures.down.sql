@@ -0,0 +1,3 @@
+drop table account_closure_sweeps;
+drop table account_closures;
+alter table wallets drop column closed;
\ No newline at end of file
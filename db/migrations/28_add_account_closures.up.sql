@@ -0,0 +1,23 @@
+alter table wallets add column closed boolean not null default false;
+
+create table account_closures (
+  id         serial primary key,
+  user_phone varchar(255) not null,
+  status     varchar(32) not null default 'in_progress',
+  created_at timestamp without time zone not null default (now() at time zone 'UTC'),
+  updated_at timestamp without time zone not null default (now() at time zone 'UTC'),
+
+  constraint account_closures_unique_user_phone_cst unique (user_phone)
+);
+
+create table account_closure_sweeps (
+  id         serial primary key,
+  closure_id integer references account_closures(id) not null,
+  wallet_id  integer references wallets(id) not null,
+  address    varchar(128) not null,
+  amount     decimal not null,
+  tx_id      bigint references txs(id) null,
+  created_at timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index account_closure_sweeps_closure_id_idx on account_closure_sweeps (closure_id);
\ No newline at end of file
@@ -0,0 +1,5 @@
+drop index txs_execute_at_idx;
+alter table txs drop column hold_funds;
+alter table txs drop column execute_at;
+
+delete from tx_statuses where name = 'scheduled';
\ No newline at end of file
@@ -0,0 +1,6 @@
+insert into tx_statuses (name) values ('scheduled');
+
+alter table txs add column execute_at timestamp without time zone null;
+alter table txs add column hold_funds boolean not null default false;
+
+create index txs_execute_at_idx on txs (execute_at asc) where execute_at is not null;
\ No newline at end of file
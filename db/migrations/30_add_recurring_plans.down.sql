@@ -0,0 +1,2 @@
+drop table recurring_occurrences;
+drop table recurring_plans;
\ No newline at end of file
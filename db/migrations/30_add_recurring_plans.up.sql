@@ -0,0 +1,31 @@
+create table recurring_plans (
+  id             serial primary key,
+  user_phone     varchar(255) not null,
+  wallet_id      integer references wallets(id) not null,
+  recipient      varchar(128) not null,
+  recipient_type varchar(16) not null,
+  amount         decimal not null,
+  period         varchar(16) not null,
+  starts_at      timestamp without time zone not null,
+  next_run_at    timestamp without time zone not null,
+  ends_at        timestamp without time zone null,
+  max_count      integer null,
+  runs_count     integer not null default 0,
+  status         varchar(16) not null default 'active',
+  created_at     timestamp without time zone not null default (now() at time zone 'UTC'),
+  updated_at     timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index recurring_plans_user_phone_idx on recurring_plans (user_phone);
+create index recurring_plans_next_run_at_idx on recurring_plans (next_run_at asc) where status = 'active';
+
+create table recurring_occurrences (
+  id           serial primary key,
+  plan_id      integer references recurring_plans(id) not null,
+  scheduled_at timestamp without time zone not null,
+  tx_id        bigint references txs(id) null,
+  error        varchar(512) null,
+  created_at   timestamp without time zone not null default (now() at time zone 'UTC'),
+
+  constraint recurring_occurrences_unique_plan_scheduled_at_cst unique (plan_id, scheduled_at)
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+drop index txs_batch_id_idx;
+alter table txs drop column batch_id;
+drop table tx_batches;
\ No newline at end of file
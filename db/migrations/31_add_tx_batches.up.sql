@@ -0,0 +1,10 @@
+create table tx_batches (
+  id           serial primary key,
+  wallet_id    integer references wallets(id) not null,
+  total_amount decimal not null,
+  created_at   timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+alter table txs add column batch_id integer references tx_batches(id) null;
+
+create index txs_batch_id_idx on txs (batch_id) where batch_id is not null;
\ No newline at end of file
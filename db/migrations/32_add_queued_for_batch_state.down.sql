@@ -0,0 +1,4 @@
+alter table txs_external drop constraint txs_external_tx_hash_unique_idx;
+alter table txs_external add constraint txs_external_tx_hash_unique_idx unique (hash);
+
+delete from tx_statuses where name = 'queued_for_batch';
\ No newline at end of file
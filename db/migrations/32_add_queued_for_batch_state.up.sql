@@ -0,0 +1,5 @@
+insert into tx_statuses (name) values ('queued_for_batch');
+
+-- multi-output tx is shared by several txs, so hash is unique only per tx
+alter table txs_external drop constraint txs_external_tx_hash_unique_idx;
+alter table txs_external add constraint txs_external_tx_hash_unique_idx unique (hash, tx_id);
\ No newline at end of file
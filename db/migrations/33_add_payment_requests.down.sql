@@ -0,0 +1 @@
+drop table payment_requests;
\ No newline at end of file
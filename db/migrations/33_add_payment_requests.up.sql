@@ -0,0 +1,21 @@
+create table payment_requests (
+  id              serial primary key,
+  requester_phone varchar(255) not null,
+  payer_phone     varchar(255) not null,
+  coin_id         integer references coins(id) not null,
+  amount          decimal null,
+  fiat_amount     decimal null,
+  fiat_currency   varchar(16) null,
+  comment         varchar(255) null,
+  status          varchar(16) not null default 'pending',
+  expires_at      timestamp without time zone not null,
+  tx_id           bigint references txs(id) null,
+  created_at      timestamp without time zone not null default (now() at time zone 'UTC'),
+  updated_at      timestamp without time zone not null default (now() at time zone 'UTC'),
+
+  constraint payment_requests_amount_cst check (amount is not null or fiat_amount is not null)
+);
+
+create index payment_requests_requester_phone_idx on payment_requests (requester_phone);
+create index payment_requests_payer_phone_idx on payment_requests (payer_phone);
+create index payment_requests_expires_at_idx on payment_requests (expires_at asc) where status = 'pending';
\ No newline at end of file
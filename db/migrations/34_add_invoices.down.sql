@@ -0,0 +1,2 @@
+drop table invoice_payments;
+drop table invoices;
\ No newline at end of file
@@ -0,0 +1,29 @@
+create table invoices (
+  id             serial primary key,
+  merchant_phone varchar(255) not null,
+  wallet_id      bigint references wallets(id) not null,
+  fiat_amount    decimal not null,
+  fiat_currency  varchar(16) not null,
+  amount         decimal not null,
+  rate           decimal not null,
+  address        varchar(255) null,
+  paid_amount    decimal not null default 0,
+  description    varchar(255) null,
+  webhook_url    varchar(1024) null,
+  status         varchar(16) not null default 'pending',
+  expires_at     timestamp without time zone not null,
+  created_at     timestamp without time zone not null default (now() at time zone 'UTC'),
+  updated_at     timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index invoices_merchant_phone_idx on invoices (merchant_phone);
+create index invoices_pending_idx on invoices (id) where status = 'pending';
+
+create table invoice_payments (
+  invoice_id integer references invoices(id) on delete cascade not null,
+  tx_id      bigint references txs(id) not null,
+
+  constraint invoice_payments_unique_tx_cst unique (tx_id)
+);
+
+create index invoice_payments_invoice_id_idx on invoice_payments (invoice_id);
\ No newline at end of file
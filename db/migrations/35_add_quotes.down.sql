@@ -0,0 +1,4 @@
+alter table txs drop column fiat_currency;
+alter table txs drop column fiat_amount;
+
+drop table quotes;
\ No newline at end of file
@@ -0,0 +1,18 @@
+create table quotes (
+  id            serial primary key,
+  user_phone    varchar(255) not null,
+  coin_id       integer references coins(id) not null,
+  fiat_amount   decimal not null,
+  fiat_currency varchar(16) not null,
+  rate          decimal not null,
+  amount        decimal not null,
+  expires_at    timestamp without time zone not null,
+  used_at       timestamp without time zone null,
+  tx_id         bigint references txs(id) null,
+  created_at    timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index quotes_user_phone_idx on quotes (user_phone);
+
+alter table txs add column fiat_amount decimal null;
+alter table txs add column fiat_currency varchar(16) null;
\ No newline at end of file
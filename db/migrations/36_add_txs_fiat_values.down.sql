@@ -0,0 +1 @@
+drop table txs_fiat_values;
\ No newline at end of file
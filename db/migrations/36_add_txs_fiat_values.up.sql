@@ -0,0 +1,9 @@
+create table txs_fiat_values (
+  id         serial primary key,
+  tx_id      bigint references txs(id) on delete cascade not null,
+  stage      varchar(16) not null,
+  currency   varchar(16) not null,
+  rate       decimal not null,
+  created_at timestamp without time zone not null default (now() at time zone 'UTC'),
+  unique (tx_id, stage, currency)
+);
\ No newline at end of file
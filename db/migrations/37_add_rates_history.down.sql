@@ -0,0 +1 @@
+drop table rates_history;
\ No newline at end of file
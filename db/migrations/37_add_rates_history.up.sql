@@ -0,0 +1,9 @@
+create table rates_history (
+  id           bigserial primary key,
+  coin_id      integer references coins(id) not null,
+  currency     varchar(16) not null,
+  rate         decimal not null,
+  collected_at timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index rates_history_pair_collected_at_idx on rates_history (coin_id, currency, collected_at);
\ No newline at end of file
@@ -0,0 +1 @@
+drop table user_settings;
\ No newline at end of file
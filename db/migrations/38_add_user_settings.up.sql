@@ -0,0 +1,10 @@
+create table user_settings (
+  user_phone      varchar(255) primary key,
+  fiat_currency   varchar(16) null,
+  hidden_coins    varchar(16)[] not null default '{}',
+  notify_txs      boolean not null default true,
+  notify_requests boolean not null default true,
+  notify_invoices boolean not null default true,
+  created_at      timestamp without time zone not null default (now() at time zone 'UTC'),
+  updated_at      timestamp without time zone not null default (now() at time zone 'UTC')
+);
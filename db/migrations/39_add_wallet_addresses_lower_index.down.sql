@@ -0,0 +1 @@
+drop index wallet_addresses_lower_address_idx;
@@ -0,0 +1 @@
+create index wallet_addresses_lower_address_idx on wallet_addresses (lower(address));
@@ -0,0 +1,2 @@
+drop table invoice_webhooks;
+alter table invoices drop column webhook_secret;
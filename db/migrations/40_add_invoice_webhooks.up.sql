@@ -0,0 +1,15 @@
+alter table invoices add column webhook_secret varchar(64) null;
+update invoices set webhook_secret = md5(random()::text || id::text) || md5(random()::text || id::text);
+
+create table invoice_webhooks (
+  id              serial primary key,
+  invoice_id      integer references invoices(id) on delete cascade not null,
+  payload         text not null,
+  attempts        integer not null default 0,
+  last_error      varchar(1024) null,
+  next_attempt_at timestamp without time zone not null default (now() at time zone 'UTC'),
+  delivered_at    timestamp without time zone null,
+  created_at      timestamp without time zone not null default (now() at time zone 'UTC')
+);
+
+create index invoice_webhooks_undelivered_idx on invoice_webhooks (next_attempt_at) where delivered_at is null;
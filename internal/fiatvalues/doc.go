@@ -0,0 +1,2 @@
+// Package fiatvalues captures rates of txs coins to fiat currencies, so txs fiat equivalents don't drift
+package fiatvalues
@@ -0,0 +1,247 @@
+package fiatvalues
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	ot "github.com/opentracing/opentracing-go"
+	"strings"
+	"time"
+)
+
+// BaseCurrency is the fiat currency which rates are always captured
+const BaseCurrency = "USD"
+
+// backfillBatchSize is the number of txs processed by single backfill db query
+const backfillBatchSize = 100
+
+// ICapturer captures rates of txs coins to fiat currencies at tx creation and confirmation
+type ICapturer interface {
+	// Capture stores current rates for txs created or confirmed during the capture window which rates aren't captured
+	// yet. Returns number of captured rates.
+	Capture(ctx context.Context) (capturedNum int, err error)
+
+	// Backfill stores historical rates for txs created before the capture window which rates aren't captured. Rates
+	// are taken at tx creation time and at tx last update time for processed txs. Returns number of captured rates.
+	Backfill(ctx context.Context) (capturedNum int, err error)
+}
+
+// Capturer is ICapturer implementation
+type Capturer struct {
+	database   *gorm.DB
+	converter  convert.ICryptoCurrency
+	history    convert.IHistoricalRates
+	currencies []string
+	window     time.Duration
+}
+
+// New creates capturer which captures rates to BaseCurrency and given fiat currencies for txs created or confirmed
+// not earlier then window ago
+func New(
+	database *gorm.DB,
+	converter convert.ICryptoCurrency,
+	history convert.IHistoricalRates,
+	currencies []string,
+	window time.Duration,
+) *Capturer {
+	return &Capturer{
+		database:   database,
+		converter:  converter,
+		history:    history,
+		currencies: Currencies(currencies),
+		window:     window,
+	}
+}
+
+// rateFunc returns rate of coin to fiat currency which should be captured for tx at given stage
+type rateFunc func(ctx context.Context, tx *processing.Tx, stage, coinName, currency string) (*convert.Rate, error)
+
+// Capture implements ICapturer
+func (c *Capturer) Capture(ctx context.Context) (capturedNum int, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "capture_txs_fiat_values")
+	defer span.Finish()
+
+	since := time.Now().UTC().Add(-c.window)
+	var txs []processing.Tx
+	err = db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return addPreloads(dbTx).Where("created_at >= ? or updated_at >= ?", since, since).Order("id").Find(&txs).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+	span.LogKV("txs_num", len(txs))
+
+	// rates of the same pair are queried once per capture
+	cache := make(map[string]*convert.Rate)
+	spotRate := func(ctx context.Context, _ *processing.Tx, _, coinName, currency string) (*convert.Rate, error) {
+		key := coinName + "/" + currency
+		if rate, ok := cache[key]; ok {
+			return rate, nil
+		}
+		rate, err := c.converter.GetRate(ctx, coinName, currency)
+		if err != nil {
+			return nil, err
+		}
+		cache[key] = rate
+		return rate, nil
+	}
+
+	capturedNum, err = c.captureTxs(ctx, span, txs, spotRate)
+	span.LogKV("captured_num", capturedNum)
+	return
+}
+
+// Backfill implements ICapturer
+func (c *Capturer) Backfill(ctx context.Context) (capturedNum int, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "backfill_txs_fiat_values")
+	defer span.Finish()
+
+	historicalRate := func(ctx context.Context, tx *processing.Tx, stage, coinName, currency string) (
+		*convert.Rate, error,
+	) {
+		at := tx.CreatedAt
+		if stage == processing.FiatStageConfirmed {
+			at = tx.UpdatedAt
+		}
+		return c.history.GetRateAt(ctx, coinName, currency, at)
+	}
+
+	since := time.Now().UTC().Add(-c.window)
+	var lastID int64
+	for {
+		var txs []processing.Tx
+		err = db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			return addPreloads(dbTx).Where(
+				"id > ? and created_at < ?", lastID, since,
+			).Order("id").Limit(backfillBatchSize).Find(&txs).Error
+		})
+		if err != nil {
+			trace.LogError(span, err)
+			return
+		}
+		if len(txs) == 0 {
+			break
+		}
+		lastID = txs[len(txs)-1].ID
+
+		batchCaptured, batchErr := c.captureTxs(ctx, span, txs, historicalRate)
+		capturedNum += batchCaptured
+		if batchErr != nil {
+			err = merrors.Append(err, batchErr)
+		}
+	}
+	span.LogKV("captured_num", capturedNum)
+	return
+}
+
+// captureTxs stores missing rates of given txs using given rate func. Unknown coins and currencies are skipped, so
+// they don't prevent capturing of other rates.
+func (c *Capturer) captureTxs(ctx context.Context, span ot.Span, txs []processing.Tx, rate rateFunc) (
+	capturedNum int, err error,
+) {
+	for i := range txs {
+		tx := &txs[i]
+		coinName := tx.CoinName()
+		for _, stage := range Stages(tx) {
+			for _, currency := range Missing(tx, stage, c.currencies) {
+				r, rateErr := rate(ctx, tx, stage, coinName, currency)
+				switch rateErr {
+				case nil:
+				case convert.ErrCryptoCurrencyName, convert.ErrFiatCurrencyName:
+					span.LogKV("skipped_tx_id", tx.ID, "coin", coinName, "currency", currency)
+					continue
+				default:
+					trace.LogErrorWithMsg(span, rateErr, "rate query failed")
+					return capturedNum, rateErr
+				}
+
+				var captured bool
+				err = db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+					var err error
+					captured, err = store(dbTx, tx.ID, stage, currency, (*decimal.Big)(r))
+					return err
+				})
+				if err != nil {
+					trace.LogError(span, err)
+					return
+				}
+				if captured {
+					capturedNum++
+				}
+			}
+		}
+	}
+	return
+}
+
+// store inserts captured rate unless it's already captured by concurrent run
+func store(dbTx *gorm.DB, txID int64, stage, currency string, rate *decimal.Big) (bool, error) {
+	res := dbTx.Exec(
+		"insert into txs_fiat_values (tx_id, stage, currency, rate) values (?, ?, ?, ?) on conflict do nothing",
+		txID, stage, currency, &processing.Decimal{V: rate},
+	)
+	return res.RowsAffected > 0, res.Error
+}
+
+// addPreloads preloads relations required to determine tx coin, state and captured rates
+func addPreloads(q *gorm.DB) *gorm.DB {
+	return q.Preload(
+		"FromWallet",
+	).Preload(
+		"FromWallet.Coin",
+	).Preload(
+		"ToWallet",
+	).Preload(
+		"ToWallet.Coin",
+	).Preload(
+		"Status",
+	).Preload("FiatValues")
+}
+
+// Currencies returns uppercased set of given currencies with BaseCurrency at the first place
+func Currencies(currencies []string) []string {
+	set := map[string]struct{}{BaseCurrency: {}}
+	res := []string{BaseCurrency}
+	for _, currency := range currencies {
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if _, ok := set[currency]; ok || currency == "" {
+			continue
+		}
+		set[currency] = struct{}{}
+		res = append(res, currency)
+	}
+	return res
+}
+
+// Stages returns stages at which rates should be captured for the tx, confirmation rate is captured only for
+// processed txs
+func Stages(tx *processing.Tx) []string {
+	if tx.StateName() == processing.TxStateProcessed {
+		return []string{processing.FiatStageCreated, processing.FiatStageConfirmed}
+	}
+	return []string{processing.FiatStageCreated}
+}
+
+// Missing returns currencies which rates aren't captured for the tx at given stage
+func Missing(tx *processing.Tx, stage string, currencies []string) []string {
+	var missing []string
+	for _, currency := range currencies {
+		found := false
+		for _, value := range tx.FiatValues {
+			if value.Stage == stage && strings.EqualFold(value.Currency, currency) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, currency)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,63 @@
+package fiatvalues_test
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/fiatvalues"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFiatValues(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "FiatValues Suite")
+}
+
+var _ = Describe("testing captured currencies", func() {
+	It("should always capture base currency first", func() {
+		Expect(fiatvalues.Currencies(nil)).To(Equal([]string{"USD"}))
+		Expect(fiatvalues.Currencies([]string{"eur", " rub"})).To(Equal([]string{"USD", "EUR", "RUB"}))
+	})
+
+	It("should drop duplicates and empty names", func() {
+		Expect(fiatvalues.Currencies([]string{"usd", "EUR", "eur", ""})).To(Equal([]string{"USD", "EUR"}))
+	})
+})
+
+var _ = Describe("testing missing fiat values", func() {
+	rate := func(s string) *processing.Decimal {
+		v, _ := new(decimal.Big).SetString(s)
+		return &processing.Decimal{V: v}
+	}
+	currencies := []string{"USD", "EUR"}
+
+	It("should capture confirmation rate only for processed txs", func() {
+		tx := &processing.Tx{Status: &processing.TxStatus{Name: processing.TxStateAwaitConfirmations}}
+		Expect(fiatvalues.Stages(tx)).To(Equal([]string{processing.FiatStageCreated}))
+
+		tx.Status.Name = processing.TxStateProcessed
+		Expect(fiatvalues.Stages(tx)).To(Equal([]string{processing.FiatStageCreated, processing.FiatStageConfirmed}))
+	})
+
+	It("should return currencies not captured at given stage", func() {
+		tx := &processing.Tx{FiatValues: []processing.TxFiatValue{
+			{Stage: processing.FiatStageCreated, Currency: "usd", Rate: rate("6500")},
+			{Stage: processing.FiatStageConfirmed, Currency: "EUR", Rate: rate("5600")},
+		}}
+		Expect(fiatvalues.Missing(tx, processing.FiatStageCreated, currencies)).To(Equal([]string{"EUR"}))
+		Expect(fiatvalues.Missing(tx, processing.FiatStageConfirmed, currencies)).To(Equal([]string{"USD"}))
+	})
+
+	It("should prefer rate captured at confirmation", func() {
+		tx := &processing.Tx{FiatValues: []processing.TxFiatValue{
+			{Stage: processing.FiatStageConfirmed, Currency: "USD", Rate: rate("6600")},
+			{Stage: processing.FiatStageCreated, Currency: "USD", Rate: rate("6500")},
+			{Stage: processing.FiatStageCreated, Currency: "EUR", Rate: rate("5600")},
+		}}
+		Expect(tx.FiatRate("usd").String()).To(Equal("6600"))
+		Expect(tx.FiatRate("EUR").String()).To(Equal("5600"))
+		Expect(tx.FiatRate("RUB")).To(BeNil())
+	})
+})
@@ -0,0 +1,189 @@
+package balance_test
+
+import (
+	"testing"
+
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers/balance"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers/mocks"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+func TestBalance(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Balance Suite")
+}
+
+const (
+	testCoinName     = "TEST"
+	testCoinFullName = "Testing"
+	walletPhone      = "+79109998877"
+	walletAddress    = "Wallet-Addr"
+	otherAddress     = "other-addr"
+)
+
+type testWallets struct {
+	wallet, other *queries.Wallet
+}
+
+func expectBalance(actual *decimal.Big, expected string) {
+	Expect(actual.String()).To(Equal(expected))
+}
+
+func byWallet(w *queries.Wallet) interface{} {
+	return mock.MatchedBy(func(arg *queries.Wallet) bool { return arg.ID == w.ID })
+}
+
+func countSnapshots(d *gorm.DB, walletID int64) (count int) {
+	err := d.Table("wallet_balances").Where("wallet_id = ?", walletID).Count(&count).Error
+	Expect(err).NotTo(HaveOccurred())
+	return
+}
+
+var _ = Describe("testing balance cache", func() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	BeforeEachCProvide(func(d *db.Db) (*gorm.DB, error) {
+		return gorm.Open("postgres", d.DB.DB)
+	})
+
+	BeforeEachCProvide(func() *mocks.IBalance {
+		return &mocks.IBalance{}
+	})
+
+	BeforeEachCProvide(func(d *db.Db) testWallets {
+		_, err := d.Exec(
+			"insert into coins (name, short_name, enabled) values ($1, $2, true)", testCoinFullName, testCoinName,
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		wts := testWallets{}
+		for _, w := range []struct {
+			dst     **queries.Wallet
+			address string
+		}{{&wts.wallet, walletAddress}, {&wts.other, otherAddress}} {
+			created, err := queries.CreateWallet(d, queries.Wallet{
+				UserPhone: walletPhone,
+				Name:      w.address,
+				Address:   w.address,
+				Coin:      queries.Coin{ShortName: testCoinName},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = queries.AddWalletAddress(d, created.ID, w.address, "")
+			Expect(err).NotTo(HaveOccurred())
+			*w.dst = &created
+		}
+		return wts
+	})
+
+	ItD("should keep balance in memory", func(d *gorm.DB, b *mocks.IBalance, wts testWallets) {
+		b.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(15, 1), nil).Once()
+		cache := balance.NewCache(d, b, time.Minute, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			value, asOf, err := cache.WalletBalanceCtx(context.Background(), wts.wallet, false)
+			Expect(err).NotTo(HaveOccurred())
+			expectBalance(value, "1.5")
+			Expect(asOf).NotTo(BeZero())
+		}
+		b.AssertNumberOfCalls(GinkgoT(), "TotalWalletBalanceCtx", 1)
+	})
+
+	ItD("should recalculate balance if fresh one requested", func(d *gorm.DB, b *mocks.IBalance, wts testWallets) {
+		b.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(1, 0), nil)
+		cache := balance.NewCache(d, b, time.Minute, time.Minute)
+
+		_, _, err := cache.WalletBalanceCtx(context.Background(), wts.wallet, false)
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = cache.WalletBalanceCtx(context.Background(), wts.wallet, true)
+		Expect(err).NotTo(HaveOccurred())
+		b.AssertNumberOfCalls(GinkgoT(), "TotalWalletBalanceCtx", 2)
+	})
+
+	ItD("should fall back to db snapshot shared between processes", func(d *gorm.DB, wts testWallets) {
+		first := &mocks.IBalance{}
+		first.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(25, 1), nil)
+		_, firstAsOf, err := balance.NewCache(d, first, time.Minute, time.Minute).WalletBalanceCtx(
+			context.Background(), wts.wallet, false,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(countSnapshots(d, wts.wallet.ID)).To(Equal(1))
+
+		By("reading balance by the other cache instance which memory is empty")
+		second := &mocks.IBalance{}
+		value, asOf, err := balance.NewCache(d, second, time.Minute, time.Minute).WalletBalanceCtx(
+			context.Background(), wts.wallet, false,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		expectBalance(value, "2.5")
+		Expect(asOf.Unix()).To(Equal(firstAsOf.Unix()))
+		second.AssertNotCalled(GinkgoT(), "TotalWalletBalanceCtx", mock.Anything, mock.Anything)
+	})
+
+	ItD("should ignore outdated db snapshot", func(d *gorm.DB, wts testWallets) {
+		first := &mocks.IBalance{}
+		first.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(1, 0), nil)
+		_, _, err := balance.NewCache(d, first, time.Minute, time.Minute).WalletBalanceCtx(
+			context.Background(), wts.wallet, false,
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		second := &mocks.IBalance{}
+		second.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(2, 0), nil).Once()
+		time.Sleep(20 * time.Millisecond)
+		value, _, err := balance.NewCache(d, second, time.Minute, 10*time.Millisecond).WalletBalanceCtx(
+			context.Background(), wts.wallet, false,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		expectBalance(value, "2")
+		second.AssertExpectations(GinkgoT())
+	})
+
+	ItD("should drop memory and snapshot on invalidation", func(d *gorm.DB, b *mocks.IBalance, wts testWallets) {
+		b.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(1, 0), nil).Once()
+		b.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(3, 0), nil).Once()
+		cache := balance.NewCache(d, b, time.Minute, time.Minute)
+
+		_, _, err := cache.WalletBalanceCtx(context.Background(), wts.wallet, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cache.Invalidate(context.Background(), wts.wallet.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(countSnapshots(d, wts.wallet.ID)).To(Equal(0))
+
+		value, _, err := cache.WalletBalanceCtx(context.Background(), wts.wallet, false)
+		Expect(err).NotTo(HaveOccurred())
+		expectBalance(value, "3")
+		b.AssertExpectations(GinkgoT())
+	})
+
+	ItD("should refresh only wallets which own changed addresses", func(d *gorm.DB, b *mocks.IBalance, wts testWallets) {
+		b.On("TotalWalletBalanceCtx", mock.Anything, byWallet(wts.wallet)).Return(decimal.New(7, 0), nil).Once()
+		cache := balance.NewCache(d, b, time.Minute, time.Minute)
+
+		// addresses are matched case insensitively
+		err := cache.RefreshAddresses(context.Background(), testCoinName, []string{"wallet-addr", "unknown"})
+		Expect(err).NotTo(HaveOccurred())
+		b.AssertExpectations(GinkgoT())
+		Expect(countSnapshots(d, wts.wallet.ID)).To(Equal(1))
+		Expect(countSnapshots(d, wts.other.ID)).To(Equal(0))
+
+		By("reading refreshed balance from memory")
+		value, _, err := cache.WalletBalanceCtx(context.Background(), wts.wallet, false)
+		Expect(err).NotTo(HaveOccurred())
+		expectBalance(value, "7")
+		b.AssertNumberOfCalls(GinkgoT(), "TotalWalletBalanceCtx", 1)
+	})
+})
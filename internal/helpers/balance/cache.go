@@ -0,0 +1,259 @@
+package balance
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/ericlagergren/decimal/sql/postgres"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	ot "github.com/opentracing/opentracing-go"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshWorkersCount limits number of concurrent balance queries while refreshing many wallets
+const refreshWorkersCount = 8
+
+// cacheEntry is in-memory cached balance
+type cacheEntry struct {
+	balance  *decimal.Big
+	asOf     time.Time
+	cachedAt time.Time
+}
+
+// Cache is IBalanceCache implementation which holds balances in memory for short period and backs them with
+// wallet_balances db snapshots which are shared between processes.
+type Cache struct {
+	database    *gorm.DB
+	balance     helpers.IBalance
+	memoryTTL   time.Duration
+	snapshotTTL time.Duration
+
+	mu      sync.RWMutex
+	entries map[int64]cacheEntry
+}
+
+// NewCache creates balance cache which uses given balance helper to calculate missing balances
+func NewCache(database *gorm.DB, balance helpers.IBalance, memoryTTL, snapshotTTL time.Duration) *Cache {
+	return &Cache{
+		database:    database,
+		balance:     balance,
+		memoryTTL:   memoryTTL,
+		snapshotTTL: snapshotTTL,
+		entries:     make(map[int64]cacheEntry),
+	}
+}
+
+// WalletBalanceCtx implements IBalanceCache
+func (c *Cache) WalletBalanceCtx(ctx context.Context, wallet *queries.Wallet, fresh bool) (
+	balance *decimal.Big, asOf time.Time, err error,
+) {
+	span, ctx := ot.StartSpanFromContext(ctx, "cached_wallet_balance")
+	defer span.Finish()
+
+	span.LogKV("wallet_id", wallet.ID, "coin", wallet.Coin.ShortName, "fresh", fresh)
+
+	if !fresh {
+		// look into process memory first
+		if entry, ok := c.fromMemory(wallet.ID); ok {
+			trace.LogMsg(span, "memory hit")
+			return entry.balance, entry.asOf, nil
+		}
+
+		// then look for db snapshot which isn't outdated yet
+		var (
+			entry cacheEntry
+			found bool
+		)
+		entry, found, err = c.fromSnapshot(ctx, wallet.ID)
+		if err != nil {
+			return
+		}
+		if found {
+			trace.LogMsg(span, "snapshot hit")
+			c.toMemory(wallet.ID, entry)
+			return entry.balance, entry.asOf, nil
+		}
+	}
+
+	return c.refresh(ctx, wallet)
+}
+
+// Invalidate implements IBalanceCache
+func (c *Cache) Invalidate(ctx context.Context, walletIDs ...int64) error {
+	if len(walletIDs) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	for _, id := range walletIDs {
+		delete(c.entries, id)
+	}
+	c.mu.Unlock()
+
+	return db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Exec(
+			`delete from wallet_balances where wallet_id = ANY (?::bigint[])`, pq.Array(walletIDs),
+		).Error
+	})
+}
+
+// RefreshCoin implements IBalanceCache
+func (c *Cache) RefreshCoin(ctx context.Context, coinName string) (err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "refresh_coin_balances")
+	defer span.Finish()
+
+	coinName = strings.ToUpper(coinName)
+	span.LogKV("coin", coinName)
+
+	var wts []queries.Wallet
+	err = db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Model(&queries.Wallet{}).Joins(
+			"inner join coins on coins.id = wallets.coin_id",
+		).Where(
+			"coins.short_name = ? and coins.enabled is true", coinName,
+		).Preload("Coin").Find(&wts).Error
+	})
+	if err != nil {
+		return
+	}
+
+	span.LogKV("wallets_num", len(wts))
+	return c.refreshAll(ctx, wts)
+}
+
+// RefreshAddresses implements IBalanceCache, wallets are matched by any address of their addresses history
+func (c *Cache) RefreshAddresses(ctx context.Context, coinName string, addresses []string) (err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "refresh_addresses_balances")
+	defer span.Finish()
+
+	coinName = strings.ToUpper(coinName)
+	span.LogKV("coin", coinName, "addresses_num", len(addresses))
+	if len(addresses) == 0 {
+		return
+	}
+
+	lowered := make([]string, len(addresses))
+	for i, address := range addresses {
+		lowered[i] = strings.ToLower(address)
+	}
+
+	var wts []queries.Wallet
+	err = db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Model(&queries.Wallet{}).Joins(
+			"inner join coins on coins.id = wallets.coin_id",
+		).Where(
+			"coins.short_name = ? and coins.enabled is true and wallets.id in ("+
+				"select wallet_id from wallet_addresses where lower(address) = ANY (?::varchar[]))",
+			coinName, pq.Array(lowered),
+		).Preload("Coin").Find(&wts).Error
+	})
+	if err != nil {
+		return
+	}
+
+	span.LogKV("wallets_num", len(wts))
+	return c.refreshAll(ctx, wts)
+}
+
+// refreshAll refreshes balances of given wallets using limited number of workers
+func (c *Cache) refreshAll(ctx context.Context, wts []queries.Wallet) (err error) {
+	var (
+		wg       sync.WaitGroup
+		errsLock sync.Mutex
+		sem      = make(chan struct{}, refreshWorkersCount)
+	)
+	for i := range wts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(wallet *queries.Wallet) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			_, _, rErr := c.refresh(ctx, wallet)
+			if rErr != nil {
+				errsLock.Lock()
+				err = merrors.Append(err, rErr)
+				errsLock.Unlock()
+			}
+		}(&wts[i])
+	}
+	wg.Wait()
+	return
+}
+
+// refresh calculates wallet balance and stores it both in memory and db snapshot
+func (c *Cache) refresh(ctx context.Context, wallet *queries.Wallet) (balance *decimal.Big, asOf time.Time, err error) {
+	balance, err = c.balance.TotalWalletBalanceCtx(ctx, wallet)
+	if err != nil {
+		return
+	}
+	asOf = time.Now().UTC()
+
+	err = db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Exec(
+			`insert into wallet_balances (wallet_id, balance, updated_at) values (?, ?, ?)
+			on conflict (wallet_id) do update set balance = excluded.balance, updated_at = excluded.updated_at`,
+			wallet.ID, &postgres.Decimal{V: balance}, asOf,
+		).Error
+	})
+	if err != nil {
+		return
+	}
+
+	c.toMemory(wallet.ID, cacheEntry{balance: balance, asOf: asOf})
+	return
+}
+
+// fromSnapshot reads wallet balance snapshot if it's not older then snapshot ttl
+func (c *Cache) fromSnapshot(ctx context.Context, walletID int64) (entry cacheEntry, found bool, err error) {
+	err = db.TransactionCtx(ctx, c.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		rows, err := dbTx.Raw(
+			`select balance, updated_at from wallet_balances where wallet_id = ? and updated_at > ?`,
+			walletID, time.Now().UTC().Add(-c.snapshotTTL),
+		).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var balance postgres.Decimal
+			err = rows.Scan(&balance, &entry.asOf)
+			if err != nil {
+				return err
+			}
+			entry.balance = balance.V
+			found = true
+		}
+		return rows.Err()
+	})
+	return
+}
+
+func (c *Cache) fromMemory(walletID int64) (entry cacheEntry, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok = c.entries[walletID]
+	if ok && time.Since(entry.cachedAt) > c.memoryTTL {
+		ok = false
+	}
+	return
+}
+
+func (c *Cache) toMemory(walletID int64, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.cachedAt = time.Now()
+	c.entries[walletID] = entry
+}
@@ -29,17 +29,41 @@ func (b *Balance) AccountBalanceCtx(ctx context.Context, coinName string) (balan
 	return b.Coordinator.AccountObserver(coinName).GetBalance(ctx)
 }
 
-// TotalWalletBalance implements IBalance
+// TotalWalletBalance implements IBalance, node balances of all wallet addresses are summed
 func (b *Balance) TotalWalletBalanceCtx(ctx context.Context, wallet *queries.Wallet) (balance *decimal.Big, err error) {
 	span, ctx := ot.StartSpanFromContext(ctx, "total_wallet_balance")
 	defer span.Finish()
 
 	span.LogKV("wallet_id", wallet.ID, "coin", wallet.Coin.ShortName)
 
-	// query address balance using node service
+	if wallet.WatchOnly {
+		balance, err = b.watchOnlyBalance(ctx, wallet)
+		span.LogKV("balance", balance)
+		return
+	}
+
+	// wallet is credited with deposits on any address it ever owned
+	addresses := []string{wallet.Address}
+	if b.ProcessingApi != nil {
+		addresses, err = b.ProcessingApi.GetWalletAddresses(ctx, wallet)
+		if err != nil {
+			return
+		}
+	}
+
+	// query addresses balances using node service
 	trace.InsideSpan(ctx, "get_node_address_balance", func(ctx context.Context, span ot.Span) {
-		balance, err = b.Coordinator.Observer(wallet.Coin.ShortName).Balance(ctx, wallet.Address)
-		span.LogKV("address_balance", balance)
+		observer := b.Coordinator.Observer(wallet.Coin.ShortName)
+		balance = new(decimal.Big)
+		for _, address := range addresses {
+			var addressBalance *decimal.Big
+			addressBalance, err = observer.Balance(ctx, address)
+			if err != nil {
+				return
+			}
+			balance.Add(balance, addressBalance)
+		}
+		span.LogKV("address_balance", balance, "addresses_num", len(addresses))
 	})
 	if err != nil {
 		return
@@ -67,3 +91,24 @@ func (b *Balance) TotalWalletBalanceCtx(ctx context.Context, wallet *queries.Wal
 
 	return
 }
+
+// watchOnlyBalance calculates external address balance. Observer of coins which nodes pool funds reports received amount
+// only, so such balance is calculated from confirmed txs of address history.
+func (b *Balance) watchOnlyBalance(ctx context.Context, wallet *queries.Wallet) (balance *decimal.Big, err error) {
+	if !b.Coordinator.TxsSender(wallet.Coin.ShortName).SupportInternalTxs() {
+		return b.Coordinator.Observer(wallet.Coin.ShortName).Balance(ctx, wallet.Address)
+	}
+
+	txs, err := b.Coordinator.TxsObserver(wallet.Coin.ShortName).GetAddressTxs(ctx, wallet.Address)
+	if err != nil {
+		return
+	}
+	balance = new(decimal.Big)
+	for _, tx := range txs {
+		// unconfirmed outgoing txs are already spent
+		if tx.Confirmed || tx.Amount.Sign() < 0 {
+			balance.Add(balance, tx.Amount)
+		}
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/ericlagergren/decimal"
+	"time"
+)
+
+// IBalanceCache caches wallets total balances both in process memory and in db snapshots, so wallets listing
+// doesn't wait for the slowest node.
+type IBalanceCache interface {
+	// WalletBalanceCtx returns cached wallet total balance alongside with the moment it was calculated at. If no
+	// actual cached value found or fresh flag is set, balance will be recalculated using IBalance and cached.
+	WalletBalanceCtx(ctx context.Context, wallet *queries.Wallet, fresh bool) (
+		balance *decimal.Big, asOf time.Time, err error,
+	)
+
+	// Invalidate drops cached balances of specified wallets, so next read will recalculate them
+	Invalidate(ctx context.Context, walletIDs ...int64) error
+
+	// RefreshCoin recalculates and caches balances of all wallets of given coin
+	RefreshCoin(ctx context.Context, coinName string) error
+
+	// RefreshAddresses recalculates and caches balances of given coin wallets which own any of given addresses,
+	// addresses are case insensitive
+	RefreshAddresses(ctx context.Context, coinName string, addresses []string) error
+}
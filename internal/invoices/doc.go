@@ -0,0 +1,3 @@
+// Package invoices defines merchant invoices: merchant prices goods in fiat currency, invoice locks coin amount at
+// current rate for a limited time and is settled by deposits to the dedicated address or by internal payments
+package invoices
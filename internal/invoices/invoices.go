@@ -0,0 +1,795 @@
+package invoices
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrNoSuchInvoice returned when merchant has no invoice with given id or there is no such invoice to pay
+	ErrNoSuchInvoice = errors.New("invoices: no such invoice")
+
+	// ErrInvalidExpiresAt returned when invoice expiration time is in the past or too far in the future
+	ErrInvalidExpiresAt = errors.New("invoices: invalid expiration time")
+
+	// ErrInvalidWebhookURL returned when webhook isn't absolute http(s) url or it's host is loopback or private
+	// address
+	ErrInvalidWebhookURL = errors.New("invoices: invalid webhook url")
+
+	// ErrDescriptionTooLong returned when invoice description exceeds maxDescriptionLen characters
+	ErrDescriptionTooLong = errors.New("invoices: description is too long")
+
+	// ErrInvoiceNotPending returned on attempt to change or pay invoice which has been already settled, expired or
+	// canceled
+	ErrInvoiceNotPending = errors.New("invoices: invoice isn't pending")
+
+	// ErrInvoiceExpired returned on attempt to pay invoice which expiration time has come
+	ErrInvoiceExpired = errors.New("invoices: invoice is expired")
+
+	// ErrInvoiceAwaitsConfirmations returned on attempt to pay invoice which received payments already cover the
+	// amount, but aren't confirmed yet
+	ErrInvoiceAwaitsConfirmations = errors.New("invoices: invoice awaits payments confirmations")
+
+	// ErrWalletCoinMismatch returned when payer wallet coin differs from the invoice coin
+	ErrWalletCoinMismatch = errors.New("invoices: wallet coin differs from invoice coin")
+)
+
+const (
+	// defaultTTL is the invoice lifetime used if expiration time isn't given
+	defaultTTL = time.Minute * 15
+
+	// maxTTL limits time for which rate is locked
+	maxTTL = time.Hour * 24
+
+	// observationGrace is the time after expiration during which deposits sent in time may still be observed, invoice
+	// isn't finished as underpaid or expired until it's passed
+	observationGrace = time.Minute * 10
+
+	// amountScale is the number of decimal places the locked coin amount is rounded to
+	amountScale = 8
+
+	// maxDescriptionLen, maxWebhookURLLen and maxWebhookErrorLen are the columns sizes in characters
+	maxDescriptionLen  = 255
+	maxWebhookURLLen   = 1024
+	maxWebhookErrorLen = 1024
+
+	// maxWebhookAttempts limits webhook delivery attempts, delay between them is doubled starting from
+	// webhookRetryDelay
+	maxWebhookAttempts = 10
+	webhookRetryDelay  = time.Minute
+)
+
+// IInvoices manages merchant invoices
+type IInvoices interface {
+	// Create validates and creates pending invoice which receives payments to the merchant wallet, coin amount is
+	// locked at the current rate. Dedicated deposit address is derived if wallet coin supports it. Returns
+	// ErrInvalidExpiresAt, ErrInvalidWebhookURL, ErrDescriptionTooLong, errs.ErrNonPositiveAmount,
+	// convert.ErrFiatCurrencyName as validation errors, may return errs.ErrNoSuchWallet, errs.ErrWatchOnlyWallet and
+	// convert.ErrUnavailable.
+	Create(ctx context.Context, merchantPhone string, params InvoiceParams) (invoice *Invoice, err error)
+
+	// Get returns merchant invoice with it's payments. Returns ErrNoSuchInvoice.
+	Get(ctx context.Context, merchantPhone string, invoiceID int64) (invoice *Invoice, err error)
+
+	// GetAll returns merchant invoices, the latest are first. Optional status filters invoices.
+	GetAll(ctx context.Context, merchantPhone string, status string) (invoices []Invoice, err error)
+
+	// Update changes pending invoice description and webhook. Returns ErrNoSuchInvoice, ErrInvoiceNotPending,
+	// ErrInvalidWebhookURL and ErrDescriptionTooLong.
+	Update(ctx context.Context, merchantPhone string, invoiceID int64, update InvoiceUpdate) (
+		invoice *Invoice, err error,
+	)
+
+	// Cancel cancels pending invoice, already received payments stay in the merchant wallet. Returns
+	// ErrNoSuchInvoice and ErrInvoiceNotPending.
+	Cancel(ctx context.Context, merchantPhone string, invoiceID int64) (invoice *Invoice, err error)
+
+	// Pay pays the rest of invoice amount from the payer wallet by ordinary transaction, it's sent to the deposit
+	// address if invoice has one, otherwise to the merchant wallet. Concurrent payments may overpay invoice. Returns
+	// ErrNoSuchInvoice, ErrInvoiceNotPending, ErrInvoiceExpired, ErrInvoiceAwaitsConfirmations,
+	// ErrWalletCoinMismatch and wallets errors.
+	Pay(ctx context.Context, payerPhone string, invoiceID, walletID int64) (
+		invoice *Invoice, tx *processing.Tx, err error,
+	)
+
+	// Refresh links transactions observed on deposit addresses to pending invoices and settles them, returns number
+	// of invoices which status has been changed
+	Refresh(ctx context.Context) (changedNum int, err error)
+
+	// DeliverWebhooks posts invoices status changes saved by other methods to the merchants webhooks, failed
+	// deliveries are retried later. Returns number of delivered webhooks.
+	DeliverWebhooks(ctx context.Context) (deliveredNum int, err error)
+}
+
+// Invoices is IInvoices implementation
+type Invoices struct {
+	database    *gorm.DB
+	walletsApi  *wallets.Api
+	converter   convert.ICryptoCurrency
+	notificator isc.IInvoicesEventNotificator
+}
+
+// New creates invoices manager which derives deposit addresses and sends internal payments using wallets api
+func New(
+	database *gorm.DB,
+	walletsApi *wallets.Api,
+	converter convert.ICryptoCurrency,
+	notificator isc.IInvoicesEventNotificator,
+) *Invoices {
+	return &Invoices{database: database, walletsApi: walletsApi, converter: converter, notificator: notificator}
+}
+
+// Create implements IInvoices
+func (i *Invoices) Create(ctx context.Context, merchantPhone string, params InvoiceParams) (
+	invoice *Invoice, err error,
+) {
+	err = trace.InsideSpanE(ctx, "create_invoice", func(ctx context.Context, span ot.Span) error {
+		span.LogKV(
+			"merchant_phone", merchantPhone,
+			"wallet_id", params.WalletID,
+			"fiat_amount", params.FiatAmount,
+			"fiat_currency", params.FiatCurrency,
+		)
+
+		merchantPhone, err = wallets.CoercePhone(merchantPhone)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if params.ExpiresAt.IsZero() {
+			params.ExpiresAt = now.Add(defaultTTL)
+		}
+		params.ExpiresAt = params.ExpiresAt.UTC()
+
+		// gather validation errors
+		var validationErrs error
+		if params.FiatAmount == nil || params.FiatAmount.Sign() <= 0 {
+			validationErrs = merrors.Append(validationErrs, errs.ErrNonPositiveAmount)
+		}
+		if params.FiatCurrency == "" {
+			validationErrs = merrors.Append(validationErrs, convert.ErrFiatCurrencyName)
+		}
+		if !params.ExpiresAt.After(now) || params.ExpiresAt.After(now.Add(maxTTL)) {
+			validationErrs = merrors.Append(validationErrs, ErrInvalidExpiresAt)
+		}
+		if webhookErr := validateWebhookURL(params.WebhookURL); webhookErr != nil {
+			validationErrs = merrors.Append(validationErrs, webhookErr)
+		}
+		if utf8.RuneCountInString(params.Description) > maxDescriptionLen {
+			validationErrs = merrors.Append(validationErrs, ErrDescriptionTooLong)
+		}
+		if validationErrs != nil {
+			return validationErrs
+		}
+
+		var wallet *queries.Wallet
+		err = db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			wallet, err = getUserWallet(dbTx, merchantPhone, params.WalletID)
+			if err != nil {
+				return err
+			}
+			if wallet.WatchOnly {
+				return errs.ErrWatchOnlyWallet
+			}
+			return wallets.CheckAccountOpenGorm(dbTx, merchantPhone, errs.ErrAccountClosed)
+		})
+		if err != nil {
+			return err
+		}
+
+		// lock coin amount at the current rate
+		rate, err := i.converter.GetRate(ctx, wallet.Coin.ShortName, params.FiatCurrency)
+		if err != nil {
+			return err
+		}
+		amount := rate.ReverseConvert(params.FiatAmount)
+		amount.Quantize(amountScale)
+		if amount.Sign() <= 0 {
+			return errs.ErrNonPositiveAmount
+		}
+		span.LogKV("rate", (*decimal.Big)(rate), "amount", amount)
+
+		webhookSecret, err := newWebhookSecret()
+		if err != nil {
+			return err
+		}
+
+		invoice = &Invoice{
+			MerchantPhone: merchantPhone,
+			WalletID:      wallet.ID,
+			Wallet:        wallet,
+			FiatAmount:    &processing.Decimal{V: params.FiatAmount},
+			FiatCurrency:  strings.ToUpper(params.FiatCurrency),
+			Amount:        &processing.Decimal{V: amount},
+			Rate:          &processing.Decimal{V: (*decimal.Big)(rate)},
+			PaidAmount:    &processing.Decimal{V: new(decimal.Big)},
+			WebhookSecret: &webhookSecret,
+			Status:        StatusPending,
+			ExpiresAt:     params.ExpiresAt,
+		}
+		if params.Description != "" {
+			invoice.Description = &params.Description
+		}
+		if params.WebhookURL != "" {
+			invoice.WebhookURL = &params.WebhookURL
+		}
+
+		// dedicated address distinguishes invoice deposits, coins which can't derive it are paid only internally
+		address, err := i.walletsApi.NewDepositAddress(ctx, merchantPhone, wallet.ID)
+		switch err {
+		case nil:
+			invoice.Address = &address.Address
+			span.LogKV("address", address.Address)
+		case errs.ErrAddressRotationNotSupported:
+			trace.LogMsg(span, "coin doesn't support deposit addresses, invoice may be paid only internally")
+		default:
+			return err
+		}
+
+		err = db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			return dbTx.Create(invoice).Error
+		})
+		if err != nil {
+			return err
+		}
+		span.LogKV("invoice_id", invoice.ID)
+		return nil
+	})
+	return
+}
+
+// Get implements IInvoices
+func (i *Invoices) Get(ctx context.Context, merchantPhone string, invoiceID int64) (invoice *Invoice, err error) {
+	err = trace.InsideSpanE(ctx, "get_invoice", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("merchant_phone", merchantPhone, "invoice_id", invoiceID)
+
+		merchantPhone, err = wallets.CoercePhone(merchantPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			invoice, err = getInvoice(dbTx.Where("merchant_phone = ?", merchantPhone).Preload("Payments"), invoiceID)
+			return err
+		})
+	})
+	return
+}
+
+// GetAll implements IInvoices
+func (i *Invoices) GetAll(ctx context.Context, merchantPhone string, status string) (invoices []Invoice, err error) {
+	err = trace.InsideSpanE(ctx, "get_invoices", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("merchant_phone", merchantPhone, "status", status)
+
+		merchantPhone, err = wallets.CoercePhone(merchantPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			q := dbTx.Where("merchant_phone = ?", merchantPhone)
+			if status != "" {
+				q = q.Where("status = ?", status)
+			}
+			return q.Preload("Wallet").Preload("Wallet.Coin").Order("id desc").Find(&invoices).Error
+		})
+	})
+	return
+}
+
+// Update implements IInvoices
+func (i *Invoices) Update(ctx context.Context, merchantPhone string, invoiceID int64, update InvoiceUpdate) (
+	invoice *Invoice, err error,
+) {
+	err = trace.InsideSpanE(ctx, "update_invoice", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("merchant_phone", merchantPhone, "invoice_id", invoiceID)
+
+		merchantPhone, err = wallets.CoercePhone(merchantPhone)
+		if err != nil {
+			return err
+		}
+
+		if update.WebhookURL != nil {
+			err = validateWebhookURL(*update.WebhookURL)
+			if err != nil {
+				return err
+			}
+		}
+		if update.Description != nil && utf8.RuneCountInString(*update.Description) > maxDescriptionLen {
+			return ErrDescriptionTooLong
+		}
+
+		return db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			invoice, err = lockMerchantInvoice(dbTx, merchantPhone, invoiceID)
+			if err != nil {
+				return err
+			}
+			if !invoice.IsPending() {
+				return ErrInvoiceNotPending
+			}
+
+			fields := map[string]interface{}{"updated_at": time.Now().UTC()}
+			if update.Description != nil {
+				invoice.Description = nullableString(*update.Description)
+				fields["description"] = invoice.Description
+			}
+			if update.WebhookURL != nil {
+				invoice.WebhookURL = nullableString(*update.WebhookURL)
+				fields["webhook_url"] = invoice.WebhookURL
+			}
+			return dbTx.Model(invoice).Updates(fields).Error
+		})
+	})
+	return
+}
+
+// Cancel implements IInvoices
+func (i *Invoices) Cancel(ctx context.Context, merchantPhone string, invoiceID int64) (invoice *Invoice, err error) {
+	err = trace.InsideSpanE(ctx, "cancel_invoice", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("merchant_phone", merchantPhone, "invoice_id", invoiceID)
+
+		merchantPhone, err = wallets.CoercePhone(merchantPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			invoice, err = lockMerchantInvoice(dbTx, merchantPhone, invoiceID)
+			if err != nil {
+				return err
+			}
+			if !invoice.IsPending() {
+				return ErrInvoiceNotPending
+			}
+			err = setStatus(dbTx, invoice, StatusCanceled)
+			if err != nil {
+				return err
+			}
+			return enqueueWebhook(dbTx, invoice)
+		})
+	})
+	return
+}
+
+// Pay implements IInvoices
+func (i *Invoices) Pay(ctx context.Context, payerPhone string, invoiceID, walletID int64) (
+	invoice *Invoice, tx *processing.Tx, err error,
+) {
+	err = trace.InsideSpanE(ctx, "pay_invoice", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("payer_phone", payerPhone, "invoice_id", invoiceID, "wallet_id", walletID)
+
+		payerPhone, err = wallets.CoercePhone(payerPhone)
+		if err != nil {
+			return err
+		}
+
+		// determine the rest of amount, payments already observed on the deposit address are taken into account
+		var rest *decimal.Big
+		err = db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			invoice, err = lockInvoice(dbTx, invoiceID)
+			if err != nil {
+				return err
+			}
+			if !invoice.IsPending() {
+				return ErrInvoiceNotPending
+			}
+			if invoice.isOutdated(time.Now().UTC()) {
+				return ErrInvoiceExpired
+			}
+			if invoice.MerchantPhone == payerPhone {
+				return errs.ErrSelfTxForbidden
+			}
+
+			wallet, err := getUserWallet(dbTx, payerPhone, walletID)
+			if err != nil {
+				return err
+			}
+			if wallet.CoinID != invoice.Wallet.CoinID {
+				return ErrWalletCoinMismatch
+			}
+
+			err = linkObservedPayments(dbTx, invoice)
+			if err != nil {
+				return err
+			}
+			confirmed, unconfirmed, err := paymentsSums(dbTx, invoice.ID)
+			if err != nil {
+				return err
+			}
+			rest = new(decimal.Big).Sub(invoice.Amount.V, confirmed)
+			rest.Sub(rest, unconfirmed)
+			if rest.Sign() <= 0 {
+				return ErrInvoiceAwaitsConfirmations
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		span.LogKV("amount", rest)
+
+		if invoice.Address != nil {
+			tx, err = i.walletsApi.SentToAddress(ctx, payerPhone, walletID, *invoice.Address, rest, nil)
+		} else {
+			tx, err = i.walletsApi.SendToPhone(
+				ctx, payerPhone, walletID, invoice.MerchantPhone, invoice.WalletID, rest, nil,
+			)
+		}
+		if err != nil {
+			return err
+		}
+		span.LogKV("tx_id", tx.ID)
+
+		return db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			err := linkPayment(dbTx, invoice.ID, tx.ID)
+			if err != nil {
+				return err
+			}
+			invoice, _, err = settle(dbTx, invoice.ID)
+			return err
+		})
+	})
+	return
+}
+
+// Refresh implements IInvoices
+func (i *Invoices) Refresh(ctx context.Context) (changedNum int, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "refresh_invoices")
+	defer span.Finish()
+
+	var ids []int64
+	err = db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Model(&Invoice{}).Where("status = ?", StatusPending).Order("id").Pluck("id", &ids).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+	span.LogKV("pending_invoices_num", len(ids))
+
+	// each invoice is settled in it's own db transaction, so failed one doesn't prevent others settlement
+	for _, id := range ids {
+		var changed bool
+		settleErr := db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			var err error
+			_, changed, err = settle(dbTx, id)
+			return err
+		})
+		if settleErr != nil {
+			trace.LogErrorWithMsg(span, settleErr, "invoice settlement failed")
+			err = merrors.Append(err, settleErr)
+			continue
+		}
+		if changed {
+			changedNum++
+		}
+	}
+	span.LogKV("changed_invoices_num", changedNum)
+	return
+}
+
+// DeliverWebhooks implements IInvoices
+func (i *Invoices) DeliverWebhooks(ctx context.Context) (deliveredNum int, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "deliver_invoices_webhooks")
+	defer span.Finish()
+
+	var ids []int64
+	err = db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Model(&Webhook{}).Where(
+			"delivered_at is null and attempts < ? and next_attempt_at <= ?", maxWebhookAttempts, time.Now().UTC(),
+		).Order("id").Pluck("id", &ids).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+	span.LogKV("due_webhooks_num", len(ids))
+
+	// each webhook is locked during it's delivery, so concurrent workers don't deliver it twice
+	for _, id := range ids {
+		var delivered bool
+		deliverErr := db.TransactionCtx(ctx, i.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			var err error
+			delivered, err = i.deliverWebhook(span, dbTx, id)
+			return err
+		})
+		if deliverErr != nil {
+			trace.LogErrorWithMsg(span, deliverErr, "webhook delivery saving failed")
+			err = merrors.Append(err, deliverErr)
+			continue
+		}
+		if delivered {
+			deliveredNum++
+		}
+	}
+	span.LogKV("delivered_webhooks_num", deliveredNum)
+	return
+}
+
+// deliverWebhook locks due webhook and posts it using notificator, failed delivery is rescheduled
+func (i *Invoices) deliverWebhook(span ot.Span, dbTx *gorm.DB, webhookID int64) (delivered bool, err error) {
+	webhook := new(Webhook)
+	err = dbTx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").Where(
+		"id = ? and delivered_at is null and next_attempt_at <= ?", webhookID, time.Now().UTC(),
+	).First(webhook).Error
+	if err == gorm.ErrRecordNotFound {
+		// already delivered or being delivered by another worker
+		return false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	var payload isc.InvoiceEventPayload
+	sendErr := json.Unmarshal([]byte(webhook.Payload), &payload)
+	if sendErr == nil {
+		sendErr = i.notificator.StatusChanged(payload)
+	}
+
+	now := time.Now().UTC()
+	webhook.Attempts++
+	fields := map[string]interface{}{"attempts": webhook.Attempts}
+	if sendErr == nil {
+		fields["delivered_at"] = now
+		fields["last_error"] = nil
+	} else {
+		trace.LogErrorWithMsg(span, sendErr, "webhook delivery failed")
+		errMsg := sendErr.Error()
+		if runes := []rune(errMsg); len(runes) > maxWebhookErrorLen {
+			errMsg = string(runes[:maxWebhookErrorLen])
+		}
+		fields["last_error"] = errMsg
+		fields["next_attempt_at"] = now.Add(webhookRetryDelay << uint(webhook.Attempts-1))
+	}
+	return sendErr == nil, dbTx.Model(webhook).Updates(fields).Error
+}
+
+// settle locks pending invoice, links payments observed on it's deposit address and updates paid amount and status,
+// changed flag is set if invoice status has been changed, the change is saved for webhook delivery
+func settle(dbTx *gorm.DB, invoiceID int64) (invoice *Invoice, changed bool, err error) {
+	invoice, err = lockInvoice(dbTx, invoiceID)
+	if err != nil || !invoice.IsPending() {
+		return
+	}
+
+	err = linkObservedPayments(dbTx, invoice)
+	if err != nil {
+		return
+	}
+	confirmed, unconfirmed, err := paymentsSums(dbTx, invoice.ID)
+	if err != nil {
+		return
+	}
+
+	outdated := invoice.isOutdated(time.Now().UTC().Add(-observationGrace))
+	status := ResolveStatus(invoice.Amount.V, confirmed, unconfirmed, outdated)
+	if status == invoice.Status && confirmed.Cmp(invoice.PaidAmount.V) == 0 {
+		return
+	}
+
+	changed = status != invoice.Status
+	invoice.Status = status
+	invoice.PaidAmount = &processing.Decimal{V: confirmed}
+	invoice.UpdatedAt = time.Now().UTC()
+	err = dbTx.Model(invoice).Updates(map[string]interface{}{
+		"status":      invoice.Status,
+		"paid_amount": invoice.PaidAmount,
+		"updated_at":  invoice.UpdatedAt,
+	}).Error
+	if err == nil && changed {
+		err = enqueueWebhook(dbTx, invoice)
+	}
+	return
+}
+
+// enqueueWebhook saves invoice event for delivery to the merchant webhook, it must be called in the db transaction
+// which changes invoice status, so event is neither lost nor sent for rolled back change
+func enqueueWebhook(dbTx *gorm.DB, invoice *Invoice) error {
+	if invoice.WebhookURL == nil {
+		return nil
+	}
+	payload, err := json.Marshal(invoiceEventPayload(invoice))
+	if err != nil {
+		return err
+	}
+	return dbTx.Create(&Webhook{
+		InvoiceID:     invoice.ID,
+		Payload:       string(payload),
+		NextAttemptAt: time.Now().UTC(),
+	}).Error
+}
+
+// linkObservedPayments links txs which have credited invoice wallet using invoice deposit address: external txs
+// found by watchNewTxs and internal transfers to that address. Only txs created during invoice lifetime are linked.
+func linkObservedPayments(dbTx *gorm.DB, invoice *Invoice) error {
+	if invoice.Address == nil {
+		return nil
+	}
+	return dbTx.Exec(
+		`insert into invoice_payments (invoice_id, tx_id)
+		select ?, txs.id from txs
+		where txs.to_wallet_id = ? and txs.created_at >= ? and txs.created_at <= ? and (
+			txs.to_address = ? or exists(
+				select 1 from txs_external where txs_external.tx_id = txs.id and txs_external.recipient = ?
+			)
+		)
+		on conflict on constraint invoice_payments_unique_tx_cst do nothing`,
+		invoice.ID,
+		invoice.WalletID,
+		invoice.CreatedAt,
+		invoice.ExpiresAt.Add(observationGrace),
+		*invoice.Address,
+		*invoice.Address,
+	).Error
+}
+
+// linkPayment links tx sent by the payer to the invoice
+func linkPayment(dbTx *gorm.DB, invoiceID, txID int64) error {
+	return dbTx.Exec(
+		`insert into invoice_payments (invoice_id, tx_id) values (?, ?)
+		on conflict on constraint invoice_payments_unique_tx_cst do nothing`,
+		invoiceID, txID,
+	).Error
+}
+
+// paymentsSums returns sums of confirmed and still processing invoice payments, failed payments are ignored
+func paymentsSums(dbTx *gorm.DB, invoiceID int64) (confirmed, unconfirmed *decimal.Big, err error) {
+	var sums struct {
+		Confirmed   processing.Decimal
+		Unconfirmed processing.Decimal
+	}
+	err = dbTx.Raw(
+		`select
+			coalesce(sum(txs.amount) filter (where tx_statuses.name = ?), 0) as confirmed,
+			coalesce(sum(txs.amount) filter (where tx_statuses.name not in (?, ?, ?)), 0) as unconfirmed
+		from invoice_payments
+		inner join txs on txs.id = invoice_payments.tx_id
+		inner join tx_statuses on tx_statuses.id = txs.status_id
+		where invoice_payments.invoice_id = ?`,
+		processing.TxStateProcessed,
+		processing.TxStateProcessed, processing.TxStateDeclined, processing.TxStateCanceled,
+		invoiceID,
+	).Scan(&sums).Error
+	if err != nil {
+		return
+	}
+	return sums.Confirmed.V, sums.Unconfirmed.V, nil
+}
+
+// lockInvoice locks and queries invoice, returns ErrNoSuchInvoice if not found
+func lockInvoice(dbTx *gorm.DB, invoiceID int64) (*Invoice, error) {
+	err := dbTx.Exec("select 1 from invoices where id = ? for update", invoiceID).Error
+	if err != nil {
+		return nil, err
+	}
+	return getInvoice(dbTx, invoiceID)
+}
+
+// lockMerchantInvoice same as lockInvoice, but invoice of another merchant isn't found
+func lockMerchantInvoice(dbTx *gorm.DB, merchantPhone string, invoiceID int64) (*Invoice, error) {
+	invoice, err := lockInvoice(dbTx, invoiceID)
+	if err == nil && invoice.MerchantPhone != merchantPhone {
+		return nil, ErrNoSuchInvoice
+	}
+	return invoice, err
+}
+
+// getInvoice queries invoice using given query, returns ErrNoSuchInvoice if not found
+func getInvoice(q *gorm.DB, invoiceID int64) (*Invoice, error) {
+	invoice := new(Invoice)
+	err := q.Where("id = ?", invoiceID).Preload("Wallet").Preload("Wallet.Coin").First(invoice).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNoSuchInvoice
+	}
+	return invoice, err
+}
+
+// getUserWallet queries user wallet with it's coin, returns errs.ErrNoSuchWallet if not found
+func getUserWallet(dbTx *gorm.DB, userPhone string, walletID int64) (*queries.Wallet, error) {
+	wallet := new(queries.Wallet)
+	err := dbTx.Where("id = ? and user_phone = ?", walletID, userPhone).Preload("Coin").First(wallet).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errs.ErrNoSuchWallet
+	}
+	return wallet, err
+}
+
+// setStatus saves invoice status
+func setStatus(dbTx *gorm.DB, invoice *Invoice, status string) error {
+	invoice.Status = status
+	invoice.UpdatedAt = time.Now().UTC()
+	return dbTx.Model(invoice).Updates(map[string]interface{}{
+		"status":     invoice.Status,
+		"updated_at": invoice.UpdatedAt,
+	}).Error
+}
+
+// validateWebhookURL accepts empty or absolute http(s) url without credentials, which host is neither localhost nor
+// loopback or private ip. Host names are resolved and checked once again on each delivery.
+func validateWebhookURL(webhookURL string) error {
+	if webhookURL == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(webhookURL) > maxWebhookURLLen {
+		return ErrInvalidWebhookURL
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return ErrInvalidWebhookURL
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInvalidWebhookURL
+	}
+	if ip := net.ParseIP(host); ip != nil && !isc.IsPublicIP(ip) {
+		return ErrInvalidWebhookURL
+	}
+	return nil
+}
+
+// newWebhookSecret generates random invoice webhook secret
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// invoiceEventPayload describes invoice for notifications
+func invoiceEventPayload(invoice *Invoice) isc.InvoiceEventPayload {
+	payload := isc.InvoiceEventPayload{
+		ID:            invoice.ID,
+		MerchantPhone: invoice.MerchantPhone,
+		WalletID:      invoice.WalletID,
+		Amount:        invoice.Amount.V,
+		PaidAmount:    invoice.PaidAmount.V,
+		FiatAmount:    invoice.FiatAmount.V,
+		FiatCurrency:  invoice.FiatCurrency,
+		Status:        invoice.Status,
+		ExpiresAt:     invoice.ExpiresAt,
+	}
+	if invoice.Wallet != nil {
+		payload.Coin = invoice.Wallet.Coin.ShortName
+	}
+	if invoice.Address != nil {
+		payload.Address = *invoice.Address
+	}
+	if invoice.WebhookURL != nil {
+		payload.WebhookURL = *invoice.WebhookURL
+	}
+	if invoice.WebhookSecret != nil {
+		payload.WebhookSecret = *invoice.WebhookSecret
+	}
+	return payload
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,137 @@
+package invoices
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/ericlagergren/decimal"
+	"time"
+)
+
+// Invoice statuses
+const (
+	StatusPending   = "pending"
+	StatusPaid      = "paid"
+	StatusUnderpaid = "underpaid"
+	StatusOverpaid  = "overpaid"
+	StatusExpired   = "expired"
+	StatusCanceled  = "canceled"
+)
+
+// Invoice is merchant invoice priced in fiat currency, coin amount is locked at creation time using the current rate
+type Invoice struct {
+	ID            int64
+	MerchantPhone string
+	WalletID      int64
+	Wallet        *queries.Wallet `gorm:"foreignkey:WalletID;association_autoupdate:false;association_autocreate:false"`
+
+	FiatAmount   *processing.Decimal
+	FiatCurrency string
+
+	// Amount is the coin amount payer must pay, it's fiat amount converted using Rate
+	Amount *processing.Decimal
+	Rate   *processing.Decimal
+
+	// Address is the dedicated deposit address, nil if wallet coin can't derive additional addresses, so invoice may
+	// be paid only internally
+	Address *string
+
+	// PaidAmount is the sum of confirmed payments
+	PaidAmount *processing.Decimal
+
+	Description *string
+	WebhookURL  *string
+
+	// WebhookSecret is the key webhook bodies are signed by, it's shown only to the merchant
+	WebhookSecret *string
+
+	Status    string
+	ExpiresAt time.Time
+
+	Payments []Payment `gorm:"foreignkey:InvoiceID;association_autoupdate:false;association_autocreate:false"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// IsPending true if invoice still awaits payments
+func (i *Invoice) IsPending() bool {
+	return i.Status == StatusPending
+}
+
+// isOutdated true if pending invoice can't be paid anymore
+func (i *Invoice) isOutdated(now time.Time) bool {
+	return i.IsPending() && !i.ExpiresAt.After(now)
+}
+
+// Payment links transaction which has been received by the invoice
+type Payment struct {
+	InvoiceID int64
+	TxID      int64
+}
+
+func (Payment) TableName() string {
+	return "invoice_payments"
+}
+
+// Webhook is invoice event awaiting delivery to the merchant webhook, it's saved in the same db transaction as the
+// invoice status change
+type Webhook struct {
+	ID        int64
+	InvoiceID int64
+
+	// Payload is json encoded isc.InvoiceEventPayload
+	Payload string
+
+	Attempts      int
+	LastError     *string
+	NextAttemptAt time.Time
+	DeliveredAt   *time.Time
+
+	CreatedAt time.Time
+}
+
+func (Webhook) TableName() string {
+	return "invoice_webhooks"
+}
+
+// InvoiceParams describes new invoice
+type InvoiceParams struct {
+	// WalletID is the merchant wallet which receives payments, invoice coin is the wallet coin
+	WalletID int64
+
+	FiatAmount   *decimal.Big
+	FiatCurrency string
+	Description  string
+	WebhookURL   string
+
+	// ExpiresAt is the time until which locked amount may be paid, default ttl is used if zero
+	ExpiresAt time.Time
+}
+
+// InvoiceUpdate describes pending invoice changes, only non-nil fields are updated, empty values clear fields
+type InvoiceUpdate struct {
+	Description *string
+	WebhookURL  *string
+}
+
+// ResolveStatus determines invoice status from the sums of confirmed and unconfirmed payments. Invoice is paid or
+// overpaid as soon as confirmed payments cover the amount, while it's finished as underpaid or expired only when it's
+// outdated and there is no unconfirmed payments, otherwise it stays pending.
+func ResolveStatus(amount, confirmed, unconfirmed *decimal.Big, outdated bool) string {
+	switch cmp := confirmed.Cmp(amount); {
+	case cmp == 0:
+		return StatusPaid
+	case cmp > 0:
+		return StatusOverpaid
+	case !outdated || unconfirmed.Sign() > 0:
+		return StatusPending
+	case confirmed.Sign() > 0:
+		return StatusUnderpaid
+	default:
+		return StatusExpired
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/wallet-api/internal/isc/handlers/base"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
@@ -18,8 +19,11 @@ import (
 
 const componentName = "isc.users.handlers"
 
-// RegistrationCompletedFactory handles user registration event and creates wallets for all available coins
-func RegistrationCompletedFactory(d *db.Db, api *wallets.Api, logger logrus.FieldLogger) base.HandlerFunc {
+// RegistrationCompletedFactory handles user registration event and creates wallets for all available coins except
+// ones user has opted out of
+func RegistrationCompletedFactory(
+	d *db.Db, api *wallets.Api, userSettings settings.ISettings, logger logrus.FieldLogger,
+) base.HandlerFunc {
 	return func(identifier broker.Identifier, dataBinder func(dst interface{}) error) (out base.HandlerOut, err error) {
 		span := opentracing.GlobalTracer().StartSpan("registration_completed_handler")
 		ctx := opentracing.ContextWithSpan(context.Background(), span)
@@ -48,13 +52,33 @@ func RegistrationCompletedFactory(d *db.Db, api *wallets.Api, logger logrus.Fiel
 			trace.LogErrorWithMsg(span, err, "default coins fetch failed")
 			return
 		}
+		// query coins user has opted out of, ones chosen during registration are stored first
+		prefs, err := registrationSettings(ctx, d, userSettings, params)
+		if err == errs.ErrInvalidPhone {
+			// redelivery doesn't help invalid phone
+			trace.LogError(span, err)
+			err = nil
+			return
+		}
+		if err != nil {
+			trace.LogErrorWithMsg(span, err, "user settings fetch failed")
+			return
+		}
+
 		coinsNamesSet := make(map[string]struct{})
 		for _, c := range coins {
+			if prefs.IsCoinHidden(c.ShortName) {
+				span.LogKV("hidden_coin", c.ShortName)
+				continue
+			}
 			coinsNamesSet[c.ShortName] = struct{}{}
 		}
 
 		// query already created wallets
-		wts, _, _, err := queries.GetWallets(d, queries.GetWalletFilters{UserPhone: params.UserPhone})
+		wts, _, _, err := queries.GetWallets(d, queries.GetWalletFilters{
+			UserPhone:    params.UserPhone,
+			WithArchived: true,
+		})
 		if err != nil {
 			trace.LogErrorWithMsg(span, err, "user wallets query failed")
 			return
@@ -67,14 +91,21 @@ func RegistrationCompletedFactory(d *db.Db, api *wallets.Api, logger logrus.Fiel
 			}
 		}
 
-		// create wallets for all enabled coins
+		// create wallets for all enabled coins which user has no wallets of
 		for _, c := range coins {
+			if _, ok := coinsNamesSet[c.ShortName]; !ok {
+				continue
+			}
+
 			// force default wallet name
 			_, cErr := api.CreateWallet(ctx, params.UserPhone, c.ShortName, "")
 			if cErr != nil {
 				if cErr == errs.ErrWalletCreationRejected {
 					// TODO wrong behaviour, if user wallet for this coin already exists, should not be called
 					trace.LogMsgf(span, `wallet for "%s" user already created`, c.ShortName)
+				} else if cErr == errs.ErrAccountClosed {
+					trace.LogMsg(span, "user account is closed, wallets aren't created")
+					break
 				} else {
 					span.LogKV("coin_name", c.ShortName)
 					trace.LogErrorWithMsg(span, cErr, "wallet creation failed")
@@ -86,3 +117,79 @@ func RegistrationCompletedFactory(d *db.Db, api *wallets.Api, logger logrus.Fiel
 		return
 	}
 }
+
+// registrationSettings stores coins user has opted out of during registration and returns user settings. Unknown coins
+// are skipped since redelivery doesn't help them, stored settings are returned as is if event holds no opt-out.
+func registrationSettings(
+	ctx context.Context, d *db.Db, userSettings settings.ISettings, params CreatedEvent,
+) (*settings.Settings, error) {
+	span := opentracing.SpanFromContext(ctx)
+
+	hiddenCoins := make([]string, 0, len(params.HiddenCoins))
+	for _, coinName := range params.HiddenCoins {
+		_, err := queries.GetCoin(d, coinName)
+		if err == errs.ErrNoSuchCoin {
+			span.LogKV("unknown_hidden_coin", coinName)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		hiddenCoins = append(hiddenCoins, coinName)
+	}
+	if len(hiddenCoins) == 0 {
+		return userSettings.Get(ctx, params.UserPhone)
+	}
+	return userSettings.Update(ctx, params.UserPhone, settings.UpdateParams{HiddenCoins: hiddenCoins})
+}
+
+// PhoneChangeSource is phone change audit source of changes made by event
+const PhoneChangeSource = "isc_event"
+
+// PhoneChangedFactory handles user phone change event and re-keys user wallets and txs which await recipient to the new
+// phone. Conflicting and invalid changes are logged and acknowledged, since redelivery doesn't help them.
+func PhoneChangedFactory(api *wallets.Api, logger logrus.FieldLogger) base.HandlerFunc {
+	return func(identifier broker.Identifier, dataBinder func(dst interface{}) error) (out base.HandlerOut, err error) {
+		span := opentracing.GlobalTracer().StartSpan("phone_changed_handler")
+		ctx := opentracing.ContextWithSpan(context.Background(), span)
+		defer span.Finish()
+
+		ext.SpanKind.Set(span, ext.SpanKindConsumerEnum)
+		ext.Component.Set(span, componentName)
+
+		// bind params
+		params := PhoneChangedEvent{}
+		err = dataBinder(&params)
+		if err != nil {
+			trace.LogErrorWithMsg(span, err, "message parsing failed")
+			return
+		}
+		if params.OldPhone == "" || params.NewPhone == "" {
+			trace.LogError(span, errors.New("user phone is empty"))
+			return
+		}
+
+		span.LogKV("old_phone", params.OldPhone, "new_phone", params.NewPhone)
+
+		change, err := api.ChangePhone(ctx, params.OldPhone, params.NewPhone, PhoneChangeSource)
+		switch err {
+		case nil:
+			logger.WithField("change_id", change.ID).WithField(
+				"wallets_num", change.WalletsNum,
+			).WithField(
+				"txs_num", change.TxsNum,
+			).Info("user phone changed")
+		case errs.ErrPhoneChangeConflict, errs.ErrSamePhone, errs.ErrInvalidPhone, errs.ErrAccountClosed:
+			logger.WithError(err).WithField(
+				"old_phone", params.OldPhone,
+			).WithField(
+				"new_phone", params.NewPhone,
+			).Warn("user phone change rejected")
+			trace.LogError(span, err)
+			err = nil
+		default:
+			trace.LogErrorWithMsg(span, err, "user phone change failed")
+		}
+		return
+	}
+}
@@ -1,6 +1,13 @@
 package users
 
-// CreatedEvent select only user id which is interesting to us
+// CreatedEvent select only user id and coins user has opted out of during registration which are interesting to us
 type CreatedEvent struct {
-	UserPhone string `json:"user_phone"`
+	UserPhone   string   `json:"user_phone"`
+	HiddenCoins []string `json:"hidden_coins"`
+}
+
+// PhoneChangedEvent describes user phone number change
+type PhoneChangedEvent struct {
+	OldPhone string `json:"old_phone"`
+	NewPhone string `json:"new_phone"`
 }
@@ -2,6 +2,7 @@ package users
 
 import (
 	"git.zam.io/wallet-backend/wallet-api/internal/isc/handlers/base"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
 	"git.zam.io/wallet-backend/web-api/db"
 	"git.zam.io/wallet-backend/web-api/pkg/services/broker"
@@ -9,9 +10,18 @@ import (
 )
 
 // Register
-func Register(broker broker.IBroker, d *db.Db, api *wallets.Api, logger logrus.FieldLogger) error {
-	return broker.Consume(
+func Register(
+	broker broker.IBroker, d *db.Db, api *wallets.Api, userSettings settings.ISettings, logger logrus.FieldLogger,
+) error {
+	err := broker.Consume(
 		"users", "registration_verification_completed_event",
-		base.WrapHandler(RegistrationCompletedFactory(d, api, logger)),
+		base.WrapHandler(RegistrationCompletedFactory(d, api, userSettings, logger)),
+	)
+	if err != nil {
+		return err
+	}
+	return broker.Consume(
+		"users", "phone_changed_event",
+		base.WrapHandler(PhoneChangedFactory(api, logger)),
 	)
 }
@@ -0,0 +1,175 @@
+package users_test
+
+import (
+	"testing"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"git.zam.io/wallet-backend/wallet-api/internal/isc/handlers/base"
+	"git.zam.io/wallet-backend/wallet-api/internal/isc/handlers/users"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes/mocks"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	"git.zam.io/wallet-backend/web-api/pkg/services/broker"
+	"github.com/jinzhu/gorm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"sort"
+	"sync/atomic"
+)
+
+func TestUsers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Users Handlers Suite")
+}
+
+const (
+	testCoinName  = "TEST"
+	otherCoinName = "OTHER"
+	userPhone     = "+79109998877"
+)
+
+// processingStub acknowledges wallets creation, other processing calls aren't expected
+type processingStub struct {
+	processing.IApi
+}
+
+// NotifyUserCreatesWallet implements processing.IApi
+func (processingStub) NotifyUserCreatesWallet(ctx context.Context, wallet *queries.Wallet) error {
+	return nil
+}
+
+// register handles registration completed event with given payload
+func register(handler base.HandlerFunc, payload string) {
+	var identifier broker.Identifier
+	_, err := handler(identifier, func(dst interface{}) error {
+		return json.Unmarshal([]byte(payload), dst)
+	})
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// walletsCoins returns sorted names of coins user has wallets of
+func walletsCoins(d *db.Db) []string {
+	wts, _, _, err := queries.GetWallets(d, queries.GetWalletFilters{UserPhone: userPhone, WithArchived: true})
+	Expect(err).NotTo(HaveOccurred())
+
+	names := make([]string, 0, len(wts))
+	for _, w := range wts {
+		names = append(names, w.Coin.ShortName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var _ = Describe("testing registration completed handler", func() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	BeforeEachCProvide(func(d *db.Db) (*gorm.DB, error) {
+		return gorm.Open("postgres", d.DB.DB)
+	})
+
+	BeforeEachCProvide(func(d *gorm.DB) settings.ISettings {
+		return settings.New(d, nil)
+	})
+
+	// generator produces unique addresses since wallets of all coins are created by single call
+	BeforeEachCProvide(func() nodes.ICoordinator {
+		var generated int64
+		generator := &mocks.IGenerator{}
+		generator.On("Create", mock.Anything).Return(
+			func(ctx context.Context) string {
+				return fmt.Sprintf("address-%d", atomic.AddInt64(&generated, 1))
+			},
+			"secret", nil,
+		)
+		coordinator := &mocks.ICoordinator{}
+		coordinator.On("Generator", mock.Anything).Return(generator)
+		return coordinator
+	})
+
+	BeforeEachCProvide(func(d *db.Db, coordinator nodes.ICoordinator) *wallets.Api {
+		return wallets.NewApi(d, coordinator, processingStub{}, nil, nil)
+	})
+
+	// only test coins are created by default
+	BeforeEachCInvoke(func(d *db.Db) {
+		_, err := d.Exec("update coins set user_default = false")
+		Expect(err).NotTo(HaveOccurred())
+		for _, name := range []string{testCoinName, otherCoinName} {
+			_, err := d.Exec(
+				"insert into coins (name, short_name, enabled, user_default) values ($1, $2, true, true)",
+				"Testing "+name, name,
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	for _, c := range []struct {
+		label   string
+		payload string
+		coins   []string
+		hidden  []string
+	}{
+		{
+			"should create wallets of all default coins",
+			fmt.Sprintf(`{"user_phone": "%s"}`, userPhone),
+			[]string{otherCoinName, testCoinName},
+			[]string{},
+		},
+		{
+			"should skip coins user has opted out of during registration",
+			fmt.Sprintf(`{"user_phone": "%s", "hidden_coins": ["other"]}`, userPhone),
+			[]string{testCoinName},
+			[]string{otherCoinName},
+		},
+		{
+			"should ignore unknown opted out coins",
+			fmt.Sprintf(`{"user_phone": "%s", "hidden_coins": ["nope", "other"]}`, userPhone),
+			[]string{testCoinName},
+			[]string{otherCoinName},
+		},
+		{
+			"should create all wallets if only unknown coins are opted out",
+			fmt.Sprintf(`{"user_phone": "%s", "hidden_coins": ["nope"]}`, userPhone),
+			[]string{otherCoinName, testCoinName},
+			[]string{},
+		},
+	} {
+		c := c
+		ItD(c.label, func(d *db.Db, api *wallets.Api, userSettings settings.ISettings) {
+			handler := users.RegistrationCompletedFactory(d, api, userSettings, logrus.New())
+			register(handler, c.payload)
+			Expect(walletsCoins(d)).To(Equal(c.coins))
+
+			s, err := userSettings.Get(context.Background(), userPhone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect([]string(s.HiddenCoins)).To(Equal(c.hidden))
+		})
+	}
+
+	ItD(
+		"should respect stored opt-out on event redelivery",
+		func(d *db.Db, api *wallets.Api, userSettings settings.ISettings) {
+			_, err := userSettings.Update(context.Background(), userPhone, settings.UpdateParams{
+				HiddenCoins: []string{testCoinName},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			handler := users.RegistrationCompletedFactory(d, api, userSettings, logrus.New())
+			register(handler, fmt.Sprintf(`{"user_phone": "%s"}`, userPhone))
+			Expect(walletsCoins(d)).To(Equal([]string{otherCoinName}))
+		},
+	)
+})
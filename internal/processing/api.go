@@ -3,6 +3,7 @@ package processing
 import (
 	"context"
 	"errors"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/wallet-api/db"
 	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
 	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
@@ -13,6 +14,8 @@ import (
 	"github.com/ericlagergren/decimal/sql/postgres"
 	"github.com/jinzhu/gorm"
 	. "github.com/opentracing/opentracing-go"
+	"strings"
+	"time"
 )
 
 var (
@@ -36,6 +39,21 @@ var (
 
 	// ErrInvalidAddress external address are invalid
 	ErrInvalidAddress = errors.New("processing: invalid external address")
+
+	// ErrWatchOnlyWallet returned on attempt to send funds from watch-only wallet
+	ErrWatchOnlyWallet = errors.New("processing: watch-only wallet can't send funds")
+
+	// ErrExecuteAtInPast returned on attempt to schedule tx at the moment which has passed
+	ErrExecuteAtInPast = errors.New("processing: tx execution time is in the past")
+
+	// ErrNoSuchTx returned when no tx found which satisfies given conditions
+	ErrNoSuchTx = errors.New("processing: no such tx")
+
+	// ErrTxNotScheduled returned on attempt to cancel tx which isn't scheduled
+	ErrTxNotScheduled = errors.New("processing: tx isn't scheduled")
+
+	// ErrEmptyBatch returned on attempt to send batch without items
+	ErrEmptyBatch = errors.New("processing: empty batch")
 )
 
 type InternalTxRecipientType int
@@ -83,9 +101,43 @@ type IApi interface {
 		fallbackCandidate ...TxRecipientCandidate,
 	) (newTx *Tx, err error)
 
+	// Schedule creates tx which will be sent since given execution time, tx amount is reserved at scheduling time only
+	// if schedule requires that. Recipient candidates are applied the same way as by Send.
+	Schedule(
+		ctx context.Context,
+		wallet *queries.Wallet,
+		recipient TxRecipientCandidate,
+		amount *decimal.Big,
+		schedule Schedule,
+		fallbackCandidate ...TxRecipientCandidate,
+	) (newTx *Tx, err error)
+
+	// SendBatch sends batch of payouts from the wallet. Total amount is validated against wallet balance once, so
+	// batch is either rejected as a whole or all it's txs are created and linked to the batch record. Each tx is
+	// processed as by Send, result describes item tx and it's validation errors. If coin supports multi-output txs,
+	// external txs of the batch are sent as single blockchain tx.
+	SendBatch(ctx context.Context, wallet *queries.Wallet, items []BatchItem) (
+		batch *TxBatch, results []BatchItemResult, err error,
+	)
+
+	// SendQueuedWithdrawals sends external txs queued for batch using single multi-output tx per coin, blockchain fee
+	// is split between txs proportionally to their amounts. Returns number of sent txs.
+	SendQueuedWithdrawals(ctx context.Context) (sentNum int, err error)
+
+	// ExecuteDueScheduled executes scheduled txs which execution time has come, returns number of executed txs. Txs
+	// which failed validation are declined and counted as executed.
+	ExecuteDueScheduled(ctx context.Context) (executedNum int, err error)
+
+	// CancelScheduled cancels scheduled tx sent by user with given phone. Returns ErrNoSuchTx if user hasn't sent such
+	// tx and ErrTxNotScheduled if tx isn't scheduled.
+	CancelScheduled(ctx context.Context, userPhone string, txID int64) (tx *Tx, err error)
+
 	// GetTxsesSum get sum of outgoing and incoming transactions for specified wallet
 	GetTxsesSum(ctx context.Context, wallet *queries.Wallet) (sum *decimal.Big, err error)
 
+	// GetWalletAddresses returns current wallet address followed by historical ones
+	GetWalletAddresses(ctx context.Context, wallet *queries.Wallet) (addresses []string, err error)
+
 	// NotifyUserCreatesWallet lookups pending transactions which waits wallet of this user and perform transactions.
 	// Returns ErrNoOneTxAwaitsWallet if no one affected.
 	NotifyUserCreatesWallet(ctx context.Context, wallet *queries.Wallet) error
@@ -95,23 +147,36 @@ type IApi interface {
 type Api struct {
 	database      *gorm.DB
 	balanceHelper helpers.IBalance
+	balanceCache  helpers.IBalanceCache
 	notificator   isc.ITxsEventNotificator
 	coordinator   nodes.ICoordinator
+
+	// batchedCoins are coins which external txs are queued to be sent by batches
+	batchedCoins map[string]bool
 }
 
-// New
+// New creates processing api, external txs of batched coins are queued and sent by SendQueuedWithdrawals, coin is
+// batched only if it's node supports multi-output txs, otherwise txs are sent immediately
 func New(
 	db *gorm.DB,
 	balanceHelper helpers.IBalance,
+	balanceCache helpers.IBalanceCache,
 	notificator isc.ITxsEventNotificator,
 	coordinator nodes.ICoordinator,
+	batchedCoins ...string,
 ) IApi {
-	return &Api{
+	api := &Api{
 		database:      db,
 		balanceHelper: balanceHelper,
+		balanceCache:  balanceCache,
 		notificator:   notificator,
 		coordinator:   coordinator,
+		batchedCoins:  make(map[string]bool, len(batchedCoins)),
 	}
+	for _, coinName := range batchedCoins {
+		api.batchedCoins[strings.ToUpper(coinName)] = true
+	}
+	return api
 }
 
 // SendExternal implements IApi interface
@@ -129,6 +194,11 @@ func (api *Api) Send(
 			"amount", amount,
 		)
 
+		// watch-only wallets address isn't controlled by the system
+		if wallet.WatchOnly {
+			return ErrWatchOnlyWallet
+		}
+
 		// check most common amount errors
 		err := checkAmount(amount)
 		if err != nil {
@@ -175,6 +245,10 @@ func (api *Api) Send(
 		if err != nil {
 			return err
 		}
+
+		// both sides balances has been changed
+		api.invalidateBalances(ctx, newTx)
+
 		if validationErrs != nil {
 			trace.LogMsg(span, "validation errs occurs")
 			return validationErrs
@@ -184,9 +258,259 @@ func (api *Api) Send(
 	return
 }
 
+// Schedule implements IApi interface
+func (api *Api) Schedule(
+	ctx context.Context,
+	wallet *queries.Wallet,
+	candidate TxRecipientCandidate,
+	amount *decimal.Big,
+	schedule Schedule,
+	fallbackCandidate ...TxRecipientCandidate,
+) (newTx *Tx, err error) {
+	err = trace.InsideSpanE(ctx, "schedule_tx", func(ctx context.Context, span Span) error {
+		span.LogKV(
+			"from_wallet_id", wallet.ID,
+			"coin", wallet.Coin.ShortName,
+			"amount", amount,
+			"execute_at", schedule.ExecuteAt,
+			"hold_funds", schedule.HoldFunds,
+		)
+
+		// watch-only wallets address isn't controlled by the system
+		if wallet.WatchOnly {
+			return ErrWatchOnlyWallet
+		}
+
+		// check most common amount errors
+		err := checkAmount(amount)
+		if err != nil {
+			return err
+		}
+
+		executeAt := schedule.ExecuteAt.UTC()
+		if !executeAt.After(time.Now().UTC()) {
+			return ErrExecuteAtInPast
+		}
+
+		pTx := applyTxCandidate(
+			&Tx{
+				FromWalletID: wallet.ID,
+				FromWallet:   wallet,
+				Amount:       &Decimal{V: amount},
+				Type:         TxTypeInternal,
+				ExecuteAt:    &executeAt,
+				HoldFunds:    schedule.HoldFunds,
+			},
+			candidate,
+		)
+		for _, c := range fallbackCandidate {
+			pTx = applyTxCandidate(pTx, c)
+		}
+		if pTx.IsSelfTx() {
+			return ErrSelfTxForbidden
+		}
+
+		err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			// amount is reserved right now, so it must be available. Wallet is locked until the tx is created, so
+			// concurrent schedules of the same wallet can't hold the same funds twice
+			if schedule.HoldFunds {
+				err := dbTx.Exec("select 1 from wallets where id = ? for update", wallet.ID).Error
+				if err != nil {
+					return err
+				}
+
+				balance, err := api.balanceHelper.TotalWalletBalanceCtx(ctx, wallet)
+				if err != nil {
+					return err
+				}
+				span.LogKV("wallet_total_balance", balance)
+				if balance.Cmp(amount) < 0 {
+					return ErrInsufficientFunds
+				}
+			}
+
+			// query status explicitly, no clear way with gorm :(
+			var stateModel TxStatus
+			err := dbTx.Model(&stateModel).Where("name = ?", TxStateScheduled).First(&stateModel).Error
+			if err != nil {
+				return err
+			}
+			pTx.Status = &stateModel
+
+			return dbTx.Create(pTx).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		newTx = pTx
+		span.LogKV("new_tx_id", pTx.ID)
+
+		if schedule.HoldFunds {
+			api.invalidateBalances(ctx, newTx)
+		}
+		return nil
+	})
+	return
+}
+
+// ExecuteDueScheduled implements IApi interface
+func (api *Api) ExecuteDueScheduled(ctx context.Context) (executedNum int, err error) {
+	span, ctx := StartSpanFromContext(ctx, "execute_due_scheduled")
+	defer span.Finish()
+
+	var dueIDs []int64
+	err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Model(&Tx{}).Where(
+			"status_id = (select id from tx_statuses where name = ?) and execute_at <= ?",
+			TxStateScheduled, time.Now().UTC(),
+		).Order("execute_at").Pluck("id", &dueIDs).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+
+	span.LogKV("due_txs_num", len(dueIDs))
+
+	// failed tx doesn't prevent others execution
+	for _, id := range dueIDs {
+		executed, execErr := api.executeScheduled(ctx, id)
+		if execErr != nil {
+			trace.LogErrorWithMsg(span, execErr, "scheduled tx execution failed")
+			err = merrors.Append(err, execErr)
+			continue
+		}
+		if executed {
+			executedNum++
+		}
+	}
+	span.LogKV("executed_txs_num", executedNum)
+	return
+}
+
+// executeScheduled steps scheduled tx with given id unless it has been canceled or executed meanwhile
+func (api *Api) executeScheduled(ctx context.Context, id int64) (executed bool, err error) {
+	span, ctx := StartSpanFromContext(ctx, "execute_scheduled")
+	defer span.Finish()
+
+	span.LogKV("tx_id", id)
+
+	var (
+		tx             Tx
+		validationErrs error
+	)
+	err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		// lock tx, so it won't be executed twice by concurrent workers or canceled while executed
+		err := dbTx.Exec("select 1 from txs where id = ? for update", id).Error
+		if err != nil {
+			return err
+		}
+
+		err = dbTx.Where("id = ?", id).Preload(
+			"FromWallet",
+		).Preload(
+			"FromWallet.Coin",
+		).Preload(
+			"ToWallet",
+		).Preload(
+			"Status",
+		).First(&tx).Error
+		if err != nil {
+			return err
+		}
+		if tx.StateName() != TxStateScheduled {
+			trace.LogMsg(span, "tx isn't scheduled anymore")
+			return nil
+		}
+
+		executed = true
+		_, validationErrs, err = StepTx(ctx, dbTx, &tx, api.createExternalResources(), tx.FromWallet.Secret)
+		return err
+	})
+	if err != nil || !executed {
+		return
+	}
+
+	api.invalidateBalances(ctx, &tx)
+
+	// user isn't waiting for the response, so declined tx should be reported
+	if validationErrs != nil {
+		trace.LogErrorWithMsg(span, validationErrs, "scheduled tx has been declined")
+		notifErr := api.notificator.Declined(txEventPayload(&tx), validationErrs)
+		if notifErr != nil {
+			trace.LogErrorWithMsg(span, notifErr, "declined notification failed")
+		}
+	}
+	return
+}
+
+// CancelScheduled implements IApi interface
+func (api *Api) CancelScheduled(ctx context.Context, userPhone string, txID int64) (tx *Tx, err error) {
+	span, ctx := StartSpanFromContext(ctx, "cancel_scheduled")
+	defer span.Finish()
+
+	span.LogKV("user_phone", userPhone, "tx_id", txID)
+
+	err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		// lock tx, so it won't be executed meanwhile
+		err := dbTx.Exec("select 1 from txs where id = ? for update", txID).Error
+		if err != nil {
+			return err
+		}
+
+		var found Tx
+		err = dbTx.Where(
+			"id = ? and from_wallet_id in (select id from wallets where user_phone = ?)", txID, userPhone,
+		).Preload(
+			"FromWallet",
+		).Preload(
+			"FromWallet.Coin",
+		).Preload(
+			"ToWallet",
+		).Preload(
+			"Status",
+		).First(&found).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrNoSuchTx
+		}
+		if err != nil {
+			return err
+		}
+		if found.StateName() != TxStateScheduled {
+			return ErrTxNotScheduled
+		}
+
+		var stateModel TxStatus
+		err = dbTx.Model(&stateModel).Where("name = ?", TxStateCanceled).First(&stateModel).Error
+		if err != nil {
+			return err
+		}
+		found.Status = &stateModel
+		found.StatusID = stateModel.ID
+		err = dbTx.Model(&found).Update(&found).Error
+		if err != nil {
+			return err
+		}
+
+		tx = &found
+		return nil
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+
+	// held amount is released
+	if tx.HoldFunds {
+		api.invalidateBalances(ctx, tx)
+	}
+	return
+}
+
 // aggregateTxsesQuery used to calculate txs sum for input and output separately
 // input is all internal and external transactions where destination wallet is given wallet, output is all
-// internal transactions where source wallet is given wallet
+// internal transactions where source wallet is given wallet, scheduled txs are counted only if they hold funds
 const aggregateTxsesQuery = `with income as (select coalesce(sum(txs.amount), 0) as val
                 from txs
                 where to_wallet_id = $1 and
@@ -196,7 +520,8 @@ const aggregateTxsesQuery = `with income as (select coalesce(sum(txs.amount), 0)
                  from txs
                  where from_wallet_id = $1 and
 					status_id not in 
-						(select id from tx_statuses where name = ANY('{cancel, decline}' :: varchar(30) [])))
+						(select id from tx_statuses where name = ANY('{cancel, decline}' :: varchar(30) [])) and
+					(hold_funds or status_id <> (select id from tx_statuses where name = 'scheduled')))
 select income.val - outcome.val as sum, income.val as income, outcome.val as outcome
 from income, outcome;`
 
@@ -249,13 +574,40 @@ func (api *Api) GetTxsesSum(ctx context.Context, wallet *queries.Wallet) (sum *d
 	return
 }
 
+// GetWalletAddresses implements IApi interface
+func (api *Api) GetWalletAddresses(ctx context.Context, wallet *queries.Wallet) (addresses []string, err error) {
+	span, ctx := StartSpanFromContext(ctx, "wallet_addresses")
+	defer span.Finish()
+
+	span.LogKV("wallet_id", wallet.ID)
+
+	loaded := *wallet
+	err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Where("wallet_id = ?", wallet.ID).Order("id").Find(&loaded.Addresses).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+
+	addresses = loaded.AllAddresses()
+	span.LogKV("addresses_num", len(addresses))
+	return
+}
+
 // NotifyUserCreatesWallet implements IApi interface
 func (api *Api) NotifyUserCreatesWallet(ctx context.Context, wallet *queries.Wallet) (err error) {
 	span, ctx := StartSpanFromContext(ctx, "notify_wallet_created")
 	defer span.Finish()
 
-	span.LogKV("wallet_id", wallet.ID, "wallet_coin", wallet.Coin.ShortName)
+	span.LogKV("wallet_id", wallet.ID, "wallet_coin", wallet.Coin.ShortName, "is_default", wallet.IsDefault)
+
+	// txs which await recipient are delivered only to the default wallet of their coin
+	if !wallet.IsDefault {
+		return
+	}
 
+	var txsToUpdate []*Tx
 	err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, dbTx *gorm.DB) (err error) {
 		// query status explicitly, no clear way with gorm :(
 		var stateModel TxStatus
@@ -277,7 +629,6 @@ func (api *Api) NotifyUserCreatesWallet(ctx context.Context, wallet *queries.Wal
 
 		// lookup tx which awaits phone number associated with this wallet
 		// then select (cannot do it in single query)
-		var txsToUpdate []*Tx
 		err = dbTx.Model(&Tx{}).Joins(
 			"inner join wallets on txs.from_wallet_id = wallets.id",
 		).Where(
@@ -301,14 +652,71 @@ func (api *Api) NotifyUserCreatesWallet(ctx context.Context, wallet *queries.Wal
 		}
 		return
 	})
+	if err != nil {
+		return
+	}
+
+	api.invalidateBalances(ctx, txsToUpdate...)
 	return
 }
 
+// invalidateBalances drops cached balances of both sides of given txs, errors are only logged because cached
+// balances will be outdated by ttl anyway
+func (api *Api) invalidateBalances(ctx context.Context, txs ...*Tx) {
+	if api.balanceCache == nil {
+		return
+	}
+
+	ids := make([]int64, 0, len(txs)*2)
+	for _, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		if tx.FromWalletID != 0 {
+			ids = append(ids, tx.FromWalletID)
+		} else if tx.FromWallet != nil {
+			ids = append(ids, tx.FromWallet.ID)
+		}
+		if tx.ToWalletID != nil {
+			ids = append(ids, *tx.ToWalletID)
+		} else if tx.ToWallet != nil {
+			ids = append(ids, tx.ToWallet.ID)
+		}
+	}
+
+	trace.InsideSpan(ctx, "invalidating_balances", func(ctx context.Context, span Span) {
+		span.LogKV("wallets_ids", ids)
+		err := api.balanceCache.Invalidate(ctx, ids...)
+		if err != nil {
+			trace.LogErrorWithMsg(span, err, "balances invalidation failed")
+		}
+	})
+}
+
+// txEventPayload fills tx event payload using given tx
+func txEventPayload(tx *Tx) isc.TxEventPayload {
+	payload := isc.TxEventPayload{
+		Coin:           tx.CoinName(),
+		Type:           string(tx.Type),
+		FromPhone:      tx.FromWallet.UserPhone,
+		FromWalletName: tx.FromWallet.Name,
+		Amount:         tx.Amount.V,
+	}
+	if tx.ToPhone != nil {
+		payload.ToPhone = *tx.ToPhone
+	}
+	if tx.ToAddress != nil {
+		payload.ToAddress = *tx.ToAddress
+	}
+	return payload
+}
+
 func (api *Api) createExternalResources() *smResources {
 	return &smResources{
 		BalanceHelper:      api.balanceHelper,
 		TxEventNotificator: api.notificator,
 		Coordinator:        api.coordinator,
+		BatchedCoins:       api.batchedCoins,
 	}
 }
 
@@ -0,0 +1,368 @@
+package processing
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	. "github.com/opentracing/opentracing-go"
+	"strings"
+)
+
+// batchOutputs collects external txs of the batch, which are sent by single multi-output tx after all batch txs are
+// stepped
+type batchOutputs struct {
+	txs []*Tx
+}
+
+// SendBatch implements IApi interface
+func (api *Api) SendBatch(ctx context.Context, wallet *queries.Wallet, items []BatchItem) (
+	batch *TxBatch, results []BatchItemResult, err error,
+) {
+	err = trace.InsideSpanE(ctx, "send_batch", func(ctx context.Context, span Span) error {
+		span.LogKV(
+			"from_wallet_id", wallet.ID,
+			"coin", wallet.Coin.ShortName,
+			"items_num", len(items),
+		)
+
+		// watch-only wallets address isn't controlled by the system
+		if wallet.WatchOnly {
+			return ErrWatchOnlyWallet
+		}
+		if len(items) == 0 {
+			return ErrEmptyBatch
+		}
+
+		// check most common amount errors
+		total := new(decimal.Big)
+		var validationErrs error
+		for _, item := range items {
+			amountErr := checkAmount(item.Amount)
+			if amountErr != nil {
+				validationErrs = merrors.Append(validationErrs, amountErr)
+				continue
+			}
+			total.Add(total, item.Amount)
+		}
+		if validationErrs != nil {
+			return validationErrs
+		}
+		span.LogKV("total_amount", total)
+
+		// total is checked once, so batch is either accepted as a whole or rejected
+		err := api.checkBatchTotal(ctx, wallet, total)
+		if err != nil {
+			return err
+		}
+
+		res := api.createExternalResources()
+		if api.coordinator.BatchTxsSender(wallet.Coin.ShortName) != nil {
+			res.BatchOutputs = &batchOutputs{}
+		}
+
+		err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			batch = &TxBatch{WalletID: wallet.ID, TotalAmount: &Decimal{V: total}}
+			err := dbTx.Create(batch).Error
+			if err != nil {
+				return err
+			}
+			span.LogKV("batch_id", batch.ID)
+
+			// query status explicitly, no clear way with gorm :(
+			var stateModel TxStatus
+			err = dbTx.Model(&stateModel).Where("name = ?", TxStateValidate).First(&stateModel).Error
+			if err != nil {
+				return err
+			}
+
+			results = make([]BatchItemResult, len(items))
+			for i, item := range items {
+				pTx := applyTxCandidate(
+					&Tx{
+						FromWallet: wallet,
+						Amount:     &Decimal{V: item.Amount},
+						Status:     &stateModel,
+						Type:       TxTypeInternal,
+						BatchID:    &batch.ID,
+					},
+					item.Recipient,
+				)
+				for _, c := range item.FallbackCandidates {
+					pTx = applyTxCandidate(pTx, c)
+				}
+
+				err = dbTx.Create(pTx).Error
+				if err != nil {
+					return err
+				}
+
+				var itemErrs error
+				results[i].Tx, itemErrs, err = StepTx(ctx, dbTx, pTx, res, wallet.Secret)
+				if err != nil {
+					return err
+				}
+				results[i].Err = itemErrs
+			}
+
+			if res.BatchOutputs == nil || len(res.BatchOutputs.txs) == 0 {
+				return nil
+			}
+			sendErrs, err := api.sendBatchOutputs(ctx, dbTx, wallet, res.BatchOutputs.txs)
+			if err != nil || sendErrs == nil {
+				return err
+			}
+			for i := range results {
+				if results[i].Err == nil && results[i].Tx.StateName() == TxStateDeclined {
+					results[i].Err = sendErrs
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// all sides balances has been changed
+		txs := make([]*Tx, 0, len(results))
+		for _, r := range results {
+			txs = append(txs, r.Tx)
+		}
+		api.invalidateBalances(ctx, txs...)
+		return nil
+	})
+	return
+}
+
+// checkBatchTotal checks that batch total amount doesn't exceed both wallet and node account balances
+func (api *Api) checkBatchTotal(ctx context.Context, wallet *queries.Wallet, total *decimal.Big) (validationErrs error) {
+	generalBalance, err := api.balanceHelper.AccountBalanceCtx(ctx, wallet.Coin.ShortName)
+	if err != nil {
+		return err
+	}
+	if generalBalance.Cmp(total) < 0 {
+		validationErrs = merrors.Append(validationErrs, ErrTxAmountToBig)
+	}
+
+	walletBalance, err := api.balanceHelper.TotalWalletBalanceCtx(ctx, wallet)
+	if err != nil {
+		return err
+	}
+	if walletBalance.Cmp(total) < 0 {
+		validationErrs = merrors.Append(validationErrs, ErrInsufficientFunds)
+	}
+	return
+}
+
+// sendBatchOutputs sends collected external txs using single multi-output tx, blockchain fee is split between them
+// proportionally to their amounts. Node wallet is shared by all wallets of the coin, so given wallet is used only to
+// pass it's address and secret. Txs with invalid addresses are declined before sending, so they don't prevent others,
+// if node still rejects some address, all txs are declined. Validation error returned if any tx is declined.
+func (api *Api) sendBatchOutputs(ctx context.Context, dbTx *gorm.DB, wallet *queries.Wallet, txs []*Tx) (
+	validateErrs error, err error,
+) {
+	validator := api.coordinator.AddressValidator(wallet.Coin.ShortName)
+	valid := make([]*Tx, 0, len(txs))
+	for _, tx := range txs {
+		// coins which can't validate addresses rely on the node
+		if validator.ValidateAddress(*tx.ToAddress) != nodes.ErrAddressInvalid {
+			valid = append(valid, tx)
+			continue
+		}
+		validateErrs = ErrInvalidAddress
+		err = updateTxState(dbTx, tx, TxStateDeclined)
+		if err != nil {
+			return
+		}
+	}
+	if len(valid) == 0 {
+		return
+	}
+	txs = valid
+
+	outputs := make([]nodes.TxOutput, 0, len(txs))
+	for _, tx := range txs {
+		outputs = append(outputs, nodes.TxOutput{Address: *tx.ToAddress, Amount: tx.Amount.V})
+	}
+
+	var (
+		txHash string
+		fee    *decimal.Big
+	)
+	err = trace.InsideSpanE(ctx, "sending_batch_tx", func(ctx context.Context, span Span) error {
+		span.LogKV("outputs_num", len(outputs))
+
+		var err error
+		txHash, fee, err = api.coordinator.BatchTxsSender(wallet.Coin.ShortName).SendBatch(
+			ctx, wallet.Address, outputs, wallet.Secret,
+		)
+		return err
+	})
+
+	sent := true
+	newState := TxStateAwaitConfirmations
+	if err == nodes.ErrAddressInvalid {
+		// return as validation err rather the ordinal error to save these transactions in txs history
+		err = nil
+		validateErrs = ErrInvalidAddress
+		newState = TxStateDeclined
+		sent = false
+	}
+	if err != nil {
+		return
+	}
+
+	fees := splitFee(fee, txs)
+	for i, tx := range txs {
+		if sent {
+			err = dbTx.Create(&TxExternal{
+				Tx:        tx,
+				Hash:      txHash,
+				Recipient: *tx.ToAddress,
+			}).Error
+			if err != nil {
+				return
+			}
+			tx.BlockchainFee = fees[i]
+		}
+
+		err = updateTxState(dbTx, tx, newState)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// splitFee splits multi-output tx fee between txs proportionally to their amounts, shares are rounded to the fee
+// scale and the rest left after rounding is added to the first one
+func splitFee(fee *decimal.Big, txs []*Tx) []*Decimal {
+	fees := make([]*Decimal, len(txs))
+	if fee == nil || len(txs) == 0 {
+		return fees
+	}
+
+	total := new(decimal.Big)
+	for _, tx := range txs {
+		total.Add(total, tx.Amount.V)
+	}
+
+	rest := new(decimal.Big).Set(fee)
+	for i, tx := range txs {
+		share := new(decimal.Big)
+		if total.Sign() > 0 {
+			share.Mul(fee, tx.Amount.V).Quo(share, total).Quantize(fee.Scale())
+		}
+		rest.Sub(rest, share)
+		fees[i] = &Decimal{V: share}
+	}
+	fees[0].V.Add(fees[0].V, rest)
+	return fees
+}
+
+// updateTxState saves tx in given state
+func updateTxState(dbTx *gorm.DB, tx *Tx, state string) error {
+	var stateModel TxStatus
+	err := dbTx.Model(&stateModel).Where("name = ?", state).First(&stateModel).Error
+	if err != nil {
+		return err
+	}
+	tx.Status = &stateModel
+	tx.StatusID = stateModel.ID
+	return dbTx.Model(tx).Update(tx).Error
+}
+
+// SendQueuedWithdrawals implements IApi interface
+func (api *Api) SendQueuedWithdrawals(ctx context.Context) (sentNum int, err error) {
+	span, ctx := StartSpanFromContext(ctx, "send_queued_withdrawals")
+	defer span.Finish()
+
+	// failed coin doesn't prevent others withdrawals
+	for coinName := range api.batchedCoins {
+		coinSentNum, sendErr := api.sendQueuedCoinWithdrawals(ctx, coinName)
+		if sendErr != nil {
+			trace.LogErrorWithMsg(span, sendErr, "queued withdrawals sending failed")
+			err = merrors.Append(err, sendErr)
+			continue
+		}
+		sentNum += coinSentNum
+	}
+	span.LogKV("sent_txs_num", sentNum)
+	return
+}
+
+// sendQueuedCoinWithdrawals sends all withdrawals of the coin queued for batch using single multi-output tx
+func (api *Api) sendQueuedCoinWithdrawals(ctx context.Context, coinName string) (sentNum int, err error) {
+	span, ctx := StartSpanFromContext(ctx, "send_queued_coin_withdrawals")
+	defer span.Finish()
+
+	span.LogKV("coin", coinName)
+
+	if api.coordinator.BatchTxsSender(coinName) == nil {
+		trace.LogMsg(span, "coin doesn't support multi-output txs")
+		return
+	}
+
+	var (
+		txs            []*Tx
+		validationErrs error
+	)
+	err = db.TransactionCtx(ctx, api.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		// lock queued txs, so they won't be sent twice by concurrent workers
+		var ids []int64
+		err := dbTx.Model(&Tx{}).Joins(
+			"inner join wallets on wallets.id = txs.from_wallet_id",
+		).Where(
+			"txs.status_id = (select id from tx_statuses where name = ?) and "+
+				"wallets.coin_id = (select id from coins where short_name = ?)",
+			TxStateQueuedForBatch, strings.ToUpper(coinName),
+		).Order("txs.id").Set("gorm:query_option", "FOR UPDATE OF txs").Pluck("txs.id", &ids).Error
+		if err != nil {
+			return err
+		}
+
+		span.LogKV("queued_txs_num", len(ids))
+		if len(ids) == 0 {
+			return nil
+		}
+
+		err = dbTx.Where("id in (?)", ids).Preload(
+			"FromWallet",
+		).Preload(
+			"FromWallet.Coin",
+		).Preload(
+			"Status",
+		).Order("id").Find(&txs).Error
+		if err != nil {
+			return err
+		}
+
+		validationErrs, err = api.sendBatchOutputs(ctx, dbTx, txs[0].FromWallet, txs)
+		return err
+	})
+	if err != nil || len(txs) == 0 {
+		return
+	}
+
+	api.invalidateBalances(ctx, txs...)
+
+	// users aren't waiting for the response, so declined txs should be reported
+	if validationErrs != nil {
+		trace.LogErrorWithMsg(span, validationErrs, "queued withdrawals have been declined")
+	}
+	for _, tx := range txs {
+		if tx.StateName() != TxStateDeclined {
+			sentNum++
+			continue
+		}
+		notifErr := api.notificator.Declined(txEventPayload(tx), validationErrs)
+		if notifErr != nil {
+			trace.LogErrorWithMsg(span, notifErr, "declined notification failed")
+		}
+	}
+	return
+}
@@ -2,9 +2,11 @@ package processing
 
 import (
 	"database/sql/driver"
+	"strings"
 	"time"
 
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/ericlagergren/decimal"
 	"github.com/ericlagergren/decimal/sql/postgres"
 )
 
@@ -28,6 +30,7 @@ const (
 
 // Tx states
 const (
+	TxStateScheduled          = "scheduled"
 	TxStateValidate           = "validation"
 	TxStateExternalSending    = "send_external"
 	TxStateDeclined           = "decline"
@@ -35,6 +38,9 @@ const (
 	TxStateAwaitRecipient     = "pending"
 	TxStateAwaitConfirmations = "waiting"
 	TxStateProcessed          = "success"
+
+	// TxStateQueuedForBatch external tx awaits to be sent by single multi-output tx with other queued withdrawals
+	TxStateQueuedForBatch = "queued_for_batch"
 )
 
 // Decimal is a PostgreSQL DECIMAL. Its zero value is valid for use with both
@@ -76,6 +82,22 @@ type Tx struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
+	// ExecuteAt is the moment since which scheduled tx may be executed, nil for txs which are executed immediately
+	ExecuteAt *time.Time
+
+	// HoldFunds indicates that scheduled tx holds it's amount since scheduling
+	HoldFunds bool
+
+	// BatchID links tx sent as a part of payouts batch
+	BatchID *int64
+
+	// FiatAmount and FiatCurrency hold the value tx amount has been quoted at, set only for txs sent in fiat amount
+	FiatAmount   *Decimal
+	FiatCurrency *string
+
+	// FiatValues holds fiat rates captured at tx creation and confirmation
+	FiatValues []TxFiatValue `gorm:"foreignkey:TxID;association_autoupdate:false;association_autocreate:false"`
+
 	StatusID int64
 	Status   *TxStatus `gorm:"foreignkey:StatusID;association_autoupdate:false;association_autocreate:false"`
 
@@ -96,6 +118,22 @@ func (tx *Tx) CoinName() string {
 	return "<unknown>"
 }
 
+// FiatRate returns captured rate of tx coin to given fiat currency, rate captured at confirmation is preferred over
+// the one captured at creation. Returns nil if no rate captured for the currency, FiatValues must be loaded.
+func (tx *Tx) FiatRate(currency string) *decimal.Big {
+	var rate *decimal.Big
+	for _, value := range tx.FiatValues {
+		if !strings.EqualFold(value.Currency, currency) || value.Rate == nil {
+			continue
+		}
+		if value.Stage == FiatStageConfirmed {
+			return value.Rate.V
+		}
+		rate = value.Rate.V
+	}
+	return rate
+}
+
 // IsExternal
 func (tx *Tx) IsExternal() bool {
 	return tx.Type == TxTypeExternal
@@ -118,6 +156,8 @@ func (tx *Tx) IsHoldsAmount() bool {
 	switch tx.Status.Name {
 	case TxStateDeclined, TxStateCanceled:
 		return false
+	case TxStateScheduled:
+		return tx.HoldFunds
 	default:
 		return true
 	}
@@ -155,6 +195,27 @@ func (tx *Tx) IsSelfTx() bool {
 	return selfTxByPhone || selfTxByWallet
 }
 
+// Fiat value capture stages
+const (
+	FiatStageCreated   = "created"
+	FiatStageConfirmed = "confirmed"
+)
+
+// TxFiatValue is the rate of tx coin to fiat currency captured at some stage of tx life, so tx fiat equivalents
+// don't drift with the market
+type TxFiatValue struct {
+	ID        int64
+	TxID      int64
+	Stage     string
+	Currency  string
+	Rate      *Decimal
+	CreatedAt time.Time
+}
+
+func (TxFiatValue) TableName() string {
+	return "txs_fiat_values"
+}
+
 // ExternalTx represents external transaction row
 type TxExternal struct {
 	ID        int64
@@ -167,3 +228,38 @@ type TxExternal struct {
 func (TxExternal) TableName() string {
 	return "txs_external"
 }
+
+// Schedule describes when tx should be executed
+type Schedule struct {
+	ExecuteAt time.Time
+
+	// HoldFunds reserves tx amount since scheduling, so it can't be spent by other txs
+	HoldFunds bool
+}
+
+// TxBatch groups txs sent from the wallet by single payouts request
+type TxBatch struct {
+	ID          int64
+	WalletID    int64
+	TotalAmount *Decimal
+	CreatedAt   time.Time
+
+	Txs []Tx `gorm:"foreignkey:BatchID"`
+}
+
+func (TxBatch) TableName() string {
+	return "tx_batches"
+}
+
+// BatchItem describes single batch payout, recipient candidates are applied the same way as by Send
+type BatchItem struct {
+	Recipient          TxRecipientCandidate
+	FallbackCandidates []TxRecipientCandidate
+	Amount             *decimal.Big
+}
+
+// BatchItemResult describes single batch payout result, Err is set if tx has been declined
+type BatchItemResult struct {
+	Tx  *Tx
+	Err error
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
 	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
 	"github.com/jinzhu/gorm"
 	"github.com/jinzhu/gorm/dialects/postgres"
@@ -35,40 +36,64 @@ type ICheckOutdatedNotifier interface {
 // CheckOutdatedNotifier
 type CheckOutdatedNotifier struct {
 	database      *gorm.DB
+	balanceCache  helpers.IBalanceCache
 	timeToOutdate time.Duration
 }
 
 // NewCheckOutdatedNotifier
-func NewCheckOutdatedNotifier(db *gorm.DB, timeToOutdate time.Duration) ICheckOutdatedNotifier {
+func NewCheckOutdatedNotifier(
+	db *gorm.DB,
+	balanceCache helpers.IBalanceCache,
+	timeToOutdate time.Duration,
+) ICheckOutdatedNotifier {
 	return &CheckOutdatedNotifier{
 		database:      db,
+		balanceCache:  balanceCache,
 		timeToOutdate: timeToOutdate,
 	}
 }
 
 // OnCheckOutdated
 func (notifier *CheckOutdatedNotifier) OnCheckOutdated() error {
-	return db.TransactionCtx(context.Background(), notifier.database, func(ctx context.Context, tx *gorm.DB) error {
-		return tx.Exec(
+	var canceled []struct {
+		FromWalletID int64
+	}
+	err := db.TransactionCtx(context.Background(), notifier.database, func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Raw(
 			`update txs set status_id = (select id from tx_statuses where name = $1)
 			where to_wallet_id is null and to_address is null and to_phone is not null and 
-				  type = 'internal' and (updated_at < $2) and status_id = (select id from tx_statuses where name = $3);`,
+				  type = 'internal' and (updated_at < $2) and status_id = (select id from tx_statuses where name = $3)
+			returning from_wallet_id;`,
 			TxStateCanceled,
 			time.Now().UTC().Add(-notifier.timeToOutdate),
 			TxStateAwaitRecipient,
-		).Error
+		).Scan(&canceled).Error
 	})
+	if err != nil || len(canceled) == 0 || notifier.balanceCache == nil {
+		return err
+	}
+
+	// canceled amounts are available to spend again
+	ids := make([]int64, 0, len(canceled))
+	for _, c := range canceled {
+		ids = append(ids, c.FromWalletID)
+	}
+	return notifier.balanceCache.Invalidate(context.Background(), ids...)
 }
 
 // ConfirmationNotifier is IConfirmationNotifier implementation
 type ConfirmationNotifier struct {
-	database    *gorm.DB
-	coordinator nodes.ICoordinator
+	database     *gorm.DB
+	coordinator  nodes.ICoordinator
+	balanceCache helpers.IBalanceCache
 }
 
-// NewConfirmationsNotifier creates new confirmations notifier
-func NewConfirmationsNotifier(db *gorm.DB, coordinator nodes.ICoordinator) IConfirmationNotifier {
-	return &ConfirmationNotifier{database: db, coordinator: coordinator}
+// NewConfirmationsNotifier creates new confirmations notifier, balances of wallets which txs are confirmed or
+// abandoned are invalidated using balance cache if it's given
+func NewConfirmationsNotifier(
+	db *gorm.DB, coordinator nodes.ICoordinator, balanceCache helpers.IBalanceCache,
+) IConfirmationNotifier {
+	return &ConfirmationNotifier{database: db, coordinator: coordinator, balanceCache: balanceCache}
 }
 
 // OnNewConfirmation implements IConfirmationNotifier
@@ -87,7 +112,7 @@ func (notifier *ConfirmationNotifier) watchConfirmations(ctx context.Context, co
 	var pendingExternalTxs []TxExternal
 	// query all pending external txs
 	err := db.TransactionCtx(ctx, notifier.database, func(ctx context.Context, dbTx *gorm.DB) error {
-		return dbTx.Model(&TxExternal{}).Joins(
+		return dbTx.Model(&TxExternal{}).Preload("Tx").Joins(
 			"inner join txs on txs.id = txs_external.tx_id",
 		).Joins(
 			`inner join wallets on (
@@ -188,7 +213,26 @@ func (notifier *ConfirmationNotifier) watchConfirmations(ctx context.Context, co
 	if err != nil {
 		return errors.Wrap(err, "error occurs while updating transactions statuses")
 	}
-	return nil
+	if notifier.balanceCache == nil {
+		return nil
+	}
+
+	// held and pending amounts of changed txs wallets are changed as well
+	changedTxsIDs := make(map[int64]bool, len(confirmedTxsIDs)+len(abandonedTxsIDs))
+	for _, id := range append(confirmedTxsIDs, abandonedTxsIDs...) {
+		changedTxsIDs[id] = true
+	}
+	walletsIDs := make([]int64, 0, 2*len(changedTxsIDs))
+	for _, txExt := range pendingExternalTxs {
+		if !changedTxsIDs[txExt.TxID] || txExt.Tx == nil {
+			continue
+		}
+		walletsIDs = append(walletsIDs, txExt.Tx.FromWalletID)
+		if txExt.Tx.ToWalletID != nil {
+			walletsIDs = append(walletsIDs, *txExt.Tx.ToWalletID)
+		}
+	}
+	return notifier.balanceCache.Invalidate(ctx, walletsIDs...)
 }
 
 func updateTxsStatus(dbTx *gorm.DB, ids []int64, newStatusName string) error {
@@ -276,8 +320,10 @@ data as (
     v.address as addr,
     row_number() OVER (ORDER BY id) AS rn
   from wallets as w
-  inner join values as v on w.address = v.address
-  where w.coin_id = (select id from coins where short_name = $5)
+  inner join values as v on w.address = v.address or exists(
+    select 1 from wallet_addresses as wa where wa.wallet_id = w.id and wa.address = v.address
+  )
+  where w.coin_id = (select id from coins where short_name = $5) and not w.watch_only
 ),
 inserted as (
   insert into txs (to_wallet_id, type, amount, status_id) select wid, t, a, sid from data
@@ -16,9 +16,11 @@ import (
 	"github.com/ericlagergren/decimal"
 	"github.com/ericlagergren/decimal/sql/postgres"
 	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/mock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"strings"
+	"time"
 	"git.zam.io/wallet-backend/wallet-api/internal/helpers/balance"
 	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
 	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes/mocks"
@@ -104,7 +106,7 @@ var _ = Describe("testing txs processing", func() {
 		d *gorm.DB, notificator isc.ITxsEventNotificator, coordinator nodes.ICoordinator,
 	) (processing.IApi, helpers.IBalance) {
 		balanceHelper := balance.New(coordinator, nil)
-		p := processing.New(d, balanceHelper, notificator, coordinator)
+		p := processing.New(d, balanceHelper, nil, notificator, coordinator)
 		balanceHelper.ProcessingApi = p
 		return p, balanceHelper
 	})
@@ -500,5 +502,326 @@ var _ = Describe("testing txs processing", func() {
 				Expect(err.Error()).To(Equal("processing: self-tx forbidden"))
 			},
 		)
+
+		Context("when scheduled tx holds funds", func() {
+			// walletBalance returns wallet total balance as float
+			walletBalance := func(balances helpers.IBalance, w *queries.Wallet) float64 {
+				bal, err := balances.TotalWalletBalanceCtx(context.Background(), w)
+				Expect(err).NotTo(HaveOccurred())
+				val, _ := bal.Float64()
+				return val
+			}
+
+			// schedule schedules tx from A to B which will be executed in an hour
+			schedule := func(p processing.IApi, actors flowActors, amount float64, hold bool) (*processing.Tx, error) {
+				return p.Schedule(
+					context.Background(),
+					actors.getA(),
+					processing.NewWalletRecipient(actors.getB()),
+					new(decimal.Big).SetFloat64(amount),
+					processing.Schedule{ExecuteAt: time.Now().Add(time.Hour), HoldFunds: hold},
+				)
+			}
+
+			BeforeEachCInvoke(func(actors flowActors, coordinator *mocks.ICoordinator) {
+				coordinator.GetWalletObserver(testCoinName).SetAddressBalance(
+					actors.getA().Address, new(decimal.Big).SetFloat64(100),
+				)
+				coordinator.GetWalletObserver(testCoinName).SetAddressBalance(actors.getB().Address, new(decimal.Big))
+				coordinator.GetAccountObserver(testCoinName).SetAccountBalance(new(decimal.Big).SetFloat64(100))
+			})
+
+			for _, c := range []struct {
+				label   string
+				hold    bool
+				balance float64
+			}{
+				{"should exclude held amount from wallet balance", true, 40},
+				{"should keep wallet balance unless amount is held", false, 100},
+			} {
+				c := c
+				ItD(c.label, func(p processing.IApi, actors flowActors, balances helpers.IBalance) {
+					_, err := schedule(p, actors, 60, c.hold)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(walletBalance(balances, actors.getA())).To(BeEquivalentTo(c.balance))
+				})
+			}
+
+			ItD(
+				"should not hold the same funds twice",
+				func(p processing.IApi, actors flowActors, balances helpers.IBalance) {
+					_, err := schedule(p, actors, 60, true)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = schedule(p, actors, 60, true)
+					Expect(err).To(Equal(processing.ErrInsufficientFunds))
+					Expect(walletBalance(balances, actors.getA())).To(BeEquivalentTo(40))
+				},
+			)
+
+			ItD(
+				"should release held amount when tx is canceled",
+				func(p processing.IApi, actors flowActors, balances helpers.IBalance) {
+					tx, err := schedule(p, actors, 60, true)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = p.CancelScheduled(context.Background(), actors.getA().UserPhone, tx.ID)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(walletBalance(balances, actors.getA())).To(BeEquivalentTo(100))
+				},
+			)
+
+			for _, c := range []struct {
+				label  string
+				amount float64
+				a, b   float64
+			}{
+				{"should execute tx which holds amount exceeding the rest of balance", 60, 40, 60},
+				{"should execute tx which holds the whole balance", 100, 0, 100},
+			} {
+				c := c
+				ItD(c.label, func(d *db.Db, p processing.IApi, actors flowActors, balances helpers.IBalance) {
+					tx, err := schedule(p, actors, c.amount, true)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = d.Exec("update txs set execute_at = $2 where id = $1", tx.ID, time.Now().UTC().Add(-time.Minute))
+					Expect(err).NotTo(HaveOccurred())
+
+					executedNum, err := p.ExecuteDueScheduled(context.Background())
+					Expect(err).NotTo(HaveOccurred())
+					Expect(executedNum).To(Equal(1))
+
+					var status string
+					err = d.QueryRowx(
+						"select s.name from txs t join tx_statuses s on s.id = t.status_id where t.id = $1", tx.ID,
+					).Scan(&status)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(status).To(Equal(processing.TxStateProcessed))
+					Expect(walletBalance(balances, actors.getA())).To(BeEquivalentTo(c.a))
+					Expect(walletBalance(balances, actors.getB())).To(BeEquivalentTo(c.b))
+				})
+			}
+		})
+
+		Context("when sending batch payouts", func() {
+			BeforeEachCInvoke(func(actors flowActors, coordinator *mocks.ICoordinator) {
+				coordinator.GetWalletObserver(testCoinName).SetAddressBalance(
+					actors.getA().Address, new(decimal.Big).SetFloat64(100),
+				)
+				coordinator.GetWalletObserver(testCoinName).SetAddressBalance(actors.getB().Address, new(decimal.Big))
+				coordinator.GetAccountObserver(testCoinName).SetAccountBalance(new(decimal.Big).SetFloat64(1000))
+
+				// addresses prefixed by "invalid" are rejected
+				validator := &mocks.IAddressValidator{}
+				validator.On("ValidateAddress", mock.Anything).Return(func(address string) error {
+					if strings.HasPrefix(address, "invalid") {
+						return nodes.ErrAddressInvalid
+					}
+					return nil
+				})
+				coordinator.On("AddressValidator", testCoinName).Return(validator)
+			})
+
+			// item creates batch item which pays amount to the address
+			item := func(address string, amount float64) processing.BatchItem {
+				return processing.BatchItem{
+					Recipient: processing.NewAddressRecipient(address),
+					Amount:    new(decimal.Big).SetFloat64(amount),
+				}
+			}
+
+			for _, c := range []struct {
+				label string
+				items []processing.BatchItem
+				err   error
+			}{
+				{"should reject empty batch", nil, processing.ErrEmptyBatch},
+				{
+					"should reject batch with negative amount",
+					[]processing.BatchItem{item("ext1", 1), item("ext2", -1)},
+					processing.ErrNegativeAmount,
+				},
+				{
+					"should reject batch which total exceeds wallet balance",
+					[]processing.BatchItem{item("ext1", 60), item("ext2", 60)},
+					processing.ErrInsufficientFunds,
+				},
+			} {
+				c := c
+				ItD(c.label, func(d *gorm.DB, p processing.IApi, actors flowActors) {
+					batch, results, err := p.SendBatch(context.Background(), actors.getA(), c.items)
+					Expect(err).To(Equal(c.err))
+					Expect(batch).To(BeNil())
+					Expect(results).To(BeEmpty())
+
+					var txsNum int
+					Expect(d.Model(&processing.Tx{}).Count(&txsNum).Error).NotTo(HaveOccurred())
+					Expect(txsNum).To(BeZero())
+				})
+			}
+
+			ItD(
+				"should send external payouts by single tx and split it's fee",
+				func(p processing.IApi, actors flowActors, coordinator *mocks.ICoordinator, balances helpers.IBalance) {
+					sender := coordinator.GetBatchTxsSender(testCoinName)
+					sender.On("SendBatch", mock.Anything, actors.getA().Address, mock.Anything, mock.Anything).Return(
+						"batch hash", decimal.New(4, 1), nil,
+					)
+
+					batch, results, err := p.SendBatch(context.Background(), actors.getA(), []processing.BatchItem{
+						item("ext1", 10),
+						{
+							Recipient: processing.NewWalletRecipient(actors.getB()),
+							Amount:    new(decimal.Big).SetFloat64(20),
+						},
+						item("ext2", 30),
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(batch).NotTo(BeNil())
+					Expect(results).To(HaveLen(3))
+					for _, r := range results {
+						Expect(r.Err).NotTo(HaveOccurred())
+						Expect(*r.Tx.BatchID).To(Equal(batch.ID))
+					}
+
+					By("sending external outputs once")
+					sender.AssertNumberOfCalls(GinkgoT(), "SendBatch", 1)
+					outputs := sender.Calls[0].Arguments.Get(2).([]nodes.TxOutput)
+					Expect(outputs).To(HaveLen(2))
+					Expect(outputs[0].Address).To(Equal("ext1"))
+					Expect(outputs[1].Address).To(Equal("ext2"))
+
+					By("splitting fee proportionally to external amounts")
+					for i, c := range []struct {
+						state string
+						fee   *decimal.Big
+					}{
+						{processing.TxStateAwaitConfirmations, decimal.New(1, 1)},
+						{processing.TxStateProcessed, nil},
+						{processing.TxStateAwaitConfirmations, decimal.New(3, 1)},
+					} {
+						Expect(results[i].Tx.StateName()).To(Equal(c.state))
+						if c.fee == nil {
+							Expect(results[i].Tx.BlockchainFee).To(BeNil())
+							continue
+						}
+						Expect(results[i].Tx.BlockchainFee.V.Cmp(c.fee)).To(BeZero())
+					}
+
+					By("holding amounts and fee")
+					bal, err := balances.TotalWalletBalanceCtx(context.Background(), actors.getA())
+					Expect(err).NotTo(HaveOccurred())
+					Expect(bal.Cmp(decimal.New(396, 1))).To(BeZero())
+				},
+			)
+
+			ItD(
+				"should decline only payouts which addresses are invalid",
+				func(p processing.IApi, actors flowActors, coordinator *mocks.ICoordinator) {
+					sender := coordinator.GetBatchTxsSender(testCoinName)
+					sender.On("SendBatch", mock.Anything, actors.getA().Address, mock.Anything, mock.Anything).Return(
+						"batch hash", decimal.New(4, 1), nil,
+					)
+
+					_, results, err := p.SendBatch(context.Background(), actors.getA(), []processing.BatchItem{
+						item("ext1", 10), item("invalid1", 20), item("ext2", 30), item("invalid2", 40),
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(results).To(HaveLen(4))
+					for i, c := range []struct {
+						state string
+						err   error
+						fee   *decimal.Big
+					}{
+						{processing.TxStateAwaitConfirmations, nil, decimal.New(1, 1)},
+						{processing.TxStateDeclined, processing.ErrInvalidAddress, nil},
+						{processing.TxStateAwaitConfirmations, nil, decimal.New(3, 1)},
+						{processing.TxStateDeclined, processing.ErrInvalidAddress, nil},
+					} {
+						Expect(results[i].Tx.StateName()).To(Equal(c.state))
+						if c.err == nil {
+							Expect(results[i].Err).NotTo(HaveOccurred())
+							Expect(results[i].Tx.BlockchainFee.V.Cmp(c.fee)).To(BeZero())
+							continue
+						}
+						Expect(results[i].Err).To(Equal(c.err))
+						Expect(results[i].Tx.BlockchainFee).To(BeNil())
+					}
+
+					By("sending only valid outputs")
+					sender.AssertNumberOfCalls(GinkgoT(), "SendBatch", 1)
+					outputs := sender.Calls[0].Arguments.Get(2).([]nodes.TxOutput)
+					Expect(outputs).To(HaveLen(2))
+					Expect(outputs[0].Address).To(Equal("ext1"))
+					Expect(outputs[1].Address).To(Equal("ext2"))
+				},
+			)
+
+			ItD(
+				"should send external payouts one by one if coin doesn't support multi-output txs",
+				func(p processing.IApi, actors flowActors, coordinator *mocks.ICoordinator) {
+					coordinator.On("BatchTxsSender", testCoinName).Return(nil)
+					sender := coordinator.GetTxsSender(testCoinName)
+					sender.On("Send", mock.Anything, actors.getA().Address, mock.Anything, mock.Anything).Return(
+						"hash", decimal.New(1, 1), nil,
+					)
+
+					_, results, err := p.SendBatch(context.Background(), actors.getA(), []processing.BatchItem{
+						item("ext1", 10), item("ext2", 30),
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(results).To(HaveLen(2))
+					for _, r := range results {
+						Expect(r.Err).NotTo(HaveOccurred())
+						Expect(r.Tx.StateName()).To(Equal(processing.TxStateAwaitConfirmations))
+					}
+					sender.AssertNumberOfCalls(GinkgoT(), "Send", 2)
+				},
+			)
+
+			ItD(
+				"should send queued withdrawals by single tx",
+				func(
+					d *gorm.DB,
+					actors flowActors,
+					coordinator *mocks.ICoordinator,
+					balances helpers.IBalance,
+					notificator isc.ITxsEventNotificator,
+				) {
+					p := processing.New(d, balances, nil, notificator, coordinator, testCoinName)
+					sender := coordinator.GetBatchTxsSender(testCoinName)
+					sender.On("SendBatch", mock.Anything, actors.getA().Address, mock.Anything, mock.Anything).Return(
+						"batch hash", decimal.New(4, 1), nil,
+					)
+
+					var ids []int64
+					for _, address := range []string{"ext1", "ext2"} {
+						tx, err := p.Send(
+							context.Background(),
+							actors.getA(),
+							processing.NewAddressRecipient(address),
+							new(decimal.Big).SetFloat64(10),
+						)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(tx.StateName()).To(Equal(processing.TxStateQueuedForBatch))
+						ids = append(ids, tx.ID)
+					}
+					sender.AssertNotCalled(GinkgoT(), "SendBatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+					sentNum, err := p.SendQueuedWithdrawals(context.Background())
+					Expect(err).NotTo(HaveOccurred())
+					Expect(sentNum).To(Equal(2))
+					sender.AssertNumberOfCalls(GinkgoT(), "SendBatch", 1)
+
+					var txs []processing.Tx
+					Expect(d.Where("id in (?)", ids).Preload("Status").Find(&txs).Error).NotTo(HaveOccurred())
+					Expect(txs).To(HaveLen(2))
+					for _, tx := range txs {
+						Expect(tx.StateName()).To(Equal(processing.TxStateAwaitConfirmations))
+						Expect(tx.BlockchainFee.V.Cmp(decimal.New(2, 1))).To(BeZero())
+					}
+				},
+			)
+		})
 	})
 })
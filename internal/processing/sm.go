@@ -11,12 +11,24 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
+	"strings"
 )
 
 type smResources struct {
 	Coordinator        nodes.ICoordinator
 	BalanceHelper      helpers.IBalance
 	TxEventNotificator isc.ITxsEventNotificator
+
+	// BatchOutputs collects external batch txs if they should be sent as single multi-output tx
+	BatchOutputs *batchOutputs
+
+	// BatchedCoins are coins which external txs are queued for batch withdrawal
+	BatchedCoins map[string]bool
+}
+
+// queuesForBatch true if external tx of the coin should be queued for batch withdrawal rather then sent immediately
+func (res *smResources) queuesForBatch(coinName string) bool {
+	return res.BatchedCoins[strings.ToUpper(coinName)] && res.Coordinator.BatchTxsSender(coinName) != nil
 }
 
 // StepTx performs as much transaction steps as possible depends on current transaction state
@@ -106,6 +118,8 @@ type stateFunc func(ctx context.Context, dbTx *gorm.DB, tx *Tx, res *smResources
 //
 func getStateFunc(state string) (stateFunc, string) {
 	switch state {
+	case TxStateScheduled:
+		return onScheduledTxDue, "onScheduledTxDue"
 	case TxStateValidate:
 		return onValidateTxState, "onValidateTxState"
 	case TxStateExternalSending:
@@ -119,6 +133,19 @@ func getStateFunc(state string) (stateFunc, string) {
 	}
 }
 
+// onScheduledTxDue moves scheduled tx into validation, it's called only when tx execution time has come
+func onScheduledTxDue(
+	ctx context.Context,
+	dbTx *gorm.DB,
+	tx *Tx,
+	res *smResources,
+	secret string,
+) (newState string, nextStep bool, validateErrs, err error) {
+	newState = TxStateValidate
+	nextStep = true
+	return
+}
+
 // onRecipientWalletCreated
 func onRecipientWalletCreated(
 	ctx context.Context,
@@ -146,16 +173,28 @@ func onSendExternalTx(
 	res *smResources,
 	secret string,
 ) (newState string, nextStep bool, validateErrs, err error) {
+	// batch total amount is already checked, multi-output tx is sent once all batch txs are stepped
+	if res.BatchOutputs != nil && tx.BatchID != nil {
+		res.BatchOutputs.txs = append(res.BatchOutputs.txs, tx)
+		newState = TxStateExternalSending
+		return
+	}
+
 	// check wallet balance again
-	walletTotalBalance, err := res.BalanceHelper.TotalWalletBalanceCtx(ctx, tx.FromWallet)
+	_, walletAvailable, err := walletAvailableBalance(ctx, tx, res)
 	if err != nil {
 		return
 	}
-	// wallet total balance should not exceed general balance
-	if walletTotalBalance.Cmp(tx.Amount.V) < 0 {
+	if walletAvailable.Cmp(tx.Amount.V) < 0 {
 		validateErrs = merrors.Append(validateErrs, ErrInsufficientFunds)
 	}
 
+	// queued withdrawal keeps it's amount reserved until it's sent by the worker
+	if validateErrs == nil && res.queuesForBatch(tx.CoinName()) {
+		newState = TxStateQueuedForBatch
+		return
+	}
+
 	var (
 		txHash string
 		fee    *decimal.Big
@@ -239,12 +278,12 @@ func onValidateTxState(
 	}
 
 	// tx amount should no exceed total wallet balance, return insufficient funds in such case
-	walletTotalBalance, err := res.BalanceHelper.TotalWalletBalanceCtx(ctx, tx.FromWallet)
+	walletTotalBalance, walletAvailable, err := walletAvailableBalance(ctx, tx, res)
 	if err != nil {
 		return
 	}
-	span.LogKV("wallet_total_balance", walletTotalBalance)
-	if walletTotalBalance.Cmp(amount) < 0 {
+	span.LogKV("wallet_total_balance", walletTotalBalance, "wallet_available_balance", walletAvailable)
+	if walletAvailable.Cmp(amount) < 0 {
 		validateErrs = merrors.Append(validateErrs, ErrInsufficientFunds)
 	}
 
@@ -313,3 +352,15 @@ func onValidateTxState(
 	}
 	return
 }
+
+// walletAvailableBalance returns sender wallet total balance and balance available to the tx, amount of tx which holds
+// funds since scheduling is already excluded from wallet total balance
+func walletAvailableBalance(ctx context.Context, tx *Tx, res *smResources) (
+	total, available *decimal.Big, err error,
+) {
+	total, err = res.BalanceHelper.TotalWalletBalanceCtx(ctx, tx.FromWallet)
+	if err != nil || !tx.HoldFunds {
+		return total, total, err
+	}
+	return total, new(decimal.Big).Add(total, tx.Amount.V), nil
+}
@@ -2,13 +2,13 @@ package providers
 
 import (
 	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
-	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/cryptocompare"
 	"git.zam.io/wallet-backend/wallet-api/config/server"
-	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/icex"
-	"fmt"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/providers"
 	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/fallback"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/cache"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"strings"
 )
 
 // CryptoCurrency create configuration defined crypto-coins converter
@@ -18,7 +18,7 @@ func CoinConverter(cfg server.Scheme, log logrus.FieldLogger) (c convert.ICrypto
 	log = log.WithField("module", "providers.converter")
 
 	log.WithField("type", conv.Type).Info("creating main converter")
-	c, err = converterForType(conv.Type, log)
+	c, err = converterForType(conv.Type, conv.Params, log)
 	if err != nil {
 		log.WithField("type", conv.Type).WithError(err).Error("error occurs while creating main converter")
 		return
@@ -33,28 +33,34 @@ func CoinConverter(cfg server.Scheme, log logrus.FieldLogger) (c convert.ICrypto
 			return
 		}
 
-		fb, err := converterForType(conv.FallbackType, log)
+		fb, err := converterForType(conv.FallbackType, conv.Params, log)
 		if err == nil {
 			c = fallback.New(c, fb, conv.FallbackTimeout)
 		} else {
 			log.WithError(err).Warn("creating fallback converter failed, so it will be used without fallback")
 		}
 	}
+	if conv.Cache.TTL > 0 {
+		log.WithField("cache_ttl", conv.Cache.TTL).Info("enabling rates cache")
+		c = cache.New(c, cache.Params{
+			TTL:          conv.Cache.TTL,
+			PairsTTL:     conv.Cache.PairsTTL,
+			RefreshAhead: conv.Cache.RefreshAhead,
+			StaleTTL:     conv.Cache.StaleTTL,
+		})
+	}
 	log.Info("success")
 	return c, nil
 }
 
-func converterForType(t string, log logrus.FieldLogger) (convert.ICryptoCurrency, error) {
-	switch t {
-	case "":
-		fallthrough
-	case "icex":
-		log.Info("creating icex converter")
-		return icex.New("https://api.icex.ch")
-	case "cryptocompare":
-		log.Info("creating cryptocompare converter")
-		return cryptocompare.New("https://min-api.cryptocompare.com")
-	default:
-		return nil, fmt.Errorf("coin converter provider: uexpected converter type %s", t)
+// converterForType creates converter using provider registered for the type, icex is used if type isn't specified
+func converterForType(
+	t string, params map[string]map[string]interface{}, log logrus.FieldLogger,
+) (convert.ICryptoCurrency, error) {
+	if t == "" {
+		t = "icex"
 	}
+	t = strings.ToLower(t)
+	log.Infof("creating %s converter", t)
+	return providers.Create(t, log.WithField("converter_type", t), params)
 }
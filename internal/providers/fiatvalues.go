@@ -0,0 +1,18 @@
+package providers
+
+import (
+	processingconf "git.zam.io/wallet-backend/wallet-api/config/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/fiatvalues"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/jinzhu/gorm"
+)
+
+// FiatValuesCapturer
+func FiatValuesCapturer(
+	db *gorm.DB,
+	converter convert.ICryptoCurrency,
+	history convert.IHistoricalRates,
+	cfg processingconf.Scheme,
+) fiatvalues.ICapturer {
+	return fiatvalues.New(db, converter, history, cfg.FiatValues.Currencies, cfg.FiatValues.CaptureWindow)
+}
@@ -0,0 +1,19 @@
+package providers
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/invoices"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/jinzhu/gorm"
+)
+
+// Invoices
+func Invoices(
+	db *gorm.DB,
+	walletsApi *wallets.Api,
+	converter convert.ICryptoCurrency,
+	notificator isc.IInvoicesEventNotificator,
+) invoices.IInvoices {
+	return invoices.New(db, walletsApi, converter, notificator)
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	processingconf "git.zam.io/wallet-backend/wallet-api/config/processing"
+	walletsconf "git.zam.io/wallet-backend/wallet-api/config/wallets"
 	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
 	"git.zam.io/wallet-backend/wallet-api/internal/helpers/balance"
 	"git.zam.io/wallet-backend/wallet-api/internal/processing"
@@ -17,19 +18,30 @@ func ProcessingApi(
 	coordinator nodes.ICoordinator,
 	_ opentracing.Tracer,
 	txNotificator isc.ITxsEventNotificator,
-) (processing.IApi, helpers.IBalance) {
+	wConf walletsconf.Scheme,
+	cfg processingconf.Scheme,
+) (processing.IApi, helpers.IBalance, helpers.IBalanceCache) {
 	b := balance.New(coordinator, nil)
-	api := processing.New(db, b, txNotificator, coordinator)
+	cache := balance.NewCache(db, b, wConf.BalanceCache.MemoryTTL, wConf.BalanceCache.SnapshotTTL)
+	api := processing.New(db, b, cache, txNotificator, coordinator, cfg.WithdrawalBatching.Coins...)
 	b.ProcessingApi = api
-	return api, b
+	return api, b, cache
 }
 
 // ConfirmationsNotifier
-func ConfirmationsNotifier(db *gorm.DB, coordinator nodes.ICoordinator) processing.IConfirmationNotifier {
-	return processing.NewConfirmationsNotifier(db, coordinator)
+func ConfirmationsNotifier(
+	db *gorm.DB,
+	coordinator nodes.ICoordinator,
+	balanceCache helpers.IBalanceCache,
+) processing.IConfirmationNotifier {
+	return processing.NewConfirmationsNotifier(db, coordinator, balanceCache)
 }
 
 // CheckOutdatedNotifier
-func CheckOutdatedNotifier(db *gorm.DB, cfg processingconf.Scheme) processing.ICheckOutdatedNotifier {
-	return processing.NewCheckOutdatedNotifier(db, cfg.TimeToWaitRecipient)
+func CheckOutdatedNotifier(
+	db *gorm.DB,
+	balanceCache helpers.IBalanceCache,
+	cfg processingconf.Scheme,
+) processing.ICheckOutdatedNotifier {
+	return processing.NewCheckOutdatedNotifier(db, balanceCache, cfg.TimeToWaitRecipient)
 }
@@ -0,0 +1,13 @@
+package providers
+
+import (
+	processingconf "git.zam.io/wallet-backend/wallet-api/config/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/quotes"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/jinzhu/gorm"
+)
+
+// Quotes
+func Quotes(db *gorm.DB, converter convert.ICryptoCurrency, cfg processingconf.Scheme) (quotes.IQuotes, error) {
+	return quotes.New(db, converter, cfg.Quotes.TTL, cfg.Quotes.RateTolerance)
+}
@@ -0,0 +1,29 @@
+package providers
+
+import (
+	processingconf "git.zam.io/wallet-backend/wallet-api/config/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/rateshistory"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/cryptocompare"
+	"github.com/jinzhu/gorm"
+)
+
+// RatesHistory creates rates history which falls back to cryptocompare service for moments without collected rates
+func RatesHistory(
+	db *gorm.DB,
+	converter convert.ICryptoCurrency,
+	cfg processingconf.Scheme,
+) (rateshistory.IHistory, error) {
+	fallback, err := cryptocompare.NewCryptoCurrency("https://min-api.cryptocompare.com")
+	if err != nil {
+		return nil, err
+	}
+	return rateshistory.New(
+		db, converter, fallback, cfg.RatesHistory.Currencies, cfg.RatesHistory.MaxGap,
+	), nil
+}
+
+// HistoricalRates
+func HistoricalRates(history rateshistory.IHistory) convert.IHistoricalRates {
+	return history
+}
@@ -0,0 +1,23 @@
+package providers
+
+import (
+	processingconf "git.zam.io/wallet-backend/wallet-api/config/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
+	"git.zam.io/wallet-backend/wallet-api/internal/reconciliation"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sentry"
+	"github.com/jinzhu/gorm"
+)
+
+// Reconciler
+func Reconciler(
+	db *gorm.DB,
+	coordinator nodes.ICoordinator,
+	balance helpers.IBalance,
+	reporter sentry.IReporter,
+	cfg processingconf.Scheme,
+) (reconciliation.IReconciler, error) {
+	return reconciliation.New(
+		db, coordinator, balance, reporter, cfg.Reconciliation.Tolerance, cfg.Reconciliation.CoinsTolerances,
+	)
+}
@@ -0,0 +1,13 @@
+package providers
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/recurring"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"github.com/jinzhu/gorm"
+)
+
+// Recurring
+func Recurring(db *gorm.DB, walletsApi *wallets.Api, notificator isc.ITxsEventNotificator) recurring.IRecurring {
+	return recurring.New(db, walletsApi, notificator)
+}
@@ -0,0 +1,19 @@
+package providers
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/requests"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/jinzhu/gorm"
+)
+
+// Requests
+func Requests(
+	db *gorm.DB,
+	walletsApi *wallets.Api,
+	converter convert.ICryptoCurrency,
+	notificator isc.IPaymentRequestsEventNotificator,
+) requests.IRequests {
+	return requests.New(db, walletsApi, converter, notificator)
+}
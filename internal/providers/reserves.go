@@ -0,0 +1,19 @@
+package providers
+
+import (
+	processingconf "git.zam.io/wallet-backend/wallet-api/config/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
+	"git.zam.io/wallet-backend/wallet-api/internal/reserves"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/jinzhu/gorm"
+)
+
+// Reserves
+func Reserves(
+	db *gorm.DB,
+	coordinator nodes.ICoordinator,
+	balance helpers.IBalance,
+	cfg processingconf.Scheme,
+) (reserves.IReserves, error) {
+	return reserves.New(db, coordinator, balance, cfg.Reserves.Secret)
+}
@@ -0,0 +1,12 @@
+package providers
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/jinzhu/gorm"
+)
+
+// UserSettings
+func UserSettings(db *gorm.DB, converter convert.ICryptoCurrency) settings.ISettings {
+	return settings.New(db, converter)
+}
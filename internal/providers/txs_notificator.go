@@ -5,9 +5,23 @@ import (
 	"git.zam.io/wallet-backend/wallet-api/internal/services/isc/simple"
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications"
 	"git.zam.io/wallet-backend/wallet-api/config/server"
+	processingconf "git.zam.io/wallet-backend/wallet-api/config/processing"
 )
 
 // TxsEventNotificator provides simple txs notificator
 func TxsEventNotificator(server server.Scheme, transport notifications.ITransport) isc.ITxsEventNotificator {
 	return simple.New(transport, server.Frontend.RootURL)
 }
+
+// RequestsEventNotificator provides simple payment requests notificator
+func RequestsEventNotificator(
+	server server.Scheme,
+	transport notifications.ITransport,
+) isc.IPaymentRequestsEventNotificator {
+	return simple.NewRequests(transport, server.Frontend.RootURL)
+}
+
+// InvoicesEventNotificator provides merchant webhooks invoices notificator
+func InvoicesEventNotificator(cfg processingconf.Scheme) isc.IInvoicesEventNotificator {
+	return simple.NewInvoices(cfg.Invoices.WebhookTimeout)
+}
@@ -14,6 +14,7 @@ func WalletsApi(
 	coordinator nodes.ICoordinator,
 	api processing.IApi,
 	balanceHelper helpers.IBalance,
+	balanceCache helpers.IBalanceCache,
 ) *wallets.Api {
-	return wallets.NewApi(d, coordinator, api, balanceHelper)
+	return wallets.NewApi(d, coordinator, api, balanceHelper, balanceCache)
 }
@@ -0,0 +1,3 @@
+// Package quotes defines fiat quotes: user asks how much coin the fiat amount costs, quote locks the rate for a short
+// time and the transaction may be sent using quote instead of coin amount
+package quotes
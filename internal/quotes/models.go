@@ -0,0 +1,59 @@
+package quotes
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/ericlagergren/decimal"
+	"time"
+)
+
+// Quote locks coin amount of the fiat amount at the rate of it's creation time, quote may be used only once
+type Quote struct {
+	ID        int64
+	UserPhone string
+	CoinID    int64
+	Coin      *queries.Coin `gorm:"foreignkey:CoinID;association_autoupdate:false;association_autocreate:false"`
+
+	FiatAmount   *processing.Decimal
+	FiatCurrency string
+
+	// Amount is the coin amount, it's fiat amount converted using Rate
+	Amount *processing.Decimal
+	Rate   *processing.Decimal
+
+	// RateSourcesNum is the number of rate sources which agree on the Rate, it's known only for just created quote
+	RateSourcesNum int `gorm:"-"`
+
+	ExpiresAt time.Time
+
+	// UsedAt is set once quote is used to send tx, TxID is set after tx is successfully sent
+	UsedAt *time.Time
+	TxID   *int64
+
+	CreatedAt time.Time
+}
+
+func (Quote) TableName() string {
+	return "quotes"
+}
+
+// IsUsed true if quote has been already used
+func (q *Quote) IsUsed() bool {
+	return q.UsedAt != nil
+}
+
+// isExpired true if quote can't be used anymore
+func (q *Quote) isExpired(now time.Time) bool {
+	return !q.ExpiresAt.After(now)
+}
+
+// RateMoved true if current rate deviates from the quoted one by more then tolerance, which is relative to the quoted
+// rate (0.01 is 1%)
+func RateMoved(quoted, current, tolerance *decimal.Big) bool {
+	if quoted.Sign() <= 0 {
+		return true
+	}
+	deviation := new(decimal.Big).Sub(current, quoted)
+	deviation.Abs(deviation).Quo(deviation, quoted)
+	return deviation.Cmp(tolerance) > 0
+}
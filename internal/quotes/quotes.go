@@ -0,0 +1,304 @@
+package quotes
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoSuchQuote returned when user has no quote with given id
+	ErrNoSuchQuote = errors.New("quotes: no such quote")
+
+	// ErrQuoteExpired returned on attempt to use quote which expiration time has come
+	ErrQuoteExpired = errors.New("quotes: quote is expired")
+
+	// ErrQuoteUsed returned on attempt to use quote which has been already used
+	ErrQuoteUsed = errors.New("quotes: quote is already used")
+
+	// ErrQuoteRateMoved returned on attempt to use quote which rate differs from the current one by more then
+	// tolerance
+	ErrQuoteRateMoved = errors.New("quotes: rate has moved beyond tolerance")
+
+	// ErrWalletCoinMismatch returned when sender wallet coin differs from the quote coin
+	ErrWalletCoinMismatch = errors.New("quotes: wallet coin differs from quote coin")
+
+	// ErrInvalidTolerance returned when rate tolerance can't be parsed as non-negative decimal
+	ErrInvalidTolerance = errors.New("quotes: invalid rate tolerance")
+)
+
+// amountScale is the number of decimal places the quoted coin amount is rounded to
+const amountScale = 8
+
+// SendFunc sends tx of given coin amount
+type SendFunc func(ctx context.Context, amount *decimal.Big) (*processing.Tx, error)
+
+// IQuotes manages fiat quotes
+type IQuotes interface {
+	// Create quotes fiat amount in the coin at the current rate. Returns errs.ErrNoSuchCoin, errs.ErrNonPositiveAmount
+	// and convert.ErrFiatCurrencyName as validation errors, may return convert.ErrUnavailable.
+	Create(ctx context.Context, userPhone, coinName string, fiatAmount *decimal.Big, fiatCurrency string) (
+		quote *Quote, err error,
+	)
+
+	// Get returns user quote. Returns ErrNoSuchQuote.
+	Get(ctx context.Context, userPhone string, quoteID int64) (quote *Quote, err error)
+
+	// Send sends quoted coin amount from the user wallet using given func, quoted fiat value is stored on the sent tx,
+	// storing failures are only logged since tx is sent already. Quote is reserved while tx is sent and released if
+	// sending fails. Returns ErrNoSuchQuote, ErrQuoteExpired, ErrQuoteUsed, ErrQuoteRateMoved, ErrWalletCoinMismatch,
+	// errs.ErrNoSuchWallet and sending errors, may return convert.ErrUnavailable since rate is checked before sending.
+	Send(ctx context.Context, userPhone string, quoteID, walletID int64, send SendFunc) (
+		quote *Quote, tx *processing.Tx, err error,
+	)
+}
+
+// Quotes is IQuotes implementation
+type Quotes struct {
+	database  *gorm.DB
+	converter convert.ICryptoCurrency
+	ttl       time.Duration
+	tolerance *decimal.Big
+}
+
+// New creates quotes manager, quotes live for given ttl and may be used while rate deviates from the quoted one by no
+// more then relative tolerance given as decimal string
+func New(database *gorm.DB, converter convert.ICryptoCurrency, ttl time.Duration, tolerance string) (*Quotes, error) {
+	q := &Quotes{database: database, converter: converter, ttl: ttl, tolerance: new(decimal.Big)}
+	if _, ok := q.tolerance.SetString(tolerance); !ok || q.tolerance.Sign() < 0 {
+		return nil, errors.Wrapf(ErrInvalidTolerance, "tolerance %q", tolerance)
+	}
+	return q, nil
+}
+
+// Create implements IQuotes
+func (q *Quotes) Create(
+	ctx context.Context, userPhone, coinName string, fiatAmount *decimal.Big, fiatCurrency string,
+) (quote *Quote, err error) {
+	err = trace.InsideSpanE(ctx, "create_quote", func(ctx context.Context, span ot.Span) error {
+		span.LogKV(
+			"user_phone", userPhone,
+			"coin", coinName,
+			"fiat_amount", fiatAmount,
+			"fiat_currency", fiatCurrency,
+		)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		// gather validation errors
+		var validationErrs error
+		if fiatAmount == nil || fiatAmount.Sign() <= 0 {
+			validationErrs = merrors.Append(validationErrs, errs.ErrNonPositiveAmount)
+		}
+		if fiatCurrency == "" {
+			validationErrs = merrors.Append(validationErrs, convert.ErrFiatCurrencyName)
+		}
+		if validationErrs != nil {
+			return validationErrs
+		}
+
+		coin := new(queries.Coin)
+		err = db.TransactionCtx(ctx, q.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			err := dbTx.Where("short_name = ? and enabled = true", strings.ToUpper(coinName)).First(coin).Error
+			if err == gorm.ErrRecordNotFound {
+				return errs.ErrNoSuchCoin
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx = convert.WithSourcesTracking(ctx)
+		rate, err := q.converter.GetRate(ctx, coin.ShortName, fiatCurrency)
+		if err != nil {
+			return err
+		}
+		amount := rate.ReverseConvert(fiatAmount)
+		amount.Quantize(amountScale)
+		if amount.Sign() <= 0 {
+			return errs.ErrNonPositiveAmount
+		}
+		span.LogKV("rate", (*decimal.Big)(rate), "amount", amount)
+
+		quote = &Quote{
+			UserPhone:      userPhone,
+			CoinID:         coin.ID,
+			Coin:           coin,
+			FiatAmount:     &processing.Decimal{V: fiatAmount},
+			FiatCurrency:   strings.ToUpper(fiatCurrency),
+			Amount:         &processing.Decimal{V: amount},
+			Rate:           &processing.Decimal{V: (*decimal.Big)(rate)},
+			ExpiresAt:      time.Now().UTC().Add(q.ttl),
+			RateSourcesNum: convert.SourcesNum(ctx),
+		}
+		err = db.TransactionCtx(ctx, q.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			return dbTx.Create(quote).Error
+		})
+		if err != nil {
+			return err
+		}
+		span.LogKV("quote_id", quote.ID)
+		return nil
+	})
+	return
+}
+
+// Get implements IQuotes
+func (q *Quotes) Get(ctx context.Context, userPhone string, quoteID int64) (quote *Quote, err error) {
+	err = trace.InsideSpanE(ctx, "get_quote", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone, "quote_id", quoteID)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, q.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			quote, err = getQuote(dbTx, userPhone, quoteID)
+			return err
+		})
+	})
+	return
+}
+
+// Send implements IQuotes
+func (q *Quotes) Send(ctx context.Context, userPhone string, quoteID, walletID int64, send SendFunc) (
+	quote *Quote, tx *processing.Tx, err error,
+) {
+	err = trace.InsideSpanE(ctx, "send_by_quote", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone, "quote_id", quoteID, "wallet_id", walletID)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		err = db.TransactionCtx(ctx, q.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			quote, err = getQuote(dbTx, userPhone, quoteID)
+			if err != nil {
+				return err
+			}
+			return checkUsable(dbTx, quote, userPhone, walletID)
+		})
+		if err != nil {
+			return err
+		}
+
+		// rate is checked outside of the db transaction since converter may be slow
+		rate, err := q.converter.GetRate(ctx, quote.Coin.ShortName, quote.FiatCurrency)
+		if err != nil {
+			return err
+		}
+		span.LogKV("quoted_rate", quote.Rate.V, "current_rate", (*decimal.Big)(rate))
+		if RateMoved(quote.Rate.V, (*decimal.Big)(rate), q.tolerance) {
+			return ErrQuoteRateMoved
+		}
+
+		// reserve quote, so concurrent request can't use it twice
+		err = db.TransactionCtx(ctx, q.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			err := dbTx.Exec("select 1 from quotes where id = ? for update", quote.ID).Error
+			if err != nil {
+				return err
+			}
+			quote, err = getQuote(dbTx, userPhone, quote.ID)
+			if err != nil {
+				return err
+			}
+			err = checkUsable(dbTx, quote, userPhone, walletID)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now().UTC()
+			quote.UsedAt = &now
+			return dbTx.Model(quote).Update("used_at", quote.UsedAt).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		tx, err = send(ctx, quote.Amount.V)
+		if err != nil {
+			releaseErr := db.TransactionCtx(ctx, q.database, func(ctx context.Context, dbTx *gorm.DB) error {
+				return dbTx.Model(quote).Update("used_at", nil).Error
+			})
+			if releaseErr != nil {
+				trace.LogErrorWithMsg(span, releaseErr, "quote release failed")
+			}
+			quote.UsedAt = nil
+			return err
+		}
+		span.LogKV("tx_id", tx.ID)
+
+		// tx is sent already and quote stays reserved, so failed linking only loses fiat amount of the tx and doesn't
+		// fail the call, otherwise client may retry and send the amount twice
+		linkErr := db.TransactionCtx(ctx, q.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			quote.TxID = &tx.ID
+			err := dbTx.Model(quote).Update("tx_id", quote.TxID).Error
+			if err != nil {
+				return err
+			}
+
+			tx.FiatAmount = quote.FiatAmount
+			tx.FiatCurrency = &quote.FiatCurrency
+			return dbTx.Model(tx).Updates(map[string]interface{}{
+				"fiat_amount":   tx.FiatAmount,
+				"fiat_currency": tx.FiatCurrency,
+			}).Error
+		})
+		if linkErr != nil {
+			trace.LogErrorWithMsg(span, linkErr, "linking quote with sent tx failed")
+		}
+		return nil
+	})
+	return
+}
+
+// checkUsable checks that quote isn't used nor expired and the wallet belongs to the user and has the quote coin
+func checkUsable(dbTx *gorm.DB, quote *Quote, userPhone string, walletID int64) error {
+	if quote.IsUsed() {
+		return ErrQuoteUsed
+	}
+	if quote.isExpired(time.Now().UTC()) {
+		return ErrQuoteExpired
+	}
+
+	wallet := new(queries.Wallet)
+	err := dbTx.Where("id = ? and user_phone = ?", walletID, userPhone).First(wallet).Error
+	if err == gorm.ErrRecordNotFound {
+		return errs.ErrNoSuchWallet
+	}
+	if err != nil {
+		return err
+	}
+	if wallet.CoinID != quote.CoinID {
+		return ErrWalletCoinMismatch
+	}
+	return nil
+}
+
+// getQuote queries user quote with it's coin, returns ErrNoSuchQuote if not found
+func getQuote(dbTx *gorm.DB, userPhone string, quoteID int64) (*Quote, error) {
+	quote := new(Quote)
+	err := dbTx.Where("id = ? and user_phone = ?", quoteID, userPhone).Preload("Coin").First(quote).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNoSuchQuote
+	}
+	return quote, err
+}
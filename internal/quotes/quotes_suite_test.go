@@ -0,0 +1,44 @@
+package quotes_test
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/quotes"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestQuotes(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Quotes Suite")
+}
+
+var _ = Describe("testing quote rate tolerance", func() {
+	d := func(s string) *decimal.Big {
+		v, _ := new(decimal.Big).SetString(s)
+		return v
+	}
+	quoted := d("6500")
+	tolerance := d("0.01")
+
+	It("should accept rate within tolerance in both directions", func() {
+		Expect(quotes.RateMoved(quoted, d("6500"), tolerance)).To(BeFalse())
+		Expect(quotes.RateMoved(quoted, d("6565"), tolerance)).To(BeFalse())
+		Expect(quotes.RateMoved(quoted, d("6435"), tolerance)).To(BeFalse())
+	})
+
+	It("should reject rate beyond tolerance in both directions", func() {
+		Expect(quotes.RateMoved(quoted, d("6565.01"), tolerance)).To(BeTrue())
+		Expect(quotes.RateMoved(quoted, d("6434.99"), tolerance)).To(BeTrue())
+	})
+
+	It("should reject any change if tolerance is zero", func() {
+		Expect(quotes.RateMoved(quoted, d("6500"), d("0"))).To(BeFalse())
+		Expect(quotes.RateMoved(quoted, d("6500.01"), d("0"))).To(BeTrue())
+	})
+
+	It("should reject non-positive quoted rate", func() {
+		Expect(quotes.RateMoved(d("0"), d("6500"), tolerance)).To(BeTrue())
+	})
+})
@@ -0,0 +1,2 @@
+// Package rateshistory collects coins rates locally and serves historical rates and daily OHLC ranges from them
+package rateshistory
@@ -0,0 +1,258 @@
+package rateshistory
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// ErrNoRate returned when no rate has been collected close enough to the requested moment and there is no fallback
+var ErrNoRate = errors.New("rates history: no rate collected for the moment")
+
+// IHistory serves historical rates from locally collected ones
+type IHistory interface {
+	convert.IHistoricalRates
+
+	// Collect stores current rates of all enabled coins to configured fiat currencies. Pairs unknown to the converter
+	// are skipped. Returns number of stored rates.
+	Collect(ctx context.Context) (collectedNum int, err error)
+}
+
+// History is IHistory implementation
+type History struct {
+	database   *gorm.DB
+	converter  convert.ICryptoCurrency
+	fallback   convert.IHistoricalRates
+	currencies []string
+	maxGap     time.Duration
+}
+
+// interfaces compile-time validations
+var _ IHistory = (*History)(nil)
+
+// New creates rates history which collects rates of given fiat currencies using converter. Collected rate is used
+// for moments up to maxGap after it's collection, otherwise rate is taken from fallback, which may be nil.
+func New(
+	database *gorm.DB,
+	converter convert.ICryptoCurrency,
+	fallback convert.IHistoricalRates,
+	currencies []string,
+	maxGap time.Duration,
+) *History {
+	upperCurrencies := make([]string, 0, len(currencies))
+	for _, currency := range currencies {
+		if currency = strings.ToUpper(strings.TrimSpace(currency)); currency != "" {
+			upperCurrencies = append(upperCurrencies, currency)
+		}
+	}
+	return &History{
+		database:   database,
+		converter:  converter,
+		fallback:   fallback,
+		currencies: upperCurrencies,
+		maxGap:     maxGap,
+	}
+}
+
+// GetRateAt implements IHistoricalRates
+func (h *History) GetRateAt(ctx context.Context, coinName string, dstCurrencyName string, at time.Time) (
+	r *convert.Rate, err error,
+) {
+	err = trace.InsideSpanE(ctx, "get_historical_rate", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("coin", coinName, "currency", dstCurrencyName, "at", at)
+		dstCurrencyName = strings.ToUpper(dstCurrencyName)
+		at = at.UTC()
+
+		point := new(Point)
+		err := db.TransactionCtx(ctx, h.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			coin, err := getCoin(dbTx, coinName)
+			if err != nil {
+				return err
+			}
+			return dbTx.Where(
+				"coin_id = ? and currency = ? and collected_at <= ? and collected_at >= ?",
+				coin.ID, dstCurrencyName, at, at.Add(-h.maxGap),
+			).Order("collected_at desc").First(point).Error
+		})
+		switch err {
+		case nil:
+			r = rate(point.Rate.V)
+			return nil
+		case gorm.ErrRecordNotFound, convert.ErrCryptoCurrencyName:
+		default:
+			return err
+		}
+
+		span.LogKV("local_rate_found", false)
+		if h.fallback == nil {
+			if err == convert.ErrCryptoCurrencyName {
+				return err
+			}
+			return ErrNoRate
+		}
+		r, err = h.fallback.GetRateAt(ctx, coinName, dstCurrencyName, at)
+		return err
+	})
+	return
+}
+
+// GetOHLC implements IHistoricalRates, days which haven't locally collected rates are taken from the fallback. If
+// fallback fails, only local days are returned unless there are no such days.
+func (h *History) GetOHLC(ctx context.Context, coinName string, dstCurrencyName string, from, to time.Time) (
+	ohlc []convert.OHLC, err error,
+) {
+	err = trace.InsideSpanE(ctx, "get_ohlc", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("coin", coinName, "currency", dstCurrencyName, "from", from, "to", to)
+		dstCurrencyName = strings.ToUpper(dstCurrencyName)
+		from = from.UTC().Truncate(day)
+		to = to.UTC().Truncate(day)
+		if to.Before(from) {
+			return nil
+		}
+
+		var points []Point
+		err := db.TransactionCtx(ctx, h.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			coin, err := getCoin(dbTx, coinName)
+			if err != nil {
+				return err
+			}
+			return dbTx.Where(
+				"coin_id = ? and currency = ? and collected_at >= ? and collected_at < ?",
+				coin.ID, dstCurrencyName, from, to.Add(day),
+			).Order("collected_at").Find(&points).Error
+		})
+		if err != nil && err != convert.ErrCryptoCurrencyName {
+			return err
+		}
+		ohlc = Candles(points)
+		span.LogKV("local_days_num", len(ohlc))
+
+		if len(ohlc) == daysNum(from, to) {
+			return nil
+		}
+		if h.fallback == nil {
+			if err == convert.ErrCryptoCurrencyName {
+				return err
+			}
+			return nil
+		}
+		remote, err := h.fallback.GetOHLC(ctx, coinName, dstCurrencyName, from, to)
+		if err != nil {
+			if len(ohlc) == 0 {
+				return err
+			}
+			trace.LogErrorWithMsg(span, err, "fallback ohlc query failed")
+			return nil
+		}
+		ohlc = mergeCandles(ohlc, remote)
+		return nil
+	})
+	return
+}
+
+// Collect implements IHistory
+func (h *History) Collect(ctx context.Context) (collectedNum int, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "collect_rates")
+	defer span.Finish()
+
+	var coins []queries.Coin
+	err = db.TransactionCtx(ctx, h.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Where("enabled = true").Order("id").Find(&coins).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	var points []Point
+	for _, currency := range h.currencies {
+		rates, rateErr := h.coinsRates(ctx, coins, currency)
+		if rateErr == convert.ErrFiatCurrencyName {
+			span.LogKV("skipped_currency", currency)
+			continue
+		}
+		if rateErr != nil {
+			trace.LogErrorWithMsg(span, rateErr, "rates query failed")
+			err = merrors.Append(err, rateErr)
+			continue
+		}
+		for _, coin := range coins {
+			if r := rates.CurrencyRate(coin.ShortName); r != nil {
+				points = append(points, Point{
+					CoinID:      coin.ID,
+					Currency:    currency,
+					Rate:        &processing.Decimal{V: (*decimal.Big)(r)},
+					CollectedAt: now,
+				})
+			}
+		}
+	}
+
+	storeErr := db.TransactionCtx(ctx, h.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		for i := range points {
+			if err := dbTx.Create(&points[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if storeErr != nil {
+		trace.LogError(span, storeErr)
+		return 0, merrors.Append(err, storeErr)
+	}
+	collectedNum = len(points)
+	span.LogKV("collected_num", collectedNum)
+	return
+}
+
+// coinsRates queries rates of all coins to the currency by single request, if converter rejects some coin rates are
+// queried one by one, so the rest of coins are still collected
+func (h *History) coinsRates(ctx context.Context, coins []queries.Coin, currency string) (convert.MultiRate, error) {
+	// converters may modify names list, so it's always built from scratch
+	names := func() []string {
+		names := make([]string, 0, len(coins))
+		for _, coin := range coins {
+			names = append(names, coin.ShortName)
+		}
+		return names
+	}
+
+	rates, err := h.converter.GetMultiRate(ctx, names(), currency)
+	if err != convert.ErrCryptoCurrencyName {
+		return rates, err
+	}
+
+	rates = make(convert.MultiRate, len(coins))
+	for _, name := range names() {
+		r, err := h.converter.GetRate(ctx, name, currency)
+		switch err {
+		case nil:
+			rates[strings.ToUpper(name)] = *r
+		case convert.ErrCryptoCurrencyName:
+		default:
+			return nil, err
+		}
+	}
+	return rates, nil
+}
+
+// getCoin queries coin by short name, returns convert.ErrCryptoCurrencyName if there is no such coin
+func getCoin(dbTx *gorm.DB, coinName string) (*queries.Coin, error) {
+	coin := new(queries.Coin)
+	err := dbTx.Where("short_name = ?", strings.ToUpper(coinName)).First(coin).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, convert.ErrCryptoCurrencyName
+	}
+	return coin, err
+}
@@ -0,0 +1,83 @@
+package rateshistory
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/ericlagergren/decimal"
+	"time"
+)
+
+// day is the OHLC range period
+const day = 24 * time.Hour
+
+// Point is the rate of the coin to fiat currency collected at some moment
+type Point struct {
+	ID          int64
+	CoinID      int64
+	Currency    string
+	Rate        *processing.Decimal
+	CollectedAt time.Time
+}
+
+func (Point) TableName() string {
+	return "rates_history"
+}
+
+// Candles folds points ordered by collection time into daily OHLC of UTC days, days without points are omitted
+func Candles(points []Point) []convert.OHLC {
+	var candles []convert.OHLC
+	for _, point := range points {
+		if point.Rate == nil || point.Rate.V == nil {
+			continue
+		}
+		rate := point.Rate.V
+		pointDay := point.CollectedAt.UTC().Truncate(day)
+
+		if len(candles) == 0 || !candles[len(candles)-1].Day.Equal(pointDay) {
+			candles = append(candles, convert.OHLC{Day: pointDay, Open: rate, High: rate, Low: rate, Close: rate})
+			continue
+		}
+		candle := &candles[len(candles)-1]
+		if rate.Cmp(candle.High) > 0 {
+			candle.High = rate
+		}
+		if rate.Cmp(candle.Low) < 0 {
+			candle.Low = rate
+		}
+		candle.Close = rate
+	}
+	return candles
+}
+
+// mergeCandles returns local candles supplemented by remote ones for days which local candles miss, both must be
+// ordered by day
+func mergeCandles(local, remote []convert.OHLC) []convert.OHLC {
+	merged := make([]convert.OHLC, 0, len(local)+len(remote))
+	i, j := 0, 0
+	for i < len(local) || j < len(remote) {
+		switch {
+		case j == len(remote) || (i < len(local) && local[i].Day.Before(remote[j].Day)):
+			merged = append(merged, local[i])
+			i++
+		case i == len(local) || remote[j].Day.Before(local[i].Day):
+			merged = append(merged, remote[j])
+			j++
+		default:
+			// local candle is preferred
+			merged = append(merged, local[i])
+			i++
+			j++
+		}
+	}
+	return merged
+}
+
+// daysNum returns number of UTC days in the range
+func daysNum(from, to time.Time) int {
+	return int(to.Sub(from)/day) + 1
+}
+
+// rate converts decimal into convert rate
+func rate(v *decimal.Big) *convert.Rate {
+	return (*convert.Rate)(v)
+}
@@ -0,0 +1,51 @@
+package rateshistory_test
+
+import (
+	"testing"
+	"time"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/rateshistory"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRatesHistory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RatesHistory Suite")
+}
+
+var _ = Describe("testing daily candles", func() {
+	point := func(at string, rate string) rateshistory.Point {
+		collectedAt, _ := time.Parse(time.RFC3339, at)
+		v, _ := new(decimal.Big).SetString(rate)
+		return rateshistory.Point{Rate: &processing.Decimal{V: v}, CollectedAt: collectedAt}
+	}
+
+	It("should return no candles for no points", func() {
+		Expect(rateshistory.Candles(nil)).To(BeEmpty())
+	})
+
+	It("should fold points into UTC days", func() {
+		candles := rateshistory.Candles([]rateshistory.Point{
+			point("2018-07-01T00:00:00Z", "6300"),
+			point("2018-07-01T06:00:00Z", "6500"),
+			point("2018-07-01T12:00:00Z", "6200"),
+			point("2018-07-02T01:00:00+03:00", "6450"),
+			point("2018-07-01T23:59:59Z", "6400"),
+			point("2018-07-03T00:00:00Z", "6600"),
+		})
+		Expect(candles).To(HaveLen(2))
+
+		Expect(candles[0].Day).To(Equal(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)))
+		Expect(candles[0].Open.String()).To(Equal("6300"))
+		Expect(candles[0].High.String()).To(Equal("6500"))
+		Expect(candles[0].Low.String()).To(Equal("6200"))
+		Expect(candles[0].Close.String()).To(Equal("6400"))
+
+		Expect(candles[1].Day).To(Equal(time.Date(2018, 7, 3, 0, 0, 0, 0, time.UTC)))
+		Expect(candles[1].Open.String()).To(Equal("6600"))
+		Expect(candles[1].Close.String()).To(Equal("6600"))
+	})
+})
@@ -0,0 +1,2 @@
+// Package reconciliation defines reconciliation of nodes balances with internal ledger
+package reconciliation
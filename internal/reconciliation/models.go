@@ -0,0 +1,59 @@
+package reconciliation
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"time"
+)
+
+// Report represents reconciliation result of a single coin
+type Report struct {
+	ID     int64
+	CoinID int64
+	Coin   *queries.Coin `gorm:"foreignkey:CoinID;association_autoupdate:false;association_autocreate:false"`
+
+	// NodeBalance is the node account balance
+	NodeBalance *processing.Decimal
+
+	// WalletsBalance is the sum of all coin wallets balances
+	WalletsBalance *processing.Decimal
+
+	// PendingAmount is the sum of txs which await recipient, such money already taken from senders but still on node
+	PendingAmount *processing.Decimal
+
+	// InFlightAmount and InFlightFee are sums of external txs amounts and fees which are not confirmed yet
+	InFlightAmount *processing.Decimal
+	InFlightFee    *processing.Decimal
+
+	// Discrepancy is node balance minus wallets balance and pending amount
+	Discrepancy *processing.Decimal
+
+	// Exceeded indicates that discrepancy exceeds tolerance or some addresses have discrepancies
+	Exceeded bool
+
+	CreatedAt time.Time
+
+	Addresses []AddressDiscrepancy `gorm:"foreignkey:ReportID"`
+}
+
+func (Report) TableName() string {
+	return "reconciliation_reports"
+}
+
+// AddressDiscrepancy represents address which on-chain receipts don't cover receipts recorded in txs_external
+type AddressDiscrepancy struct {
+	ID       int64
+	ReportID int64
+	WalletID int64
+	Address  string
+
+	OnChainReceived  *processing.Decimal
+	RecordedReceived *processing.Decimal
+
+	// Difference is on-chain receipts minus recorded receipts
+	Difference *processing.Decimal
+}
+
+func (AddressDiscrepancy) TableName() string {
+	return "reconciliation_address_discrepancies"
+}
@@ -0,0 +1,359 @@
+package reconciliation
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/services/sentry"
+	"github.com/ericlagergren/decimal"
+	"github.com/ericlagergren/decimal/sql/postgres"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrToleranceExceeded reported when coin discrepancy exceeds configured tolerance
+	ErrToleranceExceeded = errors.New("reconciliation: tolerance exceeded")
+
+	// ErrInvalidTolerance returned when tolerance can't be parsed as decimal
+	ErrInvalidTolerance = errors.New("reconciliation: invalid tolerance")
+)
+
+// IReconciler compares nodes balances with internal ledger
+type IReconciler interface {
+	// Reconcile reconciles all dialed coins, stores reports and alerts about exceeded ones. Coin which reconciliation
+	// has been failed is skipped, so reports of the rest coins are returned alongside with an error.
+	Reconcile(ctx context.Context) (reports []Report, err error)
+
+	// ReconcileCoin reconciles single coin and stores report
+	ReconcileCoin(ctx context.Context, coinName string) (report *Report, err error)
+}
+
+// Reconciler is IReconciler implementation
+type Reconciler struct {
+	database        *gorm.DB
+	coordinator     nodes.ICoordinator
+	balance         helpers.IBalance
+	reporter        sentry.IReporter
+	tolerance       *decimal.Big
+	coinsTolerances map[string]*decimal.Big
+}
+
+// New creates reconciler, tolerances are given in coin units, coins tolerances override default tolerance. Reporter
+// is optional.
+func New(
+	database *gorm.DB,
+	coordinator nodes.ICoordinator,
+	balance helpers.IBalance,
+	reporter sentry.IReporter,
+	tolerance string,
+	coinsTolerances map[string]string,
+) (*Reconciler, error) {
+	r := &Reconciler{
+		database:        database,
+		coordinator:     coordinator,
+		balance:         balance,
+		reporter:        reporter,
+		tolerance:       new(decimal.Big),
+		coinsTolerances: make(map[string]*decimal.Big, len(coinsTolerances)),
+	}
+
+	if tolerance != "" {
+		if _, ok := r.tolerance.SetString(tolerance); !ok {
+			return nil, errors.Wrapf(ErrInvalidTolerance, "default tolerance %q", tolerance)
+		}
+	}
+	for coinName, t := range coinsTolerances {
+		v, ok := new(decimal.Big).SetString(t)
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidTolerance, "%s tolerance %q", coinName, t)
+		}
+		r.coinsTolerances[strings.ToUpper(coinName)] = v
+	}
+	return r, nil
+}
+
+// Reconcile implements IReconciler
+func (r *Reconciler) Reconcile(ctx context.Context) (reports []Report, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "reconcile")
+	defer span.Finish()
+
+	for _, coinName := range r.coordinator.Coins() {
+		report, cErr := r.ReconcileCoin(ctx, coinName)
+		if cErr != nil {
+			err = merrors.Append(err, errors.Wrapf(cErr, "reconciliation of %s has been failed", coinName))
+			continue
+		}
+		if report != nil {
+			reports = append(reports, *report)
+		}
+	}
+	return
+}
+
+// ReconcileCoin implements IReconciler, returns nil report if coin is disabled
+func (r *Reconciler) ReconcileCoin(ctx context.Context, coinName string) (report *Report, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "reconcile_coin")
+	defer span.Finish()
+
+	coinName = strings.ToUpper(coinName)
+	span.LogKV("coin", coinName)
+
+	defer func() {
+		if err != nil {
+			trace.LogError(span, err)
+		}
+	}()
+
+	coin, wts, err := r.loadWallets(ctx, coinName)
+	if err != nil || coin == nil {
+		return
+	}
+
+	// query node first, so wallets which receive money meanwhile will show positive discrepancy rather then negative
+	nodeBalance, err := r.balance.AccountBalanceCtx(ctx, coinName)
+	if err != nil {
+		return
+	}
+
+	walletsBalance := new(decimal.Big)
+	for i := range wts {
+		var balance *decimal.Big
+		balance, err = r.balance.TotalWalletBalanceCtx(ctx, &wts[i])
+		if err != nil {
+			return
+		}
+		walletsBalance.Add(walletsBalance, balance)
+	}
+
+	supportInternalTxs := r.coordinator.TxsSender(coinName).SupportInternalTxs()
+
+	pending, inFlightAmount, inFlightFee, err := r.queryHeldAmounts(ctx, coin.ID)
+	if err != nil {
+		return
+	}
+	// for coins without internal txs support wallets balances are taken from the chain only, so nothing is held
+	if !supportInternalTxs {
+		pending = new(decimal.Big)
+	}
+
+	discrepancy := new(decimal.Big).Sub(nodeBalance, walletsBalance)
+	discrepancy.Sub(discrepancy, pending)
+
+	// in-flight sends are already subtracted from wallets balances, but node may still hold them
+	allowed := new(decimal.Big).Add(r.coinTolerance(coinName), inFlightAmount)
+	allowed.Add(allowed, inFlightFee)
+
+	report = &Report{
+		CoinID:         coin.ID,
+		NodeBalance:    &processing.Decimal{V: nodeBalance},
+		WalletsBalance: &processing.Decimal{V: walletsBalance},
+		PendingAmount:  &processing.Decimal{V: pending},
+		InFlightAmount: &processing.Decimal{V: inFlightAmount},
+		InFlightFee:    &processing.Decimal{V: inFlightFee},
+		Discrepancy:    &processing.Decimal{V: discrepancy},
+		Exceeded:       new(decimal.Big).Abs(discrepancy).Cmp(allowed) > 0,
+	}
+
+	// on-chain receipts are observable per address only for coins which observer returns address receipts
+	if supportInternalTxs {
+		report.Addresses, err = r.reconcileAddresses(ctx, coinName, wts)
+		if err != nil {
+			return
+		}
+		if len(report.Addresses) > 0 {
+			report.Exceeded = true
+		}
+	}
+
+	span.LogKV(
+		"node_balance", nodeBalance,
+		"wallets_balance", walletsBalance,
+		"pending", pending,
+		"in_flight_amount", inFlightAmount,
+		"in_flight_fee", inFlightFee,
+		"discrepancy", discrepancy,
+		"address_discrepancies", len(report.Addresses),
+		"exceeded", report.Exceeded,
+	)
+
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Create(report).Error
+	})
+	if err != nil {
+		return
+	}
+	report.Coin = coin
+
+	if report.Exceeded {
+		r.alert(report)
+	}
+	return
+}
+
+// loadWallets loads enabled coin and all it's custodial wallets with addresses history, returns nil coin if coin is disabled or not found
+func (r *Reconciler) loadWallets(ctx context.Context, coinName string) (
+	coin *queries.Coin, wts []queries.Wallet, err error,
+) {
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		var coins []queries.Coin
+		err := dbTx.Where("upper(short_name) = ? and enabled is true", coinName).Find(&coins).Error
+		if err != nil {
+			return err
+		}
+		if len(coins) == 0 {
+			return nil
+		}
+		coin = &coins[0]
+
+		return dbTx.Where("coin_id = ? and watch_only is false", coin.ID).Preload("Coin").Preload("Addresses").Find(&wts).Error
+	})
+	return
+}
+
+// heldAmountsQuery calculates amounts which are taken from senders wallets but not delivered yet: internal txs which
+// await recipient or are scheduled holding funds and external txs which are queued for batch or not confirmed yet
+const heldAmountsQuery = `select
+  coalesce(sum(txs.amount) filter (where txs.type = 'internal' and (tx_statuses.name = 'pending' or (tx_statuses.name = 'scheduled' and txs.hold_funds))), 0),
+  coalesce(sum(txs.amount) filter (where txs.type = 'external' and tx_statuses.name = ANY('{validation, queued_for_batch, send_external, waiting}' :: varchar(30) [])), 0),
+  coalesce(sum(txs.blockchain_fee) filter (where txs.type = 'external' and tx_statuses.name = ANY('{validation, queued_for_batch, send_external, waiting}' :: varchar(30) [])), 0)
+from txs
+  inner join tx_statuses on tx_statuses.id = txs.status_id
+  inner join wallets on wallets.id = txs.from_wallet_id
+where wallets.coin_id = ?`
+
+func (r *Reconciler) queryHeldAmounts(ctx context.Context, coinID int64) (
+	pending, inFlightAmount, inFlightFee *decimal.Big, err error,
+) {
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		rows, err := dbTx.Raw(heldAmountsQuery, coinID).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p, a, f postgres.Decimal
+			err = rows.Scan(&p, &a, &f)
+			if err != nil {
+				return err
+			}
+			pending, inFlightAmount, inFlightFee = p.V, a.V, f.V
+		}
+		return rows.Err()
+	})
+	return
+}
+
+// recordedReceiptsQuery sums successful external txs amounts grouped by recipient address
+const recordedReceiptsQuery = `select txs_external.recipient, coalesce(sum(txs.amount), 0)
+from txs_external
+  inner join txs on txs.id = txs_external.tx_id
+  inner join tx_statuses on tx_statuses.id = txs.status_id
+where txs_external.recipient = ANY (?::varchar[]) and tx_statuses.name = 'success'
+group by txs_external.recipient`
+
+// reconcileAddresses compares on-chain receipts of each wallet address (including historical ones) with receipts recorded in txs_external.
+// Addresses may receive money from outside, so only receipts which are recorded but not observed on chain are
+// treated as discrepancies.
+func (r *Reconciler) reconcileAddresses(ctx context.Context, coinName string, wts []queries.Wallet) (
+	discrepancies []AddressDiscrepancy, err error,
+) {
+	if len(wts) == 0 {
+		return
+	}
+
+	addresses := make([]string, 0, len(wts))
+	for i := range wts {
+		addresses = append(addresses, wts[i].AllAddresses()...)
+	}
+
+	recorded := make(map[string]*decimal.Big, len(wts))
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		rows, err := dbTx.Raw(recordedReceiptsQuery, pq.Array(addresses)).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				address string
+				sum     postgres.Decimal
+			)
+			err = rows.Scan(&address, &sum)
+			if err != nil {
+				return err
+			}
+			recorded[address] = sum.V
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return
+	}
+
+	tolerance := r.coinTolerance(coinName)
+	observer := r.coordinator.Observer(coinName)
+	for i := range wts {
+		w := &wts[i]
+		for _, address := range w.AllAddresses() {
+			recordedReceived, ok := recorded[address]
+			if !ok {
+				continue
+			}
+
+			var onChainReceived *decimal.Big
+			onChainReceived, err = observer.Balance(ctx, address)
+			if err != nil {
+				return
+			}
+
+			difference := new(decimal.Big).Sub(onChainReceived, recordedReceived)
+			if new(decimal.Big).Neg(difference).Cmp(tolerance) > 0 {
+				discrepancies = append(discrepancies, AddressDiscrepancy{
+					WalletID:         w.ID,
+					Address:          address,
+					OnChainReceived:  &processing.Decimal{V: onChainReceived},
+					RecordedReceived: &processing.Decimal{V: recordedReceived},
+					Difference:       &processing.Decimal{V: difference},
+				})
+			}
+		}
+	}
+	return
+}
+
+func (r *Reconciler) coinTolerance(coinName string) *decimal.Big {
+	if t, ok := r.coinsTolerances[coinName]; ok {
+		return t
+	}
+	return r.tolerance
+}
+
+func (r *Reconciler) alert(report *Report) {
+	if r.reporter == nil {
+		return
+	}
+	r.reporter.ReportErr(
+		errors.Wrapf(
+			ErrToleranceExceeded,
+			"report %d: discrepancy %s, in-flight %s, fee %s, %d addresses discrepancies",
+			report.ID,
+			report.Discrepancy.V,
+			report.InFlightAmount.V,
+			report.InFlightFee.V,
+			len(report.Addresses),
+		),
+		map[string]string{"coin": report.Coin.ShortName, "report_id": strconv.FormatInt(report.ID, 10)},
+	)
+}
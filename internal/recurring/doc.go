@@ -0,0 +1,2 @@
+// Package recurring defines recurring payment plans which spawn ordinary transactions from the user wallet on schedule
+package recurring
@@ -0,0 +1,153 @@
+package recurring
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/ericlagergren/decimal"
+	"time"
+)
+
+// Period defines how often plan spawns transactions
+type Period string
+
+const (
+	PeriodDaily   Period = "daily"
+	PeriodWeekly  Period = "weekly"
+	PeriodMonthly Period = "monthly"
+)
+
+// IsValid
+func (p Period) IsValid() bool {
+	return p == PeriodDaily || p == PeriodWeekly || p == PeriodMonthly
+}
+
+// Next returns occurrence time following t. Monthly period keeps the day of month of anchor (first occurrence time),
+// if month is shorter, the last day of month is used.
+func (p Period) Next(t, anchor time.Time) time.Time {
+	switch p {
+	case PeriodWeekly:
+		return t.AddDate(0, 0, 7)
+	case PeriodMonthly:
+		year, month, _ := t.Date()
+		firstDay := time.Date(year, month+1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		day := anchor.Day()
+		if lastDay := firstDay.AddDate(0, 1, -1).Day(); day > lastDay {
+			day = lastDay
+		}
+		return firstDay.AddDate(0, 0, day-1)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// RecipientType defines how plan recipient is interpreted
+type RecipientType string
+
+const (
+	RecipientPhone   RecipientType = "phone"
+	RecipientAddress RecipientType = "address"
+)
+
+// Plan statuses
+const (
+	PlanActive    = "active"
+	PlanPaused    = "paused"
+	PlanCompleted = "completed"
+	PlanCanceled  = "canceled"
+)
+
+// Plan is recurring payment plan which spawns ordinary transactions from the wallet on schedule
+type Plan struct {
+	ID        int64
+	UserPhone string
+	WalletID  int64
+	Wallet    *queries.Wallet `gorm:"foreignkey:WalletID;association_autoupdate:false;association_autocreate:false"`
+
+	// Recipient is a phone number or an address depending on RecipientType
+	Recipient     string
+	RecipientType RecipientType
+	Amount        *processing.Decimal
+
+	Period Period
+
+	// StartsAt is the first occurrence time, monthly plans keep its day of month when possible
+	StartsAt  time.Time
+	NextRunAt time.Time
+
+	// EndsAt and MaxCount optionally limit plan, plan is completed when any of them is reached
+	EndsAt    *time.Time
+	MaxCount  *int64
+	RunsCount int64
+
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Occurrences []Occurrence `gorm:"foreignkey:PlanID"`
+}
+
+func (Plan) TableName() string {
+	return "recurring_plans"
+}
+
+// IsActive
+func (p *Plan) IsActive() bool {
+	return p.Status == PlanActive
+}
+
+// IsFinished true if plan won't spawn transactions anymore
+func (p *Plan) IsFinished() bool {
+	return p.Status == PlanCompleted || p.Status == PlanCanceled
+}
+
+// skipMissed moves next occurrence to the first one which is after now
+func (p *Plan) skipMissed(now time.Time) {
+	for !p.NextRunAt.After(now) {
+		p.NextRunAt = p.Period.Next(p.NextRunAt, p.StartsAt)
+	}
+}
+
+// limitsReached true if plan has spawned max count of transactions or next occurrence is after plan end
+func (p *Plan) limitsReached() bool {
+	return (p.MaxCount != nil && p.RunsCount >= *p.MaxCount) || (p.EndsAt != nil && p.NextRunAt.After(*p.EndsAt))
+}
+
+// Occurrence records single plan run, TxID is set if transaction has been created, Error is set if the run failed,
+// failed run may have transaction which has been declined
+type Occurrence struct {
+	ID          int64
+	PlanID      int64
+	ScheduledAt time.Time
+	TxID        *int64
+	Error       *string
+	CreatedAt   time.Time
+}
+
+func (Occurrence) TableName() string {
+	return "recurring_occurrences"
+}
+
+// PlanParams describes new plan
+type PlanParams struct {
+	WalletID      int64
+	Recipient     string
+	RecipientType RecipientType
+	Amount        *decimal.Big
+	Period        Period
+
+	// StartsAt is the first occurrence time, current time is used if zero
+	StartsAt time.Time
+
+	EndsAt   *time.Time
+	MaxCount *int64
+}
+
+// PlanUpdate describes plan changes, nil fields are left untouched
+type PlanUpdate struct {
+	Amount   *decimal.Big
+	EndsAt   *time.Time
+	MaxCount *int64
+
+	// Paused pauses or resumes plan, missed occurrences of paused plan are skipped
+	Paused *bool
+}
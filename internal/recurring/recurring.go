@@ -0,0 +1,517 @@
+package recurring
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/jinzhu/gorm"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"time"
+)
+
+var (
+	// ErrNoSuchPlan returned when user has no plan with given id
+	ErrNoSuchPlan = errors.New("recurring: no such plan")
+
+	// ErrInvalidPeriod returned when plan period is unknown
+	ErrInvalidPeriod = errors.New("recurring: invalid period")
+
+	// ErrInvalidRecipientType returned when plan recipient type is unknown
+	ErrInvalidRecipientType = errors.New("recurring: invalid recipient type")
+
+	// ErrStartsAtInPast returned when plan first occurrence time is in the past
+	ErrStartsAtInPast = errors.New("recurring: plan start time is in the past")
+
+	// ErrInvalidEndsAt returned when plan end time is before it's start or current time
+	ErrInvalidEndsAt = errors.New("recurring: plan end time is before start or current time")
+
+	// ErrNonPositiveMaxCount returned when plan occurrences limit isn't positive
+	ErrNonPositiveMaxCount = errors.New("recurring: plan max count must be positive")
+
+	// ErrPlanFinished returned on attempt to change completed or canceled plan
+	ErrPlanFinished = errors.New("recurring: plan is completed or canceled")
+)
+
+const (
+	// startsAtTolerance allows plan start time to be slightly in the past due to request latency
+	startsAtTolerance = time.Minute
+
+	// occurrencesLimit is the number of the latest occurrences returned with the plan
+	occurrencesLimit = 50
+
+	// maxErrorLen is the occurrence error column size
+	maxErrorLen = 512
+)
+
+// IRecurring manages user recurring payment plans and executes them
+type IRecurring interface {
+	// Create validates and creates active plan, first occurrence is executed at params StartsAt. Returns wallets
+	// errors if source wallet or recipient are invalid.
+	Create(ctx context.Context, userPhone string, params PlanParams) (plan *Plan, err error)
+
+	// Get returns user plan with the latest occurrences. Returns ErrNoSuchPlan.
+	Get(ctx context.Context, userPhone string, planID int64) (plan *Plan, err error)
+
+	// GetAll returns all user plans, the latest are first
+	GetAll(ctx context.Context, userPhone string) (plans []Plan, err error)
+
+	// Update changes plan amount, limits or pauses it. Returns ErrNoSuchPlan and ErrPlanFinished.
+	Update(ctx context.Context, userPhone string, planID int64, update PlanUpdate) (plan *Plan, err error)
+
+	// Cancel cancels plan, already spawned transactions aren't affected. Returns ErrNoSuchPlan and ErrPlanFinished.
+	Cancel(ctx context.Context, userPhone string, planID int64) (plan *Plan, err error)
+
+	// ExecuteDue spawns transactions of active plans which next occurrence time has come. Failed occurrence is
+	// recorded and notified as declined, but plan stays active. Missed occurrences (e.g. due to worker downtime) are
+	// skipped, so each plan spawns at most one transaction per call.
+	ExecuteDue(ctx context.Context) (executedNum int, err error)
+}
+
+// Recurring is IRecurring implementation
+type Recurring struct {
+	database    *gorm.DB
+	walletsApi  *wallets.Api
+	notificator isc.ITxsEventNotificator
+}
+
+// New creates recurring payments manager which sends transactions using wallets api
+func New(database *gorm.DB, walletsApi *wallets.Api, notificator isc.ITxsEventNotificator) *Recurring {
+	return &Recurring{database: database, walletsApi: walletsApi, notificator: notificator}
+}
+
+// Create implements IRecurring
+func (r *Recurring) Create(ctx context.Context, userPhone string, params PlanParams) (plan *Plan, err error) {
+	err = trace.InsideSpanE(ctx, "create_recurring_plan", func(ctx context.Context, span ot.Span) error {
+		span.LogKV(
+			"user_phone", userPhone,
+			"wallet_id", params.WalletID,
+			"recipient", params.Recipient,
+			"recipient_type", params.RecipientType,
+			"amount", params.Amount,
+			"period", params.Period,
+		)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if params.StartsAt.IsZero() {
+			params.StartsAt = now
+		}
+		params.StartsAt = params.StartsAt.UTC()
+
+		// gather validation errors
+		var validationErrs error
+		if params.Amount == nil || params.Amount.Sign() <= 0 {
+			validationErrs = merrors.Append(validationErrs, errs.ErrNonPositiveAmount)
+		}
+		if !params.Period.IsValid() {
+			validationErrs = merrors.Append(validationErrs, ErrInvalidPeriod)
+		}
+		if params.StartsAt.Before(now.Add(-startsAtTolerance)) {
+			validationErrs = merrors.Append(validationErrs, ErrStartsAtInPast)
+		}
+		if params.EndsAt != nil && params.EndsAt.Before(params.StartsAt) {
+			validationErrs = merrors.Append(validationErrs, ErrInvalidEndsAt)
+		}
+		if params.MaxCount != nil && *params.MaxCount <= 0 {
+			validationErrs = merrors.Append(validationErrs, ErrNonPositiveMaxCount)
+		}
+
+		switch params.RecipientType {
+		case RecipientPhone:
+			var phoneErr error
+			params.Recipient, phoneErr = wallets.CoercePhone(params.Recipient)
+			if phoneErr != nil {
+				validationErrs = merrors.Append(validationErrs, phoneErr)
+			} else if params.Recipient == userPhone {
+				validationErrs = merrors.Append(validationErrs, errs.ErrSelfTxForbidden)
+			}
+		case RecipientAddress:
+			addrErr := r.walletsApi.ValidateAddress(ctx, userPhone, params.WalletID, params.Recipient)
+			if addrErr != nil {
+				validationErrs = merrors.Append(validationErrs, addrErr)
+			}
+		default:
+			validationErrs = merrors.Append(validationErrs, ErrInvalidRecipientType)
+		}
+
+		if validationErrs != nil {
+			return validationErrs
+		}
+
+		return db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			wallet, err := getSourceWallet(dbTx, userPhone, params.WalletID)
+			if err != nil {
+				return err
+			}
+
+			plan = &Plan{
+				UserPhone:     userPhone,
+				WalletID:      wallet.ID,
+				Wallet:        wallet,
+				Recipient:     params.Recipient,
+				RecipientType: params.RecipientType,
+				Amount:        &processing.Decimal{V: params.Amount},
+				Period:        params.Period,
+				StartsAt:      params.StartsAt,
+				NextRunAt:     params.StartsAt,
+				EndsAt:        params.EndsAt,
+				MaxCount:      params.MaxCount,
+				Status:        PlanActive,
+			}
+			err = dbTx.Create(plan).Error
+			if err != nil {
+				return err
+			}
+			span.LogKV("plan_id", plan.ID)
+			return nil
+		})
+	})
+	return
+}
+
+// Get implements IRecurring
+func (r *Recurring) Get(ctx context.Context, userPhone string, planID int64) (plan *Plan, err error) {
+	err = trace.InsideSpanE(ctx, "get_recurring_plan", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone, "plan_id", planID)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			plan, err = getPlan(dbTx.Preload("Occurrences", func(q *gorm.DB) *gorm.DB {
+				return q.Order("scheduled_at desc").Limit(occurrencesLimit)
+			}), userPhone, planID)
+			return err
+		})
+	})
+	return
+}
+
+// GetAll implements IRecurring
+func (r *Recurring) GetAll(ctx context.Context, userPhone string) (plans []Plan, err error) {
+	err = trace.InsideSpanE(ctx, "get_recurring_plans", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			return dbTx.Where("user_phone = ?", userPhone).Preload(
+				"Wallet",
+			).Preload(
+				"Wallet.Coin",
+			).Order("id desc").Find(&plans).Error
+		})
+	})
+	return
+}
+
+// Update implements IRecurring
+func (r *Recurring) Update(ctx context.Context, userPhone string, planID int64, update PlanUpdate) (
+	plan *Plan, err error,
+) {
+	err = trace.InsideSpanE(ctx, "update_recurring_plan", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone, "plan_id", planID)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		// gather validation errors
+		var validationErrs error
+		if update.Amount != nil && update.Amount.Sign() <= 0 {
+			validationErrs = merrors.Append(validationErrs, errs.ErrNonPositiveAmount)
+		}
+		if update.EndsAt != nil && update.EndsAt.Before(now) {
+			validationErrs = merrors.Append(validationErrs, ErrInvalidEndsAt)
+		}
+		if update.MaxCount != nil && *update.MaxCount <= 0 {
+			validationErrs = merrors.Append(validationErrs, ErrNonPositiveMaxCount)
+		}
+		if validationErrs != nil {
+			return validationErrs
+		}
+
+		return db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			plan, err = lockPlan(dbTx, userPhone, planID)
+			if err != nil {
+				return err
+			}
+			if plan.IsFinished() {
+				return ErrPlanFinished
+			}
+
+			if update.Amount != nil {
+				plan.Amount = &processing.Decimal{V: update.Amount}
+			}
+			if update.EndsAt != nil {
+				endsAt := update.EndsAt.UTC()
+				plan.EndsAt = &endsAt
+			}
+			if update.MaxCount != nil {
+				plan.MaxCount = update.MaxCount
+			}
+			if update.Paused != nil {
+				switch {
+				case *update.Paused && plan.IsActive():
+					plan.Status = PlanPaused
+				case !*update.Paused && plan.Status == PlanPaused:
+					plan.Status = PlanActive
+					// occurrences missed while plan has been paused aren't executed
+					plan.skipMissed(now)
+				}
+			}
+			if plan.limitsReached() {
+				plan.Status = PlanCompleted
+			}
+			span.LogKV("status", plan.Status)
+
+			return dbTx.Model(plan).Updates(map[string]interface{}{
+				"amount":      plan.Amount,
+				"ends_at":     plan.EndsAt,
+				"max_count":   plan.MaxCount,
+				"status":      plan.Status,
+				"next_run_at": plan.NextRunAt,
+			}).Error
+		})
+	})
+	return
+}
+
+// Cancel implements IRecurring
+func (r *Recurring) Cancel(ctx context.Context, userPhone string, planID int64) (plan *Plan, err error) {
+	err = trace.InsideSpanE(ctx, "cancel_recurring_plan", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone, "plan_id", planID)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			plan, err = lockPlan(dbTx, userPhone, planID)
+			if err != nil {
+				return err
+			}
+			if plan.IsFinished() {
+				return ErrPlanFinished
+			}
+
+			plan.Status = PlanCanceled
+			return dbTx.Model(plan).Update("status", plan.Status).Error
+		})
+	})
+	return
+}
+
+// ExecuteDue implements IRecurring
+func (r *Recurring) ExecuteDue(ctx context.Context) (executedNum int, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "execute_due_recurring_plans")
+	defer span.Finish()
+
+	var dueIDs []int64
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Model(&Plan{}).Where(
+			"status = ? and next_run_at <= ?", PlanActive, time.Now().UTC(),
+		).Order("next_run_at").Pluck("id", &dueIDs).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+
+	span.LogKV("due_plans_num", len(dueIDs))
+
+	// failed plan doesn't prevent others execution
+	for _, id := range dueIDs {
+		executed, execErr := r.executePlan(ctx, id)
+		if execErr != nil {
+			trace.LogErrorWithMsg(span, execErr, "recurring plan execution failed")
+			err = merrors.Append(err, execErr)
+			continue
+		}
+		if executed {
+			executedNum++
+		}
+	}
+	span.LogKV("executed_plans_num", executedNum)
+	return
+}
+
+// executePlan spawns transaction of the plan with given id unless it has been changed meanwhile. Occurrence is
+// recorded and plan is advanced before sending, so crash during sending won't cause double payment.
+func (r *Recurring) executePlan(ctx context.Context, id int64) (executed bool, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "execute_recurring_plan")
+	defer span.Finish()
+
+	span.LogKV("plan_id", id)
+
+	var (
+		plan       Plan
+		occurrence Occurrence
+	)
+	now := time.Now().UTC()
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		// lock plan, so occurrence won't be spawned twice by concurrent workers
+		err := dbTx.Exec("select 1 from recurring_plans where id = ? for update", id).Error
+		if err != nil {
+			return err
+		}
+
+		err = dbTx.Where("id = ?", id).Preload("Wallet").Preload("Wallet.Coin").First(&plan).Error
+		if err != nil {
+			return err
+		}
+		if !plan.IsActive() || plan.NextRunAt.After(now) {
+			trace.LogMsg(span, "plan isn't due anymore")
+			return nil
+		}
+
+		occurrence = Occurrence{PlanID: plan.ID, ScheduledAt: plan.NextRunAt}
+		err = dbTx.Create(&occurrence).Error
+		if err != nil {
+			return err
+		}
+
+		plan.RunsCount++
+		plan.skipMissed(now)
+		if plan.limitsReached() {
+			plan.Status = PlanCompleted
+		}
+
+		executed = true
+		return dbTx.Model(&plan).Updates(map[string]interface{}{
+			"runs_count":  plan.RunsCount,
+			"next_run_at": plan.NextRunAt,
+			"status":      plan.Status,
+		}).Error
+	})
+	if err != nil || !executed {
+		return
+	}
+
+	span.LogKV("occurrence_id", occurrence.ID, "next_run_at", plan.NextRunAt, "status", plan.Status)
+
+	tx, sendErr := r.send(ctx, &plan)
+	if tx != nil {
+		occurrence.TxID = &tx.ID
+	}
+	if sendErr != nil {
+		trace.LogErrorWithMsg(span, sendErr, "recurring payment failed")
+		errMsg := sendErr.Error()
+		if runes := []rune(errMsg); len(runes) > maxErrorLen {
+			errMsg = string(runes[:maxErrorLen])
+		}
+		occurrence.Error = &errMsg
+	}
+
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Model(&occurrence).Updates(map[string]interface{}{
+			"tx_id": occurrence.TxID,
+			"error": occurrence.Error,
+		}).Error
+	})
+	if err != nil {
+		trace.LogErrorWithMsg(span, err, "occurrence result recording failed")
+	}
+
+	// user isn't waiting for the response, so failure should be reported
+	if sendErr != nil {
+		notifErr := r.notificator.Declined(planEventPayload(&plan, tx), sendErr)
+		if notifErr != nil {
+			trace.LogErrorWithMsg(span, notifErr, "declined notification failed")
+		}
+	}
+	return
+}
+
+// send spawns ordinary transaction of the plan, declined transaction may be returned alongside with error
+func (r *Recurring) send(ctx context.Context, plan *Plan) (*processing.Tx, error) {
+	if plan.RecipientType == RecipientAddress {
+		return r.walletsApi.SentToAddress(ctx, plan.UserPhone, plan.WalletID, plan.Recipient, plan.Amount.V, nil)
+	}
+	return r.walletsApi.SendToPhone(ctx, plan.UserPhone, plan.WalletID, plan.Recipient, 0, plan.Amount.V, nil)
+}
+
+// getSourceWallet returns user wallet which is able to send funds
+func getSourceWallet(dbTx *gorm.DB, userPhone string, walletID int64) (*queries.Wallet, error) {
+	wallet := new(queries.Wallet)
+	err := dbTx.Where("id = ? and user_phone = ?", walletID, userPhone).Preload("Coin").First(wallet).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errs.ErrNoSuchWallet
+	}
+	if err != nil {
+		return nil, err
+	}
+	if wallet.WatchOnly {
+		return nil, errs.ErrWatchOnlyWallet
+	}
+
+	err = wallets.CheckAccountOpenGorm(dbTx, userPhone, errs.ErrAccountClosed)
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// getPlan queries user plan using given query, returns ErrNoSuchPlan if not found
+func getPlan(q *gorm.DB, userPhone string, planID int64) (*Plan, error) {
+	plan := new(Plan)
+	err := q.Where("id = ? and user_phone = ?", planID, userPhone).Preload(
+		"Wallet",
+	).Preload(
+		"Wallet.Coin",
+	).First(plan).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNoSuchPlan
+	}
+	return plan, err
+}
+
+// lockPlan locks and queries user plan, so it won't be executed while changed
+func lockPlan(dbTx *gorm.DB, userPhone string, planID int64) (*Plan, error) {
+	err := dbTx.Exec("select 1 from recurring_plans where id = ? for update", planID).Error
+	if err != nil {
+		return nil, err
+	}
+	return getPlan(dbTx, userPhone, planID)
+}
+
+// planEventPayload describes failed plan occurrence, tx is optional
+func planEventPayload(plan *Plan, tx *processing.Tx) isc.TxEventPayload {
+	payload := isc.TxEventPayload{
+		FromPhone: plan.UserPhone,
+		Amount:    plan.Amount.V,
+	}
+	if plan.Wallet != nil {
+		payload.Coin = plan.Wallet.Coin.ShortName
+		payload.FromWalletName = plan.Wallet.Name
+	}
+	if tx != nil {
+		payload.Type = string(tx.Type)
+	}
+	if plan.RecipientType == RecipientAddress {
+		payload.ToAddress = plan.Recipient
+	} else {
+		payload.ToPhone = plan.Recipient
+	}
+	return payload
+}
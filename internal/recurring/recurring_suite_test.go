@@ -0,0 +1,45 @@
+package recurring_test
+
+import (
+	"testing"
+	"time"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/recurring"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRecurring(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Recurring Suite")
+}
+
+var _ = Describe("testing plan periods", func() {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 30, 0, 0, time.UTC)
+	}
+
+	It("should add day and week", func() {
+		t := date(2018, time.December, 31)
+		Expect(recurring.PeriodDaily.Next(t, t)).To(Equal(date(2019, time.January, 1)))
+		Expect(recurring.PeriodWeekly.Next(t, t)).To(Equal(date(2019, time.January, 7)))
+	})
+
+	It("should keep anchor day of month", func() {
+		anchor := date(2019, time.January, 15)
+		Expect(recurring.PeriodMonthly.Next(anchor, anchor)).To(Equal(date(2019, time.February, 15)))
+	})
+
+	It("should use last day of shorter month and return to anchor day", func() {
+		anchor := date(2019, time.January, 31)
+		feb := recurring.PeriodMonthly.Next(anchor, anchor)
+		Expect(feb).To(Equal(date(2019, time.February, 28)))
+		Expect(recurring.PeriodMonthly.Next(feb, anchor)).To(Equal(date(2019, time.March, 31)))
+		Expect(recurring.PeriodMonthly.Next(date(2019, time.March, 31), anchor)).To(Equal(date(2019, time.April, 30)))
+	})
+
+	It("should switch year", func() {
+		anchor := date(2018, time.December, 5)
+		Expect(recurring.PeriodMonthly.Next(anchor, anchor)).To(Equal(date(2019, time.January, 5)))
+	})
+})
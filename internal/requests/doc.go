@@ -0,0 +1,3 @@
+// Package requests defines payment requests: user asks another user for money, payer may accept request which sends
+// ordinary transaction to the requester, or decline it
+package requests
@@ -0,0 +1,79 @@
+package requests
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/ericlagergren/decimal"
+	"time"
+)
+
+// Request statuses
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusDeclined = "declined"
+	StatusCanceled = "canceled"
+	StatusExpired  = "expired"
+)
+
+// Request is payment request sent by requester to payer, amount is specified either in coin or in fiat currency, in
+// the last case it's converted into coin amount at the moment of acceptance
+type Request struct {
+	ID             int64
+	RequesterPhone string
+	PayerPhone     string
+	CoinID         int64
+	Coin           *queries.Coin `gorm:"foreignkey:CoinID;association_autoupdate:false;association_autocreate:false"`
+
+	Amount       *processing.Decimal
+	FiatAmount   *processing.Decimal
+	FiatCurrency *string
+	Comment      *string
+
+	Status    string
+	ExpiresAt time.Time
+
+	// TxID is the transaction which paid accepted request
+	TxID *int64
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Request) TableName() string {
+	return "payment_requests"
+}
+
+// IsPending true if request still may be accepted, declined or canceled
+func (r *Request) IsPending() bool {
+	return r.Status == StatusPending
+}
+
+// isOutdated true if pending request hasn't been accepted in time
+func (r *Request) isOutdated(now time.Time) bool {
+	return r.IsPending() && !r.ExpiresAt.After(now)
+}
+
+// RequestParams describes new request, either Amount or FiatAmount with FiatCurrency must be given
+type RequestParams struct {
+	PayerPhone   string
+	Coin         string
+	Amount       *decimal.Big
+	FiatAmount   *decimal.Big
+	FiatCurrency string
+	Comment      string
+
+	// ExpiresAt is the time until which request may be accepted, default ttl is used if zero
+	ExpiresAt time.Time
+}
+
+// Direction filters user requests by user side
+type Direction string
+
+const (
+	// DirectionIncoming requests sent to the user
+	DirectionIncoming Direction = "incoming"
+
+	// DirectionOutgoing requests sent by the user
+	DirectionOutgoing Direction = "outgoing"
+)
@@ -0,0 +1,581 @@
+package requests
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrNoSuchRequest returned when user is neither requester nor payer of the request with given id
+	ErrNoSuchRequest = errors.New("requests: no such request")
+
+	// ErrInvalidAmount returned when both or none of coin and fiat amounts are given
+	ErrInvalidAmount = errors.New("requests: either coin or fiat amount must be given")
+
+	// ErrSelfRequest returned when user asks himself for money
+	ErrSelfRequest = errors.New("requests: payer is the requester")
+
+	// ErrExpiresAtInPast returned when request expiration time is in the past
+	ErrExpiresAtInPast = errors.New("requests: expiration time is in the past")
+
+	// ErrInvalidDirection returned when requests direction filter is unknown
+	ErrInvalidDirection = errors.New("requests: invalid direction")
+
+	// ErrRequestNotPending returned on attempt to change request which has been already accepted, declined, canceled
+	// or expired
+	ErrRequestNotPending = errors.New("requests: request isn't pending")
+
+	// ErrRequestExpired returned on attempt to change request which expiration time has come
+	ErrRequestExpired = errors.New("requests: request is expired")
+
+	// ErrWalletCoinMismatch returned when payer wallet coin differs from the request coin
+	ErrWalletCoinMismatch = errors.New("requests: wallet coin differs from request coin")
+
+	// ErrCommentTooLong returned when request comment exceeds maxCommentLen characters
+	ErrCommentTooLong = errors.New("requests: comment is too long")
+)
+
+const (
+	// defaultTTL is the request lifetime used if expiration time isn't given
+	defaultTTL = time.Hour * 72
+
+	// maxCommentLen is the comment column size in characters
+	maxCommentLen = 255
+)
+
+// IRequests manages payment requests between users
+type IRequests interface {
+	// Create validates and creates pending request from the requester to the payer, payer is notified. Returns
+	// ErrInvalidAmount, ErrSelfRequest, ErrExpiresAtInPast, ErrCommentTooLong, errs.ErrNoSuchCoin and
+	// convert.ErrFiatCurrencyName as validation errors.
+	Create(ctx context.Context, requesterPhone string, params RequestParams) (request *Request, err error)
+
+	// Get returns request which user is requester or payer of. Returns ErrNoSuchRequest.
+	Get(ctx context.Context, userPhone string, requestID int64) (request *Request, err error)
+
+	// GetAll returns user requests of given direction, the latest are first. Optional status filters requests.
+	GetAll(ctx context.Context, userPhone string, direction Direction, status string) (requests []Request, err error)
+
+	// Accept pays request from the payer wallet by ordinary transaction to the requester, fiat amount is converted
+	// into coin amount at current rate. Request stays pending if transaction fails, so it may be accepted again.
+	// Returns ErrNoSuchRequest, ErrRequestNotPending, ErrRequestExpired, ErrWalletCoinMismatch and wallets errors.
+	Accept(ctx context.Context, payerPhone string, requestID, walletID int64) (
+		request *Request, tx *processing.Tx, err error,
+	)
+
+	// Decline declines request by the payer. Returns ErrNoSuchRequest, ErrRequestNotPending and ErrRequestExpired.
+	Decline(ctx context.Context, payerPhone string, requestID int64) (request *Request, err error)
+
+	// Cancel cancels request by the requester. Returns ErrNoSuchRequest, ErrRequestNotPending and ErrRequestExpired.
+	Cancel(ctx context.Context, requesterPhone string, requestID int64) (request *Request, err error)
+
+	// ExpireOutdated expires pending requests which expiration time has come, returns number of expired requests
+	ExpireOutdated(ctx context.Context) (expiredNum int, err error)
+}
+
+// Requests is IRequests implementation
+type Requests struct {
+	database    *gorm.DB
+	walletsApi  *wallets.Api
+	converter   convert.ICryptoCurrency
+	notificator isc.IPaymentRequestsEventNotificator
+}
+
+// New creates payment requests manager which pays requests using wallets api
+func New(
+	database *gorm.DB,
+	walletsApi *wallets.Api,
+	converter convert.ICryptoCurrency,
+	notificator isc.IPaymentRequestsEventNotificator,
+) *Requests {
+	return &Requests{database: database, walletsApi: walletsApi, converter: converter, notificator: notificator}
+}
+
+// Create implements IRequests
+func (r *Requests) Create(ctx context.Context, requesterPhone string, params RequestParams) (
+	request *Request, err error,
+) {
+	err = trace.InsideSpanE(ctx, "create_payment_request", func(ctx context.Context, span ot.Span) error {
+		span.LogKV(
+			"requester_phone", requesterPhone,
+			"payer_phone", params.PayerPhone,
+			"coin", params.Coin,
+			"amount", params.Amount,
+			"fiat_amount", params.FiatAmount,
+			"fiat_currency", params.FiatCurrency,
+		)
+
+		requesterPhone, err = wallets.CoercePhone(requesterPhone)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if params.ExpiresAt.IsZero() {
+			params.ExpiresAt = now.Add(defaultTTL)
+		}
+		params.ExpiresAt = params.ExpiresAt.UTC()
+
+		// gather validation errors
+		var validationErrs error
+		var phoneErr error
+		params.PayerPhone, phoneErr = wallets.CoercePhone(params.PayerPhone)
+		if phoneErr != nil {
+			validationErrs = merrors.Append(validationErrs, phoneErr)
+		} else if params.PayerPhone == requesterPhone {
+			validationErrs = merrors.Append(validationErrs, ErrSelfRequest)
+		}
+
+		isFiat := params.FiatAmount != nil || params.FiatCurrency != ""
+		switch {
+		case (params.Amount == nil) == !isFiat, isFiat && (params.FiatAmount == nil || params.FiatCurrency == ""):
+			validationErrs = merrors.Append(validationErrs, ErrInvalidAmount)
+		case isFiat && params.FiatAmount.Sign() <= 0, !isFiat && params.Amount.Sign() <= 0:
+			validationErrs = merrors.Append(validationErrs, errs.ErrNonPositiveAmount)
+		}
+
+		if !params.ExpiresAt.After(now) {
+			validationErrs = merrors.Append(validationErrs, ErrExpiresAtInPast)
+		}
+		if utf8.RuneCountInString(params.Comment) > maxCommentLen {
+			validationErrs = merrors.Append(validationErrs, ErrCommentTooLong)
+		}
+
+		if isFiat && params.FiatCurrency != "" {
+			// only currency name is validated, rate may change until acceptance
+			_, rateErr := r.converter.GetRate(ctx, params.Coin, params.FiatCurrency)
+			if rateErr == convert.ErrFiatCurrencyName {
+				validationErrs = merrors.Append(validationErrs, rateErr)
+			} else if rateErr != nil {
+				trace.LogErrorWithMsg(span, rateErr, "fiat currency validation skipped")
+			}
+		}
+
+		if validationErrs != nil {
+			return validationErrs
+		}
+
+		err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			coin := new(queries.Coin)
+			err := dbTx.Where("short_name = ? and enabled = true", strings.ToUpper(params.Coin)).First(coin).Error
+			if err == gorm.ErrRecordNotFound {
+				return errs.ErrNoSuchCoin
+			}
+			if err != nil {
+				return err
+			}
+
+			err = wallets.CheckAccountOpenGorm(dbTx, requesterPhone, errs.ErrAccountClosed)
+			if err != nil {
+				return err
+			}
+			err = wallets.CheckAccountOpenGorm(dbTx, params.PayerPhone, errs.ErrRecipientAccountClosed)
+			if err != nil {
+				return err
+			}
+
+			request = &Request{
+				RequesterPhone: requesterPhone,
+				PayerPhone:     params.PayerPhone,
+				CoinID:         coin.ID,
+				Coin:           coin,
+				Status:         StatusPending,
+				ExpiresAt:      params.ExpiresAt,
+			}
+			if isFiat {
+				fiatCurrency := strings.ToUpper(params.FiatCurrency)
+				request.FiatAmount = &processing.Decimal{V: params.FiatAmount}
+				request.FiatCurrency = &fiatCurrency
+			} else {
+				request.Amount = &processing.Decimal{V: params.Amount}
+			}
+			if params.Comment != "" {
+				request.Comment = &params.Comment
+			}
+
+			err = dbTx.Create(request).Error
+			if err != nil {
+				return err
+			}
+			span.LogKV("request_id", request.ID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		notifErr := r.notificator.Created(requestEventPayload(request))
+		if notifErr != nil {
+			trace.LogErrorWithMsg(span, notifErr, "created notification failed")
+		}
+		return nil
+	})
+	return
+}
+
+// Get implements IRequests
+func (r *Requests) Get(ctx context.Context, userPhone string, requestID int64) (request *Request, err error) {
+	err = trace.InsideSpanE(ctx, "get_payment_request", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone, "request_id", requestID)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			request, err = getRequest(dbTx.Where("requester_phone = ? or payer_phone = ?", userPhone, userPhone), requestID)
+			if err != nil {
+				return err
+			}
+			presentStatus(request, time.Now().UTC())
+			return nil
+		})
+	})
+	return
+}
+
+// GetAll implements IRequests
+func (r *Requests) GetAll(ctx context.Context, userPhone string, direction Direction, status string) (
+	requests []Request, err error,
+) {
+	err = trace.InsideSpanE(ctx, "get_payment_requests", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone, "direction", direction, "status", status)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			q := dbTx
+			switch direction {
+			case DirectionIncoming:
+				q = q.Where("payer_phone = ?", userPhone)
+			case DirectionOutgoing:
+				q = q.Where("requester_phone = ?", userPhone)
+			case "":
+				q = q.Where("requester_phone = ? or payer_phone = ?", userPhone, userPhone)
+			default:
+				return ErrInvalidDirection
+			}
+
+			now := time.Now().UTC()
+			// outdated requests may be not expired by the worker yet
+			switch status {
+			case "":
+			case StatusPending:
+				q = q.Where("status = ? and expires_at > ?", StatusPending, now)
+			case StatusExpired:
+				q = q.Where("status = ? or (status = ? and expires_at <= ?)", StatusExpired, StatusPending, now)
+			default:
+				q = q.Where("status = ?", status)
+			}
+
+			err := q.Preload("Coin").Order("id desc").Find(&requests).Error
+			if err != nil {
+				return err
+			}
+			for i := range requests {
+				presentStatus(&requests[i], now)
+			}
+			return nil
+		})
+	})
+	return
+}
+
+// Accept implements IRequests
+func (r *Requests) Accept(ctx context.Context, payerPhone string, requestID, walletID int64) (
+	request *Request, tx *processing.Tx, err error,
+) {
+	err = trace.InsideSpanE(ctx, "accept_payment_request", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("payer_phone", payerPhone, "request_id", requestID, "wallet_id", walletID)
+
+		payerPhone, err = wallets.CoercePhone(payerPhone)
+		if err != nil {
+			return err
+		}
+
+		// claim request, so it won't be paid twice by concurrent acceptance
+		var expired bool
+		err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			request, expired, err = lockPendingRequest(dbTx, "payer_phone = ?", payerPhone, requestID)
+			if err != nil || expired {
+				return err
+			}
+
+			var wallet queries.Wallet
+			err = dbTx.Where("id = ? and user_phone = ?", walletID, payerPhone).First(&wallet).Error
+			if err == gorm.ErrRecordNotFound {
+				return errs.ErrNoSuchWallet
+			}
+			if err != nil {
+				return err
+			}
+			if wallet.CoinID != request.CoinID {
+				return ErrWalletCoinMismatch
+			}
+
+			return setStatus(dbTx, request, StatusAccepted)
+		})
+		if expired {
+			r.notify(span, "expired", r.notificator.Expired, request)
+			return ErrRequestExpired
+		}
+		if err != nil {
+			return err
+		}
+
+		var amount *decimal.Big
+		amount, err = r.coinAmount(ctx, request)
+		if err == nil {
+			span.LogKV("amount", amount)
+			tx, err = r.walletsApi.SendToPhone(
+				ctx, payerPhone, walletID, request.RequesterPhone, 0, amount, nil,
+			)
+		}
+		if err != nil {
+			trace.LogErrorWithMsg(span, err, "request payment failed")
+			// request stays pending, so payer may accept it again
+			revertErr := db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+				return setStatus(dbTx, request, StatusPending)
+			})
+			if revertErr != nil {
+				trace.LogErrorWithMsg(span, revertErr, "request status reverting failed")
+			}
+			return err
+		}
+
+		span.LogKV("tx_id", tx.ID)
+		request.Amount = &processing.Decimal{V: amount}
+		request.TxID = &tx.ID
+		err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			return dbTx.Model(request).Updates(map[string]interface{}{
+				"amount": request.Amount,
+				"tx_id":  request.TxID,
+			}).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		r.notify(span, "accepted", r.notificator.Accepted, request)
+		return nil
+	})
+	return
+}
+
+// Decline implements IRequests
+func (r *Requests) Decline(ctx context.Context, payerPhone string, requestID int64) (request *Request, err error) {
+	err = trace.InsideSpanE(ctx, "decline_payment_request", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("payer_phone", payerPhone, "request_id", requestID)
+
+		payerPhone, err = wallets.CoercePhone(payerPhone)
+		if err != nil {
+			return err
+		}
+
+		request, err = r.finish(ctx, span, "payer_phone = ?", payerPhone, requestID, StatusDeclined)
+		if err != nil {
+			return err
+		}
+		r.notify(span, "declined", r.notificator.Declined, request)
+		return nil
+	})
+	return
+}
+
+// Cancel implements IRequests
+func (r *Requests) Cancel(ctx context.Context, requesterPhone string, requestID int64) (request *Request, err error) {
+	err = trace.InsideSpanE(ctx, "cancel_payment_request", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("requester_phone", requesterPhone, "request_id", requestID)
+
+		requesterPhone, err = wallets.CoercePhone(requesterPhone)
+		if err != nil {
+			return err
+		}
+
+		request, err = r.finish(ctx, span, "requester_phone = ?", requesterPhone, requestID, StatusCanceled)
+		if err != nil {
+			return err
+		}
+		r.notify(span, "canceled", r.notificator.Canceled, request)
+		return nil
+	})
+	return
+}
+
+// ExpireOutdated implements IRequests
+func (r *Requests) ExpireOutdated(ctx context.Context) (expiredNum int, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "expire_outdated_payment_requests")
+	defer span.Finish()
+
+	var requests []Request
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		// lock outdated requests, so they won't be accepted while expired
+		var ids []int64
+		err := dbTx.Model(&Request{}).Where(
+			"status = ? and expires_at <= ?", StatusPending, time.Now().UTC(),
+		).Set("gorm:query_option", "FOR UPDATE").Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		err = dbTx.Where("id in (?)", ids).Preload("Coin").Find(&requests).Error
+		if err != nil {
+			return err
+		}
+		return dbTx.Model(&Request{}).Where("id in (?)", ids).Updates(map[string]interface{}{
+			"status":     StatusExpired,
+			"updated_at": time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		trace.LogError(span, err)
+		return
+	}
+
+	for i := range requests {
+		requests[i].Status = StatusExpired
+		r.notify(span, "expired", r.notificator.Expired, &requests[i])
+	}
+	expiredNum = len(requests)
+	span.LogKV("expired_requests_num", expiredNum)
+	return
+}
+
+// finish moves pending request of the user side specified by condition into final status
+func (r *Requests) finish(
+	ctx context.Context,
+	span ot.Span,
+	sideCond, userPhone string,
+	requestID int64,
+	status string,
+) (request *Request, err error) {
+	var expired bool
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		request, expired, err = lockPendingRequest(dbTx, sideCond, userPhone, requestID)
+		if err != nil || expired {
+			return err
+		}
+		return setStatus(dbTx, request, status)
+	})
+	if expired {
+		r.notify(span, "expired", r.notificator.Expired, request)
+		return nil, ErrRequestExpired
+	}
+	return
+}
+
+// coinAmount returns coin amount of the request, fiat amount is converted using current rate
+func (r *Requests) coinAmount(ctx context.Context, request *Request) (*decimal.Big, error) {
+	if request.FiatAmount == nil {
+		return request.Amount.V, nil
+	}
+	rate, err := r.converter.GetRate(ctx, request.Coin.ShortName, *request.FiatCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return rate.ReverseConvert(request.FiatAmount.V), nil
+}
+
+// notify sends request event notification, failure is only logged
+func (r *Requests) notify(
+	span ot.Span,
+	event string,
+	f func(payload isc.PaymentRequestEventPayload) error,
+	request *Request,
+) {
+	notifErr := f(requestEventPayload(request))
+	if notifErr != nil {
+		trace.LogErrorWithMsg(span, notifErr, event+" notification failed")
+	}
+}
+
+// lockPendingRequest locks and queries request of the user side specified by condition, outdated request is expired
+// and expired flag is set
+func lockPendingRequest(dbTx *gorm.DB, sideCond, userPhone string, requestID int64) (
+	request *Request, expired bool, err error,
+) {
+	err = dbTx.Exec("select 1 from payment_requests where id = ? for update", requestID).Error
+	if err != nil {
+		return
+	}
+	request, err = getRequest(dbTx.Where(sideCond, userPhone), requestID)
+	if err != nil {
+		return
+	}
+	if request.isOutdated(time.Now().UTC()) {
+		return request, true, setStatus(dbTx, request, StatusExpired)
+	}
+	if !request.IsPending() {
+		err = ErrRequestNotPending
+	}
+	return
+}
+
+// getRequest queries request using given query, returns ErrNoSuchRequest if not found
+func getRequest(q *gorm.DB, requestID int64) (*Request, error) {
+	request := new(Request)
+	err := q.Where("id = ?", requestID).Preload("Coin").First(request).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNoSuchRequest
+	}
+	return request, err
+}
+
+// setStatus saves request status
+func setStatus(dbTx *gorm.DB, request *Request, status string) error {
+	request.Status = status
+	request.UpdatedAt = time.Now().UTC()
+	return dbTx.Model(request).Updates(map[string]interface{}{
+		"status":     request.Status,
+		"updated_at": request.UpdatedAt,
+	}).Error
+}
+
+// presentStatus shows outdated request as expired even if worker hasn't expired it yet
+func presentStatus(request *Request, now time.Time) {
+	if request.isOutdated(now) {
+		request.Status = StatusExpired
+	}
+}
+
+// requestEventPayload describes request for notifications
+func requestEventPayload(request *Request) isc.PaymentRequestEventPayload {
+	payload := isc.PaymentRequestEventPayload{
+		ID:             request.ID,
+		RequesterPhone: request.RequesterPhone,
+		PayerPhone:     request.PayerPhone,
+	}
+	if request.Coin != nil {
+		payload.Coin = request.Coin.ShortName
+	}
+	if request.Amount != nil {
+		payload.Amount = request.Amount.V
+	}
+	if request.FiatAmount != nil {
+		payload.FiatAmount = request.FiatAmount.V
+		payload.FiatCurrency = *request.FiatCurrency
+	}
+	return payload
+}
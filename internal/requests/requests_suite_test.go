@@ -0,0 +1,441 @@
+package requests_test
+
+import (
+	"testing"
+
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/requests"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	iscmocks "git.zam.io/wallet-backend/wallet-api/internal/services/isc/mocks"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"strings"
+	"time"
+)
+
+func TestRequests(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Requests Suite")
+}
+
+const (
+	testCoinName     = "TEST"
+	testCoinFullName = "Testing"
+	otherCoinName    = "OTHER"
+	requesterPhone   = "+79109998877"
+	payerPhone       = "+79101112233"
+	thirdPhone       = "+79105554433"
+)
+
+// fixedRates serves rates of the test coin to given fiat currencies, other currencies are invalid
+type fixedRates map[string]int64
+
+// GetRate implements convert.ICryptoCurrency
+func (r fixedRates) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (*convert.Rate, error) {
+	rate, ok := r[strings.ToUpper(dstCurrencyName)]
+	if !ok {
+		return nil, convert.ErrFiatCurrencyName
+	}
+	return (*convert.Rate)(decimal.New(rate, 0)), nil
+}
+
+// GetMultiRate implements convert.ICryptoCurrency
+func (r fixedRates) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	convert.MultiRate, error,
+) {
+	rate, err := r.GetRate(ctx, "", dstCurrencyName)
+	if err != nil {
+		return nil, err
+	}
+	mr := make(convert.MultiRate, len(coinNames))
+	for _, name := range coinNames {
+		mr[strings.ToUpper(name)] = *rate
+	}
+	return mr, nil
+}
+
+// coinRequest returns params of the request of given test coin amount to the payer
+func coinRequest(amount int64) requests.RequestParams {
+	return requests.RequestParams{PayerPhone: payerPhone, Coin: testCoinName, Amount: decimal.New(amount, 0)}
+}
+
+// createRequest creates request of given test coin amount from the requester to the payer
+func createRequest(r requests.IRequests, amount int64) *requests.Request {
+	request, err := r.Create(context.Background(), requesterPhone, coinRequest(amount))
+	Expect(err).NotTo(HaveOccurred())
+	return request
+}
+
+// outdate moves request expiration time into the past without expiring it
+func outdate(d *db.Db, requestID int64) {
+	_, err := d.Exec(`update payment_requests set expires_at = $2 where id = $1`, requestID, time.Now().Add(-time.Hour))
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// requestStatus returns saved request status
+func requestStatus(d *db.Db, requestID int64) (status string) {
+	err := d.QueryRowx(`select status from payment_requests where id = $1`, requestID).Scan(&status)
+	Expect(err).NotTo(HaveOccurred())
+	return
+}
+
+var _ = Describe("testing payment requests", func() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	BeforeEachCProvide(func(d *db.Db) (*gorm.DB, error) {
+		return gorm.Open("postgres", d.DB.DB)
+	})
+
+	BeforeEachCProvide(func() (isc.IPaymentRequestsEventNotificator, *iscmocks.IPaymentRequestsEventNotificator) {
+		n := &iscmocks.IPaymentRequestsEventNotificator{}
+		for _, method := range []string{"Created", "Accepted", "Declined", "Canceled", "Expired"} {
+			n.On(method, mock.Anything).Return(nil)
+		}
+		return n, n
+	})
+
+	// wallets api isn't required until request is paid
+	BeforeEachCProvide(func(d *gorm.DB, notificator isc.IPaymentRequestsEventNotificator) requests.IRequests {
+		return requests.New(d, nil, fixedRates{"USD": 6500}, notificator)
+	})
+
+	// provide test coins
+	BeforeEachCInvoke(func(d *db.Db) {
+		for _, name := range []string{testCoinName, otherCoinName} {
+			_, err := d.Exec(
+				"insert into coins (name, short_name, enabled) values ($1, $2, true)", testCoinFullName+name, name,
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	Context("when creating request", func() {
+		fiat := func(amount int64, currency string) requests.RequestParams {
+			return requests.RequestParams{
+				PayerPhone:   payerPhone,
+				Coin:         testCoinName,
+				FiatAmount:   decimal.New(amount, 0),
+				FiatCurrency: currency,
+			}
+		}
+		with := func(params requests.RequestParams, f func(params *requests.RequestParams)) requests.RequestParams {
+			f(&params)
+			return params
+		}
+
+		for _, c := range []struct {
+			label  string
+			params requests.RequestParams
+			err    error
+		}{
+			{
+				label:  "should reject invalid payer phone",
+				params: with(coinRequest(1), func(p *requests.RequestParams) { p.PayerPhone = "not a phone" }),
+				err:    errs.ErrInvalidPhone,
+			},
+			{
+				label:  "should reject request to the requester",
+				params: with(coinRequest(1), func(p *requests.RequestParams) { p.PayerPhone = requesterPhone }),
+				err:    requests.ErrSelfRequest,
+			},
+			{
+				label:  "should reject request without amount",
+				params: with(coinRequest(1), func(p *requests.RequestParams) { p.Amount = nil }),
+				err:    requests.ErrInvalidAmount,
+			},
+			{
+				label: "should reject both coin and fiat amounts",
+				params: with(fiat(10, "usd"), func(p *requests.RequestParams) {
+					p.Amount = decimal.New(1, 0)
+				}),
+				err: requests.ErrInvalidAmount,
+			},
+			{
+				label:  "should reject fiat amount without currency",
+				params: fiat(10, ""),
+				err:    requests.ErrInvalidAmount,
+			},
+			{
+				label:  "should reject non-positive amount",
+				params: coinRequest(0),
+				err:    errs.ErrNonPositiveAmount,
+			},
+			{
+				label:  "should reject unknown fiat currency",
+				params: fiat(10, "xyz"),
+				err:    convert.ErrFiatCurrencyName,
+			},
+			{
+				label: "should reject expiration time in the past",
+				params: with(coinRequest(1), func(p *requests.RequestParams) {
+					p.ExpiresAt = time.Now().Add(-time.Minute)
+				}),
+				err: requests.ErrExpiresAtInPast,
+			},
+			{
+				label: "should reject comment longer than 255 characters",
+				params: with(coinRequest(1), func(p *requests.RequestParams) {
+					p.Comment = strings.Repeat("ж", 256)
+				}),
+				err: requests.ErrCommentTooLong,
+			},
+			{
+				label:  "should reject unknown coin",
+				params: with(coinRequest(1), func(p *requests.RequestParams) { p.Coin = "unknown" }),
+				err:    errs.ErrNoSuchCoin,
+			},
+		} {
+			c := c
+			ItD(c.label, func(d *db.Db, r requests.IRequests) {
+				_, err := r.Create(context.Background(), requesterPhone, c.params)
+				Expect(err).To(Equal(c.err))
+
+				var requestsNum int
+				Expect(d.QueryRowx(`select count(*) from payment_requests`).Scan(&requestsNum)).To(Succeed())
+				Expect(requestsNum).To(BeZero())
+			})
+		}
+
+		ItD("should reject request to the payer which account is closed", func(d *db.Db, r requests.IRequests) {
+			_, err := queries.StartAccountClosure(d, payerPhone)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = r.Create(context.Background(), requesterPhone, coinRequest(1))
+			Expect(err).To(Equal(errs.ErrRecipientAccountClosed))
+		})
+
+		ItD("should keep comment of 255 multibyte characters", func(
+			r requests.IRequests, notificator *iscmocks.IPaymentRequestsEventNotificator,
+		) {
+			comment := strings.Repeat("ж", 255)
+			params := coinRequest(1)
+			params.Comment = comment
+
+			request, err := r.Create(context.Background(), requesterPhone, params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(request.Status).To(Equal(requests.StatusPending))
+
+			got, err := r.Get(context.Background(), payerPhone, request.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*got.Comment).To(Equal(comment))
+			notificator.AssertNumberOfCalls(GinkgoT(), "Created", 1)
+		})
+
+		ItD("should uppercase fiat currency and use default ttl", func(r requests.IRequests) {
+			request, err := r.Create(context.Background(), requesterPhone, fiat(10, "usd"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(request.Amount).To(BeNil())
+			Expect(*request.FiatCurrency).To(Equal("USD"))
+			Expect(request.ExpiresAt).To(BeTemporally("~", time.Now().Add(72*time.Hour), time.Minute))
+		})
+	})
+
+	Context("when querying requests", func() {
+		ItD("should show request only to its sides", func(r requests.IRequests) {
+			request := createRequest(r, 1)
+
+			for _, phone := range []string{requesterPhone, payerPhone} {
+				got, err := r.Get(context.Background(), phone, request.ID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(got.ID).To(Equal(request.ID))
+				Expect(got.Coin.ShortName).To(Equal(testCoinName))
+			}
+
+			_, err := r.Get(context.Background(), thirdPhone, request.ID)
+			Expect(err).To(Equal(requests.ErrNoSuchRequest))
+		})
+
+		ItD("should show outdated request as expired", func(d *db.Db, r requests.IRequests) {
+			request := createRequest(r, 1)
+			outdate(d, request.ID)
+
+			got, err := r.Get(context.Background(), payerPhone, request.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.Status).To(Equal(requests.StatusExpired))
+			Expect(requestStatus(d, request.ID)).To(Equal(requests.StatusPending))
+		})
+
+		ItD("should filter requests by direction and status", func(d *db.Db, r requests.IRequests) {
+			outgoing := createRequest(r, 1)
+			outdated := createRequest(r, 2)
+			outdate(d, outdated.ID)
+			canceled := createRequest(r, 3)
+			_, err := r.Cancel(context.Background(), requesterPhone, canceled.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			params := coinRequest(4)
+			params.PayerPhone = requesterPhone
+			incoming, err := r.Create(context.Background(), payerPhone, params)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, c := range []struct {
+				direction requests.Direction
+				status    string
+				ids       []int64
+			}{
+				{"", "", []int64{incoming.ID, canceled.ID, outdated.ID, outgoing.ID}},
+				{requests.DirectionIncoming, "", []int64{incoming.ID}},
+				{requests.DirectionOutgoing, "", []int64{canceled.ID, outdated.ID, outgoing.ID}},
+				{requests.DirectionOutgoing, requests.StatusPending, []int64{outgoing.ID}},
+				{requests.DirectionOutgoing, requests.StatusExpired, []int64{outdated.ID}},
+				{requests.DirectionOutgoing, requests.StatusCanceled, []int64{canceled.ID}},
+				{requests.DirectionIncoming, requests.StatusDeclined, nil},
+			} {
+				got, err := r.GetAll(context.Background(), requesterPhone, c.direction, c.status)
+				Expect(err).NotTo(HaveOccurred())
+
+				var ids []int64
+				for _, request := range got {
+					ids = append(ids, request.ID)
+				}
+				Expect(ids).To(Equal(c.ids), "direction %q, status %q", c.direction, c.status)
+			}
+		})
+
+		ItD("should reject unknown direction", func(r requests.IRequests) {
+			_, err := r.GetAll(context.Background(), requesterPhone, "sideways", "")
+			Expect(err).To(Equal(requests.ErrInvalidDirection))
+		})
+	})
+
+	Context("when finishing request", func() {
+		finishers := []struct {
+			label  string
+			finish func(r requests.IRequests, requestID int64) (*requests.Request, error)
+			status string
+			event  string
+		}{
+			{
+				label: "declining",
+				finish: func(r requests.IRequests, requestID int64) (*requests.Request, error) {
+					return r.Decline(context.Background(), payerPhone, requestID)
+				},
+				status: requests.StatusDeclined,
+				event:  "Declined",
+			},
+			{
+				label: "canceling",
+				finish: func(r requests.IRequests, requestID int64) (*requests.Request, error) {
+					return r.Cancel(context.Background(), requesterPhone, requestID)
+				},
+				status: requests.StatusCanceled,
+				event:  "Canceled",
+			},
+		}
+
+		for _, c := range finishers {
+			c := c
+			ItD("should finish pending request when "+c.label, func(
+				d *db.Db, r requests.IRequests, notificator *iscmocks.IPaymentRequestsEventNotificator,
+			) {
+				request := createRequest(r, 1)
+
+				finished, err := c.finish(r, request.ID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(finished.Status).To(Equal(c.status))
+				Expect(requestStatus(d, request.ID)).To(Equal(c.status))
+				notificator.AssertNumberOfCalls(GinkgoT(), c.event, 1)
+
+				_, err = c.finish(r, request.ID)
+				Expect(err).To(Equal(requests.ErrRequestNotPending))
+			})
+
+			ItD("should expire outdated request when "+c.label, func(
+				d *db.Db, r requests.IRequests, notificator *iscmocks.IPaymentRequestsEventNotificator,
+			) {
+				request := createRequest(r, 1)
+				outdate(d, request.ID)
+
+				_, err := c.finish(r, request.ID)
+				Expect(err).To(Equal(requests.ErrRequestExpired))
+				Expect(requestStatus(d, request.ID)).To(Equal(requests.StatusExpired))
+				notificator.AssertNumberOfCalls(GinkgoT(), "Expired", 1)
+				notificator.AssertNotCalled(GinkgoT(), c.event, mock.Anything)
+			})
+		}
+
+		ItD("should not let requester decline and payer cancel", func(r requests.IRequests) {
+			request := createRequest(r, 1)
+
+			_, err := r.Decline(context.Background(), requesterPhone, request.ID)
+			Expect(err).To(Equal(requests.ErrNoSuchRequest))
+			_, err = r.Cancel(context.Background(), payerPhone, request.ID)
+			Expect(err).To(Equal(requests.ErrNoSuchRequest))
+		})
+
+		ItD("should expire only outdated pending requests", func(
+			d *db.Db, r requests.IRequests, notificator *iscmocks.IPaymentRequestsEventNotificator,
+		) {
+			pending := createRequest(r, 1)
+			outdated := createRequest(r, 2)
+			outdate(d, outdated.ID)
+			declined := createRequest(r, 3)
+			_, err := r.Decline(context.Background(), payerPhone, declined.ID)
+			Expect(err).NotTo(HaveOccurred())
+			outdate(d, declined.ID)
+
+			expiredNum, err := r.ExpireOutdated(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiredNum).To(Equal(1))
+			notificator.AssertNumberOfCalls(GinkgoT(), "Expired", 1)
+
+			Expect(requestStatus(d, pending.ID)).To(Equal(requests.StatusPending))
+			Expect(requestStatus(d, outdated.ID)).To(Equal(requests.StatusExpired))
+			Expect(requestStatus(d, declined.ID)).To(Equal(requests.StatusDeclined))
+		})
+	})
+
+	Context("when accepting request", func() {
+		createWallet := func(d *db.Db, phone, coin string) queries.Wallet {
+			w, err := queries.CreateWallet(d, queries.Wallet{
+				UserPhone: phone,
+				Address:   phone + "-" + coin,
+				Coin:      queries.Coin{ShortName: coin},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			// secret is set after address generation
+			secret := "secret"
+			Expect(queries.UpdateWallet(d, w.ID, &queries.WalletDiff{Secret: &secret})).To(Succeed())
+			return w
+		}
+
+		for _, c := range []struct {
+			label  string
+			wallet func(d *db.Db) int64
+			err    error
+		}{
+			{
+				label:  "should reject wallet of other coin",
+				wallet: func(d *db.Db) int64 { return createWallet(d, payerPhone, otherCoinName).ID },
+				err:    requests.ErrWalletCoinMismatch,
+			},
+			{
+				label:  "should reject wallet of other user",
+				wallet: func(d *db.Db) int64 { return createWallet(d, thirdPhone, testCoinName).ID },
+				err:    errs.ErrNoSuchWallet,
+			},
+		} {
+			c := c
+			ItD(c.label+" and keep request pending", func(d *db.Db, r requests.IRequests) {
+				request := createRequest(r, 1)
+
+				_, _, err := r.Accept(context.Background(), payerPhone, request.ID, c.wallet(d))
+				Expect(err).To(Equal(c.err))
+				Expect(requestStatus(d, request.ID)).To(Equal(requests.StatusPending))
+			})
+		}
+	})
+})
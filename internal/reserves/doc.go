@@ -0,0 +1,10 @@
+// Package reserves defines proof-of-reserves generation.
+//
+// Liabilities of each coin are committed using Merkle sum tree which leafs are users identified by salted phone hash
+// (hex encoded sha256("<nonce>|<phone>")). Nonce is unique for each user and report, it's derived from the report seed
+// using HMAC-SHA256 keyed by service secret and given only to it's owner alongside with inclusion proofs, so users keys
+// can't be matched with enumerated phones. Amounts are encoded as reduced decimal strings in coin units. Leaf hash is
+// sha256("<user key>|<balance>"), node hash is sha256("<left hash>|<right hash>|<sum>") where sum is sum of children
+// sums, hashes are hex encoded. If level contains odd number of nodes, the last one is paired with zero node which
+// hash is sha256("|0"). Published report contains only trees roots, not leafs.
+package reserves
@@ -0,0 +1,59 @@
+package reserves
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+)
+
+// ExportedAddress is controlled address in published report
+type ExportedAddress struct {
+	Address string        `json:"address"`
+	Balance *decimal.View `json:"balance"`
+}
+
+// ExportedCoin is coin part of published report
+type ExportedCoin struct {
+	Coin             string            `json:"coin"`
+	RootHash         string            `json:"root_hash"`
+	Liabilities      *decimal.View     `json:"liabilities"`
+	NodeBalance      *decimal.View     `json:"node_balance"`
+	AddressesBalance *decimal.View     `json:"addresses_balance"`
+	Addresses        []ExportedAddress `json:"addresses"`
+	UsersNum         int               `json:"users_num"`
+}
+
+// Export is published proof-of-reserves report, it contains only liabilities trees roots, users keys and balances are
+// given only to their owners alongside with inclusion proofs
+type Export struct {
+	ReportID  int64              `json:"report_id"`
+	CreatedAt types.UnixTimeView `json:"created_at"`
+	Coins     []ExportedCoin     `json:"coins"`
+}
+
+// NewExport prepares report for publishing, report must be loaded with all coins, addresses and leafs
+func NewExport(report *Report) *Export {
+	export := &Export{
+		ReportID:  report.ID,
+		CreatedAt: types.UnixTimeView(report.CreatedAt),
+		Coins:     make([]ExportedCoin, 0, len(report.Coins)),
+	}
+	for _, cr := range report.Coins {
+		coin := ExportedCoin{
+			Coin:             cr.Coin.ShortName,
+			RootHash:         cr.RootHash,
+			Liabilities:      (*decimal.View)(cr.Liabilities.V),
+			NodeBalance:      (*decimal.View)(cr.NodeBalance.V),
+			AddressesBalance: (*decimal.View)(cr.AddressesBalance.V),
+			Addresses:        make([]ExportedAddress, 0, len(cr.Addresses)),
+			UsersNum:         len(cr.Leafs),
+		}
+		for _, a := range cr.Addresses {
+			coin.Addresses = append(coin.Addresses, ExportedAddress{
+				Address: a.Address,
+				Balance: (*decimal.View)(a.Balance.V),
+			})
+		}
+		export.Coins = append(export.Coins, coin)
+	}
+	return export
+}
@@ -0,0 +1,145 @@
+package reserves
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ericlagergren/decimal"
+)
+
+var (
+	// ErrNegativeLiability returned when tree leaf has negative balance, such leaf makes sums unverifiable
+	ErrNegativeLiability = errors.New("reserves: negative liability")
+
+	// ErrNoLeafs returned when tree is built from empty leafs set
+	ErrNoLeafs = errors.New("reserves: no leafs")
+)
+
+// Leaf is a Merkle sum tree leaf
+type Leaf struct {
+	// UserKey is phone hash salted with user nonce
+	UserKey string
+	Balance *decimal.Big
+}
+
+// ProofStep is a sibling node on the path from leaf to the root
+type ProofStep struct {
+	Hash string       `json:"hash"`
+	Sum  *decimal.Big `json:"sum"`
+
+	// Left indicates that sibling is the left child of parent node
+	Left bool `json:"left"`
+}
+
+// node is a Merkle sum tree node
+type node struct {
+	hash string
+	sum  *decimal.Big
+}
+
+// Tree is a Merkle sum tree, levels are stored bottom-up
+type Tree struct {
+	levels [][]node
+}
+
+// BuildTree builds Merkle sum tree from given leafs, leafs order is preserved
+func BuildTree(leafs []Leaf) (*Tree, error) {
+	if len(leafs) == 0 {
+		return nil, ErrNoLeafs
+	}
+
+	level := make([]node, 0, len(leafs))
+	for _, l := range leafs {
+		if l.Balance.Sign() < 0 {
+			return nil, ErrNegativeLiability
+		}
+		level = append(level, node{hash: LeafHash(l.UserKey, l.Balance), sum: l.Balance})
+	}
+
+	t := &Tree{levels: [][]node{level}}
+	for len(level) > 1 {
+		if len(level)%2 != 0 {
+			level = append(level, zeroNode())
+			t.levels[len(t.levels)-1] = level
+		}
+
+		parents := make([]node, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			parents = append(parents, parentNode(level[i], level[i+1]))
+		}
+		t.levels = append(t.levels, parents)
+		level = parents
+	}
+	return t, nil
+}
+
+// Root returns root hash and total sum of the tree
+func (t *Tree) Root() (hash string, sum *decimal.Big) {
+	root := t.levels[len(t.levels)-1][0]
+	return root.hash, root.sum
+}
+
+// Proof returns inclusion proof of leaf with given index
+func (t *Tree) Proof(index int) (proof []ProofStep) {
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		proof = append(proof, ProofStep{
+			Hash: level[sibling].hash,
+			Sum:  level[sibling].sum,
+			Left: sibling < index,
+		})
+		index /= 2
+	}
+	return
+}
+
+// VerifyProof checks that leaf with given key and balance is included into the tree with given root
+func VerifyProof(userKey string, balance *decimal.Big, proof []ProofStep, rootHash string, rootSum *decimal.Big) bool {
+	current := node{hash: LeafHash(userKey, balance), sum: balance}
+	for _, step := range proof {
+		if step.Sum.Sign() < 0 {
+			return false
+		}
+		sibling := node{hash: step.Hash, sum: step.Sum}
+		if step.Left {
+			current = parentNode(sibling, current)
+		} else {
+			current = parentNode(current, sibling)
+		}
+	}
+	return current.hash == rootHash && current.sum.Cmp(rootSum) == 0
+}
+
+// LeafHash calculates leaf hash
+func LeafHash(userKey string, balance *decimal.Big) string {
+	return hashParts(userKey, formatAmount(balance))
+}
+
+func parentNode(left, right node) node {
+	sum := new(decimal.Big).Add(left.sum, right.sum)
+	return node{hash: hashParts(left.hash, right.hash, formatAmount(sum)), sum: sum}
+}
+
+func zeroNode() node {
+	return node{hash: hashParts("", "0"), sum: new(decimal.Big)}
+}
+
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for i, p := range parts {
+		if i > 0 {
+			h.Write([]byte("|"))
+		}
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// formatAmount formats amount in canonical form, so the same value always produces the same hash
+func formatAmount(amount *decimal.Big) string {
+	if amount.Sign() == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%f", new(decimal.Big).Copy(amount).Reduce())
+}
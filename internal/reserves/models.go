@@ -0,0 +1,87 @@
+package reserves
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/jinzhu/gorm/dialects/postgres"
+	"time"
+)
+
+// Report represents proof-of-reserves attestation which consists of per-coin reports
+type Report struct {
+	ID int64
+
+	// Salt is random report seed which users nonces are derived from, it's neither published nor given to users
+	Salt string
+
+	CreatedAt time.Time
+
+	Coins []CoinReport `gorm:"foreignkey:ReportID"`
+}
+
+func (Report) TableName() string {
+	return "reserves_reports"
+}
+
+// CoinReport holds liabilities tree root and controlled on-chain assets of a single coin
+type CoinReport struct {
+	ID       int64
+	ReportID int64
+	CoinID   int64
+	Coin     *queries.Coin `gorm:"foreignkey:CoinID;association_autoupdate:false;association_autocreate:false"`
+
+	// RootHash and Liabilities are Merkle sum tree root hash and sum
+	RootHash    string
+	Liabilities *processing.Decimal
+
+	// NodeBalance is the node account balance
+	NodeBalance *processing.Decimal
+
+	// AddressesBalance is the sum of controlled addresses balances
+	AddressesBalance *processing.Decimal
+
+	Addresses []Address `gorm:"foreignkey:CoinReportID"`
+	Leafs     []LeafRow `gorm:"foreignkey:CoinReportID"`
+}
+
+func (CoinReport) TableName() string {
+	return "reserves_coin_reports"
+}
+
+// Address is on-chain controlled address
+type Address struct {
+	ID           int64
+	CoinReportID int64
+	Address      string
+	Balance      *processing.Decimal
+}
+
+func (Address) TableName() string {
+	return "reserves_addresses"
+}
+
+// LeafRow is a stored tree leaf alongside with it's inclusion proof encoded as json array of ProofStep
+type LeafRow struct {
+	ID           int64
+	CoinReportID int64
+	UserKey      string
+	Balance      *processing.Decimal
+	Proof        postgres.Jsonb
+}
+
+func (LeafRow) TableName() string {
+	return "reserves_leafs"
+}
+
+// UserProof is user inclusion proof into coin liabilities tree
+type UserProof struct {
+	Coin        string
+	RootHash    string
+	Liabilities *processing.Decimal
+
+	// Nonce is user own nonce of the report, user key is sha256("<nonce>|<phone>")
+	Nonce   string
+	UserKey string
+	Balance *processing.Decimal
+	Proof   []ProofStep
+}
@@ -0,0 +1,283 @@
+package reserves
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/dialects/postgres"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+const saltSize = 32
+
+var (
+	// ErrNoReports returned when no proof-of-reserves report has been generated yet
+	ErrNoReports = errors.New("reserves: no reports generated")
+
+	// ErrNoSecret returned when generator is created without secret
+	ErrNoSecret = errors.New("reserves: secret is required")
+)
+
+// IReserves generates proof-of-reserves reports and provides users inclusion proofs
+type IReserves interface {
+	// Generate builds liabilities trees of all dialed coins, collects controlled addresses balances and stores report
+	Generate(ctx context.Context) (report *Report, err error)
+
+	// GetUserProofs returns latest report and user inclusion proofs of each coin which user has balance in, proofs
+	// contain user nonce, so only this user key may be checked with them. Returns ErrNoReports if there is no reports
+	// yet.
+	GetUserProofs(ctx context.Context, userPhone string) (report *Report, proofs []UserProof, err error)
+}
+
+// Reserves is IReserves implementation
+type Reserves struct {
+	database    *gorm.DB
+	coordinator nodes.ICoordinator
+	balance     helpers.IBalance
+	secret      []byte
+}
+
+// New creates proof-of-reserves generator, secret is used to derive users nonces and must be kept private
+func New(database *gorm.DB, coordinator nodes.ICoordinator, balance helpers.IBalance, secret string) (
+	*Reserves, error,
+) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+	return &Reserves{database: database, coordinator: coordinator, balance: balance, secret: []byte(secret)}, nil
+}
+
+// UserNonce derives user nonce of the report with given salt, nonce is unique for each user and report and can't be
+// calculated without secret
+func UserNonce(secret []byte, salt, userPhone string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(salt + "|" + userPhone))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// UserKey calculates user key used as tree leaf identifier
+func UserKey(nonce, userPhone string) string {
+	return hashParts(nonce, userPhone)
+}
+
+// Generate implements IReserves
+func (r *Reserves) Generate(ctx context.Context) (report *Report, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "generate_proof_of_reserves")
+	defer span.Finish()
+
+	defer func() {
+		if err != nil {
+			trace.LogError(span, err)
+		}
+	}()
+
+	salt := make([]byte, saltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return
+	}
+	report = &Report{Salt: hex.EncodeToString(salt)}
+
+	for _, coinName := range r.coordinator.Coins() {
+		var coinReport *CoinReport
+		coinReport, err = r.generateCoin(ctx, report.Salt, coinName)
+		if err != nil {
+			err = errors.Wrapf(err, "proof of %s reserves has been failed", coinName)
+			return
+		}
+		if coinReport != nil {
+			report.Coins = append(report.Coins, *coinReport)
+		}
+	}
+
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		return dbTx.Create(report).Error
+	})
+	return
+}
+
+// generateCoin builds coin report, returns nil report if coin is disabled or has no wallets
+func (r *Reserves) generateCoin(ctx context.Context, salt, coinName string) (report *CoinReport, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "generate_coin_reserves")
+	defer span.Finish()
+
+	coinName = strings.ToUpper(coinName)
+	span.LogKV("coin", coinName)
+
+	coin, wts, err := r.loadWallets(ctx, coinName)
+	if err != nil || coin == nil || len(wts) == 0 {
+		return
+	}
+
+	report = &CoinReport{CoinID: coin.ID, Coin: coin}
+
+	// collect liabilities, user may have several wallets of the same coin
+	liabilities := make(map[string]*decimal.Big)
+	observer := r.coordinator.Observer(coinName)
+	addressesBalance := new(decimal.Big)
+	for i := range wts {
+		w := &wts[i]
+
+		var balance *decimal.Big
+		balance, err = r.balance.TotalWalletBalanceCtx(ctx, w)
+		if err != nil {
+			return
+		}
+		key := UserKey(UserNonce(r.secret, salt, w.UserPhone), w.UserPhone)
+		if sum, ok := liabilities[key]; ok {
+			sum.Add(sum, balance)
+		} else {
+			liabilities[key] = new(decimal.Big).Copy(balance)
+		}
+
+		// historical addresses are still controlled by the wallet
+		for _, address := range w.AllAddresses() {
+			var addressBalance *decimal.Big
+			addressBalance, err = observer.Balance(ctx, address)
+			if err != nil {
+				return
+			}
+			addressesBalance.Add(addressesBalance, addressBalance)
+			report.Addresses = append(report.Addresses, Address{
+				Address: address,
+				Balance: &processing.Decimal{V: addressBalance},
+			})
+		}
+	}
+
+	nodeBalance, err := r.balance.AccountBalanceCtx(ctx, coinName)
+	if err != nil {
+		return
+	}
+
+	// sort leafs, so tree doesn't depend on wallets order
+	leafs := make([]Leaf, 0, len(liabilities))
+	for key, balance := range liabilities {
+		leafs = append(leafs, Leaf{UserKey: key, Balance: balance})
+	}
+	sort.Slice(leafs, func(i, j int) bool {
+		return leafs[i].UserKey < leafs[j].UserKey
+	})
+
+	tree, err := BuildTree(leafs)
+	if err != nil {
+		return
+	}
+	rootHash, rootSum := tree.Root()
+
+	for i, l := range leafs {
+		var encoded []byte
+		encoded, err = json.Marshal(tree.Proof(i))
+		if err != nil {
+			return
+		}
+		report.Leafs = append(report.Leafs, LeafRow{
+			UserKey: l.UserKey,
+			Balance: &processing.Decimal{V: l.Balance},
+			Proof:   postgres.Jsonb{RawMessage: json.RawMessage(encoded)},
+		})
+	}
+
+	report.RootHash = rootHash
+	report.Liabilities = &processing.Decimal{V: rootSum}
+	report.NodeBalance = &processing.Decimal{V: nodeBalance}
+	report.AddressesBalance = &processing.Decimal{V: addressesBalance}
+
+	span.LogKV(
+		"root_hash", rootHash,
+		"liabilities", rootSum,
+		"node_balance", nodeBalance,
+		"addresses_balance", addressesBalance,
+		"users_num", len(leafs),
+	)
+	return
+}
+
+// GetUserProofs implements IReserves
+func (r *Reserves) GetUserProofs(ctx context.Context, userPhone string) (
+	report *Report, proofs []UserProof, err error,
+) {
+	span, ctx := ot.StartSpanFromContext(ctx, "get_user_reserves_proofs")
+	defer span.Finish()
+
+	span.LogKV("user_phone", userPhone)
+
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		var reports []Report
+		err := dbTx.Order("id desc").Limit(1).Find(&reports).Error
+		if err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			return ErrNoReports
+		}
+		report = &reports[0]
+
+		nonce := UserNonce(r.secret, report.Salt, userPhone)
+		var coinReports []CoinReport
+		err = dbTx.Where("report_id = ?", report.ID).Preload("Coin").Preload(
+			"Leafs", "user_key = ?", UserKey(nonce, userPhone),
+		).Find(&coinReports).Error
+		if err != nil {
+			return err
+		}
+
+		for _, cr := range coinReports {
+			for _, l := range cr.Leafs {
+				var steps []ProofStep
+				err = json.Unmarshal(l.Proof.RawMessage, &steps)
+				if err != nil {
+					return err
+				}
+				proofs = append(proofs, UserProof{
+					Coin:        cr.Coin.ShortName,
+					RootHash:    cr.RootHash,
+					Liabilities: cr.Liabilities,
+					Nonce:       nonce,
+					UserKey:     l.UserKey,
+					Balance:     l.Balance,
+					Proof:       steps,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		trace.LogError(span, err)
+	}
+	return
+}
+
+// loadWallets loads enabled coin and all it's custodial wallets with addresses history, returns nil coin if coin is disabled or not found
+func (r *Reserves) loadWallets(ctx context.Context, coinName string) (
+	coin *queries.Coin, wts []queries.Wallet, err error,
+) {
+	err = db.TransactionCtx(ctx, r.database, func(ctx context.Context, dbTx *gorm.DB) error {
+		var coins []queries.Coin
+		err := dbTx.Where("upper(short_name) = ? and enabled is true", coinName).Find(&coins).Error
+		if err != nil {
+			return err
+		}
+		if len(coins) == 0 {
+			return nil
+		}
+		coin = &coins[0]
+
+		return dbTx.Where("coin_id = ? and watch_only is false", coin.ID).Preload("Coin").Preload("Addresses").Order("id").Find(&wts).Error
+	})
+	return
+}
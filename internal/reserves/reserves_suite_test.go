@@ -0,0 +1,112 @@
+package reserves_test
+
+import (
+	"testing"
+
+	"encoding/json"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/reserves"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestReserves(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Reserves Suite")
+}
+
+var secret = []byte("secret")
+
+func userKey(salt, phone string) string {
+	return reserves.UserKey(reserves.UserNonce(secret, salt, phone), phone)
+}
+
+var _ = Describe("testing users keys", func() {
+	It("should derive distinct nonces for each user and report", func() {
+		nonces := map[string]bool{
+			reserves.UserNonce(secret, "report a", "+79991112233"):          true,
+			reserves.UserNonce(secret, "report a", "+79991112244"):          true,
+			reserves.UserNonce(secret, "report b", "+79991112233"):          true,
+			reserves.UserNonce([]byte("other"), "report a", "+79991112233"): true,
+		}
+		Expect(nonces).To(HaveLen(4))
+		Expect(reserves.UserNonce(secret, "report a", "+79991112233")).To(
+			Equal(reserves.UserNonce(secret, "report a", "+79991112233")),
+		)
+	})
+
+	It("should not derive key from salt only", func() {
+		Expect(userKey("salt", "+79991112233")).NotTo(Equal(reserves.UserKey("salt", "+79991112233")))
+	})
+
+	It("should require secret", func() {
+		_, err := reserves.New(nil, nil, nil, "")
+		Expect(err).To(Equal(reserves.ErrNoSecret))
+	})
+})
+
+var _ = Describe("testing report export", func() {
+	It("should publish roots without users keys and balances", func() {
+		report := &reserves.Report{ID: 1, Salt: "salt", Coins: []reserves.CoinReport{{
+			Coin:             &queries.Coin{ShortName: "BTC"},
+			RootHash:         "root",
+			Liabilities:      &processing.Decimal{V: new(decimal.Big).SetFloat64(3.75)},
+			NodeBalance:      &processing.Decimal{V: new(decimal.Big).SetFloat64(4)},
+			AddressesBalance: &processing.Decimal{V: new(decimal.Big).SetFloat64(4)},
+			Leafs: []reserves.LeafRow{
+				{UserKey: userKey("salt", "+79991112233"), Balance: &processing.Decimal{V: new(decimal.Big).SetFloat64(1.5)}},
+				{UserKey: userKey("salt", "+79991112244"), Balance: &processing.Decimal{V: new(decimal.Big).SetFloat64(2.25)}},
+			},
+		}}}
+
+		encoded, err := json.Marshal(reserves.NewExport(report))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(encoded)).To(ContainSubstring(`"root_hash":"root"`))
+		Expect(string(encoded)).To(ContainSubstring(`"users_num":2`))
+		Expect(string(encoded)).NotTo(ContainSubstring("salt"))
+		Expect(string(encoded)).NotTo(ContainSubstring(report.Coins[0].Leafs[0].UserKey))
+		Expect(string(encoded)).NotTo(ContainSubstring("2.25"))
+	})
+})
+
+var _ = Describe("testing merkle sum tree", func() {
+	leafs := []reserves.Leaf{
+		{UserKey: userKey("salt", "+79991112233"), Balance: new(decimal.Big).SetFloat64(1.5)},
+		{UserKey: userKey("salt", "+79991112244"), Balance: new(decimal.Big).SetFloat64(0)},
+		{UserKey: userKey("salt", "+79991112255"), Balance: new(decimal.Big).SetFloat64(2.25)},
+	}
+
+	It("should sum all leafs", func() {
+		tree, err := reserves.BuildTree(leafs)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, sum := tree.Root()
+		Expect(sum.Cmp(new(decimal.Big).SetFloat64(3.75))).To(Equal(0))
+	})
+
+	It("should verify each leaf proof", func() {
+		tree, err := reserves.BuildTree(leafs)
+		Expect(err).NotTo(HaveOccurred())
+
+		hash, sum := tree.Root()
+		for i, l := range leafs {
+			Expect(reserves.VerifyProof(l.UserKey, l.Balance, tree.Proof(i), hash, sum)).To(BeTrue())
+		}
+	})
+
+	It("should reject proof with forged balance", func() {
+		tree, err := reserves.BuildTree(leafs)
+		Expect(err).NotTo(HaveOccurred())
+
+		hash, sum := tree.Root()
+		forged := new(decimal.Big).SetFloat64(1)
+		Expect(reserves.VerifyProof(leafs[0].UserKey, forged, tree.Proof(0), hash, sum)).To(BeFalse())
+	})
+
+	It("should fail on negative liability", func() {
+		_, err := reserves.BuildTree([]reserves.Leaf{{UserKey: "key", Balance: new(decimal.Big).SetFloat64(-1)}})
+		Expect(err).To(Equal(reserves.ErrNegativeLiability))
+	})
+})
@@ -0,0 +1,84 @@
+package common_test
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+func TestCommon(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Handlers Common Suite")
+}
+
+var _ = Describe("testing converter errors coercion", func() {
+	for _, c := range []struct {
+		label string
+		err   error
+		want  error
+	}{
+		{"should coerce unavailable rates", convert.ErrUnavailable, common.ErrRatesUnavailable},
+		{
+			"should coerce wrapped quorum failure",
+			errors.Wrapf(convert.ErrNoQuorum, "median converter: %d of %d required sources agree", 1, 2),
+			common.ErrRatesUnavailable,
+		},
+		{"should keep request dependent error", convert.ErrFiatCurrencyName, convert.ErrFiatCurrencyName},
+	} {
+		c := c
+		It(c.label, func() {
+			Expect(common.CoerceConvertErr(c.err)).To(Equal(c.want))
+		})
+	}
+
+	It("should keep unknown wrapped error as is", func() {
+		err := errors.Wrap(errors.New("unknown"), "querying rate")
+		Expect(common.CoerceConvertErr(err)).To(BeIdenticalTo(err))
+	})
+})
+
+var _ = Describe("testing convert param parsing", func() {
+	// manyCurrencies builds comma-separated list of n distinct currencies names
+	manyCurrencies := func(n int) string {
+		names := make([]string, n)
+		for i := range names {
+			names[i] = "c" + strconv.Itoa(i)
+		}
+		return strings.Join(names, ",")
+	}
+
+	for _, c := range []struct {
+		label string
+		param string
+		want  []string
+	}{
+		{"should return default currency for empty param", "", []string{common.DefaultFiatCurrency}},
+		{"should return default currency for blank names only", " , ,,", []string{common.DefaultFiatCurrency}},
+		{"should lower-case single currency", "EUR", []string{"eur"}},
+		{"should keep currencies order", "rub,usd,eur", []string{"rub", "usd", "eur"}},
+		{"should trim spaces around names", " usd , eur ", []string{"usd", "eur"}},
+		{"should skip empty names", "usd,,eur,", []string{"usd", "eur"}},
+		{"should deduplicate case-insensitively", "usd,EUR,Usd,eur", []string{"usd", "eur"}},
+		{
+			"should take at most max currencies",
+			manyCurrencies(common.MaxConvertCurrencies + 2),
+			strings.Split(manyCurrencies(common.MaxConvertCurrencies), ","),
+		},
+		{
+			"should not count duplicates against the limit",
+			"c0,c0," + manyCurrencies(common.MaxConvertCurrencies),
+			strings.Split(manyCurrencies(common.MaxConvertCurrencies), ","),
+		},
+	} {
+		c := c
+		It(c.label, func() {
+			Expect(common.ParseConvertParam(c.param)).To(Equal(c.want))
+		})
+	}
+})
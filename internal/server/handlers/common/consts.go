@@ -4,4 +4,7 @@ package common
 const DefaultFiatCurrency = "usd"
 
 // DefaultCryptoCurrency system default crypto-currency
-const DefaultCryptoCurrency = "btc"
\ No newline at end of file
+const DefaultCryptoCurrency = "btc"
+
+// MaxConvertCurrencies max number of fiat currencies balances may be converted into by single request
+const MaxConvertCurrencies = 10
\ No newline at end of file
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// ErrRatesUnavailable returned when rates can't be queried or their sources don't agree
+var ErrRatesUnavailable = base.ErrorView{Code: http.StatusServiceUnavailable, Message: "rates are unavailable"}
+
+// CoerceConvertErr converts converter errors, which don't depend on request params, into api errors, other errors are
+// returned as is. Errors are unwrapped before comparison.
+func CoerceConvertErr(e error) error {
+	switch errors.Cause(e) {
+	case convert.ErrUnavailable, convert.ErrNoQuorum:
+		return ErrRatesUnavailable
+	default:
+		return e
+	}
+}
@@ -1,9 +1,13 @@
 package common
 
 import (
+	"context"
 	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
 	bdecimal "github.com/ericlagergren/decimal"
+	ot "github.com/opentracing/opentracing-go"
 	"strings"
 )
 
@@ -15,6 +19,16 @@ type AdditionalRate struct {
 	*convert.Rate
 	CoinCurrency string
 	FiatCurrency string
+
+	// ExtraRates holds rates to fiat currencies requested along with FiatCurrency, keyed by lower-cased currency name,
+	// nil rate means it's unknown
+	ExtraRates map[string]*convert.Rate
+
+	// Stale is set if rate is outdated since it can't be queried
+	Stale bool
+
+	// SourcesNum is the number of rate sources which agree on the rate, zero if converter doesn't aggregate sources
+	SourcesNum int
 }
 
 var zeroDecimalView = (*decimal.View)(new(bdecimal.Big).SetFloat64(0))
@@ -25,28 +39,108 @@ func (ar *AdditionalRate) RepresentBalance(balance *bdecimal.Big) MultiCurrencyB
 		strings.ToLower(ar.CoinCurrency): (*decimal.View)(balance),
 	}
 	if ar.FiatCurrency != "" {
-		var value *decimal.View
-		if ar.Rate != nil {
-			value = (*decimal.View)(ar.Convert(balance))
-		} else {
-			value = zeroDecimalView
-		}
-		balances[strings.ToLower(ar.FiatCurrency)] = value
+		balances[strings.ToLower(ar.FiatCurrency)] = representInFiat(ar.Rate, balance)
+	}
+	for currency, rate := range ar.ExtraRates {
+		balances[currency] = representInFiat(rate, balance)
 	}
 	return balances
 }
 
+// representInFiat converts balance using rate, zero is returned if rate is unknown
+func representInFiat(rate *convert.Rate, balance *bdecimal.Big) *decimal.View {
+	if rate == nil {
+		return zeroDecimalView
+	}
+	return (*decimal.View)(rate.Convert(balance))
+}
+
 // AdditionalRates same as AdditionalRate, but for multiple crypto-currency balances
 type AdditionalRates struct {
 	convert.MultiRate
 	FiatCurrency string
+
+	// ExtraCurrencies fiat currencies requested along with FiatCurrency, ExtraRates holds coins rates to them
+	ExtraCurrencies []string
+	ExtraRates      convert.RatesMatrix
+
+	// Stale is set if rates are outdated since they can't be queried
+	Stale bool
+
+	// SourcesNum is the least number of rate sources which agree on the rates, zero if converter doesn't aggregate
+	// sources
+	SourcesNum int
+}
+
+// NewAdditionalRates creates rates description for currencies list, first currency becomes the primary one, matrix may
+// be nil if rates are unknown
+func NewAdditionalRates(currencies []string, m convert.RatesMatrix) AdditionalRates {
+	if len(currencies) == 0 {
+		return AdditionalRates{}
+	}
+	return AdditionalRates{
+		MultiRate:       m.FiatRates(currencies[0]),
+		FiatCurrency:    currencies[0],
+		ExtraCurrencies: currencies[1:],
+		ExtraRates:      m,
+	}
 }
 
 // ForCoinCurrency return rate description for selected currency
 func (ar *AdditionalRates) ForCoinCurrency(coinName string) AdditionalRate {
+	var extraRates map[string]*convert.Rate
+	if len(ar.ExtraCurrencies) > 0 {
+		extraRates = make(map[string]*convert.Rate, len(ar.ExtraCurrencies))
+		for _, currency := range ar.ExtraCurrencies {
+			extraRates[strings.ToLower(currency)] = ar.ExtraRates.FiatRates(currency).CurrencyRate(coinName)
+		}
+	}
 	return AdditionalRate{
 		Rate:         ar.CurrencyRate(coinName),
 		CoinCurrency: coinName,
 		FiatCurrency: strings.ToLower(ar.FiatCurrency),
+		ExtraRates:   extraRates,
+		Stale:        ar.Stale,
+		SourcesNum:   ar.SourcesNum,
 	}
 }
+
+// ParseConvertParam parses comma-separated list of fiat currencies, names are lower-cased and deduplicated, at most
+// MaxConvertCurrencies first currencies are taken. Returns default currency if list is empty.
+func ParseConvertParam(param string) []string {
+	currencies := make([]string, 0, 1)
+	seen := make(map[string]struct{})
+	for _, currency := range strings.Split(param, ",") {
+		currency = strings.ToLower(strings.TrimSpace(currency))
+		if _, ok := seen[currency]; ok || currency == "" {
+			continue
+		}
+		if len(currencies) == MaxConvertCurrencies {
+			break
+		}
+		seen[currency] = struct{}{}
+		currencies = append(currencies, currency)
+	}
+	if len(currencies) == 0 {
+		currencies = append(currencies, DefaultFiatCurrency)
+	}
+	return currencies
+}
+
+// ConvertParamOrPreferred returns convert param, user preferred fiat currency is returned if param is empty. Settings
+// query errors are only traced, so default currency is used in such case, as well as if settings aren't provided.
+func ConvertParamOrPreferred(ctx context.Context, userSettings settings.ISettings, userPhone, param string) string {
+	if strings.TrimSpace(param) != "" || userSettings == nil {
+		return param
+	}
+	trace.InsideSpan(ctx, "querying_preferred_fiat_currency", func(ctx context.Context, span ot.Span) {
+		s, err := userSettings.Get(ctx, userPhone)
+		if err != nil {
+			trace.LogErrorWithMsg(span, err, "preferred fiat currency query failed")
+			return
+		}
+		param = s.PreferredFiat()
+		span.LogKV("preferred_fiat_currency", param)
+	})
+	return param
+}
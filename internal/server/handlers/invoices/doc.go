@@ -0,0 +1,2 @@
+// Package invoices holds all /invoices/* endpoints
+package invoices
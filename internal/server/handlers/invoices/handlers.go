@@ -0,0 +1,318 @@
+package invoices
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/internal/invoices"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/txs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	bdecimal "github.com/ericlagergren/decimal"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+var (
+	// create and update errors
+	errWrongAmount         = base.NewFieldErr("body", "fiat_amount", "must be greater then zero")
+	errInvalidFiatCurrency = base.NewFieldErr("body", "fiat_currency", "invalid fiat currency")
+	errInvalidExpiresAt    = base.NewFieldErr("body", "expires_at", "must be in the future, but not later then a day")
+	errInvalidWebhookURL   = base.NewFieldErr("body", "webhook_url", "must be absolute http or https url of public host")
+	errDescriptionTooLong  = base.NewFieldErr("body", "description", "must be up to 255 characters")
+	errNoSuchWallet        = base.NewFieldErr("body", "wallet_id", "no such wallet")
+	errWatchOnlyWallet     = base.NewFieldErr("body", "wallet_id", "watch-only wallet can't be used")
+	errAccountClosed       = base.ErrorView{Code: http.StatusForbidden, Message: "account is closed"}
+
+	// pay errors
+	errWalletCoinMismatch  = base.NewFieldErr("body", "wallet_id", "wallet coin differs from invoice coin")
+	errSelfPayment         = base.ErrorView{Code: http.StatusBadRequest, Message: "you can't pay your own invoice"}
+	errInsufficientFunds   = base.ErrorView{Code: http.StatusBadRequest, Message: "insufficient funds"}
+	errMerchantClosed      = base.ErrorView{Code: http.StatusConflict, Message: "merchant account is closed"}
+	errAwaitsConfirmations = base.ErrorView{Code: http.StatusConflict, Message: "invoice awaits payments confirmations"}
+	errInvoiceExpired      = base.ErrorView{Code: http.StatusConflict, Message: "invoice is expired"}
+	errInvoiceNotPending   = base.ErrorView{Code: http.StatusConflict, Message: "invoice is already finished"}
+
+	// invoice path errors
+	errInvoiceIDInvalid = base.NewFieldErr("path", "invoice_id", "invoice id is invalid")
+	errInvoiceNotFound  = base.NewFieldErr("path", "invoice_id", "no such invoice")
+)
+
+// CreateFactory creates handler which creates merchant invoice accepting 'CreateRequest' like scheme, coin amount is
+// locked at the current rate. Returns 'SingleResponse' on success.
+func CreateFactory(merchantInvoices invoices.IInvoices) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := CreateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		span.LogKV(
+			"wallet_id", params.WalletID,
+			"fiat_amount", params.FiatAmount,
+			"fiat_currency", params.FiatCurrency,
+		)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		invoice, err := merchantInvoices.Create(ctx, userPhone, invoices.InvoiceParams{
+			WalletID:     params.WalletID,
+			FiatAmount:   (*bdecimal.Big)(params.FiatAmount),
+			FiatCurrency: params.FiatCurrency,
+			Description:  params.Description,
+			WebhookURL:   params.WebhookURL,
+			ExpiresAt:    fromUnixTime(params.ExpiresAt),
+		})
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		code = 201
+		resp = SingleResponse{Invoice: ToView(invoice, userPhone)}
+		return
+	}
+}
+
+// GetFactory creates handler which returns merchant invoice specified by path param 'invoice_id' with it's payments
+// txs, returns 'SingleResponse' on success.
+func GetFactory(merchantInvoices invoices.IInvoices) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse invoice id path param
+		invoiceID, invoiceIDValid := FromIdView(c.Param("invoice_id"))
+		if !invoiceIDValid {
+			err = errInvoiceIDInvalid
+			return
+		}
+		span.LogKV("invoice_id", invoiceID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		invoice, err := merchantInvoices.Get(ctx, userPhone, invoiceID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Invoice: ToView(invoice, userPhone)}
+		return
+	}
+}
+
+// GetAllFactory creates handler which returns merchant invoices, query param 'status' optionally filters them.
+// Returns 'MultipleResponse' on success.
+func GetAllFactory(merchantInvoices invoices.IInvoices) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// bind query params ignore error
+		params := GetAllRequest{}
+		c.ShouldBindQuery(&params)
+		span.LogKV("status", params.Status)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		list, err := merchantInvoices.GetAll(ctx, userPhone, params.Status)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = ToMultipleResponse(list, userPhone)
+		return
+	}
+}
+
+// UpdateFactory creates handler which changes description or webhook of pending invoice specified by path param
+// 'invoice_id' accepting 'UpdateRequest' like scheme, returns 'SingleResponse' on success.
+func UpdateFactory(merchantInvoices invoices.IInvoices) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse invoice id path param
+		invoiceID, invoiceIDValid := FromIdView(c.Param("invoice_id"))
+		if !invoiceIDValid {
+			err = errInvoiceIDInvalid
+			return
+		}
+		span.LogKV("invoice_id", invoiceID)
+
+		// bind params
+		params := UpdateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		invoice, err := merchantInvoices.Update(ctx, userPhone, invoiceID, invoices.InvoiceUpdate{
+			Description: params.Description,
+			WebhookURL:  params.WebhookURL,
+		})
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Invoice: ToView(invoice, userPhone)}
+		return
+	}
+}
+
+// CancelFactory creates handler which cancels pending invoice specified by path param 'invoice_id', already received
+// payments aren't affected. Returns 'SingleResponse' on success.
+func CancelFactory(merchantInvoices invoices.IInvoices) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse invoice id path param
+		invoiceID, invoiceIDValid := FromIdView(c.Param("invoice_id"))
+		if !invoiceIDValid {
+			err = errInvoiceIDInvalid
+			return
+		}
+		span.LogKV("invoice_id", invoiceID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		invoice, err := merchantInvoices.Cancel(ctx, userPhone, invoiceID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Invoice: ToView(invoice, userPhone)}
+		return
+	}
+}
+
+// PayFactory creates handler which pays the rest of another user invoice specified by path param 'invoice_id' from
+// the wallet given by 'PayRequest' like scheme, returns 'PayResponse' on success.
+func PayFactory(merchantInvoices invoices.IInvoices) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse invoice id path param
+		invoiceID, invoiceIDValid := FromIdView(c.Param("invoice_id"))
+		if !invoiceIDValid {
+			err = errInvoiceIDInvalid
+			return
+		}
+		span.LogKV("invoice_id", invoiceID)
+
+		// bind params
+		params := PayRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+		span.LogKV("wallet_id", params.WalletID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		invoice, tx, err := merchantInvoices.Pay(ctx, userPhone, invoiceID, params.WalletID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = PayResponse{Invoice: ToView(invoice, userPhone), TxID: txs.ToIdView(tx.ID)}
+		return
+	}
+}
+
+func coerceErrs(err error) error {
+	if errors, ok := err.(merrors.Errors); ok {
+		for i, e := range errors {
+			errors[i] = coerceErr(e)
+		}
+		return errors
+	}
+	return coerceErr(err)
+}
+
+func coerceErr(e error) (newE error) {
+	switch errors.Cause(e) {
+	case errs.ErrNonPositiveAmount:
+		newE = errWrongAmount
+	case convert.ErrFiatCurrencyName:
+		newE = errInvalidFiatCurrency
+	case invoices.ErrInvalidExpiresAt:
+		newE = errInvalidExpiresAt
+	case invoices.ErrInvalidWebhookURL:
+		newE = errInvalidWebhookURL
+	case invoices.ErrDescriptionTooLong:
+		newE = errDescriptionTooLong
+	case errs.ErrNoSuchWallet:
+		newE = errNoSuchWallet
+	case errs.ErrWatchOnlyWallet, processing.ErrWatchOnlyWallet:
+		newE = errWatchOnlyWallet
+	case errs.ErrAccountClosed:
+		newE = errAccountClosed
+	case invoices.ErrWalletCoinMismatch:
+		newE = errWalletCoinMismatch
+	case errs.ErrSelfTxForbidden:
+		newE = errSelfPayment
+	case processing.ErrInsufficientFunds:
+		newE = errInsufficientFunds
+	case errs.ErrRecipientAccountClosed:
+		newE = errMerchantClosed
+	case invoices.ErrInvoiceAwaitsConfirmations:
+		newE = errAwaitsConfirmations
+	case invoices.ErrInvoiceExpired:
+		newE = errInvoiceExpired
+	case invoices.ErrInvoiceNotPending:
+		newE = errInvoiceNotPending
+	case invoices.ErrNoSuchInvoice:
+		newE = errInvoiceNotFound
+	default:
+		newE = common.CoerceConvertErr(e)
+	}
+	return
+}
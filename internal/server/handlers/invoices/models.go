@@ -0,0 +1,156 @@
+package invoices
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/wallet-api/internal/invoices"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/txs"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/wallets"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateRequest used to parse create invoice request body
+type CreateRequest struct {
+	WalletID     int64         `json:"wallet_id,string" validate:"required"`
+	FiatAmount   *decimal.View `json:"fiat_amount" validate:"required"`
+	FiatCurrency string        `json:"fiat_currency" validate:"required"`
+	Description  string        `json:"description,omitempty"`
+
+	// WebhookURL receives invoice status changes
+	WebhookURL string `json:"webhook_url,omitempty"`
+
+	// ExpiresAt is the unix time until which locked amount may be paid, default ttl is used if omitted
+	ExpiresAt *int64 `json:"expires_at,omitempty"`
+}
+
+// UpdateRequest used to parse update invoice request body, only presented fields are updated
+type UpdateRequest struct {
+	Description *string `json:"description"`
+	WebhookURL  *string `json:"webhook_url"`
+}
+
+// PayRequest used to parse pay invoice request body
+type PayRequest struct {
+	WalletID int64 `json:"wallet_id,string" validate:"required"`
+}
+
+// GetAllRequest used to parse invoices list query params
+type GetAllRequest struct {
+	Status string `form:"status"`
+}
+
+// View represents merchant invoice, webhook and it's secret are shown only to the merchant
+type View struct {
+	ID            string              `json:"id"`
+	WalletID      string              `json:"wallet_id"`
+	Coin          string              `json:"coin"`
+	Address       string              `json:"address,omitempty"`
+	Amount        *decimal.View       `json:"amount"`
+	PaidAmount    *decimal.View       `json:"paid_amount"`
+	Rate          *decimal.View       `json:"rate"`
+	FiatAmount    *decimal.View       `json:"fiat_amount"`
+	FiatCurrency  string              `json:"fiat_currency"`
+	Description   string              `json:"description,omitempty"`
+	WebhookURL    string              `json:"webhook_url,omitempty"`
+	WebhookSecret string              `json:"webhook_secret,omitempty"`
+	Status        string              `json:"status"`
+	ExpiresAt     types.UnixTimeView  `json:"expires_at"`
+	TxIDs         []string            `json:"tx_ids,omitempty"`
+	CreatedAt     types.UnixTimeView  `json:"created_at"`
+	UpdatedAt     *types.UnixTimeView `json:"updated_at,omitempty"`
+}
+
+// SingleResponse single invoice response
+type SingleResponse struct {
+	Invoice View `json:"invoice"`
+}
+
+// PayResponse invoice payment response
+type PayResponse struct {
+	Invoice View   `json:"invoice"`
+	TxID    string `json:"tx_id"`
+}
+
+// MultipleResponse merchant invoices response
+type MultipleResponse struct {
+	Count    int    `json:"count"`
+	Invoices []View `json:"invoices"`
+}
+
+// ToIdView converts invoice id to api representation
+func ToIdView(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// FromIdView converts id api representation into invoice id and provides valid flag
+func FromIdView(idView string) (id int64, valid bool) {
+	id, parseIntErr := strconv.ParseInt(idView, 10, 64)
+	valid = parseIntErr == nil
+	return
+}
+
+// ToView renders invoice for the user
+func ToView(invoice *invoices.Invoice, userPhone string) View {
+	view := View{
+		ID:           ToIdView(invoice.ID),
+		WalletID:     wallets.GetWalletIDView(invoice.WalletID),
+		Amount:       (*decimal.View)(invoice.Amount.V),
+		PaidAmount:   (*decimal.View)(invoice.PaidAmount.V),
+		Rate:         (*decimal.View)(invoice.Rate.V),
+		FiatAmount:   (*decimal.View)(invoice.FiatAmount.V),
+		FiatCurrency: strings.ToLower(invoice.FiatCurrency),
+		Status:       invoice.Status,
+		ExpiresAt:    types.UnixTimeView(invoice.ExpiresAt),
+		CreatedAt:    types.UnixTimeView(invoice.CreatedAt),
+	}
+	if invoice.Wallet != nil {
+		view.Coin = strings.ToLower(invoice.Wallet.Coin.ShortName)
+	}
+	if invoice.Address != nil {
+		view.Address = *invoice.Address
+	}
+	if invoice.Description != nil {
+		view.Description = *invoice.Description
+	}
+	if invoice.MerchantPhone == userPhone {
+		if invoice.WebhookURL != nil {
+			view.WebhookURL = *invoice.WebhookURL
+		}
+		if invoice.WebhookSecret != nil {
+			view.WebhookSecret = *invoice.WebhookSecret
+		}
+	}
+	for _, p := range invoice.Payments {
+		view.TxIDs = append(view.TxIDs, txs.ToIdView(p.TxID))
+	}
+	if !invoice.IsPending() {
+		view.UpdatedAt = toTimeView(&invoice.UpdatedAt)
+	}
+	return view
+}
+
+// ToMultipleResponse renders merchant invoices
+func ToMultipleResponse(invoicesList []invoices.Invoice, userPhone string) MultipleResponse {
+	views := make([]View, 0, len(invoicesList))
+	for i := range invoicesList {
+		views = append(views, ToView(&invoicesList[i], userPhone))
+	}
+	return MultipleResponse{Count: len(views), Invoices: views}
+}
+
+func toTimeView(t *time.Time) *types.UnixTimeView {
+	if t == nil {
+		return nil
+	}
+	view := types.UnixTimeView(*t)
+	return &view
+}
+
+func fromUnixTime(unixTime *int64) time.Time {
+	if unixTime == nil {
+		return time.Time{}
+	}
+	return time.Unix(*unixTime, 0).UTC()
+}
@@ -0,0 +1,56 @@
+package invoices
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/invoices"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+)
+
+// Dependencies
+type Dependencies struct {
+	dig.In
+
+	Routes         gin.IRouter     `name:"api_routes"`
+	AuthMiddleware gin.HandlerFunc `name:"auth_middleware"`
+	UserMiddleware gin.HandlerFunc `name:"user_middleware"`
+
+	Invoices invoices.IInvoices
+}
+
+// Register
+func Register(dependencies Dependencies) error {
+	group := dependencies.Routes.Group(
+		"/user/:user_phone/",
+		trace.StartSpanMiddleware(),
+		dependencies.AuthMiddleware,
+		dependencies.UserMiddleware,
+	)
+
+	group.POST(
+		"/invoices",
+		base.WrapHandler(CreateFactory(dependencies.Invoices)),
+	)
+	group.GET(
+		"/invoices",
+		base.WrapHandler(GetAllFactory(dependencies.Invoices)),
+	)
+	group.GET(
+		"/invoices/:invoice_id",
+		base.WrapHandler(GetFactory(dependencies.Invoices)),
+	)
+	group.PATCH(
+		"/invoices/:invoice_id",
+		base.WrapHandler(UpdateFactory(dependencies.Invoices)),
+	)
+	group.DELETE(
+		"/invoices/:invoice_id",
+		base.WrapHandler(CancelFactory(dependencies.Invoices)),
+	)
+	group.POST(
+		"/invoices/:invoice_id/pay",
+		base.WrapHandler(PayFactory(dependencies.Invoices)),
+	)
+	return nil
+}
@@ -2,15 +2,23 @@ package isc
 
 import (
 	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/common/pkg/types"
 	decimal2 "git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/wallet-api/internal/reserves"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
 	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/cache"
 	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
 	"github.com/ericlagergren/decimal"
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
+	"net/http"
 	"strings"
 )
 
@@ -19,8 +27,10 @@ const (
 	defaultFeatCurrency   = "usd"
 )
 
-// UserStatFactory returns total user wallets balances
-func UserStatFactory(api *wallets.Api, cryptoConverter convert.ICryptoCurrency) base.HandlerFunc {
+// UserStatFactory returns total user wallets balances, user preferred fiat currency is used if convert param is omitted
+func UserStatFactory(
+	api *wallets.Api, cryptoConverter convert.ICryptoCurrency, userSettings settings.ISettings,
+) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		span, ctx := trace.GetSpanWithCtx(c)
 		defer span.Finish()
@@ -31,18 +41,29 @@ func UserStatFactory(api *wallets.Api, cryptoConverter convert.ICryptoCurrency)
 		if err != nil {
 			return
 		}
-		if params.Convert == "" {
-			params.Convert = defaultCryptoCurrency
-		}
+		// rates to multiple currencies may be requested
+		currencies := common.ParseConvertParam(
+			common.ConvertParamOrPreferred(ctx, userSettings, params.UserPhone, params.Convert),
+		)
 
-		span.LogKV("user_phone", params.UserPhone)
+		span.LogKV("user_phone", params.UserPhone, "with_watch_only", params.WithWatchOnly)
 
-		wtsCount, totalFiatBalance, totalBalanceInDefCurr := 0, new(decimal.Big), new(decimal.Big)
+		wtsCount, totalBalanceInDefCurr := 0, new(decimal.Big)
+		totalFiatBalances := make(map[string]*decimal.Big, len(currencies))
+		for _, currency := range currencies {
+			totalFiatBalances[currency] = new(decimal.Big)
+		}
+
+		// outdated rates are acceptable to represent balances
+		ctx = convert.WithSourcesTracking(convert.WithStaleTracking(ctx))
 		err = trace.InsideSpanE(
 			ctx,
 			"querying_user_wallets_balance",
 			func(ctx context.Context, span opentracing.Span) error {
-				wts, _, _, err := api.GetWallets(ctx, params.UserPhone, "", 0, 0)
+				wts, _, _, err := api.GetWallets(ctx, params.UserPhone, wallets.GetWalletsFilters{
+					WithArchived:  true,
+					WithWatchOnly: params.WithWatchOnly,
+				}, false)
 				if err != nil {
 					return err
 				}
@@ -78,28 +99,31 @@ func UserStatFactory(api *wallets.Api, cryptoConverter convert.ICryptoCurrency)
 					ctx,
 					"converting_coin_balances",
 					func(ctx context.Context, span opentracing.Span) error {
-						rates, err := convert.GetMultiRateDefaultFiat(
-							cryptoConverter, ctx, coinNames, params.Convert, defaultFeatCurrency,
+						m, err := convert.GetRatesMatrixDefaultFiat(
+							cryptoConverter, ctx, coinNames, currencies, defaultFeatCurrency,
 						)
 						if err != nil {
 							return err
 						}
-						logrus.Info(rates)
+						logrus.Info(m)
 
-						// calculate total fiat balance
-						for _, w := range wts {
-							if w.Balance == nil || w.Balance.Sign() == 0 {
-								continue
-							}
+						// calculate total fiat balances
+						for _, currency := range currencies {
+							rates, totalFiatBalance := m.FiatRates(currency), totalFiatBalances[currency]
+							for _, w := range wts {
+								if w.Balance == nil || w.Balance.Sign() == 0 {
+									continue
+								}
 
-							totalFiatBalance = totalFiatBalance.Add(
-								totalFiatBalance, rates.CurrencyRate(w.Coin.ShortName).Convert(w.Balance),
-							)
+								totalFiatBalance.Add(
+									totalFiatBalance, rates.CurrencyRate(w.Coin.ShortName).Convert(w.Balance),
+								)
+							}
 						}
 
-						// calculate total btc balance by reverse converting total fiat balance
-						defaultCurrencyRate := rates.CurrencyRate(defaultCryptoCurrency)
-						totalBalanceInDefCurr = defaultCurrencyRate.ReverseConvert(totalFiatBalance)
+						// calculate total btc balance by reverse converting total fiat balance of the first currency
+						defaultCurrencyRate := m.FiatRates(currencies[0]).CurrencyRate(defaultCryptoCurrency)
+						totalBalanceInDefCurr = defaultCurrencyRate.ReverseConvert(totalFiatBalances[currencies[0]])
 
 						return nil
 					},
@@ -116,14 +140,194 @@ func UserStatFactory(api *wallets.Api, cryptoConverter convert.ICryptoCurrency)
 		}
 
 		// prepare response
+		totalBalance := map[string]*decimal2.View{
+			strings.ToLower(defaultCryptoCurrency): (*decimal2.View)(totalBalanceInDefCurr),
+		}
+		for currency, totalFiatBalance := range totalFiatBalances {
+			totalBalance[currency] = (*decimal2.View)(totalFiatBalance)
+		}
 		resp = UserStatsResponseView{
-			Count: wtsCount,
-			TotalBalance: map[string]*decimal2.View{
-				strings.ToLower(defaultCryptoCurrency): (*decimal2.View)(totalBalanceInDefCurr),
-				strings.ToLower(params.Convert):        (*decimal2.View)(totalFiatBalance),
-			},
+			Count:           wtsCount,
+			TotalBalance:    totalBalance,
+			RatesStale:      convert.IsStale(ctx),
+			RatesSourcesNum: convert.SourcesNum(ctx),
+		}
+
+		return
+	}
+}
+
+// PhoneChangeSource is phone change audit source of changes made through internal api
+const PhoneChangeSource = "internal_api"
+
+var (
+	errPhoneChangeConflict = base.ErrorView{Code: http.StatusConflict, Message: "new phone already has wallets"}
+	errSamePhone           = base.NewFieldErr("body", "new_phone", "new phone is the same as old one")
+)
+
+// PhoneChangeFactory re-keys user wallets and txs which await recipient to the new phone
+func PhoneChangeFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := PhoneChangeRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		span.LogKV("old_phone", params.OldPhone, "new_phone", params.NewPhone)
+
+		change, err := api.ChangePhone(ctx, params.OldPhone, params.NewPhone, PhoneChangeSource)
+		if err != nil {
+			switch err {
+			case errs.ErrPhoneChangeConflict:
+				err = errPhoneChangeConflict
+			case errs.ErrSamePhone:
+				err = errSamePhone
+			}
+			return
+		}
+
+		resp = PhoneChangeView{
+			ID:         change.ID,
+			OldPhone:   change.OldPhone,
+			NewPhone:   change.NewPhone,
+			WalletsNum: change.WalletsNum,
+			TxsNum:     change.TxsNum,
+			CreatedAt:  types.UnixTimeView(change.CreatedAt),
+		}
+		return
+	}
+}
+
+var (
+	errClosureTargetAddressInvalid = base.NewFieldErr("body", "targets", "invalid withdrawal address")
+	errClosureTargetCoinInvalid    = base.NewFieldErr("body", "targets", "invalid coin name")
+	errNegativeFeeReserve          = base.NewFieldErr("body", "targets", "fee reserve must not be negative")
+	errNoSuchAccountClosure        = base.ErrorView{Code: http.StatusNotFound, Message: "account closure isn't started"}
+)
+
+// CloseAccountFactory starts or resumes user account closure withdrawing remaining funds to given targets
+func CloseAccountFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := AccountClosureRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		span.LogKV("user_phone", params.UserPhone)
+
+		targets := make(map[string]wallets.SweepTarget, len(params.Targets))
+		for coinName, t := range params.Targets {
+			target := wallets.SweepTarget{Address: t.Address}
+			if t.FeeReserve != nil {
+				target.FeeReserve = (*decimal.Big)(t.FeeReserve)
+				if target.FeeReserve.Sign() < 0 {
+					err = errNegativeFeeReserve
+					return
+				}
+			}
+			targets[coinName] = target
+		}
+
+		closure, err := api.CloseAccount(ctx, params.UserPhone, targets)
+		if err != nil {
+			switch {
+			case err == errs.ErrInvalidAddress:
+				err = errClosureTargetAddressInvalid
+			case err == errs.ErrNoSuchCoin:
+				err = errClosureTargetCoinInvalid
+			case len(closure.MissingTargets) > 0:
+				// closure is in progress, but some coins funds have nowhere to be withdrawn
+				var targetsErrs error
+				for _, coinName := range closure.MissingTargets {
+					targetsErrs = merrors.Append(targetsErrs, base.NewFieldErr(
+						"body", "targets."+strings.ToLower(coinName), "withdrawal address required",
+					))
+				}
+				err = targetsErrs
+			}
+			return
+		}
+
+		resp = ToAccountClosureView(&closure)
+		return
+	}
+}
+
+// GetAccountClosureFactory returns user account closure progress
+func GetAccountClosureFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := AccountClosureQuery{}
+		err = c.BindQuery(&params)
+		if err != nil {
+			return
 		}
 
+		span.LogKV("user_phone", params.UserPhone)
+
+		closure, err := api.GetAccountClosure(ctx, params.UserPhone)
+		if err != nil {
+			if err == errs.ErrNoSuchAccountClosure {
+				err = errNoSuchAccountClosure
+			}
+			return
+		}
+
+		resp = ToAccountClosureView(&closure)
+		return
+	}
+}
+
+var errNoReservesReports = base.ErrorView{Code: http.StatusNotFound, Message: "no proof-of-reserves reports yet"}
+
+// ReservesProofFactory returns user inclusion proofs of the latest proof-of-reserves report
+func ReservesProofFactory(reservesApi reserves.IReserves) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := ReservesProofRequest{}
+		err = c.BindQuery(&params)
+		if err != nil {
+			return
+		}
+
+		span.LogKV("user_phone", params.UserPhone)
+
+		report, proofs, err := reservesApi.GetUserProofs(ctx, params.UserPhone)
+		if err != nil {
+			if err == reserves.ErrNoReports {
+				err = errNoReservesReports
+			}
+			return
+		}
+
+		resp = ToReservesProofView(report, proofs)
+		return
+	}
+}
+
+var errRatesCacheDisabled = base.ErrorView{Code: http.StatusNotFound, Message: "rates cache is disabled"}
+
+// ConverterStatsFactory returns rates cache usage statistics
+func ConverterStatsFactory(converter convert.ICryptoCurrency) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		stats, ok := converter.(cache.IStats)
+		if !ok {
+			err = errRatesCacheDisabled
+			return
+		}
+		resp = ToConverterStatsView(stats.Stats())
 		return
 	}
 }
@@ -1,11 +1,21 @@
 package isc
 
-import "git.zam.io/wallet-backend/common/pkg/types/decimal"
+import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/wallet-api/internal/reserves"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/cache"
+	"strconv"
+)
 
 // UserStatRequest used to parse incoming user statistic request
 type UserStatRequest struct {
 	UserPhone string `form:"user_phone" validate:"required,phone"`
 	Convert   string `form:"convert"`
+
+	// WithWatchOnly includes watch-only wallets balances into totals
+	WithWatchOnly bool `form:"with_watch_only"`
 }
 
 func DefaultUserStatRequest() UserStatRequest {
@@ -18,4 +28,166 @@ func DefaultUserStatRequest() UserStatRequest {
 type UserStatsResponseView struct {
 	Count        int                      `json:"count"`
 	TotalBalance map[string]*decimal.View `json:"total_balance"`
+
+	// RatesStale is set if fiat balance is calculated at outdated rates
+	RatesStale bool `json:"rates_stale,omitempty"`
+
+	// RatesSourcesNum is the least number of rate sources which agree on the rates fiat balance is calculated at
+	RatesSourcesNum int `json:"rates_sources_num,omitempty"`
+}
+
+// PhoneChangeRequest used to parse user phone change request body
+type PhoneChangeRequest struct {
+	OldPhone string `json:"old_phone" validate:"required,phone"`
+	NewPhone string `json:"new_phone" validate:"required,phone"`
+}
+
+// PhoneChangeView represents phone change audit record
+type PhoneChangeView struct {
+	ID         int64              `json:"id"`
+	OldPhone   string             `json:"old_phone"`
+	NewPhone   string             `json:"new_phone"`
+	WalletsNum int64              `json:"wallets_num"`
+	TxsNum     int64              `json:"txs_num"`
+	CreatedAt  types.UnixTimeView `json:"created_at"`
+}
+
+// SweepTargetRequest describes where coin funds are withdrawn to on account closure
+type SweepTargetRequest struct {
+	Address    string        `json:"address" validate:"required"`
+	FeeReserve *decimal.View `json:"fee_reserve"`
+}
+
+// AccountClosureRequest used to parse account closure request body, targets are keyed by coin short name
+type AccountClosureRequest struct {
+	UserPhone string                        `json:"user_phone" validate:"required,phone"`
+	Targets   map[string]SweepTargetRequest `json:"targets" validate:"dive"`
+}
+
+// AccountClosureQuery used to parse account closure progress request
+type AccountClosureQuery struct {
+	UserPhone string `form:"user_phone" validate:"required,phone"`
+}
+
+// ClosureSweepView represents final withdrawal of wallet funds
+type ClosureSweepView struct {
+	WalletID  string             `json:"wallet_id"`
+	Address   string             `json:"address"`
+	Amount    *decimal.View      `json:"amount"`
+	TxID      string             `json:"tx_id,omitempty"`
+	TxStatus  string             `json:"tx_status,omitempty"`
+	CreatedAt types.UnixTimeView `json:"created_at"`
+}
+
+// AccountClosureView represents account closure progress
+type AccountClosureView struct {
+	ID             int64              `json:"id"`
+	UserPhone      string             `json:"user_phone"`
+	Status         string             `json:"status"`
+	CanceledTxsNum int                `json:"canceled_txs_num"`
+	Sweeps         []ClosureSweepView `json:"sweeps"`
+	CreatedAt      types.UnixTimeView `json:"created_at"`
+	UpdatedAt      types.UnixTimeView `json:"updated_at"`
+}
+
+// ToAccountClosureView
+func ToAccountClosureView(closure *wallets.AccountClosure) AccountClosureView {
+	sweeps := make([]ClosureSweepView, 0, len(closure.Sweeps))
+	for _, s := range closure.Sweeps {
+		view := ClosureSweepView{
+			WalletID:  strconv.FormatInt(s.WalletID, 10),
+			Address:   s.Address,
+			Amount:    (*decimal.View)(s.Amount.V),
+			TxStatus:  s.TxStatus,
+			CreatedAt: types.UnixTimeView(s.CreatedAt),
+		}
+		if s.TxID != nil {
+			view.TxID = strconv.FormatInt(*s.TxID, 10)
+		}
+		sweeps = append(sweeps, view)
+	}
+	return AccountClosureView{
+		ID:             closure.ID,
+		UserPhone:      closure.UserPhone,
+		Status:         closure.Status,
+		CanceledTxsNum: closure.CanceledTxsNum,
+		Sweeps:         sweeps,
+		CreatedAt:      types.UnixTimeView(closure.CreatedAt),
+		UpdatedAt:      types.UnixTimeView(closure.UpdatedAt),
+	}
+}
+
+// ReservesProofRequest used to parse user inclusion proof request
+type ReservesProofRequest struct {
+	UserPhone string `form:"user_phone" validate:"required,phone"`
+}
+
+// ProofStepView represents sibling node on the path from user leaf to the root
+type ProofStepView struct {
+	Hash string        `json:"hash"`
+	Sum  *decimal.View `json:"sum"`
+	Left bool          `json:"left"`
+}
+
+// CoinProofView represents user inclusion proof into coin liabilities tree
+type CoinProofView struct {
+	Coin        string          `json:"coin"`
+	RootHash    string          `json:"root_hash"`
+	Liabilities *decimal.View   `json:"liabilities"`
+	Nonce       string          `json:"nonce"`
+	UserKey     string          `json:"user_key"`
+	Balance     *decimal.View   `json:"balance"`
+	Path        []ProofStepView `json:"path"`
+}
+
+// ReservesProofResponseView represents user inclusion proofs of the latest proof-of-reserves report
+type ReservesProofResponseView struct {
+	ReportID  int64              `json:"report_id"`
+	CreatedAt types.UnixTimeView `json:"created_at"`
+	Proofs    []CoinProofView    `json:"proofs"`
+}
+
+// ToReservesProofView
+func ToReservesProofView(report *reserves.Report, proofs []reserves.UserProof) ReservesProofResponseView {
+	views := make([]CoinProofView, 0, len(proofs))
+	for _, p := range proofs {
+		path := make([]ProofStepView, 0, len(p.Proof))
+		for _, step := range p.Proof {
+			path = append(path, ProofStepView{Hash: step.Hash, Sum: (*decimal.View)(step.Sum), Left: step.Left})
+		}
+		views = append(views, CoinProofView{
+			Coin:        p.Coin,
+			RootHash:    p.RootHash,
+			Liabilities: (*decimal.View)(p.Liabilities.V),
+			Nonce:       p.Nonce,
+			UserKey:     p.UserKey,
+			Balance:     (*decimal.View)(p.Balance.V),
+			Path:        path,
+		})
+	}
+	return ReservesProofResponseView{
+		ReportID:  report.ID,
+		CreatedAt: types.UnixTimeView(report.CreatedAt),
+		Proofs:    views,
+	}
+}
+
+// ConverterStatsView represents rates cache usage statistics
+type ConverterStatsView struct {
+	Hits      uint64  `json:"hits"`
+	StaleHits uint64  `json:"stale_hits"`
+	Misses    uint64  `json:"misses"`
+	Errors    uint64  `json:"errors"`
+	HitRatio  float64 `json:"hit_ratio"`
+}
+
+// ToConverterStatsView
+func ToConverterStatsView(stats cache.Stats) ConverterStatsView {
+	return ConverterStatsView{
+		Hits:      stats.Hits,
+		StaleHits: stats.StaleHits,
+		Misses:    stats.Misses,
+		Errors:    stats.Errors,
+		HitRatio:  stats.HitRatio(),
+	}
 }
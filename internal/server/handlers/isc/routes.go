@@ -2,6 +2,8 @@ package isc
 
 import (
 	"git.zam.io/wallet-backend/wallet-api/config/server"
+	"git.zam.io/wallet-backend/wallet-api/internal/reserves"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
 	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
 	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
@@ -16,10 +18,12 @@ import (
 type Dependencies struct {
 	dig.In
 
-	Routes     gin.IRouter `name:"internal_api_routes"`
-	Config     server.Scheme
-	WalletsApi *wallets.Api
-	Converter  convert.ICryptoCurrency
+	Routes       gin.IRouter `name:"internal_api_routes"`
+	Config       server.Scheme
+	WalletsApi   *wallets.Api
+	Converter    convert.ICryptoCurrency
+	Reserves     reserves.IReserves
+	UserSettings settings.ISettings
 }
 
 // Register
@@ -28,7 +32,37 @@ func Register(dependencies Dependencies) error {
 		"/user_stat",
 		trace.StartSpanMiddleware(),
 		base.WrapMiddleware(TokenAuthMiddlewareFactory(dependencies.Config.InternalAccessToken)),
-		base.WrapHandler(UserStatFactory(dependencies.WalletsApi, dependencies.Converter)),
+		base.WrapHandler(UserStatFactory(dependencies.WalletsApi, dependencies.Converter, dependencies.UserSettings)),
+	)
+	dependencies.Routes.GET(
+		"/reserves/proof",
+		trace.StartSpanMiddleware(),
+		base.WrapMiddleware(TokenAuthMiddlewareFactory(dependencies.Config.InternalAccessToken)),
+		base.WrapHandler(ReservesProofFactory(dependencies.Reserves)),
+	)
+	dependencies.Routes.GET(
+		"/converter/stats",
+		trace.StartSpanMiddleware(),
+		base.WrapMiddleware(TokenAuthMiddlewareFactory(dependencies.Config.InternalAccessToken)),
+		base.WrapHandler(ConverterStatsFactory(dependencies.Converter)),
+	)
+	dependencies.Routes.POST(
+		"/users/phone_change",
+		trace.StartSpanMiddleware(),
+		base.WrapMiddleware(TokenAuthMiddlewareFactory(dependencies.Config.InternalAccessToken)),
+		base.WrapHandler(PhoneChangeFactory(dependencies.WalletsApi)),
+	)
+	dependencies.Routes.POST(
+		"/users/closure",
+		trace.StartSpanMiddleware(),
+		base.WrapMiddleware(TokenAuthMiddlewareFactory(dependencies.Config.InternalAccessToken)),
+		base.WrapHandler(CloseAccountFactory(dependencies.WalletsApi)),
+	)
+	dependencies.Routes.GET(
+		"/users/closure",
+		trace.StartSpanMiddleware(),
+		base.WrapMiddleware(TokenAuthMiddlewareFactory(dependencies.Config.InternalAccessToken)),
+		base.WrapHandler(GetAccountClosureFactory(dependencies.WalletsApi)),
 	)
 	return nil
 }
@@ -0,0 +1,2 @@
+// Package quotes holds all /quotes/* endpoints
+package quotes
@@ -0,0 +1,125 @@
+package quotes
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/internal/quotes"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	bdecimal "github.com/ericlagergren/decimal"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var (
+	// create errors
+	errInvalidCoin         = base.NewFieldErr("body", "coin", "invalid coin name")
+	errWrongAmount         = base.NewFieldErr("body", "fiat_amount", "must be greater then zero")
+	errInvalidFiatCurrency = base.NewFieldErr("body", "fiat_currency", "invalid fiat currency")
+
+	// quote path errors
+	errQuoteIDInvalid = base.NewFieldErr("path", "quote_id", "quote id is invalid")
+	errQuoteNotFound  = base.NewFieldErr("path", "quote_id", "no such quote")
+)
+
+// CreateFactory creates handler which quotes fiat amount in the coin accepting 'CreateRequest' like scheme, quote id
+// may be used to send tx instead of coin amount until quote expires. Returns 'SingleResponse' on success.
+func CreateFactory(fiatQuotes quotes.IQuotes) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := CreateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		span.LogKV(
+			"coin", params.Coin,
+			"fiat_amount", params.FiatAmount,
+			"fiat_currency", params.FiatCurrency,
+		)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		quote, err := fiatQuotes.Create(
+			ctx, userPhone, params.Coin, (*bdecimal.Big)(params.FiatAmount), params.FiatCurrency,
+		)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		code = 201
+		resp = SingleResponse{Quote: ToView(quote)}
+		return
+	}
+}
+
+// GetFactory creates handler which returns user quote specified by path param 'quote_id', returns 'SingleResponse'
+// on success.
+func GetFactory(fiatQuotes quotes.IQuotes) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse quote id path param
+		quoteID, quoteIDValid := FromIdView(c.Param("quote_id"))
+		if !quoteIDValid {
+			err = errQuoteIDInvalid
+			return
+		}
+		span.LogKV("quote_id", quoteID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		quote, err := fiatQuotes.Get(ctx, userPhone, quoteID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Quote: ToView(quote)}
+		return
+	}
+}
+
+func coerceErrs(err error) error {
+	if errors, ok := err.(merrors.Errors); ok {
+		for i, e := range errors {
+			errors[i] = coerceErr(e)
+		}
+		return errors
+	}
+	return coerceErr(err)
+}
+
+func coerceErr(e error) (newE error) {
+	switch errors.Cause(e) {
+	case errs.ErrNoSuchCoin, convert.ErrCryptoCurrencyName:
+		newE = errInvalidCoin
+	case errs.ErrNonPositiveAmount:
+		newE = errWrongAmount
+	case convert.ErrFiatCurrencyName:
+		newE = errInvalidFiatCurrency
+	case quotes.ErrNoSuchQuote:
+		newE = errQuoteNotFound
+	default:
+		newE = common.CoerceConvertErr(e)
+	}
+	return
+}
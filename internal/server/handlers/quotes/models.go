@@ -0,0 +1,71 @@
+package quotes
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/wallet-api/internal/quotes"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/txs"
+	"strconv"
+	"strings"
+)
+
+// CreateRequest used to parse create quote request body
+type CreateRequest struct {
+	Coin         string        `json:"coin" validate:"required"`
+	FiatAmount   *decimal.View `json:"fiat_amount" validate:"required"`
+	FiatCurrency string        `json:"fiat_currency" validate:"required"`
+}
+
+// View represents fiat quote, tx id is set once quote is used
+type View struct {
+	ID           string             `json:"id"`
+	Coin         string             `json:"coin"`
+	Amount       *decimal.View      `json:"amount"`
+	Rate         *decimal.View      `json:"rate"`
+	RateSources  int                `json:"rate_sources_num,omitempty"`
+	FiatAmount   *decimal.View      `json:"fiat_amount"`
+	FiatCurrency string             `json:"fiat_currency"`
+	Used         bool               `json:"used"`
+	TxID         string             `json:"tx_id,omitempty"`
+	ExpiresAt    types.UnixTimeView `json:"expires_at"`
+	CreatedAt    types.UnixTimeView `json:"created_at"`
+}
+
+// SingleResponse single quote response
+type SingleResponse struct {
+	Quote View `json:"quote"`
+}
+
+// ToIdView converts quote id to api representation
+func ToIdView(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// FromIdView converts id api representation into quote id and provides valid flag
+func FromIdView(idView string) (id int64, valid bool) {
+	id, parseIntErr := strconv.ParseInt(idView, 10, 64)
+	valid = parseIntErr == nil
+	return
+}
+
+// ToView renders quote
+func ToView(quote *quotes.Quote) View {
+	view := View{
+		ID:           ToIdView(quote.ID),
+		Amount:       (*decimal.View)(quote.Amount.V),
+		Rate:         (*decimal.View)(quote.Rate.V),
+		RateSources:  quote.RateSourcesNum,
+		FiatAmount:   (*decimal.View)(quote.FiatAmount.V),
+		FiatCurrency: strings.ToLower(quote.FiatCurrency),
+		Used:         quote.IsUsed(),
+		ExpiresAt:    types.UnixTimeView(quote.ExpiresAt),
+		CreatedAt:    types.UnixTimeView(quote.CreatedAt),
+	}
+	if quote.Coin != nil {
+		view.Coin = strings.ToLower(quote.Coin.ShortName)
+	}
+	if quote.TxID != nil {
+		view.TxID = txs.ToIdView(*quote.TxID)
+	}
+	return view
+}
@@ -0,0 +1,40 @@
+package quotes
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/quotes"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+)
+
+// Dependencies
+type Dependencies struct {
+	dig.In
+
+	Routes         gin.IRouter     `name:"api_routes"`
+	AuthMiddleware gin.HandlerFunc `name:"auth_middleware"`
+	UserMiddleware gin.HandlerFunc `name:"user_middleware"`
+
+	Quotes quotes.IQuotes
+}
+
+// Register
+func Register(dependencies Dependencies) error {
+	group := dependencies.Routes.Group(
+		"/user/:user_phone/",
+		trace.StartSpanMiddleware(),
+		dependencies.AuthMiddleware,
+		dependencies.UserMiddleware,
+	)
+
+	group.POST(
+		"/quotes",
+		base.WrapHandler(CreateFactory(dependencies.Quotes)),
+	)
+	group.GET(
+		"/quotes/:quote_id",
+		base.WrapHandler(GetFactory(dependencies.Quotes)),
+	)
+	return nil
+}
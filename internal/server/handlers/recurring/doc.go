@@ -0,0 +1,2 @@
+// Package recurring holds all /recurring/* endpoints
+package recurring
@@ -0,0 +1,282 @@
+package recurring
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/recurring"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/txs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	bdecimal "github.com/ericlagergren/decimal"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var (
+	// create and update errors
+	errWrongAmount             = base.NewFieldErr("body", "amount", "must be greater then zero")
+	errNoSuchWallet            = base.NewFieldErr("body", "wallet_id", "no such wallet")
+	errWatchOnlyWallet         = base.NewFieldErr("body", "wallet_id", "watch-only wallet can't send funds")
+	errRecipientIsYou          = base.NewFieldErr("body", "recipient", "you can't send amount to your self")
+	errRecipientPhoneInvalid   = base.NewFieldErr("body", "recipient", "invalid recipient phone")
+	errRecipientAddressInvalid = base.NewFieldErr("body", "recipient", "invalid recipient address")
+	errInvalidPeriod           = base.NewFieldErr("body", "period", "must be one of daily, weekly or monthly")
+	errStartsAtInPast          = base.NewFieldErr("body", "starts_at", "start time must be in the future")
+	errInvalidEndsAt           = base.NewFieldErr("body", "ends_at", "end time must be after start and current time")
+	errNonPositiveMaxCount     = base.NewFieldErr("body", "max_count", "must be greater then zero")
+	errAccountClosed           = base.ErrorView{Code: http.StatusForbidden, Message: "account is closed"}
+
+	// plan path errors
+	errPlanIDInvalid = base.NewFieldErr("path", "plan_id", "plan id is invalid")
+	errPlanNotFound  = base.NewFieldErr("path", "plan_id", "no such plan")
+	errPlanFinished  = base.ErrorView{Code: http.StatusConflict, Message: "plan is completed or canceled"}
+)
+
+// CreateFactory creates handler which creates recurring payment plan accepting 'CreateRequest' like scheme, recipient
+// is treated as address if it's valid address of the wallet coin, otherwise it must look like phone. Returns
+// 'SingleResponse' on success.
+func CreateFactory(walletApi *wallets.Api, recurringPayments recurring.IRecurring) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := CreateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		span.LogKV(
+			"wallet_id", params.WalletID,
+			"recipient", params.Recipient,
+			"amount", params.Amount,
+			"period", params.Period,
+		)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		recipientType := recurring.RecipientPhone
+		addrErr := walletApi.ValidateAddress(ctx, userPhone, params.WalletID, params.Recipient)
+		switch {
+		case addrErr == nil:
+			recipientType = recurring.RecipientAddress
+		case addrErr == errs.ErrInvalidAddress:
+			if !txs.IsPhoneNumber(params.Recipient) {
+				err = errRecipientAddressInvalid
+				return
+			}
+		default:
+			err = coerceErrs(addrErr)
+			return
+		}
+
+		planParams := recurring.PlanParams{
+			WalletID:      params.WalletID,
+			Recipient:     params.Recipient,
+			RecipientType: recipientType,
+			Amount:        (*bdecimal.Big)(params.Amount),
+			Period:        recurring.Period(params.Period),
+			EndsAt:        fromUnixTime(params.EndsAt),
+			MaxCount:      params.MaxCount,
+		}
+		if startsAt := fromUnixTime(params.StartsAt); startsAt != nil {
+			planParams.StartsAt = *startsAt
+		}
+
+		plan, err := recurringPayments.Create(ctx, userPhone, planParams)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		code = 201
+		resp = SingleResponse{Plan: ToView(plan)}
+		return
+	}
+}
+
+// GetFactory creates handler which returns user plan specified by path param 'plan_id' with it's latest payments,
+// returns 'SingleResponse' on success.
+func GetFactory(recurringPayments recurring.IRecurring) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse plan id path param
+		planID, planIDValid := FromIdView(c.Param("plan_id"))
+		if !planIDValid {
+			err = errPlanIDInvalid
+			return
+		}
+		span.LogKV("plan_id", planID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		plan, err := recurringPayments.Get(ctx, userPhone, planID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Plan: ToView(plan)}
+		return
+	}
+}
+
+// GetAllFactory creates handler which returns all user plans, returns 'MultipleResponse' on success.
+func GetAllFactory(recurringPayments recurring.IRecurring) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		plans, err := recurringPayments.GetAll(ctx, userPhone)
+		if err != nil {
+			return
+		}
+
+		resp = ToMultipleResponse(plans)
+		return
+	}
+}
+
+// UpdateFactory creates handler which changes amount, limits or pauses plan specified by path param 'plan_id'
+// accepting 'UpdateRequest' like scheme, returns 'SingleResponse' on success.
+func UpdateFactory(recurringPayments recurring.IRecurring) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse plan id path param
+		planID, planIDValid := FromIdView(c.Param("plan_id"))
+		if !planIDValid {
+			err = errPlanIDInvalid
+			return
+		}
+		span.LogKV("plan_id", planID)
+
+		// bind params
+		params := UpdateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		plan, err := recurringPayments.Update(ctx, userPhone, planID, recurring.PlanUpdate{
+			Amount:   (*bdecimal.Big)(params.Amount),
+			EndsAt:   fromUnixTime(params.EndsAt),
+			MaxCount: params.MaxCount,
+			Paused:   params.Paused,
+		})
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Plan: ToView(plan)}
+		return
+	}
+}
+
+// CancelFactory creates handler which cancels plan specified by path param 'plan_id', already made payments aren't
+// affected. Returns 'SingleResponse' on success.
+func CancelFactory(recurringPayments recurring.IRecurring) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse plan id path param
+		planID, planIDValid := FromIdView(c.Param("plan_id"))
+		if !planIDValid {
+			err = errPlanIDInvalid
+			return
+		}
+		span.LogKV("plan_id", planID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		plan, err := recurringPayments.Cancel(ctx, userPhone, planID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Plan: ToView(plan)}
+		return
+	}
+}
+
+func coerceErrs(err error) error {
+	if errors, ok := err.(merrors.Errors); ok {
+		for i, e := range errors {
+			errors[i] = coerceErr(e)
+		}
+		return errors
+	}
+	return coerceErr(err)
+}
+
+func coerceErr(e error) (newE error) {
+	switch e {
+	case errs.ErrNonPositiveAmount:
+		newE = errWrongAmount
+	case errs.ErrNoSuchWallet:
+		newE = errNoSuchWallet
+	case errs.ErrWatchOnlyWallet, processing.ErrWatchOnlyWallet:
+		newE = errWatchOnlyWallet
+	case errs.ErrSelfTxForbidden:
+		newE = errRecipientIsYou
+	case errs.ErrInvalidPhone:
+		newE = errRecipientPhoneInvalid
+	case errs.ErrInvalidAddress:
+		newE = errRecipientAddressInvalid
+	case errs.ErrAccountClosed:
+		newE = errAccountClosed
+	case recurring.ErrInvalidPeriod:
+		newE = errInvalidPeriod
+	case recurring.ErrStartsAtInPast:
+		newE = errStartsAtInPast
+	case recurring.ErrInvalidEndsAt:
+		newE = errInvalidEndsAt
+	case recurring.ErrNonPositiveMaxCount:
+		newE = errNonPositiveMaxCount
+	case recurring.ErrNoSuchPlan:
+		newE = errPlanNotFound
+	case recurring.ErrPlanFinished:
+		newE = errPlanFinished
+	default:
+		newE = e
+	}
+	return
+}
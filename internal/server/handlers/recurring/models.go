@@ -0,0 +1,144 @@
+package recurring
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/wallet-api/internal/recurring"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/txs"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/wallets"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateRequest used to parse create plan request body
+type CreateRequest struct {
+	WalletID  int64         `json:"wallet_id,string" validate:"required"`
+	Recipient string        `json:"recipient" validate:"required"`
+	Amount    *decimal.View `json:"amount" validate:"required"`
+	Period    string        `json:"period" validate:"required"`
+
+	// StartsAt is the first payment unix time, payment is made as soon as possible if omitted
+	StartsAt *int64 `json:"starts_at,omitempty"`
+
+	// EndsAt and MaxCount optionally limit plan by unix time of the last payment and payments count
+	EndsAt   *int64 `json:"ends_at,omitempty"`
+	MaxCount *int64 `json:"max_count,omitempty"`
+}
+
+// UpdateRequest used to parse update plan request body, only presented fields are updated
+type UpdateRequest struct {
+	Amount   *decimal.View `json:"amount"`
+	EndsAt   *int64        `json:"ends_at"`
+	MaxCount *int64        `json:"max_count"`
+	Paused   *bool         `json:"paused"`
+}
+
+// OccurrenceView represents single plan payment
+type OccurrenceView struct {
+	ScheduledAt types.UnixTimeView `json:"scheduled_at"`
+	TxID        string             `json:"tx_id,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// View represents recurring payment plan
+type View struct {
+	ID            string              `json:"id"`
+	WalletID      string              `json:"wallet_id"`
+	Coin          string              `json:"coin"`
+	Recipient     string              `json:"recipient"`
+	RecipientType string              `json:"recipient_type"`
+	Amount        *decimal.View       `json:"amount"`
+	Period        string              `json:"period"`
+	Status        string              `json:"status"`
+	StartsAt      types.UnixTimeView  `json:"starts_at"`
+	NextRunAt     *types.UnixTimeView `json:"next_run_at,omitempty"`
+	EndsAt        *types.UnixTimeView `json:"ends_at,omitempty"`
+	MaxCount      *int64              `json:"max_count,omitempty"`
+	RunsCount     int64               `json:"runs_count"`
+	CreatedAt     types.UnixTimeView  `json:"created_at"`
+	Occurrences   []OccurrenceView    `json:"occurrences,omitempty"`
+}
+
+// SingleResponse single plan response
+type SingleResponse struct {
+	Plan View `json:"plan"`
+}
+
+// MultipleResponse all user plans response
+type MultipleResponse struct {
+	Count int    `json:"count"`
+	Plans []View `json:"plans"`
+}
+
+// ToIdView converts plan id to api representation
+func ToIdView(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// FromIdView converts id api representation into plan id and provides valid flag
+func FromIdView(idView string) (id int64, valid bool) {
+	id, parseIntErr := strconv.ParseInt(idView, 10, 64)
+	valid = parseIntErr == nil
+	return
+}
+
+// ToView renders plan, next payment time is omitted for finished plans
+func ToView(plan *recurring.Plan) View {
+	view := View{
+		ID:            ToIdView(plan.ID),
+		WalletID:      wallets.GetWalletIDView(plan.WalletID),
+		Recipient:     plan.Recipient,
+		RecipientType: string(plan.RecipientType),
+		Amount:        (*decimal.View)(plan.Amount.V),
+		Period:        string(plan.Period),
+		Status:        plan.Status,
+		StartsAt:      types.UnixTimeView(plan.StartsAt),
+		EndsAt:        toTimeView(plan.EndsAt),
+		MaxCount:      plan.MaxCount,
+		RunsCount:     plan.RunsCount,
+		CreatedAt:     types.UnixTimeView(plan.CreatedAt),
+	}
+	if plan.Wallet != nil {
+		view.Coin = strings.ToLower(plan.Wallet.Coin.ShortName)
+	}
+	if !plan.IsFinished() {
+		view.NextRunAt = toTimeView(&plan.NextRunAt)
+	}
+	for _, o := range plan.Occurrences {
+		oView := OccurrenceView{ScheduledAt: types.UnixTimeView(o.ScheduledAt)}
+		if o.TxID != nil {
+			oView.TxID = txs.ToIdView(*o.TxID)
+		}
+		if o.Error != nil {
+			oView.Error = *o.Error
+		}
+		view.Occurrences = append(view.Occurrences, oView)
+	}
+	return view
+}
+
+// ToMultipleResponse renders all user plans
+func ToMultipleResponse(plans []recurring.Plan) MultipleResponse {
+	views := make([]View, 0, len(plans))
+	for i := range plans {
+		views = append(views, ToView(&plans[i]))
+	}
+	return MultipleResponse{Count: len(views), Plans: views}
+}
+
+func toTimeView(t *time.Time) *types.UnixTimeView {
+	if t == nil {
+		return nil
+	}
+	view := types.UnixTimeView(*t)
+	return &view
+}
+
+func fromUnixTime(unixTime *int64) *time.Time {
+	if unixTime == nil {
+		return nil
+	}
+	t := time.Unix(*unixTime, 0).UTC()
+	return &t
+}
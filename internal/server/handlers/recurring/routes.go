@@ -0,0 +1,54 @@
+package recurring
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/recurring"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+)
+
+// Dependencies
+type Dependencies struct {
+	dig.In
+
+	Routes         gin.IRouter     `name:"api_routes"`
+	AuthMiddleware gin.HandlerFunc `name:"auth_middleware"`
+	UserMiddleware gin.HandlerFunc `name:"user_middleware"`
+
+	WalletsApi *wallets.Api
+	Recurring  recurring.IRecurring
+}
+
+// Register
+func Register(dependencies Dependencies) error {
+	group := dependencies.Routes.Group(
+		"/user/:user_phone/",
+		trace.StartSpanMiddleware(),
+		dependencies.AuthMiddleware,
+		dependencies.UserMiddleware,
+	)
+
+	group.POST(
+		"/recurring",
+		base.WrapHandler(CreateFactory(dependencies.WalletsApi, dependencies.Recurring)),
+	)
+	group.GET(
+		"/recurring",
+		base.WrapHandler(GetAllFactory(dependencies.Recurring)),
+	)
+	group.GET(
+		"/recurring/:plan_id",
+		base.WrapHandler(GetFactory(dependencies.Recurring)),
+	)
+	group.PATCH(
+		"/recurring/:plan_id",
+		base.WrapHandler(UpdateFactory(dependencies.Recurring)),
+	)
+	group.DELETE(
+		"/recurring/:plan_id",
+		base.WrapHandler(CancelFactory(dependencies.Recurring)),
+	)
+	return nil
+}
@@ -0,0 +1,2 @@
+// Package requests holds all /requests/* endpoints
+package requests
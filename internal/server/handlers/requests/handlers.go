@@ -0,0 +1,299 @@
+package requests
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/requests"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	bdecimal "github.com/ericlagergren/decimal"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+var (
+	// create errors
+	errPayerPhoneInvalid   = base.NewFieldErr("body", "payer", "invalid payer phone")
+	errPayerIsYou          = base.NewFieldErr("body", "payer", "you can't request money from your self")
+	errPayerAccountClosed  = base.NewFieldErr("body", "payer", "payer account is closed")
+	errInvalidCoin         = base.NewFieldErr("body", "coin", "invalid coin name")
+	errInvalidAmount       = base.NewFieldErr("body", "amount", "either amount or fiat amount with currency required")
+	errWrongAmount         = base.NewFieldErr("body", "amount", "must be greater then zero")
+	errInvalidFiatCurrency = base.NewFieldErr("body", "fiat_currency", "invalid fiat currency")
+	errExpiresAtInPast     = base.NewFieldErr("body", "expires_at", "expiration time must be in the future")
+	errCommentTooLong      = base.NewFieldErr("body", "comment", "comment must be up to 255 characters")
+	errAccountClosed       = base.ErrorView{Code: http.StatusForbidden, Message: "account is closed"}
+
+	// accept errors
+	errNoSuchWallet       = base.NewFieldErr("body", "wallet_id", "no such wallet")
+	errWatchOnlyWallet    = base.NewFieldErr("body", "wallet_id", "watch-only wallet can't send funds")
+	errWalletCoinMismatch = base.NewFieldErr("body", "wallet_id", "wallet coin differs from request coin")
+	errInsufficientFunds  = base.ErrorView{Code: http.StatusBadRequest, Message: "insufficient funds"}
+
+	// list errors
+	errInvalidDirection = base.NewFieldErr("query", "direction", "must be one of incoming or outgoing")
+
+	// request path errors
+	errRequestIDInvalid  = base.NewFieldErr("path", "request_id", "request id is invalid")
+	errRequestNotFound   = base.NewFieldErr("path", "request_id", "no such request")
+	errRequestNotPending = base.ErrorView{Code: http.StatusConflict, Message: "request is already finished"}
+	errRequestExpired    = base.ErrorView{Code: http.StatusConflict, Message: "request is expired"}
+)
+
+// CreateFactory creates handler which asks another user for money accepting 'CreateRequest' like scheme, returns
+// 'SingleResponse' on success.
+func CreateFactory(paymentRequests requests.IRequests) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := CreateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		span.LogKV(
+			"payer", params.Payer,
+			"coin", params.Coin,
+			"amount", params.Amount,
+			"fiat_amount", params.FiatAmount,
+			"fiat_currency", params.FiatCurrency,
+		)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		request, err := paymentRequests.Create(ctx, userPhone, requests.RequestParams{
+			PayerPhone:   params.Payer,
+			Coin:         params.Coin,
+			Amount:       (*bdecimal.Big)(params.Amount),
+			FiatAmount:   (*bdecimal.Big)(params.FiatAmount),
+			FiatCurrency: params.FiatCurrency,
+			Comment:      params.Comment,
+			ExpiresAt:    fromUnixTime(params.ExpiresAt),
+		})
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		code = 201
+		resp = SingleResponse{Request: ToView(request, userPhone)}
+		return
+	}
+}
+
+// GetFactory creates handler which returns user request specified by path param 'request_id', user may be either
+// requester or payer. Returns 'SingleResponse' on success.
+func GetFactory(paymentRequests requests.IRequests) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse request id path param
+		requestID, requestIDValid := FromIdView(c.Param("request_id"))
+		if !requestIDValid {
+			err = errRequestIDInvalid
+			return
+		}
+		span.LogKV("request_id", requestID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		request, err := paymentRequests.Get(ctx, userPhone, requestID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Request: ToView(request, userPhone)}
+		return
+	}
+}
+
+// GetAllFactory creates handler which returns user requests, query params 'direction' (incoming or outgoing) and
+// 'status' optionally filter them. Returns 'MultipleResponse' on success.
+func GetAllFactory(paymentRequests requests.IRequests) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// bind query params ignore error
+		params := GetAllRequest{}
+		c.ShouldBindQuery(&params)
+		span.LogKV("direction", params.Direction, "status", params.Status)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		list, err := paymentRequests.GetAll(ctx, userPhone, requests.Direction(params.Direction), params.Status)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = ToMultipleResponse(list, userPhone)
+		return
+	}
+}
+
+// AcceptFactory creates handler which pays incoming request specified by path param 'request_id' from the wallet
+// given by 'AcceptRequest' like scheme, returns 'SingleResponse' which holds paying tx id on success.
+func AcceptFactory(paymentRequests requests.IRequests) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse request id path param
+		requestID, requestIDValid := FromIdView(c.Param("request_id"))
+		if !requestIDValid {
+			err = errRequestIDInvalid
+			return
+		}
+		span.LogKV("request_id", requestID)
+
+		// bind params
+		params := AcceptRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+		span.LogKV("wallet_id", params.WalletID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		request, _, err := paymentRequests.Accept(ctx, userPhone, requestID, params.WalletID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Request: ToView(request, userPhone)}
+		return
+	}
+}
+
+// DeclineFactory creates handler which declines incoming request specified by path param 'request_id', returns
+// 'SingleResponse' on success.
+func DeclineFactory(paymentRequests requests.IRequests) base.HandlerFunc {
+	return finishFactory(paymentRequests.Decline)
+}
+
+// CancelFactory creates handler which cancels outgoing request specified by path param 'request_id', returns
+// 'SingleResponse' on success.
+func CancelFactory(paymentRequests requests.IRequests) base.HandlerFunc {
+	return finishFactory(paymentRequests.Cancel)
+}
+
+// finishFactory creates handler which finishes request specified by path param 'request_id' using given func
+func finishFactory(
+	finish func(ctx context.Context, userPhone string, requestID int64) (*requests.Request, error),
+) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse request id path param
+		requestID, requestIDValid := FromIdView(c.Param("request_id"))
+		if !requestIDValid {
+			err = errRequestIDInvalid
+			return
+		}
+		span.LogKV("request_id", requestID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		request, err := finish(ctx, userPhone, requestID)
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = SingleResponse{Request: ToView(request, userPhone)}
+		return
+	}
+}
+
+func coerceErrs(err error) error {
+	if errors, ok := err.(merrors.Errors); ok {
+		for i, e := range errors {
+			errors[i] = coerceErr(e)
+		}
+		return errors
+	}
+	return coerceErr(err)
+}
+
+func coerceErr(e error) (newE error) {
+	switch errors.Cause(e) {
+	case errs.ErrInvalidPhone:
+		newE = errPayerPhoneInvalid
+	case requests.ErrSelfRequest, errs.ErrSelfTxForbidden:
+		newE = errPayerIsYou
+	case errs.ErrRecipientAccountClosed:
+		newE = errPayerAccountClosed
+	case errs.ErrNoSuchCoin:
+		newE = errInvalidCoin
+	case requests.ErrInvalidAmount:
+		newE = errInvalidAmount
+	case errs.ErrNonPositiveAmount:
+		newE = errWrongAmount
+	case convert.ErrFiatCurrencyName:
+		newE = errInvalidFiatCurrency
+	case requests.ErrExpiresAtInPast:
+		newE = errExpiresAtInPast
+	case requests.ErrCommentTooLong:
+		newE = errCommentTooLong
+	case errs.ErrAccountClosed:
+		newE = errAccountClosed
+	case errs.ErrNoSuchWallet:
+		newE = errNoSuchWallet
+	case errs.ErrWatchOnlyWallet, processing.ErrWatchOnlyWallet:
+		newE = errWatchOnlyWallet
+	case requests.ErrWalletCoinMismatch:
+		newE = errWalletCoinMismatch
+	case processing.ErrInsufficientFunds:
+		newE = errInsufficientFunds
+	case requests.ErrInvalidDirection:
+		newE = errInvalidDirection
+	case requests.ErrNoSuchRequest:
+		newE = errRequestNotFound
+	case requests.ErrRequestNotPending:
+		newE = errRequestNotPending
+	case requests.ErrRequestExpired:
+		newE = errRequestExpired
+	default:
+		newE = common.CoerceConvertErr(e)
+	}
+	return
+}
@@ -0,0 +1,137 @@
+package requests
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/common/pkg/types/decimal"
+	"git.zam.io/wallet-backend/wallet-api/internal/requests"
+	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/txs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateRequest used to parse create payment request body, either amount or fiat amount with fiat currency must be
+// given
+type CreateRequest struct {
+	Payer        string        `json:"payer" validate:"required"`
+	Coin         string        `json:"coin" validate:"required"`
+	Amount       *decimal.View `json:"amount,omitempty"`
+	FiatAmount   *decimal.View `json:"fiat_amount,omitempty"`
+	FiatCurrency string        `json:"fiat_currency,omitempty"`
+	Comment      string        `json:"comment,omitempty"`
+
+	// ExpiresAt is the unix time until which request may be accepted, default ttl is used if omitted
+	ExpiresAt *int64 `json:"expires_at,omitempty"`
+}
+
+// AcceptRequest used to parse accept payment request body
+type AcceptRequest struct {
+	WalletID int64 `json:"wallet_id,string" validate:"required"`
+}
+
+// GetAllRequest used to parse requests list query params
+type GetAllRequest struct {
+	Direction string `form:"direction"`
+	Status    string `form:"status"`
+}
+
+// View represents payment request, direction is relative to the user
+type View struct {
+	ID           string              `json:"id"`
+	Direction    string              `json:"direction"`
+	Requester    string              `json:"requester"`
+	Payer        string              `json:"payer"`
+	Coin         string              `json:"coin"`
+	Amount       *decimal.View       `json:"amount,omitempty"`
+	FiatAmount   *decimal.View       `json:"fiat_amount,omitempty"`
+	FiatCurrency string              `json:"fiat_currency,omitempty"`
+	Comment      string              `json:"comment,omitempty"`
+	Status       string              `json:"status"`
+	ExpiresAt    types.UnixTimeView  `json:"expires_at"`
+	TxID         string              `json:"tx_id,omitempty"`
+	CreatedAt    types.UnixTimeView  `json:"created_at"`
+	UpdatedAt    *types.UnixTimeView `json:"updated_at,omitempty"`
+}
+
+// SingleResponse single payment request response
+type SingleResponse struct {
+	Request View `json:"request"`
+}
+
+// MultipleResponse user payment requests response
+type MultipleResponse struct {
+	Count    int    `json:"count"`
+	Requests []View `json:"requests"`
+}
+
+// ToIdView converts request id to api representation
+func ToIdView(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// FromIdView converts id api representation into request id and provides valid flag
+func FromIdView(idView string) (id int64, valid bool) {
+	id, parseIntErr := strconv.ParseInt(idView, 10, 64)
+	valid = parseIntErr == nil
+	return
+}
+
+// ToView renders request for the user
+func ToView(request *requests.Request, userPhone string) View {
+	view := View{
+		ID:        ToIdView(request.ID),
+		Direction: string(requests.DirectionOutgoing),
+		Requester: request.RequesterPhone,
+		Payer:     request.PayerPhone,
+		Status:    request.Status,
+		ExpiresAt: types.UnixTimeView(request.ExpiresAt),
+		CreatedAt: types.UnixTimeView(request.CreatedAt),
+	}
+	if request.PayerPhone == userPhone {
+		view.Direction = string(requests.DirectionIncoming)
+	}
+	if request.Coin != nil {
+		view.Coin = strings.ToLower(request.Coin.ShortName)
+	}
+	if request.Amount != nil {
+		view.Amount = (*decimal.View)(request.Amount.V)
+	}
+	if request.FiatAmount != nil {
+		view.FiatAmount = (*decimal.View)(request.FiatAmount.V)
+		view.FiatCurrency = strings.ToLower(*request.FiatCurrency)
+	}
+	if request.Comment != nil {
+		view.Comment = *request.Comment
+	}
+	if request.TxID != nil {
+		view.TxID = txs.ToIdView(*request.TxID)
+	}
+	if !request.IsPending() {
+		view.UpdatedAt = toTimeView(&request.UpdatedAt)
+	}
+	return view
+}
+
+// ToMultipleResponse renders user requests
+func ToMultipleResponse(requestsList []requests.Request, userPhone string) MultipleResponse {
+	views := make([]View, 0, len(requestsList))
+	for i := range requestsList {
+		views = append(views, ToView(&requestsList[i], userPhone))
+	}
+	return MultipleResponse{Count: len(views), Requests: views}
+}
+
+func toTimeView(t *time.Time) *types.UnixTimeView {
+	if t == nil {
+		return nil
+	}
+	view := types.UnixTimeView(*t)
+	return &view
+}
+
+func fromUnixTime(unixTime *int64) time.Time {
+	if unixTime == nil {
+		return time.Time{}
+	}
+	return time.Unix(*unixTime, 0).UTC()
+}
@@ -0,0 +1,56 @@
+package requests
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/requests"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+)
+
+// Dependencies
+type Dependencies struct {
+	dig.In
+
+	Routes         gin.IRouter     `name:"api_routes"`
+	AuthMiddleware gin.HandlerFunc `name:"auth_middleware"`
+	UserMiddleware gin.HandlerFunc `name:"user_middleware"`
+
+	Requests requests.IRequests
+}
+
+// Register
+func Register(dependencies Dependencies) error {
+	group := dependencies.Routes.Group(
+		"/user/:user_phone/",
+		trace.StartSpanMiddleware(),
+		dependencies.AuthMiddleware,
+		dependencies.UserMiddleware,
+	)
+
+	group.POST(
+		"/requests",
+		base.WrapHandler(CreateFactory(dependencies.Requests)),
+	)
+	group.GET(
+		"/requests",
+		base.WrapHandler(GetAllFactory(dependencies.Requests)),
+	)
+	group.GET(
+		"/requests/:request_id",
+		base.WrapHandler(GetFactory(dependencies.Requests)),
+	)
+	group.POST(
+		"/requests/:request_id/accept",
+		base.WrapHandler(AcceptFactory(dependencies.Requests)),
+	)
+	group.POST(
+		"/requests/:request_id/decline",
+		base.WrapHandler(DeclineFactory(dependencies.Requests)),
+	)
+	group.POST(
+		"/requests/:request_id/cancel",
+		base.WrapHandler(CancelFactory(dependencies.Requests)),
+	)
+	return nil
+}
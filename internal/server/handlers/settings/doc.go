@@ -0,0 +1,2 @@
+// Package settings holds all /settings endpoints
+package settings
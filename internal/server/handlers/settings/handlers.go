@@ -0,0 +1,95 @@
+package settings
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/pkg/server/middlewares"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// update errors
+	errInvalidFiatCurrency = base.NewFieldErr("body", "fiat_currency", "invalid fiat currency")
+	errInvalidHiddenCoin   = base.NewFieldErr("body", "hidden_coins", "invalid coin name")
+)
+
+// GetFactory creates handler which returns user settings, default settings are returned if user hasn't changed them.
+// Returns 'Response' on success.
+func GetFactory(userSettings settings.ISettings) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		s, err := userSettings.Get(ctx, userPhone)
+		if err != nil {
+			return
+		}
+
+		resp = Response{Settings: ToView(s)}
+		return
+	}
+}
+
+// UpdateFactory creates handler which changes user settings accepting 'UpdateRequest' like scheme, omitted fields are
+// left unchanged. Returns 'Response' on success.
+func UpdateFactory(userSettings settings.ISettings) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := UpdateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		s, err := userSettings.Update(ctx, userPhone, params.ToUpdateParams())
+		if err != nil {
+			err = coerceErrs(err)
+			return
+		}
+
+		resp = Response{Settings: ToView(s)}
+		return
+	}
+}
+
+func coerceErrs(err error) error {
+	if errors, ok := err.(merrors.Errors); ok {
+		for i, e := range errors {
+			errors[i] = coerceErr(e)
+		}
+		return errors
+	}
+	return coerceErr(err)
+}
+
+func coerceErr(e error) (newE error) {
+	switch e {
+	case convert.ErrFiatCurrencyName:
+		newE = errInvalidFiatCurrency
+	case errs.ErrNoSuchCoin:
+		newE = errInvalidHiddenCoin
+	default:
+		newE = e
+	}
+	return
+}
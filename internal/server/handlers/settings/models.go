@@ -0,0 +1,76 @@
+package settings
+
+import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
+	"strings"
+)
+
+// NotificationsRequest used to parse notifications preferences, omitted preferences are left unchanged
+type NotificationsRequest struct {
+	Txs      *bool `json:"txs"`
+	Requests *bool `json:"requests"`
+	Invoices *bool `json:"invoices"`
+}
+
+// UpdateRequest used to parse settings update request body, omitted fields are left unchanged. Empty fiat currency
+// resets preferred currency, empty hidden coins list shows all coins.
+type UpdateRequest struct {
+	FiatCurrency  *string               `json:"fiat_currency"`
+	HiddenCoins   []string              `json:"hidden_coins"`
+	Notifications *NotificationsRequest `json:"notifications"`
+}
+
+// ToUpdateParams converts request into settings update params
+func (r UpdateRequest) ToUpdateParams() settings.UpdateParams {
+	params := settings.UpdateParams{FiatCurrency: r.FiatCurrency, HiddenCoins: r.HiddenCoins}
+	if r.Notifications != nil {
+		params.NotifyTxs = r.Notifications.Txs
+		params.NotifyRequests = r.Notifications.Requests
+		params.NotifyInvoices = r.Notifications.Invoices
+	}
+	return params
+}
+
+// NotificationsView represents kinds of events user wants to be notified about
+type NotificationsView struct {
+	Txs      bool `json:"txs"`
+	Requests bool `json:"requests"`
+	Invoices bool `json:"invoices"`
+}
+
+// View represents user settings, fiat currency is omitted if user has no preferred one, update time is omitted if
+// settings have never been changed
+type View struct {
+	FiatCurrency  string              `json:"fiat_currency,omitempty"`
+	HiddenCoins   []string            `json:"hidden_coins"`
+	Notifications NotificationsView   `json:"notifications"`
+	UpdatedAt     *types.UnixTimeView `json:"updated_at,omitempty"`
+}
+
+// Response represents get and update settings response
+type Response struct {
+	Settings View `json:"settings"`
+}
+
+// ToView renders user settings
+func ToView(s *settings.Settings) View {
+	hiddenCoins := make([]string, len(s.HiddenCoins))
+	for i, coinName := range s.HiddenCoins {
+		hiddenCoins[i] = strings.ToLower(coinName)
+	}
+	view := View{
+		FiatCurrency: s.PreferredFiat(),
+		HiddenCoins:  hiddenCoins,
+		Notifications: NotificationsView{
+			Txs:      s.NotifyTxs,
+			Requests: s.NotifyRequests,
+			Invoices: s.NotifyInvoices,
+		},
+	}
+	if !s.UpdatedAt.IsZero() {
+		updatedAt := types.UnixTimeView(s.UpdatedAt)
+		view.UpdatedAt = &updatedAt
+	}
+	return view
+}
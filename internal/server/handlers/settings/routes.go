@@ -0,0 +1,40 @@
+package settings
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/dig"
+)
+
+// Dependencies
+type Dependencies struct {
+	dig.In
+
+	Routes         gin.IRouter     `name:"api_routes"`
+	AuthMiddleware gin.HandlerFunc `name:"auth_middleware"`
+	UserMiddleware gin.HandlerFunc `name:"user_middleware"`
+
+	UserSettings settings.ISettings
+}
+
+// Register
+func Register(dependencies Dependencies) error {
+	group := dependencies.Routes.Group(
+		"/user/:user_phone/",
+		trace.StartSpanMiddleware(),
+		dependencies.AuthMiddleware,
+		dependencies.UserMiddleware,
+	)
+
+	group.GET(
+		"/settings",
+		base.WrapHandler(GetFactory(dependencies.UserSettings)),
+	)
+	group.PUT(
+		"/settings",
+		base.WrapHandler(UpdateFactory(dependencies.UserSettings)),
+	)
+	return nil
+}
@@ -10,52 +10,104 @@ import (
 	"strings"
 )
 
-// getRateForTx helper which queries tx coin rate for specified fiat currency
+// getRateForTx helper which queries tx coin rates for fiat currencies specified by convert param, rates captured for
+// the tx are used if any
 func getRateForTx(
 	ctx context.Context,
 	tx *processing.Tx,
-	dstFiatCurrency string,
+	convertParam string,
 	converter convert.ICryptoCurrency,
 ) (bRate common.AdditionalRate, err error) {
 	// perform convertation if this argument presented
-	if dstFiatCurrency == "" {
-		dstFiatCurrency = common.DefaultFiatCurrency
+	currencies := common.ParseConvertParam(convertParam)
+	bRates := common.NewAdditionalRates(currencies, nil)
+	bRate = bRates.ForCoinCurrency(tx.CoinName())
+	if captured := withCapturedRate(tx, bRate); capturedAll(captured) {
+		return captured, nil
 	}
-	bRate = common.AdditionalRate{FiatCurrency: dstFiatCurrency, CoinCurrency: tx.CoinName()}
 
+	// outdated rate is acceptable to represent tx values
+	ctx = convert.WithSourcesTracking(convert.WithStaleTracking(ctx))
 	err = trace.InsideSpanE(ctx, "converting_balance_to_fiat_currency", func(ctx context.Context, span ot.Span) error {
-		span.LogKV("convert_to", dstFiatCurrency)
+		span.LogKV("convert_to", strings.Join(currencies, ","))
 		span.LogKV("convert_from", tx.CoinName())
 
-		// query rate with fallback currency
-		rate, err := convert.GetRateDefaultFiat(
-			converter, ctx, tx.CoinName(), dstFiatCurrency, common.DefaultFiatCurrency,
+		// query rates with fallback currency
+		m, err := convert.GetRatesMatrixDefaultFiat(
+			converter, ctx, []string{tx.CoinName()}, currencies, common.DefaultFiatCurrency,
 		)
 		if err != nil {
 			return err
 		}
-		bRate.Rate = rate
+		bRates = common.NewAdditionalRates(currencies, m)
+		bRate = bRates.ForCoinCurrency(tx.CoinName())
 		return nil
 	})
+	bRate.Stale = convert.IsStale(ctx)
+	bRate.SourcesNum = convert.SourcesNum(ctx)
 	return
 }
 
-// getRatesForTxs helper which queries txs coins rates for specified fiat currency
+// withCapturedRate replaces rates with the ones captured for the tx, so tx fiat equivalents don't drift with the
+// market. Stale flag and sources number are reset only if rates of all currencies are captured.
+func withCapturedRate(tx *processing.Tx, rate common.AdditionalRate) common.AdditionalRate {
+	if rate.FiatCurrency == "" {
+		return rate
+	}
+	allCaptured := true
+	if captured := tx.FiatRate(rate.FiatCurrency); captured != nil {
+		rate.Rate = (*convert.Rate)(captured)
+	} else {
+		allCaptured = false
+	}
+	if len(rate.ExtraRates) > 0 {
+		extraRates := make(map[string]*convert.Rate, len(rate.ExtraRates))
+		for currency, extraRate := range rate.ExtraRates {
+			if captured := tx.FiatRate(currency); captured != nil {
+				extraRate = (*convert.Rate)(captured)
+			} else {
+				allCaptured = false
+			}
+			extraRates[currency] = extraRate
+		}
+		rate.ExtraRates = extraRates
+	}
+	if allCaptured {
+		rate.Stale = false
+		rate.SourcesNum = 0
+	}
+	return rate
+}
+
+// capturedAll checks whether rates to all currencies are known
+func capturedAll(rate common.AdditionalRate) bool {
+	if rate.Rate == nil {
+		return false
+	}
+	for _, extraRate := range rate.ExtraRates {
+		if extraRate == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// getRatesForTxs helper which queries txs coins rates for fiat currencies specified by convert param
 func getRatesForTxs(
 	ctx context.Context,
 	txs []processing.Tx,
-	dstFiatCurrency string,
+	convertParam string,
 	converter convert.ICryptoCurrency,
 	additionalCoinCurrency string,
 ) (bRates common.AdditionalRates, err error) {
-	// coerce fiat currency name
-	if dstFiatCurrency == "" {
-		dstFiatCurrency = common.DefaultFiatCurrency
-	}
+	// coerce fiat currencies names
+	currencies := common.ParseConvertParam(convertParam)
 
 	// perform convertation if this argument presented for all txs
-	bRates = common.AdditionalRates{FiatCurrency: dstFiatCurrency}
+	bRates = common.NewAdditionalRates(currencies, nil)
 	if len(txs) > 0 {
+		// outdated rates are acceptable to represent txs values
+		ctx = convert.WithSourcesTracking(convert.WithStaleTracking(ctx))
 		err = trace.InsideSpanE(
 			ctx, "converting_balances_to_fiat_currency",
 			func(ctx context.Context, span ot.Span) error {
@@ -73,17 +125,19 @@ func getRatesForTxs(
 					coinsList = append(coinsList, c)
 				}
 
-				span.LogKV("convert_to", dstFiatCurrency)
+				span.LogKV("convert_to", strings.Join(currencies, ","))
 				span.LogKV("convert_from", strings.Join(coinsList, ","))
 
 				// query rates with fallback currency
-				rates, err := convert.GetMultiRateDefaultFiat(
-					converter, ctx, coinsList, dstFiatCurrency, common.DefaultFiatCurrency,
+				m, err := convert.GetRatesMatrixDefaultFiat(
+					converter, ctx, coinsList, currencies, common.DefaultFiatCurrency,
 				)
 				if err != nil {
 					return err
 				}
-				bRates = common.AdditionalRates{MultiRate: rates, FiatCurrency: dstFiatCurrency}
+				bRates = common.NewAdditionalRates(currencies, m)
+				bRates.Stale = convert.IsStale(ctx)
+				bRates.SourcesNum = convert.SourcesNum(ctx)
 				return nil
 			},
 		)
@@ -4,8 +4,10 @@ import (
 	"context"
 	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/quotes"
 	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
 	walletshandlers "git.zam.io/wallet-backend/wallet-api/internal/server/handlers/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/txs"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
@@ -16,7 +18,9 @@ import (
 	"github.com/ericlagergren/decimal"
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -34,11 +38,33 @@ var (
 	errRecipientIsYou          = base.NewFieldErr("body", "recipient", "you can't send amount to your self")
 	errRecipientPhoneInvalid   = base.NewFieldErr("body", "recipient", "invalid recipient phone")
 	errRecipientAddressInvalid = base.NewFieldErr("body", "recipient", "invalid recipient address")
+	errNoSuchRecipientWallet   = base.NewFieldErr("body", "recipient_wallet_id", "no such recipient wallet")
+	errWatchOnlyWallet         = base.NewFieldErr("body", "wallet_id", "watch-only wallet can't send funds")
+	errRecipientAccountClosed  = base.NewFieldErr("body", "recipient", "recipient account is closed")
+	errExecuteAtInPast         = base.NewFieldErr("body", "execute_at", "execution time must be in the future")
+	errHoldFundsNotScheduled   = base.NewFieldErr("body", "hold_funds", "only scheduled tx may hold funds")
+	errAccountClosed           = base.ErrorView{Code: http.StatusForbidden, Message: "account is closed"}
+
+	// quoted sending errors
+	errAmountOrQuoteRequired = base.NewFieldErr("body", "amount", "either amount or quote id required")
+	errAmountWithQuote       = base.NewFieldErr("body", "amount", "amount can't be given along with quote id")
+	errQuoteNotFound         = base.NewFieldErr("body", "quote_id", "no such quote")
+	errQuoteCoinMismatch     = base.NewFieldErr("body", "wallet_id", "wallet coin differs from quote coin")
+	errQuoteExpired          = base.ErrorView{Code: http.StatusConflict, Message: "quote is expired"}
+	errQuoteUsed             = base.ErrorView{Code: http.StatusConflict, Message: "quote is already used"}
+	errQuoteRateMoved        = base.ErrorView{Code: http.StatusConflict, Message: "rate has moved, request new quote"}
+
+	// batch send errors
+	errTooManyBatchItems = base.NewFieldErr("body", "items", "too many items, maximum is "+strconv.Itoa(maxBatchItems))
+	errEmptyBatch        = base.NewFieldErr("body", "items", "at least one item required")
 
 	// get tx errors
 	errTxIdInvalid = base.NewFieldErr("path", "tx_id", "tx id is invalid")
 	errTxNotFound  = base.NewFieldErr("path", "tx_id", "no such tx")
 
+	// cancel tx errors
+	errTxNotScheduled = base.NewFieldErr("path", "tx_id", "only scheduled tx may be canceled")
+
 	// get all filters errors
 	errInvalidWalletID   = base.NewFieldErr("query", "wallet_id", "invalid wallet id")
 	errInvalidPage       = base.NewFieldErr("query", "page", "invalid page identifier")
@@ -47,8 +73,20 @@ var (
 	errInvalidStatusName = base.NewFieldErr("query", "status", "invalid tx status name")
 )
 
-// SendFactory
-func SendFactory(walletApi *wallets.Api, converter convert.ICryptoCurrency) base.HandlerFunc {
+var phoneNumberRegexp = regexp.MustCompile(`^\+?[0-9][0-9 ()\-]{5,20}$`)
+
+// maxBatchItems limits payouts count of the single batch
+const maxBatchItems = 100
+
+// SendFactory creates handler which sends funds accepting 'SendRequest' like scheme, either coin amount or id of the
+// fiat quote must be given. Quoted coin amount is sent if quote is still valid and rate hasn't moved beyond tolerance,
+// quoted fiat value is stored on the tx. Returns 'SingleResponse' on success.
+func SendFactory(
+	walletApi *wallets.Api,
+	fiatQuotes quotes.IQuotes,
+	converter convert.ICryptoCurrency,
+	userSettings settings.ISettings,
+) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		span, ctx := trace.GetSpanWithCtx(c)
 		defer span.Finish()
@@ -65,9 +103,33 @@ func SendFactory(walletApi *wallets.Api, converter convert.ICryptoCurrency) base
 		span.LogKV(
 			"wallet_id", params.WalletID,
 			"recipient", params.Recipient,
+			"recipient_wallet_id", params.RecipientWalletID,
 			"amount", params.Amount,
+			"quote_id", params.QuoteID,
+			"execute_at", params.ExecuteAt,
+			"hold_funds", params.HoldFunds,
 		)
 
+		switch {
+		case params.Amount == nil && params.QuoteID == 0:
+			err = errAmountOrQuoteRequired
+			return
+		case params.Amount != nil && params.QuoteID != 0:
+			err = errAmountWithQuote
+			return
+		}
+
+		var schedule *processing.Schedule
+		if params.ExecuteAt != nil {
+			schedule = &processing.Schedule{
+				ExecuteAt: time.Unix(*params.ExecuteAt, 0).UTC(),
+				HoldFunds: params.HoldFunds,
+			}
+		} else if params.HoldFunds {
+			err = errHoldFundsNotScheduled
+			return
+		}
+
 		// extract user phone
 		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
 		if err != nil {
@@ -75,40 +137,69 @@ func SendFactory(walletApi *wallets.Api, converter convert.ICryptoCurrency) base
 		}
 		span.LogKV("user_phone", userPhone)
 
+		// recipient is treated as address if it's valid address of the wallet coin, otherwise it must look like phone
+		isAddress := false
+		addrErr := walletApi.ValidateAddress(ctx, userPhone, params.WalletID, params.Recipient)
+		switch {
+		case addrErr == nil:
+			isAddress = true
+		case addrErr == errs.ErrInvalidAddress:
+			if !IsPhoneNumber(params.Recipient) {
+				err = errRecipientAddressInvalid
+				return
+			}
+		default:
+			err = coerceProcessingErrs(addrErr)
+			return
+		}
+
+		send := func(ctx context.Context, amount *decimal.Big) (tx *processing.Tx, err error) {
+			if isAddress {
+				err = trace.InsideSpanE(ctx, "send_to_address", func(ctx context.Context, span opentracing.Span) error {
+					var err error
+					tx, err = walletApi.SentToAddress(
+						ctx,
+						userPhone,
+						params.WalletID,
+						params.Recipient,
+						amount,
+						schedule,
+					)
+					return err
+				})
+			} else {
+				err = trace.InsideSpanE(ctx, "send_to_phone", func(ctx context.Context, span opentracing.Span) error {
+					var err error
+					// try send money
+					tx, err = walletApi.SendToPhone(
+						ctx,
+						userPhone,
+						params.WalletID,
+						params.Recipient,
+						params.RecipientWalletID,
+						amount,
+						schedule,
+					)
+					return err
+				})
+			}
+			return
+		}
+
 		var tx *processing.Tx
-		if isCryproAddress(params.Recipient) {
-			err = trace.InsideSpanE(ctx, "send_to_address", func(ctx context.Context, span opentracing.Span) error {
-				var err error
-				tx, err = walletApi.SentToAddress(
-					ctx,
-					userPhone,
-					params.WalletID,
-					params.Recipient,
-					(*decimal.Big)(params.Amount),
-				)
-				return err
-			})
+		if params.QuoteID != 0 {
+			_, tx, err = fiatQuotes.Send(ctx, userPhone, params.QuoteID, params.WalletID, send)
 		} else {
-			err = trace.InsideSpanE(ctx, "send_to_phone", func(ctx context.Context, span opentracing.Span) error {
-				var err error
-				// try send money
-				tx, err = walletApi.SendToPhone(
-					ctx,
-					userPhone,
-					params.WalletID,
-					params.Recipient,
-					(*decimal.Big)(params.Amount),
-				)
-				return err
-			})
+			tx, err = send(ctx, (*decimal.Big)(params.Amount))
 		}
 		if err != nil {
 			err = coerceProcessingErrs(err)
 			return
 		}
 
-		// query rates ignore error
-		rates, _ := getRateForTx(ctx, tx, queryParams.Convert, converter)
+		// query rates ignore error, user preferred fiat currency is used if convert param is omitted
+		convertParam := common.ConvertParamOrPreferred(ctx, userSettings, userPhone, queryParams.Convert)
+		rates, _ := getRateForTx(ctx, tx, convertParam, converter)
 
 		// render response converting db format into api format
 		resp = SingleResponse{Transaction: ToView(tx, userPhone, rates)}
@@ -116,8 +207,92 @@ func SendFactory(walletApi *wallets.Api, converter convert.ICryptoCurrency) base
 	}
 }
 
+// BatchSendFactory creates handler which sends funds from the wallet to many recipients at once accepting
+// 'BatchSendRequest' like scheme. Each item recipient is treated as phone if it looks like phone, otherwise as the
+// address of the wallet coin. Items with invalid recipient or amount are rejected individually, other items are sent
+// as single batch. Returns 'BatchResponse' with per-item results in the request order.
+func BatchSendFactory(
+	walletApi *wallets.Api, converter convert.ICryptoCurrency, userSettings settings.ISettings,
+) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		params := BatchSendRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+		// bind query params ignore error
+		queryParams := ConvertParams{}
+		c.ShouldBindQuery(&queryParams)
+
+		span.LogKV("wallet_id", params.WalletID, "items_num", len(params.Items))
+
+		switch {
+		case len(params.Items) == 0:
+			err = errEmptyBatch
+			return
+		case len(params.Items) > maxBatchItems:
+			err = errTooManyBatchItems
+			return
+		}
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		payouts := make([]wallets.BatchPayout, len(params.Items))
+		for i, item := range params.Items {
+			payouts[i].Amount = (*decimal.Big)(item.Amount)
+			if IsPhoneNumber(item.Recipient) {
+				payouts[i].ToPhone = item.Recipient
+			} else {
+				payouts[i].ToAddress = item.Recipient
+			}
+		}
+
+		batch, results, err := walletApi.SendBatch(ctx, userPhone, params.WalletID, payouts)
+		if err != nil {
+			err = coerceProcessingErrs(err)
+			return
+		}
+
+		// render response converting db format into api format
+		response := BatchResponse{Items: make([]BatchItemView, len(results))}
+		if batch != nil {
+			response.Batch = ToBatchView(batch)
+		}
+
+		var rates common.AdditionalRate
+		for i, r := range results {
+			view := BatchItemView{Recipient: params.Items[i].Recipient}
+			if r.Tx != nil {
+				// all txs are of the same coin, so query rates once ignoring error
+				if rates.CoinCurrency == "" {
+					convertParam := common.ConvertParamOrPreferred(ctx, userSettings, userPhone, queryParams.Convert)
+					rates, _ = getRateForTx(ctx, r.Tx, convertParam, converter)
+				}
+				view.Transaction = ToView(r.Tx, userPhone, rates)
+			}
+			if r.Err != nil {
+				view.Error = coerceProcessingErrs(r.Err).Error()
+			}
+			response.Items[i] = view
+		}
+
+		resp = response
+		return
+	}
+}
+
 // GetFactory creates get user tx by id handler, requires tx_id param in request path
-func GetFactory(txsApi txs.IApi, converter convert.ICryptoCurrency) base.HandlerFunc {
+func GetFactory(
+	txsApi txs.IApi, converter convert.ICryptoCurrency, userSettings settings.ISettings,
+) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		span, ctx := trace.GetSpanWithCtx(c)
 		defer span.Finish()
@@ -155,8 +330,9 @@ func GetFactory(txsApi txs.IApi, converter convert.ICryptoCurrency) base.Handler
 			return
 		}
 
-		// query rates ignore error
-		rates, _ := getRateForTx(ctx, tx, params.Convert, converter)
+		// query rates ignore error, user preferred fiat currency is used if convert param is omitted
+		convertParam := common.ConvertParamOrPreferred(ctx, userSettings, userPhone, params.Convert)
+		rates, _ := getRateForTx(ctx, tx, convertParam, converter)
 
 		// prepare response body
 		resp = SingleResponse{Transaction: ToView(tx, userPhone, rates)}
@@ -164,10 +340,59 @@ func GetFactory(txsApi txs.IApi, converter convert.ICryptoCurrency) base.Handler
 	}
 }
 
+// CancelFactory creates cancel user scheduled tx handler, requires tx_id param in request path
+func CancelFactory(
+	walletApi *wallets.Api, converter convert.ICryptoCurrency, userSettings settings.ISettings,
+) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// bind query params ignore error
+		params := ConvertParams{}
+		c.ShouldBindQuery(&params)
+
+		// parse tx id path param
+		txID, txIDValid := FromIdView(c.Param("tx_id"))
+		if !txIDValid {
+			err = errTxIdInvalid
+			return
+		}
+		span.LogKV("tx_id", txID)
+
+		// extract user phone
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		tx, err := walletApi.CancelScheduledTx(ctx, userPhone, txID)
+		if err != nil {
+			switch err {
+			case processing.ErrNoSuchTx:
+				err = errTxNotFound
+			case processing.ErrTxNotScheduled:
+				err = errTxNotScheduled
+			}
+			return
+		}
+
+		// query rates ignore error, user preferred fiat currency is used if convert param is omitted
+		convertParam := common.ConvertParamOrPreferred(ctx, userSettings, userPhone, params.Convert)
+		rates, _ := getRateForTx(ctx, tx, convertParam, converter)
+
+		resp = SingleResponse{Transaction: ToView(tx, userPhone, rates)}
+		return
+	}
+}
+
 const defaultTxCountValue = 20
 
 // GetAllFactory creates get all user txs request handler
-func GetAllFactory(txsApi txs.IApi, converter convert.ICryptoCurrency) base.HandlerFunc {
+func GetAllFactory(
+	txsApi txs.IApi, converter convert.ICryptoCurrency, userSettings settings.ISettings,
+) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		span, ctx := trace.GetSpanWithCtx(c)
 		defer span.Finish()
@@ -238,8 +463,9 @@ func GetAllFactory(txsApi txs.IApi, converter convert.ICryptoCurrency) base.Hand
 			return
 		}
 
-		// get rates ignore error
-		rates, _ := getRatesForTxs(ctx, allTxs, params.Convert, converter, common.DefaultCryptoCurrency)
+		// get rates ignore error, user preferred fiat currency is used if convert param is omitted
+		convertParam := common.ConvertParamOrPreferred(ctx, userSettings, userPhone, params.Convert)
+		rates, _ := getRatesForTxs(ctx, allTxs, convertParam, converter, common.DefaultCryptoCurrency)
 
 		var next *string
 		if hasNext && len(allTxs) > 0 {
@@ -280,7 +506,7 @@ func coerceProcessingErrs(err error) error {
 }
 
 func coerceProcessingErr(e error) (newE error) {
-	switch e {
+	switch errors.Cause(e) {
 	case errs.ErrNoSuchWallet:
 		newE = errNoSuchWallet
 	case processing.ErrInsufficientFunds:
@@ -293,29 +519,38 @@ func coerceProcessingErr(e error) (newE error) {
 		newE = errTxAmountToBig
 	case errs.ErrInvalidPhone:
 		newE = errRecipientPhoneInvalid
-	case processing.ErrInvalidAddress:
+	case processing.ErrInvalidAddress, errs.ErrInvalidAddress:
 		newE = errRecipientAddressInvalid
+	case errs.ErrNoSuchRecipientWallet:
+		newE = errNoSuchRecipientWallet
+	case processing.ErrWatchOnlyWallet, errs.ErrWatchOnlyWallet:
+		newE = errWatchOnlyWallet
+	case errs.ErrAccountClosed:
+		newE = errAccountClosed
+	case errs.ErrRecipientAccountClosed:
+		newE = errRecipientAccountClosed
+	case processing.ErrExecuteAtInPast:
+		newE = errExecuteAtInPast
+	case quotes.ErrNoSuchQuote:
+		newE = errQuoteNotFound
+	case quotes.ErrWalletCoinMismatch:
+		newE = errQuoteCoinMismatch
+	case quotes.ErrQuoteExpired:
+		newE = errQuoteExpired
+	case quotes.ErrQuoteUsed:
+		newE = errQuoteUsed
+	case quotes.ErrQuoteRateMoved:
+		newE = errQuoteRateMoved
 	default:
-		newE = e
+		newE = common.CoerceConvertErr(e)
 	}
 	return
 }
 
-// isBtcAddress tries to guess is that sting represents btc address, address format description taken from here
-// https://en.bitcoinwiki.org/wiki/Bitcoin_address
-// Bitcoin address is an identifier (account number), starting with 1 or 3 or bc1 and containing 27-34 alphanumeric
-// Latin characters (except 0, O, I)
-func isBtcAddress(candidate string) bool {
-	if len(candidate) >= 27 && len(candidate) <= 42 {
-		return true
-	}
-
-	return false
-}
-
-// isCryproAddress looks is candidate looks like crypto-address
-func isCryproAddress(candidate string) bool {
-	return isBtcAddress(candidate)
+// IsPhoneNumber checks is candidate looks like phone number: optional leading plus followed by digits which may be
+// separated by spaces, dashes or parenthesis. Actual phone validation is made by wallets api.
+func IsPhoneNumber(candidate string) bool {
+	return phoneNumberRegexp.MatchString(candidate)
 }
 
 // generates txs filters params for specified user
@@ -16,9 +16,56 @@ import (
 
 // SendRequest used to parse send tx request body
 type SendRequest struct {
-	WalletID  int64         `json:"wallet_id,string" validate:"required"`
-	Recipient string        `json:"recipient" validate:"required"`
-	Amount    *decimal.View `json:"amount" validate:"required"`
+	WalletID  int64  `json:"wallet_id,string" validate:"required"`
+	Recipient string `json:"recipient" validate:"required"`
+
+	// Amount is in wallet coin units, it may be omitted if QuoteID is given
+	Amount *decimal.View `json:"amount"`
+
+	// QuoteID specifies fiat quote which coin amount is sent instead of Amount
+	QuoteID int64 `json:"quote_id,string,omitempty"`
+
+	// RecipientWalletID optionally specifies recipient wallet when sending by phone, recipient default wallet of the
+	// same coin is used otherwise
+	RecipientWalletID int64 `json:"recipient_wallet_id,string,omitempty"`
+
+	// ExecuteAt optionally schedules tx execution at given unix time
+	ExecuteAt *int64 `json:"execute_at,omitempty"`
+
+	// HoldFunds reserves scheduled tx amount since scheduling
+	HoldFunds bool `json:"hold_funds,omitempty"`
+}
+
+// BatchSendRequest used to parse batch send request body
+type BatchSendRequest struct {
+	WalletID int64              `json:"wallet_id,string" validate:"required"`
+	Items    []BatchItemRequest `json:"items" validate:"required"`
+}
+
+// BatchItemRequest describes single batch payout, recipient is a phone number or an address of the wallet coin
+type BatchItemRequest struct {
+	Recipient string        `json:"recipient"`
+	Amount    *decimal.View `json:"amount"`
+}
+
+// BatchItemView represents single batch payout result, either transaction or error is set
+type BatchItemView struct {
+	Recipient   string `json:"recipient"`
+	Transaction *View  `json:"transaction,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BatchView represents batch of txs
+type BatchView struct {
+	ID          string        `json:"id"`
+	WalletID    string        `json:"wallet_id"`
+	TotalAmount *decimal.View `json:"total_amount"`
+}
+
+// BatchResponse batch send response, batch is omitted if all payouts are rejected
+type BatchResponse struct {
+	Batch *BatchView      `json:"batch,omitempty"`
+	Items []BatchItemView `json:"items"`
 }
 
 // ConvertParams used in send tx request to parse query params
@@ -55,6 +102,22 @@ type View struct {
 	Amount    common.MultiCurrencyBalance `json:"amount"`
 	Fee       common.MultiCurrencyBalance `json:"fee,omitempty"`
 	CreatedAt types.UnixTimeView          `json:"created_at"`
+	ExecuteAt *types.UnixTimeView         `json:"execute_at,omitempty"`
+
+	// QuotedFiat is the fiat value tx has been sent at, only txs sent using fiat quote have it
+	QuotedFiat *QuotedFiatView `json:"quoted_fiat,omitempty"`
+
+	// RatesStale is set if fiat values are calculated at outdated rate
+	RatesStale bool `json:"rates_stale,omitempty"`
+
+	// RatesSourcesNum is the number of rate sources which agree on the rate fiat values are calculated at
+	RatesSourcesNum int `json:"rates_sources_num,omitempty"`
+}
+
+// QuotedFiatView represents tx quoted fiat value
+type QuotedFiatView struct {
+	Amount   *decimal.View `json:"amount"`
+	Currency string        `json:"currency"`
 }
 
 // SingleResponse single tx response
@@ -100,6 +163,8 @@ func FromIdView(idView string) (id int64, valid bool) {
 
 // ToView
 func ToView(tx *processing.Tx, userPhone string, rate common.AdditionalRate) *View {
+	rate = withCapturedRate(tx, rate)
+
 	// wallet id must be shadowed if tx is incoming
 	var (
 		walletID  string
@@ -137,6 +202,20 @@ func ToView(tx *processing.Tx, userPhone string, rate common.AdditionalRate) *Vi
 		direction = "incoming"
 	}
 
+	var executeAt *types.UnixTimeView
+	if tx.ExecuteAt != nil {
+		t := types.UnixTimeView(*tx.ExecuteAt)
+		executeAt = &t
+	}
+
+	var quotedFiat *QuotedFiatView
+	if tx.FiatAmount != nil && tx.FiatCurrency != nil {
+		quotedFiat = &QuotedFiatView{
+			Amount:   (*decimal.View)(tx.FiatAmount.V),
+			Currency: strings.ToLower(*tx.FiatCurrency),
+		}
+	}
+
 	coinName := strings.ToLower(tx.CoinName())
 	rate.CoinCurrency = coinName
 	return &View{
@@ -151,6 +230,11 @@ func ToView(tx *processing.Tx, userPhone string, rate common.AdditionalRate) *Vi
 		Amount:    rate.RepresentBalance(tx.Amount.V),
 		Fee:       fee,
 		CreatedAt: types.UnixTimeView(tx.CreatedAt),
+		ExecuteAt: executeAt,
+
+		QuotedFiat:      quotedFiat,
+		RatesStale:      rate.Stale,
+		RatesSourcesNum: rate.SourcesNum,
 	}
 }
 
@@ -286,3 +370,12 @@ func ToAllView(txs []processing.Tx, userPhone string, rates common.AdditionalRat
 	}
 	return res
 }
+
+// ToBatchView renders batch
+func ToBatchView(batch *processing.TxBatch) *BatchView {
+	return &BatchView{
+		ID:          ToIdView(batch.ID),
+		WalletID:    wallets.GetWalletIDView(batch.WalletID),
+		TotalAmount: (*decimal.View)(batch.TotalAmount.V),
+	}
+}
@@ -1,6 +1,8 @@
 package txs
 
 import (
+	"git.zam.io/wallet-backend/wallet-api/internal/quotes"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/txs"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
 	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
@@ -18,9 +20,11 @@ type Dependencies struct {
 	AuthMiddleware gin.HandlerFunc `name:"auth_middleware"`
 	UserMiddleware gin.HandlerFunc `name:"user_middleware"`
 
-	WalletsApi *wallets.Api
-	TxsApi     txs.IApi
-	Converter  convert.ICryptoCurrency
+	WalletsApi   *wallets.Api
+	TxsApi       txs.IApi
+	Quotes       quotes.IQuotes
+	Converter    convert.ICryptoCurrency
+	UserSettings settings.ISettings
 }
 
 // Register
@@ -34,15 +38,25 @@ func Register(dependencies Dependencies) error {
 
 	group.POST(
 		"/txs",
-		base.WrapHandler(SendFactory(dependencies.WalletsApi, dependencies.Converter)),
+		base.WrapHandler(SendFactory(
+			dependencies.WalletsApi, dependencies.Quotes, dependencies.Converter, dependencies.UserSettings,
+		)),
+	)
+	group.POST(
+		"/batches",
+		base.WrapHandler(BatchSendFactory(dependencies.WalletsApi, dependencies.Converter, dependencies.UserSettings)),
+	)
+	group.POST(
+		"/txs/:tx_id/cancel",
+		base.WrapHandler(CancelFactory(dependencies.WalletsApi, dependencies.Converter, dependencies.UserSettings)),
 	)
 	group.GET(
 		"/txs/:tx_id",
-		base.WrapHandler(GetFactory(dependencies.TxsApi, dependencies.Converter)),
+		base.WrapHandler(GetFactory(dependencies.TxsApi, dependencies.Converter, dependencies.UserSettings)),
 	)
 	group.GET(
 		"/txs",
-		base.WrapHandler(GetAllFactory(dependencies.TxsApi, dependencies.Converter)),
+		base.WrapHandler(GetAllFactory(dependencies.TxsApi, dependencies.Converter, dependencies.UserSettings)),
 	)
 	return nil
 }
@@ -4,22 +4,42 @@ import (
 	"context"
 	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/pkg/qr"
 	"git.zam.io/wallet-backend/wallet-api/pkg/server/middlewares"
 	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
 	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
+	bdecimal "github.com/ericlagergren/decimal"
 	"github.com/gin-gonic/gin"
 	ot "github.com/opentracing/opentracing-go"
+	"net/http"
 	"strings"
 )
 
 var (
 	errWalletIDInvalid               = base.NewFieldErr("path", "wallet_id", "wallet id invalid")
 	errWalletIDNotFound              = base.NewFieldErr("path", "wallet_id", "wallet not found")
-	errWalletOfSuchCoinAlreadyExists = base.NewFieldErr("body", "coin", "wallet of such coin already exists")
+	errWalletOfSuchNameAlreadyExists = base.NewFieldErr("body", "wallet_name", "wallet of such coin and name already exists")
 	errCoinInvalid                   = base.NewFieldErr("body", "coin", "invalid coin name")
+	errWalletHasUnsettledTxs         = base.NewFieldErr("body", "archived", "wallet has held or pending txs")
+	errAddressRotationNotSupported   = base.NewFieldErr("path", "wallet_id", "wallet doesn't support address rotation")
+	errWatchAddressInvalid           = base.NewFieldErr("body", "address", "invalid address")
+	errWatchOnlyNotSupported         = base.NewFieldErr("body", "coin", "coin doesn't support watch-only wallets")
+	errWatchAddressCustodial         = base.NewFieldErr("body", "address", "address can't be watched")
+	errWalletWatchOnly               = base.NewFieldErr("path", "wallet_id", "watch-only wallet can't be default")
+	errAccountClosed                 = base.ErrorView{Code: http.StatusForbidden, Message: "account is closed"}
+
+	// payment uri errors
+	errPaymentAmountInvalid   = base.NewFieldErr("query", "amount", "must be decimal greater then zero")
+	errQRFormatInvalid        = base.NewFieldErr("query", "qr_format", "must be one of png or svg")
+	errQRSizeInvalid          = base.NewFieldErr("query", "qr_size", "must be between 64 and 1024")
+	errPaymentURINotSupported = base.NewFieldErr("path", "wallet_id", "wallet coin doesn't support payment uri")
+	errScannedURIInvalid      = base.NewFieldErr("body", "uri", "unknown or malformed payment uri")
+	errScannedAddressInvalid  = base.NewFieldErr("body", "uri", "invalid recipient address")
+	errScannedAmountInvalid   = base.NewFieldErr("body", "uri", "amount must be greater then zero")
 )
 
 // CreateFactory creates handler which used to create wallet, accepting 'CreateRequest' like scheme and returns
@@ -51,8 +71,14 @@ func CreateFactory(api *wallets.Api) base.HandlerFunc {
 		}
 		span.LogKV("user_phone", userPhone)
 
-		// create wallet
-		wallet, err := api.CreateWallet(ctx, userPhone, params.Coin, params.WalletName)
+		// create wallet, import watch-only one if external address is given
+		var wallet wallets.WalletWithBalance
+		if params.Address != "" {
+			span.LogKV("watch_address", params.Address)
+			wallet, err = api.ImportWatchOnlyWallet(ctx, userPhone, params.Coin, params.WalletName, params.Address)
+		} else {
+			wallet, err = api.CreateWallet(ctx, userPhone, params.Coin, params.WalletName)
+		}
 
 		if err != nil {
 			// coerce error
@@ -60,7 +86,15 @@ func CreateFactory(api *wallets.Api) base.HandlerFunc {
 			case errs.ErrNoSuchCoin:
 				err = errCoinInvalid
 			case errs.ErrWalletCreationRejected:
-				err = errWalletOfSuchCoinAlreadyExists
+				err = errWalletOfSuchNameAlreadyExists
+			case errs.ErrInvalidAddress:
+				err = errWatchAddressInvalid
+			case errs.ErrWatchOnlyNotSupported:
+				err = errWatchOnlyNotSupported
+			case errs.ErrCustodialAddress:
+				err = errWatchAddressCustodial
+			case errs.ErrAccountClosed:
+				err = errAccountClosed
 			}
 			return
 		}
@@ -74,8 +108,10 @@ func CreateFactory(api *wallets.Api) base.HandlerFunc {
 }
 
 // GetFactory creates handler which used to query wallet which is specified by path param 'wallet_id', returns
-// 'Response' on success.
-func GetFactory(api *wallets.Api, converter convert.ICryptoCurrency) base.HandlerFunc {
+// 'Response' on success. User preferred fiat currency is used if convert param is omitted.
+func GetFactory(
+	api *wallets.Api, converter convert.ICryptoCurrency, userSettings settings.ISettings,
+) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		span, ctx := trace.GetSpanWithCtx(c)
 		defer span.Finish()
@@ -100,7 +136,7 @@ func GetFactory(api *wallets.Api, converter convert.ICryptoCurrency) base.Handle
 		c.BindQuery(&params)
 
 		// perform request
-		wallet, err := api.GetWallet(ctx, userPhone, walletID)
+		wallet, err := api.GetWallet(ctx, userPhone, walletID, params.Fresh)
 		if err != nil {
 			if err == errs.ErrNoSuchWallet {
 				// invalid wallet id also set 404 error code
@@ -111,22 +147,30 @@ func GetFactory(api *wallets.Api, converter convert.ICryptoCurrency) base.Handle
 			return
 		}
 
-		// coerce convert param
-		if params.Convert == "" {
-			params.Convert = common.DefaultFiatCurrency
-		}
-		additionalRate := common.AdditionalRate{FiatCurrency: params.Convert}
+		// parse convert param, rates to multiple currencies may be requested
+		currencies := common.ParseConvertParam(
+			common.ConvertParamOrPreferred(ctx, userSettings, userPhone, params.Convert),
+		)
+		additionalRates := common.NewAdditionalRates(currencies, nil)
 
+		// outdated rate is acceptable to represent balance
+		ctx = convert.WithSourcesTracking(convert.WithStaleTracking(ctx))
 		trace.InsideSpanE(ctx, "converting_balance_to_fiat_currency", func(ctx context.Context, span ot.Span) error {
-			span.LogKV("convert_to", params.Convert)
+			span.LogKV("convert_to", strings.Join(currencies, ","))
 			span.LogKV("convert_from", wallet.Coin.ShortName)
 
-			var err error
-			additionalRate.Rate, err = convert.GetRateDefaultFiat(
-				converter, ctx, wallet.Coin.ShortName, params.Convert, common.DefaultFiatCurrency,
+			m, err := convert.GetRatesMatrixDefaultFiat(
+				converter, ctx, []string{wallet.Coin.ShortName}, currencies, common.DefaultFiatCurrency,
 			)
-			return err
+			if err != nil {
+				return err
+			}
+			additionalRates = common.NewAdditionalRates(currencies, m)
+			return nil
 		})
+		additionalRate := additionalRates.ForCoinCurrency(wallet.Coin.ShortName)
+		additionalRate.Stale = convert.IsStale(ctx)
+		additionalRate.SourcesNum = convert.SourcesNum(ctx)
 
 		// prepare response body
 		resp = ResponseFromWallet(wallet, additionalRate)
@@ -134,8 +178,289 @@ func GetFactory(api *wallets.Api, converter convert.ICryptoCurrency) base.Handle
 	}
 }
 
-// GetAllFactory
-func GetAllFactory(api *wallets.Api, converter convert.ICryptoCurrency) base.HandlerFunc {
+// SetDefaultFactory creates handler which makes wallet specified by path param 'wallet_id' default one for it's coin,
+// returns 'Response' on success.
+func SetDefaultFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse wallet id path param
+		walletID, walletIDValid := ParseWalletIDView(c.Param("wallet_id"))
+		if !walletIDValid {
+			err = errWalletIDInvalid
+			return
+		}
+		span.LogKV("wallet_id", walletID)
+
+		// extract user id
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		err = api.SetDefaultWallet(ctx, userPhone, walletID)
+		if err == nil {
+			var wallet wallets.WalletWithBalance
+			wallet, err = api.GetWallet(ctx, userPhone, walletID, false)
+			resp = ResponseFromWallet(wallet, common.AdditionalRate{})
+		}
+		switch err {
+		case errs.ErrNoSuchWallet:
+			err = errWalletIDNotFound
+		case errs.ErrWatchOnlyWallet:
+			err = errWalletWatchOnly
+		}
+		return
+	}
+}
+
+// UpdateFactory creates handler which renames wallet specified by path param 'wallet_id', changes it's display order
+// and archives or unarchives it accepting 'UpdateRequest' like scheme, returns 'Response' on success.
+func UpdateFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse wallet id path param
+		walletID, walletIDValid := ParseWalletIDView(c.Param("wallet_id"))
+		if !walletIDValid {
+			err = errWalletIDInvalid
+			return
+		}
+		span.LogKV("wallet_id", walletID)
+
+		// bind params
+		params := UpdateRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		// extract user id
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		wallet, err := api.UpdateWallet(
+			ctx, userPhone, walletID, params.WalletName, params.DisplayOrder, params.Archived,
+		)
+		if err != nil {
+			// coerce error
+			switch err {
+			case errs.ErrNoSuchWallet:
+				err = errWalletIDNotFound
+			case errs.ErrWalletUpdateRejected:
+				err = errWalletOfSuchNameAlreadyExists
+			case errs.ErrWalletHasUnsettledTxs:
+				err = errWalletHasUnsettledTxs
+			default:
+				trace.LogErrorWithMsg(span, err, "updating wallet error")
+			}
+			return
+		}
+
+		resp = ResponseFromWallet(wallet, common.AdditionalRate{})
+		return
+	}
+}
+
+// NewAddressFactory creates handler which derives fresh address for wallet specified by path param 'wallet_id',
+// previous addresses still credit deposits to the wallet, returns 'AddressResponse' on success.
+func NewAddressFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse wallet id path param
+		walletID, walletIDValid := ParseWalletIDView(c.Param("wallet_id"))
+		if !walletIDValid {
+			err = errWalletIDInvalid
+			return
+		}
+		span.LogKV("wallet_id", walletID)
+
+		// extract user id
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		address, err := api.RotateAddress(ctx, userPhone, walletID)
+		if err != nil {
+			// coerce error
+			switch err {
+			case errs.ErrNoSuchWallet:
+				err = errWalletIDNotFound
+			case errs.ErrAddressRotationNotSupported:
+				err = errAddressRotationNotSupported
+			case errs.ErrAccountClosed:
+				err = errAccountClosed
+			default:
+				trace.LogErrorWithMsg(span, err, "rotating wallet address error")
+			}
+			return
+		}
+
+		code = 201
+		resp = AddressResponse{Address: AddressViewFromAddress(address)}
+		return
+	}
+}
+
+// GetAddressesFactory creates handler which returns addresses history of wallet specified by path param 'wallet_id',
+// returns 'AddressesResponse' on success.
+func GetAddressesFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse wallet id path param
+		walletID, walletIDValid := ParseWalletIDView(c.Param("wallet_id"))
+		if !walletIDValid {
+			err = errWalletIDInvalid
+			return
+		}
+		span.LogKV("wallet_id", walletID)
+
+		// extract user id
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		addresses, err := api.GetAddresses(ctx, userPhone, walletID)
+		if err != nil {
+			if err == errs.ErrNoSuchWallet {
+				err = errWalletIDNotFound
+			}
+			return
+		}
+
+		resp = AddressesResponseFromAddresses(addresses)
+		return
+	}
+}
+
+// PaymentURIFactory creates handler which returns payment uri of the wallet specified by path param 'wallet_id' with
+// its QR code, query params 'amount' and 'label' are optionally encoded into uri, 'qr_format' (png or svg) and
+// 'qr_size' specifies QR code image. Returns 'PaymentURIResponse' on success.
+func PaymentURIFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// parse wallet id path param
+		walletID, walletIDValid := ParseWalletIDView(c.Param("wallet_id"))
+		if !walletIDValid {
+			err = errWalletIDInvalid
+			return
+		}
+		span.LogKV("wallet_id", walletID)
+
+		// bind query params ignore error, invalid params are checked below
+		params := PaymentURIRequest{QRFormat: qr.FormatPNG, QRSize: qr.DefaultSize}
+		c.ShouldBindQuery(&params)
+		span.LogKV("amount", params.Amount, "qr_format", params.QRFormat, "qr_size", params.QRSize)
+
+		var amount *bdecimal.Big
+		if params.Amount != "" {
+			var ok bool
+			amount, ok = new(bdecimal.Big).SetString(params.Amount)
+			if !ok || amount.IsNaN(0) || amount.IsInf(0) || amount.Sign() <= 0 {
+				err = errPaymentAmountInvalid
+				return
+			}
+		}
+
+		// extract user id
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		uri, err := api.PaymentURI(ctx, userPhone, walletID, amount, params.Label)
+		if err != nil {
+			switch err {
+			case errs.ErrNoSuchWallet:
+				err = errWalletIDNotFound
+			case errs.ErrNonPositiveAmount:
+				err = errPaymentAmountInvalid
+			case errs.ErrPaymentURINotSupported:
+				err = errPaymentURINotSupported
+			}
+			return
+		}
+
+		image, err := qr.Render(uri, params.QRFormat, params.QRSize)
+		if err != nil {
+			switch err {
+			case qr.ErrInvalidFormat:
+				err = errQRFormatInvalid
+			case qr.ErrInvalidSize:
+				err = errQRSizeInvalid
+			}
+			return
+		}
+
+		resp = PaymentURIResponse{
+			URI: uri,
+			QR:  QRView{Format: params.QRFormat, Size: params.QRSize, Image: image},
+		}
+		return
+	}
+}
+
+// ParsePaymentURIFactory creates handler which parses scanned payment uri accepting 'ParsePaymentURIRequest' like
+// scheme, returns 'ParsePaymentURIResponse' which holds prefilled send tx request on success.
+func ParsePaymentURIFactory(api *wallets.Api) base.HandlerFunc {
+	return func(c *gin.Context) (resp interface{}, code int, err error) {
+		span, ctx := trace.GetSpanWithCtx(c)
+		defer span.Finish()
+
+		// bind params
+		params := ParsePaymentURIRequest{}
+		err = base.ShouldBindJSON(c, &params)
+		if err != nil {
+			return
+		}
+
+		// extract user id
+		userPhone, err := middlewares.GetUserPhoneFromCtxE(c)
+		if err != nil {
+			return
+		}
+		span.LogKV("user_phone", userPhone)
+
+		payment, err := api.ParsePaymentURI(ctx, userPhone, params.URI)
+		if err != nil {
+			switch err {
+			case errs.ErrInvalidPaymentURI:
+				err = errScannedURIInvalid
+			case errs.ErrInvalidAddress:
+				err = errScannedAddressInvalid
+			case errs.ErrNonPositiveAmount:
+				err = errScannedAmountInvalid
+			}
+			return
+		}
+
+		resp = ParsePaymentURIResponseFromPayment(payment)
+		return
+	}
+}
+
+// GetAllFactory creates handler which returns user wallets, balances are represented in user preferred fiat currency
+// if convert param is omitted.
+func GetAllFactory(
+	api *wallets.Api, converter convert.ICryptoCurrency, userSettings settings.ISettings,
+) base.HandlerFunc {
 	return func(c *gin.Context) (resp interface{}, code int, err error) {
 		span, ctx := trace.GetSpanWithCtx(c)
 		defer span.Finish()
@@ -156,19 +481,27 @@ func GetAllFactory(api *wallets.Api, converter convert.ICryptoCurrency) base.Han
 		span.LogKV("user_phone", userPhone)
 
 		// query wallets
-		wts, totalCount, hasNext, err := api.GetWallets(ctx, userPhone, params.ByCoin, fromID, params.Count)
+		wts, totalCount, hasNext, err := api.GetWallets(ctx, userPhone, wallets.GetWalletsFilters{
+			ByCoin:        params.ByCoin,
+			FromID:        fromID,
+			Count:         params.Count,
+			WithArchived:  params.WithArchived,
+			WithWatchOnly: params.WithWatchOnly,
+		}, params.Fresh)
 		if err != nil {
 			trace.LogErrorWithMsg(span, err, "error getting wallets")
 			return
 		}
 
-		// coerce convert param
-		if params.Convert == "" {
-			params.Convert = common.DefaultFiatCurrency
-		}
+		// parse convert param, rates to multiple currencies may be requested
+		currencies := common.ParseConvertParam(
+			common.ConvertParamOrPreferred(ctx, userSettings, userPhone, params.Convert),
+		)
 		// perform convertation if this argument presented for all wallets
-		additionalRates := common.AdditionalRates{FiatCurrency: params.Convert}
+		additionalRates := common.NewAdditionalRates(currencies, nil)
 		if len(wts) > 0 {
+			// outdated rates are acceptable to represent balances
+			ctx = convert.WithSourcesTracking(convert.WithStaleTracking(ctx))
 			trace.InsideSpanE(ctx, "converting_balances_to_fiat_currency", func(ctx context.Context, span ot.Span) error {
 				nonZeroWts := filterNonZeroWallets(wts)
 				if len(nonZeroWts) == 0 {
@@ -179,15 +512,20 @@ func GetAllFactory(api *wallets.Api, converter convert.ICryptoCurrency) base.Han
 					coinsList[i] = w.Coin.ShortName
 				}
 
-				span.LogKV("convert_to", params.Convert)
+				span.LogKV("convert_to", strings.Join(currencies, ","))
 				span.LogKV("convert_from", strings.Join(coinsList, ","))
 
-				var err error
-				additionalRates.MultiRate, err = convert.GetMultiRateDefaultFiat(
-					converter, ctx, coinsList, params.Convert, common.DefaultFiatCurrency,
+				m, err := convert.GetRatesMatrixDefaultFiat(
+					converter, ctx, coinsList, currencies, common.DefaultFiatCurrency,
 				)
-				return err
+				if err != nil {
+					return err
+				}
+				additionalRates = common.NewAdditionalRates(currencies, m)
+				return nil
 			})
+			additionalRates.Stale = convert.IsStale(ctx)
+			additionalRates.SourcesNum = convert.SourcesNum(ctx)
 		}
 
 		// prepare response body
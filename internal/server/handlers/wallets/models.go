@@ -1,8 +1,11 @@
 package wallets
 
 import (
+	"git.zam.io/wallet-backend/common/pkg/types"
+	"git.zam.io/wallet-backend/common/pkg/types/decimal"
 	"git.zam.io/wallet-backend/wallet-api/internal/server/handlers/common"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
 	"strconv"
 	"strings"
 )
@@ -11,28 +14,54 @@ import (
 type CreateRequest struct {
 	Coin       string `json:"coin"`
 	WalletName string `json:"wallet_name" validate:"omitempty,min=3"`
+
+	// Address is external address to watch, watch-only wallet is imported if it's specified
+	Address string `json:"address"`
+}
+
+// UpdateRequest used to parse update wallet request body params, only presented fields are updated
+type UpdateRequest struct {
+	WalletName   *string `json:"wallet_name" validate:"omitempty,min=3"`
+	DisplayOrder *int64  `json:"display_order"`
+	Archived     *bool   `json:"archived"`
 }
 
 // GetRequest used to bind query params to get wallet by id request
 type GetRequest struct {
 	Convert string `form:"convert"`
+	Fresh   bool   `form:"fresh"`
 }
 
 // GetAllRequest used to parse get all wallets request filter parms bounded from query
 type GetAllRequest struct {
-	ByCoin  string `form:"coin"`
-	Cursor  string `form:"cursor"`
-	Count   int64  `form:"count"`
-	Convert string `form:"convert"`
+	ByCoin        string `form:"coin"`
+	Cursor        string `form:"cursor"`
+	Count         int64  `form:"count"`
+	Convert       string `form:"convert"`
+	Fresh         bool   `form:"fresh"`
+	WithArchived  bool   `form:"with_archived"`
+	WithWatchOnly bool   `form:"with_watch_only"`
 }
 
 // View used to represent wallet model
 type View struct {
-	ID       string                      `json:"id"`
-	Coin     string                      `json:"coin"`
-	Name     string                      `json:"wallet_name"`
-	Address  string                      `json:"address"`
-	Balances common.MultiCurrencyBalance `json:"balances"`
+	ID           string                      `json:"id"`
+	Coin         string                      `json:"coin"`
+	Name         string                      `json:"wallet_name"`
+	IsDefault    bool                        `json:"is_default"`
+	DisplayOrder int64                       `json:"display_order"`
+	Archived     bool                        `json:"archived"`
+	WatchOnly    bool                        `json:"watch_only"`
+	Closed       bool                        `json:"closed"`
+	Address      string                      `json:"address"`
+	Balances     common.MultiCurrencyBalance `json:"balances"`
+	BalanceAsOf  types.UnixTimeView          `json:"balance_as_of"`
+
+	// RatesStale is set if fiat balance is calculated at outdated rate
+	RatesStale bool `json:"rates_stale,omitempty"`
+
+	// RatesSourcesNum is the number of rate sources which agree on the rate fiat balance is calculated at
+	RatesSourcesNum int `json:"rates_sources_num,omitempty"`
 }
 
 // Response represents create and get wallets response
@@ -52,11 +81,19 @@ func ResponseFromWallet(wallet wallets.WalletWithBalance, additionalRate common.
 	additionalRate.CoinCurrency = wallet.Coin.ShortName
 	return Response{
 		Wallet: View{
-			ID:       GetWalletIDView(wallet.ID),
-			Coin:     strings.ToLower(wallet.Coin.ShortName),
-			Name:     wallet.Name,
-			Address:  wallet.Address,
-			Balances: additionalRate.RepresentBalance(wallet.Balance),
+			ID:              GetWalletIDView(wallet.ID),
+			Coin:            strings.ToLower(wallet.Coin.ShortName),
+			Name:            wallet.Name,
+			IsDefault:       wallet.IsDefault,
+			DisplayOrder:    wallet.DisplayOrder,
+			Archived:        wallet.Archived,
+			WatchOnly:       wallet.WatchOnly,
+			Closed:          wallet.Closed,
+			Address:         wallet.Address,
+			Balances:        additionalRate.RepresentBalance(wallet.Balance),
+			BalanceAsOf:     types.UnixTimeView(wallet.BalanceAsOf),
+			RatesStale:      additionalRate.Stale,
+			RatesSourcesNum: additionalRate.SourcesNum,
 		},
 	}
 }
@@ -84,6 +121,91 @@ func AllResponseFromWallets(
 	}
 }
 
+// AddressView used to represent wallet address
+type AddressView struct {
+	Address   string             `json:"address"`
+	CreatedAt types.UnixTimeView `json:"created_at"`
+}
+
+// AddressResponse represents new wallet address response
+type AddressResponse struct {
+	Address AddressView `json:"address"`
+}
+
+// AddressesResponse represents wallet addresses history response
+type AddressesResponse struct {
+	Addresses []AddressView `json:"addresses"`
+}
+
+// AddressViewFromAddress renders wallet address view
+func AddressViewFromAddress(address queries.WalletAddress) AddressView {
+	return AddressView{Address: address.Address, CreatedAt: types.UnixTimeView(address.CreatedAt)}
+}
+
+// AddressesResponseFromAddresses renders wallet addresses history
+func AddressesResponseFromAddresses(addresses []queries.WalletAddress) AddressesResponse {
+	views := make([]AddressView, 0, len(addresses))
+	for _, a := range addresses {
+		views = append(views, AddressViewFromAddress(a))
+	}
+	return AddressesResponse{Addresses: views}
+}
+
+// PaymentURIRequest used to bind wallet payment uri request query params
+type PaymentURIRequest struct {
+	Amount   string `form:"amount"`
+	Label    string `form:"label"`
+	QRFormat string `form:"qr_format"`
+	QRSize   int    `form:"qr_size"`
+}
+
+// QRView represents QR code image as data uri
+type QRView struct {
+	Format string `json:"format"`
+	Size   int    `json:"size"`
+	Image  string `json:"image"`
+}
+
+// PaymentURIResponse represents wallet payment uri response
+type PaymentURIResponse struct {
+	URI string `json:"uri"`
+	QR  QRView `json:"qr"`
+}
+
+// ParsePaymentURIRequest used to parse scanned payment uri request body
+type ParsePaymentURIRequest struct {
+	URI string `json:"uri" validate:"required"`
+}
+
+// ScannedPaymentView represents send tx request prefilled from scanned payment uri, wallet id is empty if user has no
+// wallet of the coin
+type ScannedPaymentView struct {
+	WalletID  string        `json:"wallet_id,omitempty"`
+	Coin      string        `json:"coin"`
+	Recipient string        `json:"recipient"`
+	Amount    *decimal.View `json:"amount,omitempty"`
+	Label     string        `json:"label,omitempty"`
+}
+
+// ParsePaymentURIResponse represents scanned payment uri parsing response
+type ParsePaymentURIResponse struct {
+	Send ScannedPaymentView `json:"send"`
+}
+
+// ParsePaymentURIResponseFromPayment renders scanned payment
+func ParsePaymentURIResponseFromPayment(payment wallets.ScannedPayment) ParsePaymentURIResponse {
+	view := ScannedPaymentView{
+		Coin:      strings.ToLower(payment.Coin),
+		Recipient: payment.Recipient,
+		Amount:    (*decimal.View)(payment.Amount),
+		Label:     payment.Label,
+	}
+	if payment.WalletID != 0 {
+		view.WalletID = GetWalletIDView(payment.WalletID)
+	}
+	return ParsePaymentURIResponse{Send: view}
+}
+
 // GetWalletIDView wallet id to view representation
 func GetWalletIDView(id int64) string {
 	return strconv.FormatInt(id, 10)
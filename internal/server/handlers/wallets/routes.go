@@ -1,6 +1,7 @@
 package wallets
 
 import (
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
 	"git.zam.io/wallet-backend/web-api/pkg/server/handlers/base"
 	"github.com/gin-gonic/gin"
@@ -20,6 +21,8 @@ type Dependencies struct {
 	Api *wallets.Api
 
 	Converter convert.ICryptoCurrency
+
+	UserSettings settings.ISettings
 }
 
 // Register
@@ -37,11 +40,35 @@ func Register(dependencies Dependencies) error {
 	)
 	group.GET(
 		"/wallets/:wallet_id",
-		base.WrapHandler(GetFactory(dependencies.Api, dependencies.Converter)),
+		base.WrapHandler(GetFactory(dependencies.Api, dependencies.Converter, dependencies.UserSettings)),
 	)
 	group.GET(
 		"/wallets",
-		base.WrapHandler(GetAllFactory(dependencies.Api, dependencies.Converter)),
+		base.WrapHandler(GetAllFactory(dependencies.Api, dependencies.Converter, dependencies.UserSettings)),
+	)
+	group.PATCH(
+		"/wallets/:wallet_id",
+		base.WrapHandler(UpdateFactory(dependencies.Api)),
+	)
+	group.POST(
+		"/wallets/:wallet_id/addresses",
+		base.WrapHandler(NewAddressFactory(dependencies.Api)),
+	)
+	group.GET(
+		"/wallets/:wallet_id/addresses",
+		base.WrapHandler(GetAddressesFactory(dependencies.Api)),
+	)
+	group.PUT(
+		"/wallets/:wallet_id/default",
+		base.WrapHandler(SetDefaultFactory(dependencies.Api)),
+	)
+	group.GET(
+		"/wallets/:wallet_id/payment_uri",
+		base.WrapHandler(PaymentURIFactory(dependencies.Api)),
+	)
+	group.POST(
+		"/payment_uri/parse",
+		base.WrapHandler(ParsePaymentURIFactory(dependencies.Api)),
 	)
 	return nil
 }
@@ -80,7 +80,7 @@ var _ = Describe("testings /wallets endpoints", func() {
 		)
 
 		BeforeEachCProvide(func(d *db.Db, coordinator nodes.ICoordinator) base.HandlerFunc {
-			return CreateFactory(wallets.NewApi(d, coordinator, nil, nil))
+			return CreateFactory(wallets.NewApi(d, coordinator, nil, nil, nil))
 		})
 
 		ItD("should create wallet successfully", func(handler base.HandlerFunc, d *db.Db, generator *mocks.IGenerator) {
@@ -194,7 +194,7 @@ var _ = Describe("testings /wallets endpoints", func() {
 		Context("when querying multiple wallets", func() {
 			BeforeEachCProvide(func(d *db.Db, coordinator nodes.ICoordinator, observer *mocks.IWalletObserver) base.HandlerFunc {
 				observer.On("Balances", mock.Anything).Return(nil, nil).Times(10)
-				return GetAllFactory(wallets.NewApi(d, coordinator, nil, nil), nil)
+				return GetAllFactory(wallets.NewApi(d, coordinator, nil, nil, nil), nil, nil)
 			})
 
 			ItD("should return all rows due to no filters", func(handler base.HandlerFunc, btcWIDs btcWIDsT, ethWIDs ethWIDsT) {
@@ -0,0 +1,69 @@
+package isc
+
+import (
+	"github.com/ericlagergren/decimal"
+	"net"
+	"time"
+)
+
+// InvoiceEventPayload describes merchant invoice state, PaidAmount is the confirmed amount received so far
+type InvoiceEventPayload struct {
+	ID            int64
+	MerchantPhone string
+	WalletID      int64
+	Coin          string
+	Address       string
+	Amount        *decimal.Big
+	PaidAmount    *decimal.Big
+	FiatAmount    *decimal.Big
+	FiatCurrency  string
+	Status        string
+	ExpiresAt     time.Time
+
+	// WebhookURL is the merchant endpoint which should receive invoice events, may be empty
+	WebhookURL string
+
+	// WebhookSecret is the invoice key which webhook body is signed by, so merchant may verify the sender
+	WebhookSecret string
+}
+
+// IInvoicesEventNotificator used to notify merchants and other system parts about invoices status changes
+type IInvoicesEventNotificator interface {
+	// StatusChanged notify merchant that invoice has been paid, underpaid, overpaid, expired or canceled
+	StatusChanged(payload InvoiceEventPayload) error
+}
+
+// nonPublicNets are networks which webhooks must not be delivered to, so merchants can't reach internal services
+var nonPublicNets = func() (nets []*net.IPNet) {
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"fc00::/7",
+		"fe80::/10",
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return
+}()
+
+// IsPublicIP true if ip isn't loopback, private, link-local, unspecified or multicast one
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package isc
+
+import "github.com/ericlagergren/decimal"
+
+// PaymentRequestEventPayload describes payment request details, either Amount or FiatAmount with FiatCurrency is set
+type PaymentRequestEventPayload struct {
+	ID             int64
+	Coin           string
+	RequesterPhone string
+	PayerPhone     string
+	Amount         *decimal.Big
+	FiatAmount     *decimal.Big
+	FiatCurrency   string
+}
+
+// IPaymentRequestsEventNotificator used to notify other system parts about payment requests status changes
+type IPaymentRequestsEventNotificator interface {
+	// Created notify payer that he has been asked for money
+	Created(payload PaymentRequestEventPayload) error
+
+	// Accepted notify requester that request has been paid
+	Accepted(payload PaymentRequestEventPayload) error
+
+	// Declined notify requester that payer declined request
+	Declined(payload PaymentRequestEventPayload) error
+
+	// Canceled notify payer that requester canceled request
+	Canceled(payload PaymentRequestEventPayload) error
+
+	// Expired notify both sides that request hasn't been paid in time
+	Expired(payload PaymentRequestEventPayload) error
+}
@@ -0,0 +1,139 @@
+package simple
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"github.com/ericlagergren/decimal"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSignatureHeader holds hex encoded HMAC-SHA256 of the webhook body keyed by the invoice webhook secret
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// errNonPublicAddress returned by dialer when webhook host resolves into internal address
+var errNonPublicAddress = errors.New("simple invoices event notificator: webhook host address isn't public")
+
+// invoicesEventNotificator posts signed invoice status changes to the merchant webhook as json, invoices without
+// webhook aren't reported.
+type invoicesEventNotificator struct {
+	client *http.Client
+}
+
+// NewInvoices simple IInvoicesEventNotificator implementation which calls merchant webhooks with given timeout.
+// Webhook host is resolved on each call and only public addresses are dialed, redirects aren't followed.
+func NewInvoices(timeout time.Duration) isc.IInvoicesEventNotificator {
+	dialer := &net.Dialer{Timeout: timeout}
+	return &invoicesEventNotificator{client: &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialPublic(ctx, dialer, network, address)
+			},
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// WebhookSignature returns hex encoded HMAC-SHA256 of the body keyed by the secret
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dialPublic resolves host and dials it's first address, fails if any of addresses isn't public, so host can't be
+// rebound to internal address after webhook validation
+func dialPublic(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errNonPublicAddress
+	}
+	for _, addr := range addrs {
+		if !isc.IsPublicIP(addr.IP) {
+			return nil, errNonPublicAddress
+		}
+	}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(addrs[0].IP.String(), port))
+}
+
+// invoiceWebhookBody is the invoice event representation sent to the webhook
+type invoiceWebhookBody struct {
+	ID           string       `json:"id"`
+	Status       string       `json:"status"`
+	WalletID     string       `json:"wallet_id"`
+	Coin         string       `json:"coin"`
+	Address      string       `json:"address,omitempty"`
+	Amount       *decimal.Big `json:"amount"`
+	PaidAmount   *decimal.Big `json:"paid_amount"`
+	FiatAmount   *decimal.Big `json:"fiat_amount"`
+	FiatCurrency string       `json:"fiat_currency"`
+	ExpiresAt    int64        `json:"expires_at"`
+}
+
+// StatusChanged implements IInvoicesEventNotificator
+func (n *invoicesEventNotificator) StatusChanged(payload isc.InvoiceEventPayload) error {
+	if payload.WebhookURL == "" {
+		return nil
+	}
+	if payload.Status == "" {
+		return errors.New("simple invoices event notificator: empty Status field")
+	}
+	if payload.WebhookSecret == "" {
+		return errors.New("simple invoices event notificator: empty WebhookSecret field")
+	}
+
+	body, err := json.Marshal(invoiceWebhookBody{
+		ID:           strconv.FormatInt(payload.ID, 10),
+		Status:       payload.Status,
+		WalletID:     strconv.FormatInt(payload.WalletID, 10),
+		Coin:         strings.ToLower(payload.Coin),
+		Address:      payload.Address,
+		Amount:       payload.Amount,
+		PaidAmount:   payload.PaidAmount,
+		FiatAmount:   payload.FiatAmount,
+		FiatCurrency: strings.ToLower(payload.FiatCurrency),
+		ExpiresAt:    payload.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "simple invoices event notificator: payload encoding failed")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, payload.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "simple invoices event notificator: invalid webhook url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(payload.WebhookSecret, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "simple invoices event notificator: webhook call failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("simple invoices event notificator: webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
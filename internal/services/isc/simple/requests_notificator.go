@@ -0,0 +1,81 @@
+package simple
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/services/isc"
+	"git.zam.io/wallet-backend/web-api/pkg/services/notifications"
+	"github.com/chonla/format"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// requestsEventNotificator simply renders payer notification in simple text form and uses notifications.ITransport
+// to send it, other events aren't sent.
+type requestsEventNotificator struct {
+	transport notifications.ITransport
+	appUrl    string
+}
+
+// NewRequests simple IPaymentRequestsEventNotificator implementation which uses specified message transport
+func NewRequests(transport notifications.ITransport, applicationUrl string) isc.IPaymentRequestsEventNotificator {
+	return &requestsEventNotificator{transport, applicationUrl}
+}
+
+// Created implements IPaymentRequestsEventNotificator
+func (n *requestsEventNotificator) Created(payload isc.PaymentRequestEventPayload) error {
+	if payload.PayerPhone == "" {
+		return errors.New("simple requests event notificator: empty PayerPhone field")
+	}
+	if payload.RequesterPhone == "" {
+		return errors.New("simple requests event notificator: empty RequesterPhone field")
+	}
+	if payload.Coin == "" {
+		return errors.New("simple requests event notificator: empty Coin field")
+	}
+
+	amount, currency := payload.Amount, strings.ToUpper(payload.Coin)
+	if amount == nil {
+		amount, currency = payload.FiatAmount, strings.ToUpper(payload.FiatCurrency)
+	}
+	if amount == nil {
+		return errors.New("simple requests event notificator: empty Amount field")
+	}
+
+	return n.transport.Send(
+		payload.PayerPhone,
+		format.Sprintf(
+			requestMessageTemplate,
+			map[string]interface{}{
+				"amount":       amount,
+				"currency":     currency,
+				"phone_number": payload.RequesterPhone,
+				"app_url":      n.appUrl,
+			},
+		),
+	)
+}
+
+// Accepted implements IPaymentRequestsEventNotificator
+func (*requestsEventNotificator) Accepted(payload isc.PaymentRequestEventPayload) error {
+	// does nothing right now
+	return nil
+}
+
+// Declined implements IPaymentRequestsEventNotificator
+func (*requestsEventNotificator) Declined(payload isc.PaymentRequestEventPayload) error {
+	// does nothing right now
+	return nil
+}
+
+// Canceled implements IPaymentRequestsEventNotificator
+func (*requestsEventNotificator) Canceled(payload isc.PaymentRequestEventPayload) error {
+	// does nothing right now
+	return nil
+}
+
+// Expired implements IPaymentRequestsEventNotificator
+func (*requestsEventNotificator) Expired(payload isc.PaymentRequestEventPayload) error {
+	// does nothing right now
+	return nil
+}
+
+const requestMessageTemplate = `Hi from Zamzam! %<phone_number>s asks you for %<amount>s %<currency>s, go to %<app_url>s`
@@ -9,6 +9,10 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"git.zam.io/wallet-backend/web-api/pkg/services/notifications/mocks"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
 )
 
 func TestSimpleTxsNotificator(t *testing.T) {
@@ -72,4 +76,78 @@ var _ = Describe("testing txs events notificator", func() {
 	})
 })
 
+var _ = Describe("testing invoices events notificator", func() {
+	payload := func(webhookURL string) isc.InvoiceEventPayload {
+		return isc.InvoiceEventPayload{
+			ID:            1,
+			Status:        "paid",
+			Coin:          coinName,
+			Amount:        amount,
+			PaidAmount:    amount,
+			FiatAmount:    amount,
+			FiatCurrency:  "USD",
+			WebhookURL:    webhookURL,
+			WebhookSecret: "secret",
+		}
+	}
+
+	It("should sign body by HMAC-SHA256 keyed by the secret", func() {
+		Expect(simple.WebhookSignature("key", []byte("The quick brown fox jumps over the lazy dog"))).To(Equal(
+			"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		))
+	})
+
+	It("should not call webhook which host resolves into internal address", func() {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+		}))
+		defer server.Close()
+
+		for _, webhookURL := range []string{server.URL, "http://localhost" + server.URL[len("http://127.0.0.1"):]} {
+			err := simple.NewInvoices(time.Second).StatusChanged(payload(webhookURL))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("webhook host address isn't public"))
+		}
+		Expect(calls).To(BeZero())
+	})
+
+	It("should not send unsigned webhook", func() {
+		p := payload("https://merchant.example.com/")
+		p.WebhookSecret = ""
+		err := simple.NewInvoices(time.Second).StatusChanged(p)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("simple invoices event notificator: empty WebhookSecret field"))
+	})
+
+	It("should skip invoice without webhook", func() {
+		Expect(simple.NewInvoices(time.Second).StatusChanged(payload(""))).To(Succeed())
+	})
+
+	for _, c := range []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.32.0.1", true},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+	} {
+		c := c
+		It("should check whether "+c.ip+" is public", func() {
+			Expect(isc.IsPublicIP(net.ParseIP(c.ip))).To(Equal(c.public))
+		})
+	}
+})
+
 const pattern = `Hi from Zamzam Bank! You got %<amount>s %<coin>s from %<phone_number>s, in order to receive them, go through the verification of your phone at %<app_url>s`
\ No newline at end of file
@@ -0,0 +1,17 @@
+package nodes
+
+// IAddressValidator validates coin addresses format and checksum without querying the node
+type IAddressValidator interface {
+	// ValidateAddress returns ErrAddressInvalid if address is malformed or belongs to the other network
+	ValidateAddress(address string) error
+}
+
+// retErrAddressValidator returns error on each call
+type retErrAddressValidator struct {
+	e error
+}
+
+// ValidateAddress implements IAddressValidator
+func (v retErrAddressValidator) ValidateAddress(address string) error {
+	return v.e
+}
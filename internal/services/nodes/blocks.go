@@ -0,0 +1,20 @@
+package nodes
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTooManyBlocks returned if blocks range is too wide to be scanned
+	ErrTooManyBlocks = errors.New("blocks observer: too many blocks to scan")
+)
+
+// IBlocksObserver reports addresses which balances are changed by released blocks, so only balances of affected
+// wallets have to be recalculated
+type IBlocksObserver interface {
+	// ChangedAddresses returns addresses which balances change once chain grows from block of fromHeight to block of
+	// toHeight, blocks are scanned according to node confirmations policy. If toHeight doesn't exceed fromHeight (chain
+	// reorganization), only toHeight block is scanned. Returns ErrTooManyBlocks if range is too wide.
+	ChangedAddresses(ctx context.Context, fromHeight, toHeight int) (addresses []string, err error)
+}
@@ -0,0 +1,292 @@
+package btc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"math/big"
+	"strings"
+)
+
+// base58 alphabet used by bitcoin legacy addresses
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// charset used by both bech32 and cashaddr encodings
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// legacy addresses version bytes
+var (
+	mainNetVersions = []byte{0x00, 0x05}
+	testNetVersions = []byte{0x6f, 0xc4}
+)
+
+var (
+	bech32Generator   = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	cashAddrGenerator = [5]uint64{0x98f2bc8e61, 0x79b76d99e2, 0xf33e5fb3c4, 0xae2eabe2a8, 0x1e4f43e470}
+)
+
+// interfaces compile-time validations
+var _ nodes.IAddressValidator = (*btcNode)(nil)
+
+// ValidateAddress implements IAddressValidator interface, BTC accepts legacy and segwit addresses, BCH accepts
+// legacy and cashaddr addresses
+func (n *btcNode) ValidateAddress(address string) error {
+	if isLegacyAddress(address, n.testnet) {
+		return nil
+	}
+
+	switch n.coinName {
+	case "bch":
+		if isCashAddress(address, n.testnet) {
+			return nil
+		}
+	default:
+		if isSegwitAddress(address, n.testnet) {
+			return nil
+		}
+	}
+	return nodes.ErrAddressInvalid
+}
+
+// isLegacyAddress validates base58check encoded P2PKH/P2SH address
+func isLegacyAddress(address string, testnet bool) bool {
+	decoded, ok := base58Decode(address)
+	if !ok || len(decoded) != 25 {
+		return false
+	}
+
+	payload, checksum := decoded[:21], decoded[21:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return false
+	}
+
+	versions := mainNetVersions
+	if testnet {
+		versions = testNetVersions
+	}
+	return bytes.IndexByte(versions, payload[0]) >= 0
+}
+
+// isSegwitAddress validates bech32 (witness v0) or bech32m (witness v1+) encoded address
+func isSegwitAddress(address string, testnet bool) bool {
+	if len(address) > 90 || !isSingleCase(address) {
+		return false
+	}
+	address = strings.ToLower(address)
+
+	sepPos := strings.LastIndexByte(address, '1')
+	if sepPos < 1 || sepPos+7 > len(address) {
+		return false
+	}
+	hrp := address[:sepPos]
+	if testnet {
+		if hrp != "tb" && hrp != "bcrt" {
+			return false
+		}
+	} else if hrp != "bc" {
+		return false
+	}
+
+	// at least witness version and checksum are required
+	data, ok := decodeCharset(address[sepPos+1:])
+	if !ok || len(data) < 7 {
+		return false
+	}
+
+	witnessVersion := data[0]
+	if witnessVersion > 16 {
+		return false
+	}
+
+	expectedConst := uint32(bech32mConst)
+	if witnessVersion == 0 {
+		expectedConst = bech32Const
+	}
+	if bech32Polymod(append(bech32HrpExpand(hrp), data...)) != expectedConst {
+		return false
+	}
+
+	program, ok := convertBits(data[1:len(data)-6], 5, 8, false)
+	if !ok || len(program) < 2 || len(program) > 40 {
+		return false
+	}
+	if witnessVersion == 0 && len(program) != 20 && len(program) != 32 {
+		return false
+	}
+	return true
+}
+
+// isCashAddress validates cashaddr encoded address, prefix may be omitted
+func isCashAddress(address string, testnet bool) bool {
+	if !isSingleCase(address) {
+		return false
+	}
+	address = strings.ToLower(address)
+
+	prefixes := []string{"bitcoincash"}
+	if testnet {
+		prefixes = []string{"bchtest", "bchreg"}
+	}
+
+	prefix, payload := "", address
+	if sepPos := strings.IndexByte(address, ':'); sepPos >= 0 {
+		prefix, payload = address[:sepPos], address[sepPos+1:]
+	}
+
+	data, ok := decodeCharset(payload)
+	if !ok || len(data) <= 8 {
+		return false
+	}
+
+	// try each prefix if it's omitted
+	valid := false
+	for _, p := range prefixes {
+		if prefix != "" && prefix != p {
+			continue
+		}
+		if cashAddrPolymod(append(cashAddrPrefixExpand(p), data...)) == 0 {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return false
+	}
+
+	decoded, ok := convertBits(data[:len(data)-8], 5, 8, false)
+	if !ok || len(decoded) == 0 {
+		return false
+	}
+
+	// version byte: highest bit is reserved, then 4 bits of type (P2PKH or P2SH), then 3 bits of hash size
+	versionByte, hash := decoded[0], decoded[1:]
+	if versionByte&0x80 != 0 || (versionByte>>3)&0x0f > 1 {
+		return false
+	}
+	hashSizes := [8]int{20, 24, 28, 32, 40, 48, 56, 64}
+	return len(hash) == hashSizes[versionByte&0x07]
+}
+
+func base58Decode(s string) ([]byte, bool) {
+	if s == "" {
+		return nil, false
+	}
+
+	value, radix := new(big.Int), big.NewInt(58)
+	for _, r := range s {
+		digit := strings.IndexRune(base58Alphabet, r)
+		if digit < 0 {
+			return nil, false
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	// each leading '1' represents leading zero byte
+	leadingZeros := 0
+	for leadingZeros < len(s) && s[leadingZeros] == base58Alphabet[0] {
+		leadingZeros++
+	}
+	return append(make([]byte, leadingZeros), value.Bytes()...), true
+}
+
+func decodeCharset(s string) ([]byte, bool) {
+	data := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return nil, false
+		}
+		data = append(data, byte(v))
+	}
+	return data, len(data) > 0
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range bech32Generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+func cashAddrPrefixExpand(prefix string) []byte {
+	expanded := make([]byte, 0, len(prefix)+1)
+	for i := 0; i < len(prefix); i++ {
+		expanded = append(expanded, prefix[i]&31)
+	}
+	return append(expanded, 0)
+}
+
+func cashAddrPolymod(values []byte) uint64 {
+	c := uint64(1)
+	for _, v := range values {
+		top := c >> 35
+		c = (c&0x07ffffffff)<<5 ^ uint64(v)
+		for i, g := range cashAddrGenerator {
+			if (top>>uint(i))&1 == 1 {
+				c ^= g
+			}
+		}
+	}
+	return c ^ 1
+}
+
+// convertBits regroups bits of given values, used to convert between 5-bit charset groups and bytes
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, bool) {
+	var (
+		acc    uint
+		bits   uint
+		out    []byte
+		max    = uint(1)<<toBits - 1
+		maxAcc = uint(1)<<(fromBits+toBits-1) - 1
+	)
+	for _, v := range data {
+		if uint(v)>>fromBits != 0 {
+			return nil, false
+		}
+		acc = (acc<<fromBits | uint(v)) & maxAcc
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&max))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&max))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&max != 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+func isSingleCase(s string) bool {
+	return s == strings.ToLower(s) || s == strings.ToUpper(s)
+}
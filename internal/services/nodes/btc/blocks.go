@@ -0,0 +1,64 @@
+package btc
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+)
+
+// maxScannedBlocks limits number of blocks scanned at once for changed addresses
+const maxScannedBlocks = 50
+
+// interfaces compile-time validations
+var _ nodes.IBlocksObserver = (*btcNode)(nil)
+
+// ChangedAddresses implements IBlocksObserver interface. Addresses balances consist of outputs which have enough
+// confirmations, so blocks which txs reach required confirmations count are scanned and recipients of their outputs
+// are reported. Spent outputs are taken into account once spending tx is sent, so inputs aren't scanned.
+func (n *btcNode) ChangedAddresses(ctx context.Context, fromHeight, toHeight int) (addresses []string, err error) {
+	if toHeight <= fromHeight {
+		fromHeight = toHeight - 1
+	}
+	if toHeight-fromHeight > maxScannedBlocks {
+		return nil, nodes.ErrTooManyBlocks
+	}
+
+	// tx of block h reaches required confirmations once block h+confirmations-1 is released
+	shift := n.confirmationsCount - 1
+	if shift < 0 {
+		shift = 0
+	}
+
+	seen := make(map[string]struct{})
+	for height := fromHeight + 1 - shift; height <= toHeight-shift; height++ {
+		if height < 0 {
+			continue
+		}
+
+		var hash string
+		err = n.doCall("getblockhash", &hash, height)
+		if err != nil {
+			return
+		}
+		var block struct {
+			Tx []struct {
+				Vout []decodedTxOut `json:"vout"`
+			} `json:"tx"`
+		}
+		err = n.doCall("getblock", &block, hash, 2)
+		if err != nil {
+			return
+		}
+
+		for _, tx := range block.Tx {
+			for _, out := range tx.Vout {
+				for _, address := range out.addresses() {
+					if _, ok := seen[address]; !ok {
+						seen[address] = struct{}{}
+						addresses = append(addresses, address)
+					}
+				}
+			}
+		}
+	}
+	return
+}
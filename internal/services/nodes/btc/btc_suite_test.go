@@ -0,0 +1,289 @@
+package btc
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestBtc(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Btc Suite")
+}
+
+type addressVector struct {
+	address string
+	testnet bool
+}
+
+var _ = Describe("testing addresses validation", func() {
+	Context("when validating segwit addresses (BIP173, BIP350)", func() {
+		for _, v := range []addressVector{
+			{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", false},
+			{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", true},
+			{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", false},
+			{"BC1SW50QGDZ25J", false},
+			{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", false},
+			{"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", true},
+			{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", true},
+			{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", false},
+		} {
+			v := v
+			It("should accept "+v.address, func() {
+				Expect(isSegwitAddress(v.address, v.testnet)).To(BeTrue())
+			})
+		}
+
+		for _, v := range []struct {
+			addressVector
+			reason string
+		}{
+			{addressVector{"tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", true}, "invalid hrp"},
+			{addressVector{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", false}, "bech32 checksum of v1"},
+			{addressVector{"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf", true}, "bech32 checksum of v2"},
+			{addressVector{"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", false}, "bech32 checksum of v16"},
+			{addressVector{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", false}, "bech32m checksum of v0"},
+			{addressVector{"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47", true}, "bech32m checksum of v0"},
+			{addressVector{"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", false}, "invalid character"},
+			{addressVector{"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", false}, "witness version 17"},
+			{addressVector{"bc1pw5dgrnzv", false}, "1 byte program"},
+			{
+				addressVector{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", false},
+				"41 bytes program",
+			},
+			{addressVector{"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", false}, "16 bytes v0 program"},
+			{addressVector{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq", true}, "mixed case"},
+			{addressVector{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf", false}, "padding over 4 bits"},
+			{addressVector{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j", true}, "non-zero padding"},
+			{addressVector{"bc1gmk9yu", false}, "empty data"},
+			{addressVector{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T5", false}, "wrong checksum"},
+			{addressVector{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", true}, "mainnet address on testnet"},
+			{
+				addressVector{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", false},
+				"testnet address on mainnet",
+			},
+		} {
+			v := v
+			It("should reject "+v.address+" due to "+v.reason, func() {
+				Expect(isSegwitAddress(v.address, v.testnet)).To(BeFalse())
+			})
+		}
+	})
+
+	Context("when validating legacy addresses", func() {
+		for _, v := range []addressVector{
+			{"1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", false},
+			{"3CWFddi6m4ndiGyKqzYvsFYagqDLPVMTzC", false},
+			{"1KXrWXciRDZUpQwQmuM1DbwsKDLYAYsVLR", false},
+			{"16w1D5WRVKJuZUsSRzdLp9w3YGcgoxDXb", false},
+			{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", true},
+			{"2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", true},
+		} {
+			v := v
+			It("should accept "+v.address, func() {
+				Expect(isLegacyAddress(v.address, v.testnet)).To(BeTrue())
+			})
+		}
+
+		for _, v := range []struct {
+			addressVector
+			reason string
+		}{
+			{addressVector{"1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggv", false}, "wrong checksum"},
+			{addressVector{"1BpEi6DfDAUFd7GtittLSdBeYJvcoaVgg0", false}, "invalid character"},
+			{addressVector{"1BpEi6DfDAUFd7GtittLSdBeYJvcoaV", false}, "short payload"},
+			{addressVector{"", false}, "empty address"},
+			{addressVector{"1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", true}, "mainnet address on testnet"},
+			{addressVector{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", false}, "testnet address on mainnet"},
+		} {
+			v := v
+			It("should reject "+v.address+" due to "+v.reason, func() {
+				Expect(isLegacyAddress(v.address, v.testnet)).To(BeFalse())
+			})
+		}
+	})
+
+	Context("when validating cashaddr addresses", func() {
+		for _, v := range []addressVector{
+			{"bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", false},
+			{"bitcoincash:qr95sy3j9xwd2ap32xkykttr4cvcu7as4y0qverfuy", false},
+			{"bitcoincash:qqq3728yw0y47sqn6l2na30mcw6zm78dzqre909m2r", false},
+			{"bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq", false},
+			{"bitcoincash:pr95sy3j9xwd2ap32xkykttr4cvcu7as4yc93ky28e", false},
+			{"bitcoincash:pqq3728yw0y47sqn6l2na30mcw6zm78dzq5ucqzc37", false},
+			{"bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2", false},
+			{"bitcoincash:q9adhakpwzztepkpwp5z0dq62m6u5v5xtyj7j3h2ws4mr9g0", false},
+			{"bitcoincash:qvch8mmxy0rtfrlarg7ucrxxfzds5pamg73h7370aa87d80gyhqxq5nlegake", false},
+			{"bchtest:pr6m7j9njldwwzlg9v7v53unlr4jkmx6eyvwc0uz5t", true},
+			{"bchtest:p9adhakpwzztepkpwp5z0dq62m6u5v5xtyj7j3h2u94tsynr", true},
+			{"qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", false},
+			{"BITCOINCASH:QPM2QSZNHKS23Z7629MMS6S4CWEF74VCWVY22GDX6A", false},
+		} {
+			v := v
+			It("should accept "+v.address, func() {
+				Expect(isCashAddress(v.address, v.testnet)).To(BeTrue())
+			})
+		}
+
+		for _, v := range []struct {
+			addressVector
+			reason string
+		}{
+			{addressVector{"bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6b", false}, "wrong checksum"},
+			{addressVector{"bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvY22gdx6a", false}, "mixed case"},
+			{addressVector{"pref:pr6m7j9njldwwzlg9v7v53unlr4jkmx6ey65nvtks5", false}, "unknown prefix"},
+			{addressVector{"prefix:0r6m7j9njldwwzlg9v7v53unlr4jkmx6ey3qnjwsrf", false}, "unknown prefix and type"},
+			{addressVector{"bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", true}, "mainnet address on testnet"},
+			{addressVector{"bchtest:pr6m7j9njldwwzlg9v7v53unlr4jkmx6eyvwc0uz5t", false}, "testnet address on mainnet"},
+		} {
+			v := v
+			It("should reject "+v.address+" due to "+v.reason, func() {
+				Expect(isCashAddress(v.address, v.testnet)).To(BeFalse())
+			})
+		}
+	})
+
+	Context("when validating addresses by coin node", func() {
+		btcNode := &btcNode{coinName: "btc"}
+		bchNode := &btcNode{coinName: "bch"}
+
+		It("should accept legacy addresses by both coins", func() {
+			Expect(btcNode.ValidateAddress("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu")).To(Succeed())
+			Expect(bchNode.ValidateAddress("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu")).To(Succeed())
+		})
+
+		It("should accept segwit addresses by BTC only", func() {
+			Expect(btcNode.ValidateAddress("BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4")).To(Succeed())
+			Expect(bchNode.ValidateAddress("BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4")).To(
+				Equal(nodes.ErrAddressInvalid),
+			)
+		})
+
+		It("should accept cashaddr addresses by BCH only", func() {
+			Expect(bchNode.ValidateAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a")).To(Succeed())
+			Expect(btcNode.ValidateAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a")).To(
+				Equal(nodes.ErrAddressInvalid),
+			)
+		})
+	})
+
+	Context("when regrouping bits", func() {
+		It("should convert bytes into 5-bit groups padding the last one", func() {
+			groups, ok := convertBits([]byte{0xff, 0x01}, 8, 5, true)
+			Expect(ok).To(BeTrue())
+			Expect(groups).To(Equal([]byte{31, 28, 0, 16}))
+		})
+
+		It("should convert 5-bit groups back into bytes", func() {
+			decoded, ok := convertBits([]byte{31, 28, 0, 16}, 5, 8, false)
+			Expect(ok).To(BeTrue())
+			Expect(decoded).To(Equal([]byte{0xff, 0x01}))
+		})
+
+		It("should reject values which don't fit into source groups", func() {
+			_, ok := convertBits([]byte{32}, 5, 8, false)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should reject non-zero padding", func() {
+			_, ok := convertBits([]byte{31, 28, 0, 17}, 5, 8, false)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("testing BIP21 payment uris", func() {
+	const (
+		legacyAddress  = "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu"
+		cashAddress    = "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"
+		testnetAddress = "bchtest:pr6m7j9njldwwzlg9v7v53unlr4jkmx6eyvwc0uz5t"
+	)
+	btcNode := &btcNode{coinName: "btc"}
+	bchNode := &btcNode{coinName: "bch"}
+	bchTestNode := &btcNode{coinName: "bch", testnet: true}
+
+	Context("when encoding", func() {
+		for _, c := range []struct {
+			label string
+			node  *btcNode
+			uri   nodes.PaymentURI
+			want  string
+		}{
+			{"should render address only", btcNode, nodes.PaymentURI{Address: legacyAddress}, "bitcoin:" + legacyAddress},
+			{
+				label: "should render amount without trailing zeros and percent-encoded label",
+				node:  btcNode,
+				uri:   nodes.PaymentURI{Address: legacyAddress, Amount: decimal.New(1500, 5), Label: "Coffee & cake"},
+				want:  "bitcoin:" + legacyAddress + "?amount=0.015&label=Coffee%20%26%20cake",
+			},
+			{
+				label: "should use cashaddr prefix as scheme",
+				node:  bchNode,
+				uri:   nodes.PaymentURI{Address: cashAddress, Amount: decimal.New(25, 1)},
+				want:  cashAddress + "?amount=2.5",
+			},
+			{
+				label: "should use testnet scheme for address without prefix",
+				node:  bchTestNode,
+				uri:   nodes.PaymentURI{Address: testnetAddress[len("bchtest:"):]},
+				want:  testnetAddress,
+			},
+		} {
+			c := c
+			It(c.label, func() {
+				Expect(c.node.EncodePaymentURI(c.uri)).To(Equal(c.want))
+			})
+		}
+	})
+
+	Context("when decoding", func() {
+		It("should parse case-insensitive scheme, amount and label", func() {
+			uri, err := btcNode.DecodePaymentURI("BITCOIN:" + legacyAddress + "?amount=0.015&label=Coffee%20shop&message=hi")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri.Address).To(Equal(legacyAddress))
+			Expect(uri.Amount.Cmp(decimal.New(15, 3))).To(BeZero())
+			Expect(uri.Label).To(Equal("Coffee shop"))
+		})
+
+		It("should leave amount empty if it isn't given", func() {
+			uri, err := btcNode.DecodePaymentURI("bitcoin:" + legacyAddress)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri).To(Equal(nodes.PaymentURI{Address: legacyAddress}))
+		})
+
+		It("should keep cashaddr prefix of BCH address", func() {
+			uri, err := bchNode.DecodePaymentURI(cashAddress)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri.Address).To(Equal(cashAddress))
+		})
+
+		for _, c := range []struct {
+			node   *btcNode
+			uri    string
+			err    error
+			reason string
+		}{
+			{btcNode, cashAddress, nodes.ErrPaymentURIScheme, "BCH scheme"},
+			{bchNode, "bitcoin:" + legacyAddress, nodes.ErrPaymentURIScheme, "BTC scheme"},
+			{bchTestNode, cashAddress, nodes.ErrPaymentURIScheme, "mainnet scheme"},
+			{btcNode, "ethereum:0xde709f2102306220921060314715629080e2fb77", nodes.ErrPaymentURIScheme, "ETH scheme"},
+			{btcNode, legacyAddress, nodes.ErrPaymentURIInvalid, "missing scheme"},
+			{btcNode, "bitcoin:?amount=1", nodes.ErrPaymentURIInvalid, "missing address"},
+			{btcNode, "bitcoin:" + legacyAddress + "?req-somethingyoudontunderstand=50", nodes.ErrPaymentURIInvalid,
+				"unknown required param"},
+			{btcNode, "bitcoin:" + legacyAddress + "?amount=-1", nodes.ErrPaymentURIInvalid, "negative amount"},
+			{btcNode, "bitcoin:" + legacyAddress + "?amount=NaN", nodes.ErrPaymentURIInvalid, "NaN amount"},
+			{btcNode, "bitcoin:" + legacyAddress + "?amount=1,5", nodes.ErrPaymentURIInvalid, "malformed amount"},
+			{btcNode, "bitcoin:" + legacyAddress + "?label=%zz", nodes.ErrPaymentURIInvalid, "malformed query"},
+		} {
+			c := c
+			It("should reject "+c.uri+" due to "+c.reason, func() {
+				_, err := c.node.DecodePaymentURI(c.uri)
+				Expect(err).To(Equal(c.err))
+			})
+		}
+	})
+})
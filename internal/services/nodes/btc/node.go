@@ -12,6 +12,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,16 +21,47 @@ const (
 	defaultTestNetPort = 18332
 
 	rpcErrInvalidAddressCode = -5
+
+	// watchOnlyLabelPrefix is label prefix of imported external addresses, each address gets own label
+	watchOnlyLabelPrefix = "watch-only:"
+
+	// addressTxsTTL is duration while address txs stay cached
+	addressTxsTTL = 30 * time.Second
+
+	// rescanTimeout is timeout of blockchain rescan request
+	rescanTimeout = 12 * time.Hour
+
+	// watchOnlyRescanDepth is number of recent blocks (about a month) rescanned for txs of imported address
+	watchOnlyRescanDepth = 4320
+
+	// maxListedTxs limits txs count returned by listtransactions
+	maxListedTxs = 1000000
 )
 
 // btcNode implements IGenerator interface for BTC/BCH nodes
 type btcNode struct {
 	coinName           string
+	testnet            bool
 	logger             logrus.FieldLogger
 	client             *http.Client
 	rpcClient          jsonrpc.RPCClient
 	subscriber         func(ctx context.Context, blockHeight int) error
 	confirmationsCount int
+
+	// rescanClient used for long-running blockchain rescans
+	rescanClient      jsonrpc.RPCClient
+	rescanMu          sync.Mutex
+	rescanning        bool
+	rescanPending     bool
+	rescanPendingFrom int
+
+	addressTxsMu sync.Mutex
+	addressTxs   map[string]cachedAddressTxs
+}
+
+type cachedAddressTxs struct {
+	txs []nodes.AddressTxDescr
+	at  time.Time
 }
 
 // interfaces compile-time validations
@@ -39,6 +71,7 @@ var _ nodes.IAccountObserver = (*btcNode)(nil)
 var _ nodes.ITxSender = (*btcNode)(nil)
 var _ nodes.ITxsObserver = (*btcNode)(nil)
 var _ nodes.IWatcherLoop = (*btcNode)(nil)
+var _ nodes.IBatchTxSender = (*btcNode)(nil)
 
 // Dial creates client HTTP connection using passed params, also checks connectivity by sending "getwalletinfo" request.
 //
@@ -65,6 +98,9 @@ func Dial(
 	if user != "" && pass != "" {
 		httpClient = HttpClientWithBasicAuth(httpClient, user, pass)
 	}
+	// rescan client shares transport, but waits much longer
+	rescanHttpClient := *httpClient
+	rescanHttpClient.Timeout = rescanTimeout
 
 	// if port not specified applies default BTC port for selected network type
 	if !strings.Contains(addr, ":") {
@@ -100,8 +136,13 @@ func Dial(
 		rpcClient: jsonrpc.NewClientWithOpts(
 			addr, &jsonrpc.RPCClientOpts{HTTPClient: httpClient},
 		),
+		rescanClient: jsonrpc.NewClientWithOpts(
+			addr, &jsonrpc.RPCClientOpts{HTTPClient: &rescanHttpClient},
+		),
 		confirmationsCount: confirmationsCount,
 		coinName:           coin,
+		testnet:            testnet,
+		addressTxs:         make(map[string]cachedAddressTxs),
 	}
 	// ping node
 	err := n.Ping()
@@ -222,6 +263,51 @@ func (n *btcNode) Send(
 	return
 }
 
+// SendBatch implements IBatchTxSender interface using sendmany rpc method, fee is subtracted from outputs amounts
+// the same way as Send does
+func (n *btcNode) SendBatch(
+	ctx context.Context,
+	fromAddress string,
+	outputs []nodes.TxOutput,
+	secret string,
+) (txHash string, fee *decimal.Big, err error) {
+	amounts := make(map[string]*decimal.Big, len(outputs))
+	subtractFeeFrom := make([]string, 0, len(outputs))
+	for _, o := range outputs {
+		if amount, ok := amounts[o.Address]; ok {
+			amount.Add(amount, o.Amount)
+			continue
+		}
+		amounts[o.Address] = new(decimal.Big).Set(o.Amount)
+		subtractFeeFrom = append(subtractFeeFrom, o.Address)
+	}
+
+	err = n.doCall("sendmany", &txHash, "", amounts, 1, "", subtractFeeFrom)
+	if rpcErr, ok := err.(*jsonrpc.RPCError); ok {
+		if rpcErr.Code == rpcErrInvalidAddressCode {
+			err = nodes.ErrAddressInvalid
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	// each detail of multi-output tx holds the whole fee, so top-level one is used
+	var resp struct {
+		Fee *bigIntJSONView `json:"fee"`
+	}
+	err = n.doCall("gettransaction", &resp, txHash)
+	if err != nil {
+		// should not broke the transaction sending if second request occurs error
+		err = nil
+		return
+	}
+	if resp.Fee != nil {
+		fee = new(decimal.Big).Abs((*decimal.Big)(resp.Fee))
+	}
+	return
+}
+
 // SupportInternalTxs btc supports internal txs
 func (n *btcNode) SupportInternalTxs() bool {
 	return true
@@ -289,6 +375,219 @@ func (n *btcNode) GetIncoming(ctx context.Context) (txs []nodes.IncomingTxDescr,
 	return
 }
 
+// WatchAddress implements ITxsObserver interface using importaddress rpc method. Address imported without rescan,
+// so call returns immediately while address history of last watchOnlyRescanDepth blocks is collected by blockchain
+// rescan in background.
+func (n *btcNode) WatchAddress(ctx context.Context, address string) error {
+	err := n.doCall("importaddress", nil, address, watchOnlyLabelPrefix+coerceAddress(address), false)
+	if rpcErr, ok := err.(*jsonrpc.RPCError); ok {
+		if rpcErr.Code == rpcErrInvalidAddressCode {
+			err = nodes.ErrAddressInvalid
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var height int
+	err = n.doCall("getblockcount", &height)
+	if err != nil {
+		return err
+	}
+	from := height - watchOnlyRescanDepth
+	if from < 0 {
+		from = 0
+	}
+	n.scheduleRescan(from)
+	return nil
+}
+
+// scheduleRescan starts blockchain rescan from given height in background, addresses imported while rescan is
+// running are covered by one more rescan which follows current one and starts from the lowest requested height
+func (n *btcNode) scheduleRescan(from int) {
+	n.rescanMu.Lock()
+	defer n.rescanMu.Unlock()
+
+	if n.rescanning {
+		if !n.rescanPending || from < n.rescanPendingFrom {
+			n.rescanPendingFrom = from
+		}
+		n.rescanPending = true
+		return
+	}
+	n.rescanning = true
+	go n.rescanLoop(from)
+}
+
+func (n *btcNode) rescanLoop(from int) {
+	for {
+		err := n.callWith(n.rescanClient, "rescanblockchain", nil, from)
+		if err != nil {
+			n.logger.WithError(err).WithField("from", from).Error("blockchain rescan failed")
+		}
+		// rescan may reveal txs of watched addresses
+		n.addressTxsMu.Lock()
+		n.addressTxs = make(map[string]cachedAddressTxs)
+		n.addressTxsMu.Unlock()
+
+		n.rescanMu.Lock()
+		if !n.rescanPending {
+			n.rescanning = false
+			n.rescanMu.Unlock()
+			return
+		}
+		from = n.rescanPendingFrom
+		n.rescanPending = false
+		n.rescanMu.Unlock()
+	}
+}
+
+type addressTxsListItem struct {
+	listTransactionsResultItem
+	Vout              int  `json:"vout"`
+	InvolvesWatchOnly bool `json:"involvesWatchonly"`
+}
+
+// outPoint identifies tx output
+type outPoint struct {
+	txID string
+	vout int
+}
+
+// GetAddressTxs implements ITxsObserver interface using listtransactions rpc method. Incoming txs are listed by the
+// address label, outgoing ones are found among watch-only spends by their inputs, which refer to address outputs,
+// so spent outputs stay in the history and sum of txs amounts matches address balance. Result is cached for a short
+// time.
+func (n *btcNode) GetAddressTxs(ctx context.Context, address string) (txs []nodes.AddressTxDescr, err error) {
+	address = coerceAddress(address)
+
+	n.addressTxsMu.Lock()
+	cached, ok := n.addressTxs[address]
+	n.addressTxsMu.Unlock()
+	if ok && time.Since(cached.at) < addressTxsTTL {
+		return cached.txs, nil
+	}
+
+	var received []addressTxsListItem
+	err = n.doCall("listtransactions", &received, watchOnlyLabelPrefix+address, maxListedTxs, 0, true)
+	if err != nil {
+		return
+	}
+
+	// single tx may have several outputs to the address, as well as spend and receive change
+	byHash := make(map[string]int)
+	addAmount := func(hash string, confirmations int, amount *decimal.Big) {
+		if i, ok := byHash[hash]; ok {
+			txs[i].Amount.Add(txs[i].Amount, amount)
+			return
+		}
+		byHash[hash] = len(txs)
+		txs = append(txs, nodes.AddressTxDescr{
+			Hash:      hash,
+			Confirmed: confirmations >= n.confirmationsCount,
+			Amount:    amount,
+		})
+	}
+
+	outputs := make(map[outPoint]*decimal.Big)
+	for _, r := range received {
+		if r.Category != "receive" || coerceAddress(r.Address) != address || r.Abandoned || r.Confirmations < 0 {
+			continue
+		}
+		amount := decimal.Big(r.Amount)
+		outputs[outPoint{r.TxID, r.Vout}] = new(decimal.Big).Set(&amount)
+		addAmount(r.TxID, r.Confirmations, &amount)
+	}
+	if len(outputs) == 0 {
+		n.cacheAddressTxs(address, txs)
+		return
+	}
+
+	// sends aren't listed by label, so all watch-only spends are checked
+	var listed []addressTxsListItem
+	err = n.doCall("listtransactions", &listed, "*", maxListedTxs, 0, true)
+	if err != nil {
+		return
+	}
+	checked := make(map[string]bool)
+	for _, r := range listed {
+		if r.Category != "send" || !r.InvolvesWatchOnly || r.Abandoned || r.Confirmations < 0 || checked[r.TxID] {
+			continue
+		}
+		checked[r.TxID] = true
+
+		var inputs []outPoint
+		inputs, err = n.getTxInputs(r.TxID)
+		if err != nil {
+			return nil, err
+		}
+		spent := new(decimal.Big)
+		for _, in := range inputs {
+			if amount, ok := outputs[in]; ok {
+				spent.Sub(spent, amount)
+			}
+		}
+		if spent.Sign() != 0 {
+			addAmount(r.TxID, r.Confirmations, spent)
+		}
+	}
+
+	n.cacheAddressTxs(address, txs)
+	return
+}
+
+func (n *btcNode) cacheAddressTxs(address string, txs []nodes.AddressTxDescr) {
+	n.addressTxsMu.Lock()
+	n.addressTxs[address] = cachedAddressTxs{txs: txs, at: time.Now()}
+	n.addressTxsMu.Unlock()
+}
+
+// getTxInputs returns outputs spent by wallet tx
+func (n *btcNode) getTxInputs(hash string) (inputs []outPoint, err error) {
+	var tx struct {
+		Hex string `json:"hex"`
+	}
+	err = n.doCall("gettransaction", &tx, hash, true)
+	if err != nil {
+		return
+	}
+	var decoded struct {
+		Vin []struct {
+			TxID string `json:"txid"`
+			Vout int    `json:"vout"`
+		} `json:"vin"`
+	}
+	err = n.doCall("decoderawtransaction", &decoded, tx.Hex)
+	if err != nil {
+		return
+	}
+	for _, in := range decoded.Vin {
+		inputs = append(inputs, outPoint{in.TxID, in.Vout})
+	}
+	return
+}
+
+// decodedTxOut is output of decoded tx
+type decodedTxOut struct {
+	Value        bigIntJSONView `json:"value"`
+	ScriptPubKey struct {
+		Address   string   `json:"address"`
+		Addresses []string `json:"addresses"`
+	} `json:"scriptPubKey"`
+}
+
+// addresses returns coerced output recipients addresses, both new and legacy node response formats are supported
+func (o *decodedTxOut) addresses() []string {
+	addresses := make([]string, 0, len(o.ScriptPubKey.Addresses)+1)
+	if o.ScriptPubKey.Address != "" {
+		addresses = append(addresses, coerceAddress(o.ScriptPubKey.Address))
+	}
+	for _, a := range o.ScriptPubKey.Addresses {
+		addresses = append(addresses, coerceAddress(a))
+	}
+	return addresses
+}
+
 // Ping node by calling getwalletinfo
 func (n *btcNode) Ping() error {
 	return n.doCall("getwalletinfo", nil)
@@ -296,11 +595,18 @@ func (n *btcNode) Ping() error {
 
 //
 func (n *btcNode) doCall(method string, output interface{}, params ...interface{}) (err error) {
+	return n.callWith(n.rpcClient, method, output, params...)
+}
+
+// callWith calls rpc method using specified client
+func (n *btcNode) callWith(
+	client jsonrpc.RPCClient, method string, output interface{}, params ...interface{},
+) (err error) {
 	l := n.logger.WithField("method", method)
 
 	l.WithField("params", params).WithField("method", method).Info("calling rpc")
 
-	resp, err := n.rpcClient.Call(method, params...)
+	resp, err := client.Call(method, params...)
 	if err == nil && resp.Error != nil {
 		err = resp.Error
 	}
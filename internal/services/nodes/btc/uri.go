@@ -0,0 +1,76 @@
+package btc
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"net/url"
+	"strings"
+)
+
+// interfaces compile-time validations
+var _ nodes.IPaymentURICodec = (*btcNode)(nil)
+
+// EncodePaymentURI implements IPaymentURICodec interface, renders BIP21 uri, BCH uses cashaddr prefix as the scheme
+func (n *btcNode) EncodePaymentURI(uri nodes.PaymentURI) (string, error) {
+	// cashaddr may already contain prefix which is the scheme
+	address := uri.Address
+	if sepPos := strings.IndexByte(address, ':'); sepPos >= 0 {
+		address = address[sepPos+1:]
+	}
+
+	query := url.Values{}
+	if uri.Amount != nil {
+		query.Set("amount", nodes.FormatPaymentAmount(uri.Amount))
+	}
+	if uri.Label != "" {
+		query.Set("label", uri.Label)
+	}
+
+	rendered := n.uriScheme() + ":" + address
+	if len(query) > 0 {
+		rendered += "?" + nodes.EncodePaymentQuery(query)
+	}
+	return rendered, nil
+}
+
+// DecodePaymentURI implements IPaymentURICodec interface, parses BIP21 uri, BCH cashaddr is returned with prefix
+func (n *btcNode) DecodePaymentURI(rawURI string) (uri nodes.PaymentURI, err error) {
+	scheme, target, query, err := nodes.SplitPaymentURI(rawURI)
+	if err != nil {
+		return
+	}
+	if scheme != n.uriScheme() {
+		return uri, nodes.ErrPaymentURIScheme
+	}
+	if target == "" {
+		return uri, nodes.ErrPaymentURIInvalid
+	}
+
+	// BIP21 requires to reject uris with required params which aren't understood
+	for key := range query {
+		if strings.HasPrefix(key, "req-") {
+			return uri, nodes.ErrPaymentURIInvalid
+		}
+	}
+
+	uri.Address = target
+	if n.coinName == "bch" && isCashAddress(scheme+":"+target, n.testnet) {
+		uri.Address = scheme + ":" + strings.ToLower(target)
+	}
+	uri.Amount, err = nodes.ParsePaymentAmount(query.Get("amount"))
+	if err != nil {
+		return
+	}
+	uri.Label = query.Get("label")
+	return
+}
+
+// uriScheme returns payment uri scheme of the coin
+func (n *btcNode) uriScheme() string {
+	if n.coinName != "bch" {
+		return "bitcoin"
+	}
+	if n.testnet {
+		return "bchtest"
+	}
+	return "bitcoincash"
+}
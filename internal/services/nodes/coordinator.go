@@ -6,6 +6,7 @@ import (
 	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes/providers"
 	"github.com/sirupsen/logrus"
 	"io"
+	"sort"
 	"strings"
 )
 
@@ -29,6 +30,9 @@ type ICoordinator interface {
 	// Close closes all connections
 	Close() error
 
+	// Coins returns short names of all dialed coins in upper case
+	Coins() []string
+
 	// WatcherLoop returns watcher loop implementation for specified coin or ErrNoSuchCoin.
 	WatcherLoop(coinName string) (IWatcherLoop, error)
 
@@ -46,6 +50,19 @@ type ICoordinator interface {
 
 	// TxsSender get tx sender implementation by coin name
 	TxsSender(coinName string) ITxSender
+
+	// AddressValidator get address validator implementation by coin name
+	AddressValidator(coinName string) IAddressValidator
+
+	// BatchTxsSender get multi-output tx sender implementation by coin name, returns nil if coin doesn't support
+	// multi-output txs
+	BatchTxsSender(coinName string) IBatchTxSender
+
+	// BlocksObserver get blocks observer implementation by coin name, returns nil if coin doesn't support it
+	BlocksObserver(coinName string) IBlocksObserver
+
+	// PaymentURICodec get payment uri codec implementation by coin name
+	PaymentURICodec(coinName string) IPaymentURICodec
 }
 
 // New creates new default coordinator
@@ -59,6 +76,10 @@ func New(logger logrus.FieldLogger) ICoordinator {
 		txsObserevers:    make(map[string]ITxsObserver),
 		watchers:         make(map[string]IWatcherLoop),
 		senders:          make(map[string]ITxSender),
+		validators:       make(map[string]IAddressValidator),
+		batchSenders:     make(map[string]IBatchTxSender),
+		blocksObservers:  make(map[string]IBlocksObserver),
+		uriCodecs:        make(map[string]IPaymentURICodec),
 	}
 }
 
@@ -72,6 +93,10 @@ type coordinator struct {
 	txsObserevers    map[string]ITxsObserver
 	watchers         map[string]IWatcherLoop
 	senders          map[string]ITxSender
+	validators       map[string]IAddressValidator
+	batchSenders     map[string]IBatchTxSender
+	blocksObservers  map[string]IBlocksObserver
+	uriCodecs        map[string]IPaymentURICodec
 }
 
 // Dial lookup service provider registry, dial no safe with concurrent getters usage
@@ -118,6 +143,22 @@ func (c *coordinator) Dial(
 		c.senders[coinName] = sender
 	}
 
+	if validator, ok := services.(IAddressValidator); ok {
+		c.validators[coinName] = validator
+	}
+
+	if sender, ok := services.(IBatchTxSender); ok {
+		c.batchSenders[coinName] = sender
+	}
+
+	if observer, ok := services.(IBlocksObserver); ok {
+		c.blocksObservers[coinName] = observer
+	}
+
+	if codec, ok := services.(IPaymentURICodec); ok {
+		c.uriCodecs[coinName] = codec
+	}
+
 	return nil
 }
 
@@ -133,6 +174,16 @@ func (c *coordinator) Close() (err error) {
 	return
 }
 
+// Coins implements ICoordinator interface
+func (c *coordinator) Coins() []string {
+	coins := make([]string, 0, len(c.closers))
+	for coinName := range c.closers {
+		coins = append(coins, coinName)
+	}
+	sort.Strings(coins)
+	return coins
+}
+
 // Generator implements ICoordinator interface
 func (c *coordinator) Generator(coinName string) IGenerator {
 	coinName = strings.ToUpper(coinName)
@@ -223,3 +274,55 @@ func (c *coordinator) TxsSender(coinName string) ITxSender {
 	}
 	return sender
 }
+
+// AddressValidator implements ICoordinator interface
+func (c *coordinator) AddressValidator(coinName string) IAddressValidator {
+	coinName = strings.ToUpper(coinName)
+
+	if _, ok := c.closers[coinName]; !ok {
+		panic(ErrNoSuchCoin)
+	}
+
+	validator, ok := c.validators[coinName]
+	if !ok {
+		return retErrAddressValidator{e: ErrCoinServiceNotImplemented}
+	}
+	return validator
+}
+
+// BatchTxsSender implements ICoordinator interface
+func (c *coordinator) BatchTxsSender(coinName string) IBatchTxSender {
+	coinName = strings.ToUpper(coinName)
+
+	if _, ok := c.closers[coinName]; !ok {
+		panic(ErrNoSuchCoin)
+	}
+
+	return c.batchSenders[coinName]
+}
+
+// BlocksObserver implements ICoordinator interface
+func (c *coordinator) BlocksObserver(coinName string) IBlocksObserver {
+	coinName = strings.ToUpper(coinName)
+
+	if _, ok := c.closers[coinName]; !ok {
+		panic(ErrNoSuchCoin)
+	}
+
+	return c.blocksObservers[coinName]
+}
+
+// PaymentURICodec implements ICoordinator interface
+func (c *coordinator) PaymentURICodec(coinName string) IPaymentURICodec {
+	coinName = strings.ToUpper(coinName)
+
+	if _, ok := c.closers[coinName]; !ok {
+		panic(ErrNoSuchCoin)
+	}
+
+	codec, ok := c.uriCodecs[coinName]
+	if !ok {
+		return retErrPaymentURICodec{e: ErrCoinServiceNotImplemented}
+	}
+	return codec
+}
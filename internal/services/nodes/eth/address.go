@@ -0,0 +1,48 @@
+package eth
+
+import (
+	"encoding/hex"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/ethereum/go-ethereum/crypto"
+	"strings"
+)
+
+// interfaces compile-time validations
+var _ nodes.IAddressValidator = (*ethNode)(nil)
+
+// ValidateAddress implements IAddressValidator interface, mixed-case addresses must have valid EIP-55 checksum,
+// single-case addresses have no checksum so they are only checked to be 20 bytes hex
+func (n *ethNode) ValidateAddress(address string) error {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return nodes.ErrAddressInvalid
+	}
+
+	hexPart := address[2:]
+	if _, err := hex.DecodeString(hexPart); err != nil {
+		return nodes.ErrAddressInvalid
+	}
+
+	lower := strings.ToLower(hexPart)
+	if hexPart == lower || hexPart == strings.ToUpper(hexPart) {
+		return nil
+	}
+
+	if toChecksumAddress(lower) != address {
+		return nodes.ErrAddressInvalid
+	}
+	return nil
+}
+
+// toChecksumAddress applies EIP-55 checksum onto lower-case hex address without prefix: letter is upper-cased if
+// corresponding nibble of keccak256 hash of the address is greater then 7
+func toChecksumAddress(lower string) string {
+	hash := hex.EncodeToString(crypto.Keccak256([]byte(lower)))
+
+	result := []byte(lower)
+	for i, c := range result {
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			result[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(result)
+}
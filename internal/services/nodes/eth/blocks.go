@@ -0,0 +1,60 @@
+package eth
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"strings"
+)
+
+// maxScannedBlocks limits number of blocks scanned at once for changed addresses
+const maxScannedBlocks = 100
+
+// interfaces compile-time validations
+var _ nodes.IBlocksObserver = (*ethNode)(nil)
+
+// ChangedAddresses implements IBlocksObserver. Balances are queried for the latest block, so senders and recipients
+// of txs of each released block are reported. Value transfers made by contracts internally aren't reported.
+func (node *ethNode) ChangedAddresses(ctx context.Context, fromHeight, toHeight int) (addresses []string, err error) {
+	if toHeight <= fromHeight {
+		fromHeight = toHeight - 1
+	}
+	if toHeight-fromHeight > maxScannedBlocks {
+		return nil, nodes.ErrTooManyBlocks
+	}
+
+	seen := make(map[string]struct{})
+	add := func(address string) {
+		address = strings.ToLower(address)
+		if _, ok := seen[address]; !ok {
+			seen[address] = struct{}{}
+			addresses = append(addresses, address)
+		}
+	}
+	for height := fromHeight + 1; height <= toHeight; height++ {
+		if height < 0 {
+			continue
+		}
+
+		var block struct {
+			Transactions []struct {
+				From string  `json:"from"`
+				To   *string `json:"to"`
+			} `json:"transactions"`
+		}
+		err = node.doRPCCall(ctx, "eth_getBlockByNumber", &block, hexutil.EncodeUint64(uint64(height)), true)
+		if err != nil {
+			err = wrapNodeErr(err, "block query failed")
+			return
+		}
+
+		for _, tx := range block.Transactions {
+			add(tx.From)
+			// contract creation txs have no recipient
+			if tx.To != nil {
+				add(*tx.To)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package eth
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestEth(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Eth Suite")
+}
+
+var _ = Describe("testing addresses validation", func() {
+	node := &ethNode{}
+
+	// EIP-55 test vectors
+	for _, address := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+		"0x52908400098527886E0F7030069857D2E4169EE7",
+		"0x8617E340B3D01FA5F11F306F4090FD50E238070D",
+		"0xde709f2102306220921060314715629080e2fb77",
+		"0x27b1fdb04752bbc536007a920d24acb045561c26",
+	} {
+		address := address
+		It("should accept "+address, func() {
+			Expect(node.ValidateAddress(address)).To(Succeed())
+		})
+	}
+
+	It("should compute EIP-55 checksum of lower-case address", func() {
+		Expect(toChecksumAddress("5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")).To(
+			Equal("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"),
+		)
+	})
+
+	for _, v := range []struct {
+		address, reason string
+	}{
+		{"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "wrong checksum"},
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "wrong checksum"},
+		{"5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "missing prefix"},
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", "short address"},
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAedAA", "long address"},
+		{"0xde709f2102306220921060314715629080e2fbzz", "non-hex characters"},
+	} {
+		v := v
+		It("should reject "+v.address+" due to "+v.reason, func() {
+			Expect(node.ValidateAddress(v.address)).To(Equal(nodes.ErrAddressInvalid))
+		})
+	}
+})
+
+var _ = Describe("testing EIP-681 payment uris", func() {
+	const address = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	mainnetNode := &ethNode{chainID: "1"}
+	anyChainNode := &ethNode{}
+
+	It("should render amount in wei and chain id, label is ignored", func() {
+		Expect(mainnetNode.EncodePaymentURI(nodes.PaymentURI{
+			Address: address, Amount: decimal.New(15, 1), Label: "ignored",
+		})).To(Equal("ethereum:" + address + "@1?value=1500000000000000000"))
+	})
+
+	It("should render address only if node chain id is unknown", func() {
+		Expect(anyChainNode.EncodePaymentURI(nodes.PaymentURI{Address: address})).To(Equal("ethereum:" + address))
+	})
+
+	for _, c := range []struct {
+		node   *ethNode
+		uri    string
+		amount *decimal.Big
+	}{
+		{mainnetNode, "ethereum:" + address, nil},
+		{mainnetNode, "ethereum:pay-" + address + "@1?value=2.014e18", decimal.New(2014, 3)},
+		{mainnetNode, "Ethereum:" + address + "?value=1", decimal.New(1, 18)},
+		{anyChainNode, "ethereum:" + address + "@3?value=5000000000000000000", decimal.New(5, 0)},
+	} {
+		c := c
+		It("should parse "+c.uri, func() {
+			uri, err := c.node.DecodePaymentURI(c.uri)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri.Address).To(Equal(address))
+			if c.amount == nil {
+				Expect(uri.Amount).To(BeNil())
+			} else {
+				Expect(uri.Amount.Cmp(c.amount)).To(BeZero())
+			}
+		})
+	}
+
+	for _, c := range []struct {
+		uri, reason string
+		err         error
+	}{
+		{"bitcoin:1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", "BTC scheme", nodes.ErrPaymentURIScheme},
+		{"ethereum:" + address + "@3", "another chain", nodes.ErrPaymentURIInvalid},
+		{
+			"ethereum:0x89205a3a3b2a69de6dbf7f01ed13b2108b2c43e7/transfer?address=" + address + "&uint256=1",
+			"token transfer",
+			nodes.ErrPaymentURIInvalid,
+		},
+		{"ethereum:vitalik.eth?value=1", "ENS name", nodes.ErrPaymentURIInvalid},
+		{"ethereum:" + address + "?value=-1", "negative value", nodes.ErrPaymentURIInvalid},
+	} {
+		c := c
+		It("should reject uri due to "+c.reason, func() {
+			_, err := mainnetNode.DecodePaymentURI(c.uri)
+			Expect(err).To(Equal(c.err))
+		})
+	}
+})
@@ -39,17 +39,21 @@ var netTypes = map[string]string{
 
 //
 func (n *netIdT) UnmarshalJSON(data []byte) error {
-	sData := strings.Replace(string(data), `"`, "", -1)
-	val, ok := netTypes[sData]
+	*n = netIdT(strings.Replace(string(data), `"`, "", -1))
+	return nil
+}
+
+// String returns network name
+func (n netIdT) String() string {
+	val, ok := netTypes[string(n)]
 	if !ok {
-		val = fmt.Sprintf("Unknown(%s)", sData)
+		val = fmt.Sprintf("Unknown(%s)", string(n))
 	}
-	*n = netIdT(val)
-	return nil
+	return val
 }
 
 func (n *netIdT) IsTestNet() bool {
-	return string(*n) != "Ethereum Mainnet"
+	return string(*n) != "1"
 }
 
 // ethNode
@@ -60,6 +64,8 @@ type ethNode struct {
 	rpcClient         jsonrpc.RPCClient
 	httpClient        *http.Client
 	needConfirmations int
+	// chainID is the network id, which is equal to chain id for all known networks
+	chainID string
 
 	subscriber func(ctx context.Context, blockHeight int) error
 
@@ -129,6 +135,7 @@ func Dial(
 		}
 		return nil, wrapNodeErr(err)
 	}
+	node.chainID = string(netId)
 
 	return node, nil
 }
@@ -329,6 +336,72 @@ func (node *ethNode) GetIncoming(ctx context.Context) (txs []nodes.IncomingTxDes
 	return
 }
 
+// WatchAddress implements ITxsObserver, any address balance and history are available through node and etherscan, so
+// it does nothing
+func (node *ethNode) WatchAddress(ctx context.Context, address string) error {
+	return nil
+}
+
+// GetAddressTxs implements ITxsObserver using etherscan txlist call, outgoing txs amounts include used gas cost, failed
+// txs only cost gas
+func (node *ethNode) GetAddressTxs(ctx context.Context, address string) (txs []nodes.AddressTxDescr, err error) {
+	var res []struct {
+		Confirmations int    `json:"confirmations,string"`
+		Value         string `json:"value"`
+		GasUsed       string `json:"gasUsed"`
+		GasPrice      string `json:"gasPrice"`
+		IsErr         int    `json:"isError,string"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Hash          string `json:"hash"`
+	}
+	params := map[string]interface{}{"address": address}
+	err = node.doESCall(ctx, "account", "txlist", &res, params)
+	// if rate limit error occurs, repeat it with 1 sec delay
+	for err == errESRateLimit {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			err = node.doESCall(ctx, "account", "txlist", &res, params)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	for _, tDescr := range res {
+		value, gasUsed, gasPrice := new(big.Int), new(big.Int), new(big.Int)
+		if _, ok := value.SetString(tDescr.Value, 10); !ok {
+			return nil, wrapNodeErr(fmt.Errorf("escan: invalid tx %s value", tDescr.Hash))
+		}
+		gasUsed.SetString(tDescr.GasUsed, 10)
+		gasPrice.SetString(tDescr.GasPrice, 10)
+		if tDescr.IsErr == 1 {
+			value.SetInt64(0)
+		}
+
+		change := new(big.Int)
+		if strings.EqualFold(tDescr.To, address) {
+			change.Add(change, value)
+		}
+		if strings.EqualFold(tDescr.From, address) {
+			change.Sub(change, value)
+			change.Sub(change, new(big.Int).Mul(gasUsed, gasPrice))
+		}
+		if change.Sign() == 0 {
+			continue
+		}
+
+		txs = append(txs, nodes.AddressTxDescr{
+			Hash:      tDescr.Hash,
+			Confirmed: tDescr.Confirmations >= node.needConfirmations,
+			Amount:    new(decimal.Big).SetBigMantScale(change, weiOrderOfNumber),
+		})
+	}
+	return
+}
+
 // Send
 func (node *ethNode) Send(ctx context.Context, fromAddress, toAddress string, amount *decimal.Big, secret string) (txHash string, fee *decimal.Big, err error) {
 	// unlock wallet first
@@ -0,0 +1,67 @@
+package eth
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"net/url"
+	"strings"
+)
+
+// interfaces compile-time validations
+var _ nodes.IPaymentURICodec = (*ethNode)(nil)
+
+const uriScheme = "ethereum"
+
+// EncodePaymentURI implements IPaymentURICodec interface, renders EIP-681 uri with chain id, amount is rendered as
+// integer wei value. EIP-681 has no label param, so label is ignored.
+func (n *ethNode) EncodePaymentURI(uri nodes.PaymentURI) (string, error) {
+	rendered := uriScheme + ":" + uri.Address
+	if n.chainID != "" {
+		rendered += "@" + n.chainID
+	}
+	if uri.Amount != nil {
+		query := url.Values{}
+		query.Set("value", nodes.FormatPaymentAmount(convertToWei(uri.Amount).RoundToInt()))
+		rendered += "?" + nodes.EncodePaymentQuery(query)
+	}
+	return rendered, nil
+}
+
+// DecodePaymentURI implements IPaymentURICodec interface, parses EIP-681 uri of plain ether transfer. Uris which
+// calls contract functions (e.g. ERC-20 transfer) are rejected since node doesn't handle tokens, as well as uris of
+// another chain.
+func (n *ethNode) DecodePaymentURI(rawURI string) (uri nodes.PaymentURI, err error) {
+	scheme, target, query, err := nodes.SplitPaymentURI(rawURI)
+	if err != nil {
+		return
+	}
+	if scheme != uriScheme {
+		return uri, nodes.ErrPaymentURIScheme
+	}
+
+	// target is "[pay-]address[@chain_id][/function_name]"
+	target = strings.TrimPrefix(target, "pay-")
+	if strings.IndexByte(target, '/') >= 0 {
+		return uri, nodes.ErrPaymentURIInvalid
+	}
+	if chainPos := strings.IndexByte(target, '@'); chainPos >= 0 {
+		chainID := target[chainPos+1:]
+		if n.chainID != "" && chainID != n.chainID {
+			return uri, nodes.ErrPaymentURIInvalid
+		}
+		target = target[:chainPos]
+	}
+	// ENS names aren't supported
+	if !strings.HasPrefix(target, "0x") {
+		return uri, nodes.ErrPaymentURIInvalid
+	}
+	uri.Address = target
+
+	wei, err := nodes.ParsePaymentAmount(query.Get("value"))
+	if err != nil {
+		return
+	}
+	if wei != nil {
+		uri.Amount = convertToEth(wei)
+	}
+	return
+}
@@ -25,6 +25,54 @@ func (_m *ICoordinator) AccountObserver(coinName string) nodes.IAccountObserver
 	return r0
 }
 
+// AddressValidator provides a mock function with given fields: coinName
+func (_m *ICoordinator) AddressValidator(coinName string) nodes.IAddressValidator {
+	ret := _m.Called(coinName)
+
+	var r0 nodes.IAddressValidator
+	if rf, ok := ret.Get(0).(func(string) nodes.IAddressValidator); ok {
+		r0 = rf(coinName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(nodes.IAddressValidator)
+		}
+	}
+
+	return r0
+}
+
+// BatchTxsSender provides a mock function with given fields: coinName
+func (_m *ICoordinator) BatchTxsSender(coinName string) nodes.IBatchTxSender {
+	ret := _m.Called(coinName)
+
+	var r0 nodes.IBatchTxSender
+	if rf, ok := ret.Get(0).(func(string) nodes.IBatchTxSender); ok {
+		r0 = rf(coinName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(nodes.IBatchTxSender)
+		}
+	}
+
+	return r0
+}
+
+// BlocksObserver provides a mock function with given fields: coinName
+func (_m *ICoordinator) BlocksObserver(coinName string) nodes.IBlocksObserver {
+	ret := _m.Called(coinName)
+
+	var r0 nodes.IBlocksObserver
+	if rf, ok := ret.Get(0).(func(string) nodes.IBlocksObserver); ok {
+		r0 = rf(coinName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(nodes.IBlocksObserver)
+		}
+	}
+
+	return r0
+}
+
 // Close provides a mock function with given fields:
 func (_m *ICoordinator) Close() error {
 	ret := _m.Called()
@@ -39,6 +87,22 @@ func (_m *ICoordinator) Close() error {
 	return r0
 }
 
+// Coins provides a mock function with given fields:
+func (_m *ICoordinator) Coins() []string {
+	ret := _m.Called()
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
 // Dial provides a mock function with given fields: coinName, host, user, pass, testnet, additionalParams
 func (_m *ICoordinator) Dial(coinName string, host string, user string, pass string, testnet bool, additionalParams map[string]interface{}) error {
 	ret := _m.Called(coinName, host, user, pass, testnet, additionalParams)
@@ -85,6 +149,22 @@ func (_m *ICoordinator) Observer(coinName string) nodes.IWalletObserver {
 	return r0
 }
 
+// PaymentURICodec provides a mock function with given fields: coinName
+func (_m *ICoordinator) PaymentURICodec(coinName string) nodes.IPaymentURICodec {
+	ret := _m.Called(coinName)
+
+	var r0 nodes.IPaymentURICodec
+	if rf, ok := ret.Get(0).(func(string) nodes.IPaymentURICodec); ok {
+		r0 = rf(coinName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(nodes.IPaymentURICodec)
+		}
+	}
+
+	return r0
+}
+
 // TxsObserver provides a mock function with given fields: coinName
 func (_m *ICoordinator) TxsObserver(coinName string) nodes.ITxsObserver {
 	ret := _m.Called(coinName)
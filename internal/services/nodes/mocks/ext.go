@@ -57,6 +57,22 @@ func (c *ICoordinator) GetTxsSender(coinName string) (ts *ITxSender) {
 	return
 }
 
+func (c *ICoordinator) GetBatchTxsSender(coinName string) (bs *IBatchTxSender) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			if isMockPanic(r) {
+				bs = &IBatchTxSender{}
+				c.On("BatchTxsSender", coinName).Return(bs)
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	bs = c.BatchTxsSender(coinName).(*IBatchTxSender)
+	return
+}
 
 func (wo *IWalletObserver) SetAddressBalance(address string, amount *decimal.Big) {
 	wo.On("Balance", mock.Anything, address).Return(amount, nil)
@@ -0,0 +1,90 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ericlagergren/decimal"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrPaymentURIScheme returned when payment uri scheme belongs to another coin
+	ErrPaymentURIScheme = errors.New("payment uri: scheme doesn't belong to the coin")
+
+	// ErrPaymentURIInvalid returned when payment uri of the coin scheme is malformed or can't be paid by the coin
+	ErrPaymentURIInvalid = errors.New("payment uri: invalid uri")
+)
+
+// PaymentURI describes payment encoded into uri, amount and label are optional
+type PaymentURI struct {
+	Address string
+	Amount  *decimal.Big
+	Label   string
+}
+
+// IPaymentURICodec encodes and decodes coin standard payment uris, such as BIP21
+type IPaymentURICodec interface {
+	// EncodePaymentURI renders payment uri, amount is given in default coin units
+	EncodePaymentURI(uri PaymentURI) (string, error)
+
+	// DecodePaymentURI parses payment uri, returns ErrPaymentURIScheme if uri scheme belongs to another coin and
+	// ErrPaymentURIInvalid if uri is malformed. Address isn't validated.
+	DecodePaymentURI(uri string) (PaymentURI, error)
+}
+
+// SplitPaymentURI splits uri of "scheme:target?query" form, scheme is lower-cased
+func SplitPaymentURI(uri string) (scheme, target string, query url.Values, err error) {
+	sepPos := strings.IndexByte(uri, ':')
+	if sepPos <= 0 {
+		return "", "", nil, ErrPaymentURIInvalid
+	}
+	scheme, target = strings.ToLower(uri[:sepPos]), uri[sepPos+1:]
+
+	rawQuery := ""
+	if queryPos := strings.IndexByte(target, '?'); queryPos >= 0 {
+		target, rawQuery = target[:queryPos], target[queryPos+1:]
+	}
+	query, err = url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", nil, ErrPaymentURIInvalid
+	}
+	return
+}
+
+// ParsePaymentAmount parses non-negative decimal amount param, returns nil amount if param is empty
+func ParsePaymentAmount(param string) (*decimal.Big, error) {
+	if param == "" {
+		return nil, nil
+	}
+	amount, ok := new(decimal.Big).SetString(param)
+	if !ok || amount.IsNaN(0) || amount.IsInf(0) || amount.Sign() < 0 {
+		return nil, ErrPaymentURIInvalid
+	}
+	return amount, nil
+}
+
+// FormatPaymentAmount renders amount in plain decimal notation without trailing zeros
+func FormatPaymentAmount(amount *decimal.Big) string {
+	return fmt.Sprintf("%f", new(decimal.Big).Copy(amount).Reduce())
+}
+
+// EncodePaymentQuery renders uri query params, spaces are percent-encoded as uri schemes require
+func EncodePaymentQuery(query url.Values) string {
+	return strings.Replace(query.Encode(), "+", "%20", -1)
+}
+
+// retErrPaymentURICodec returns error on each call
+type retErrPaymentURICodec struct {
+	e error
+}
+
+// EncodePaymentURI implements IPaymentURICodec
+func (c retErrPaymentURICodec) EncodePaymentURI(uri PaymentURI) (string, error) {
+	return "", c.e
+}
+
+// DecodePaymentURI implements IPaymentURICodec
+func (c retErrPaymentURICodec) DecodePaymentURI(uri string) (PaymentURI, error) {
+	return PaymentURI{}, c.e
+}
@@ -20,6 +20,15 @@ type IncomingTxDescr struct {
 	Amount    *decimal.Big
 }
 
+// AddressTxDescr describes tx which affects address balance
+type AddressTxDescr struct {
+	Hash      string
+	Confirmed bool
+
+	// Amount is positive for incoming and negative for outgoing txs, outgoing amount includes fee
+	Amount *decimal.Big
+}
+
 // ITxsObserver used to observe transaction usually by their hash
 type ITxsObserver interface {
 	// IsConfirmed query tx confirmations count by tx hash and decides if tx confirmed or not, also returns flag which
@@ -29,6 +38,14 @@ type ITxsObserver interface {
 
 	// GetIncoming
 	GetIncoming(ctx context.Context) (txs []IncomingTxDescr, err error)
+
+	// WatchAddress starts observing address which isn't controlled by the node, so it's balance and txs history
+	// become available, history may be collected in background. Returns ErrAddressInvalid if address is invalid.
+	WatchAddress(ctx context.Context, address string) error
+
+	// GetAddressTxs returns history of txs which affect address balance including spending ones, address must be
+	// watched.
+	GetAddressTxs(ctx context.Context, address string) (txs []AddressTxDescr, err error)
 }
 
 // ITxSender sends transaction from specified address
@@ -41,6 +58,20 @@ type ITxSender interface {
 	Send(ctx context.Context, fromAddress, toAddress string, amount *decimal.Big, secret string) (txHash string, fee *decimal.Big, err error)
 }
 
+// TxOutput describes single recipient of multi-output transaction
+type TxOutput struct {
+	Address string
+	Amount  *decimal.Big
+}
+
+// IBatchTxSender sends single transaction which pays to multiple recipients
+type IBatchTxSender interface {
+	// SendBatch sends transaction from address with an output per each given output in default coin units, outputs to
+	// the same address are merged. Returns selected fee and new transaction hash. If any of addresses is invalid,
+	// returns ErrAddressInvalid.
+	SendBatch(ctx context.Context, fromAddress string, outputs []TxOutput, secret string) (txHash string, fee *decimal.Big, err error)
+}
+
 // retErrTxs returns error on each call
 type retErrTxs struct {
 	e error
@@ -56,6 +87,16 @@ func (r retErrTxs) GetIncoming(ctx context.Context) (txs []IncomingTxDescr, err
 	return nil, r.e
 }
 
+// WatchAddress implements ITxsObserver
+func (r retErrTxs) WatchAddress(ctx context.Context, address string) error {
+	return r.e
+}
+
+// GetAddressTxs implements ITxsObserver
+func (r retErrTxs) GetAddressTxs(ctx context.Context, address string) (txs []AddressTxDescr, err error) {
+	return nil, r.e
+}
+
 // Send implements ITxSender
 func (r retErrTxs) Send(ctx context.Context, fromAddress, toAddress string, amount *decimal.Big, secret string) (txHash string, fee *decimal.Big, err error) {
 	return "", nil, r.e
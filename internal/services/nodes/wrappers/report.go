@@ -50,6 +50,10 @@ func (c *coordinatorMultiWrapper) Close() error {
 	})
 }
 
+func (c *coordinatorMultiWrapper) Coins() []string {
+	return c.coordinator.Coins()
+}
+
 func (c *coordinatorMultiWrapper) WatcherLoop(coinName string) (nodes.IWatcherLoop, error) {
 	return c.coordinator.WatcherLoop(coinName)
 }
@@ -74,6 +78,34 @@ func (c *coordinatorMultiWrapper) TxsSender(coinName string) nodes.ITxSender {
 	return &multiWrapper{ITxSender: c.coordinator.TxsSender(coinName), coin: coinName, reporter: c.reporter}
 }
 
+// AddressValidator doesn't wrap validator since invalid address is the user error rather then node failure
+func (c *coordinatorMultiWrapper) AddressValidator(coinName string) nodes.IAddressValidator {
+	return c.coordinator.AddressValidator(coinName)
+}
+
+// BatchTxsSender wraps multi-output tx sender only if coin supports it
+func (c *coordinatorMultiWrapper) BatchTxsSender(coinName string) nodes.IBatchTxSender {
+	sender := c.coordinator.BatchTxsSender(coinName)
+	if sender == nil {
+		return nil
+	}
+	return &multiWrapper{IBatchTxSender: sender, coin: coinName, reporter: c.reporter}
+}
+
+// BlocksObserver wraps blocks observer only if coin supports it
+func (c *coordinatorMultiWrapper) BlocksObserver(coinName string) nodes.IBlocksObserver {
+	observer := c.coordinator.BlocksObserver(coinName)
+	if observer == nil {
+		return nil
+	}
+	return &multiWrapper{IBlocksObserver: observer, coin: coinName, reporter: c.reporter}
+}
+
+// PaymentURICodec doesn't wrap codec since it works locally and it's errors are caused by user input
+func (c *coordinatorMultiWrapper) PaymentURICodec(coinName string) nodes.IPaymentURICodec {
+	return c.coordinator.PaymentURICodec(coinName)
+}
+
 // reportWrapper
 type multiWrapper struct {
 	reporter sentry.IReporter
@@ -85,6 +117,8 @@ type multiWrapper struct {
 	nodes.ITxSender
 	nodes.ITxsObserver
 	nodes.IWatcherLoop
+	nodes.IBatchTxSender
+	nodes.IBlocksObserver
 }
 
 func (w *multiWrapper) getTags() map[string]string {
@@ -148,6 +182,27 @@ func (w *multiWrapper) Send(
 	return
 }
 
+func (w *multiWrapper) SendBatch(
+	ctx context.Context,
+	fromAddress string,
+	outputs []nodes.TxOutput,
+	secret string,
+) (txHash string, fee *decimal.Big, err error) {
+	w.safeInvoke(func() error {
+		txHash, fee, err = w.IBatchTxSender.SendBatch(ctx, fromAddress, outputs, secret)
+		return err
+	})
+	return
+}
+
+func (w *multiWrapper) ChangedAddresses(ctx context.Context, fromHeight, toHeight int) (addresses []string, err error) {
+	w.safeInvoke(func() error {
+		addresses, err = w.IBlocksObserver.ChangedAddresses(ctx, fromHeight, toHeight)
+		return err
+	})
+	return
+}
+
 func (w *multiWrapper) IsConfirmed(ctx context.Context, hash string) (confirmed, abandoned bool, err error) {
 	w.safeInvoke(func() error {
 		confirmed, abandoned, err = w.ITxsObserver.IsConfirmed(ctx, hash)
@@ -163,3 +218,19 @@ func (w *multiWrapper) GetIncoming(ctx context.Context) (txs []nodes.IncomingTxD
 	})
 	return
 }
+
+func (w *multiWrapper) WatchAddress(ctx context.Context, address string) (err error) {
+	w.safeInvoke(func() error {
+		err = w.ITxsObserver.WatchAddress(ctx, address)
+		return err
+	})
+	return
+}
+
+func (w *multiWrapper) GetAddressTxs(ctx context.Context, address string) (txs []nodes.AddressTxDescr, err error) {
+	w.safeInvoke(func() error {
+		txs, err = w.ITxsObserver.GetAddressTxs(ctx, address)
+		return err
+	})
+	return
+}
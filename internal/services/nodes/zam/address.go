@@ -0,0 +1,18 @@
+package zam
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/andskur/go/strkey"
+)
+
+// interfaces compile-time validations
+var _ nodes.IAddressValidator = (*zamNode)(nil)
+
+// ValidateAddress implements IAddressValidator interface, address must be stellar account id strkey ("G..." key)
+func (n *zamNode) ValidateAddress(address string) error {
+	_, err := strkey.Decode(strkey.VersionByteAccountID, address)
+	if err != nil {
+		return nodes.ErrAddressInvalid
+	}
+	return nil
+}
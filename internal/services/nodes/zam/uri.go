@@ -0,0 +1,76 @@
+package zam
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/andskur/go/build"
+	"net/url"
+)
+
+// interfaces compile-time validations
+var _ nodes.IPaymentURICodec = (*zamNode)(nil)
+
+const (
+	uriScheme    = "web+stellar"
+	uriOperation = "pay"
+	// maxMsgLen is SEP-7 limit of the msg param
+	maxMsgLen = 300
+)
+
+// EncodePaymentURI implements IPaymentURICodec interface, renders SEP-7 pay operation uri of the node asset, label
+// is passed as the msg param. Network passphrase is specified only for test network, as SEP-7 assumes public network
+// by default.
+func (n *zamNode) EncodePaymentURI(uri nodes.PaymentURI) (string, error) {
+	query := url.Values{}
+	query.Set("destination", uri.Address)
+	if uri.Amount != nil {
+		query.Set("amount", nodes.FormatPaymentAmount(uri.Amount))
+	}
+	query.Set("asset_code", n.assetName)
+	query.Set("asset_issuer", n.issuerPublicKey)
+	if uri.Label != "" {
+		label := []rune(uri.Label)
+		if len(label) > maxMsgLen {
+			label = label[:maxMsgLen]
+		}
+		query.Set("msg", string(label))
+	}
+	if n.network.Passphrase != build.PublicNetwork.Passphrase {
+		query.Set("network_passphrase", n.network.Passphrase)
+	}
+	return uriScheme + ":" + uriOperation + "?" + nodes.EncodePaymentQuery(query), nil
+}
+
+// DecodePaymentURI implements IPaymentURICodec interface, parses SEP-7 pay operation uri, which asset and network
+// must be the node ones
+func (n *zamNode) DecodePaymentURI(rawURI string) (uri nodes.PaymentURI, err error) {
+	scheme, operation, query, err := nodes.SplitPaymentURI(rawURI)
+	if err != nil {
+		return
+	}
+	if scheme != uriScheme {
+		return uri, nodes.ErrPaymentURIScheme
+	}
+	if operation != uriOperation || query.Get("destination") == "" {
+		return uri, nodes.ErrPaymentURIInvalid
+	}
+
+	// native lumens payment hasn't asset params
+	if query.Get("asset_code") != n.assetName || query.Get("asset_issuer") != n.issuerPublicKey {
+		return uri, nodes.ErrPaymentURIInvalid
+	}
+	passphrase := query.Get("network_passphrase")
+	if passphrase == "" {
+		passphrase = build.PublicNetwork.Passphrase
+	}
+	if passphrase != n.network.Passphrase {
+		return uri, nodes.ErrPaymentURIInvalid
+	}
+
+	uri.Address = query.Get("destination")
+	uri.Amount, err = nodes.ParsePaymentAmount(query.Get("amount"))
+	if err != nil {
+		return
+	}
+	uri.Label = query.Get("msg")
+	return
+}
@@ -0,0 +1,138 @@
+package zam
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"github.com/andskur/go/build"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/url"
+	"strings"
+)
+
+func TestZam(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Zam Suite")
+}
+
+var _ = Describe("testing addresses validation", func() {
+	node := &zamNode{}
+
+	for _, address := range []string{
+		"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H",
+		"GAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAWHF",
+	} {
+		address := address
+		It("should accept account id "+address, func() {
+			Expect(node.ValidateAddress(address)).To(Succeed())
+		})
+	}
+
+	for _, v := range []struct {
+		address, reason string
+	}{
+		{"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2A", "wrong checksum"},
+		{"SBU2RRGLXH3E5CQHTD3ODLDF2BWDCYUSSBLLZ5GNW7JXHDIYKXZWHOKR", "secret seed version byte"},
+		{"GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2", "truncated key"},
+		{"gbrpyhil2ci3fnq4bxlfmndlfjunpu2hy3zmfshonuceoasw7qc7ox2h", "lower-case key"},
+		{"", "empty address"},
+	} {
+		v := v
+		It("should reject "+v.address+" due to "+v.reason, func() {
+			Expect(node.ValidateAddress(v.address)).To(Equal(nodes.ErrAddressInvalid))
+		})
+	}
+})
+
+var _ = Describe("testing SEP-7 payment uris", func() {
+	const (
+		destination = "GAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAWHF"
+		issuer      = "GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"
+		assetQuery  = "asset_code=ZAM&asset_issuer=" + issuer
+	)
+	publicNode := &zamNode{assetName: "ZAM", issuerPublicKey: issuer, network: build.PublicNetwork}
+	testNode := &zamNode{assetName: "ZAM", issuerPublicKey: issuer, network: build.TestNetwork}
+
+	Context("when encoding", func() {
+		It("should render pay operation of the node asset with amount and message", func() {
+			Expect(publicNode.EncodePaymentURI(nodes.PaymentURI{
+				Address: destination, Amount: decimal.New(105, 1), Label: "order 42",
+			})).To(Equal(
+				"web+stellar:pay?amount=10.5&" + assetQuery + "&destination=" + destination + "&msg=order%2042",
+			))
+		})
+
+		It("should specify passphrase of test network only", func() {
+			rendered, err := testNode.EncodePaymentURI(nodes.PaymentURI{Address: destination})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal(
+				"web+stellar:pay?" + assetQuery + "&destination=" + destination + "&network_passphrase=" +
+					strings.Replace(url.QueryEscape(build.TestNetwork.Passphrase), "+", "%20", -1),
+			))
+		})
+
+		It("should truncate message to 300 characters", func() {
+			rendered, err := publicNode.EncodePaymentURI(nodes.PaymentURI{
+				Address: destination, Label: strings.Repeat("ж", 301),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			uri, err := publicNode.DecodePaymentURI(rendered)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri.Label).To(Equal(strings.Repeat("ж", 300)))
+		})
+	})
+
+	Context("when decoding", func() {
+		It("should parse destination, amount and message", func() {
+			uri, err := publicNode.DecodePaymentURI(
+				"web+stellar:pay?destination=" + destination + "&amount=120.1234567&" + assetQuery + "&msg=hello",
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri.Address).To(Equal(destination))
+			Expect(uri.Amount.Cmp(decimal.New(1201234567, 7))).To(BeZero())
+			Expect(uri.Label).To(Equal("hello"))
+		})
+
+		It("should parse test network uri by test node", func() {
+			rendered, err := testNode.EncodePaymentURI(nodes.PaymentURI{Address: destination, Amount: decimal.New(1, 0)})
+			Expect(err).NotTo(HaveOccurred())
+
+			uri, err := testNode.DecodePaymentURI(rendered)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri.Address).To(Equal(destination))
+			Expect(uri.Amount.Cmp(decimal.New(1, 0))).To(BeZero())
+
+			_, err = publicNode.DecodePaymentURI(rendered)
+			Expect(err).To(Equal(nodes.ErrPaymentURIInvalid))
+		})
+
+		for _, c := range []struct {
+			reason, uri string
+			err         error
+		}{
+			{"BTC scheme", "bitcoin:1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", nodes.ErrPaymentURIScheme},
+			{"tx operation", "web+stellar:tx?xdr=AAAA&" + assetQuery, nodes.ErrPaymentURIInvalid},
+			{"missing destination", "web+stellar:pay?amount=1&" + assetQuery, nodes.ErrPaymentURIInvalid},
+			{"native lumens", "web+stellar:pay?destination=" + destination + "&amount=1", nodes.ErrPaymentURIInvalid},
+			{
+				"another issuer",
+				"web+stellar:pay?destination=" + destination + "&asset_code=ZAM&asset_issuer=" + destination,
+				nodes.ErrPaymentURIInvalid,
+			},
+			{
+				"invalid amount",
+				"web+stellar:pay?destination=" + destination + "&amount=ten&" + assetQuery,
+				nodes.ErrPaymentURIInvalid,
+			},
+		} {
+			c := c
+			It("should reject uri due to "+c.reason, func() {
+				_, err := publicNode.DecodePaymentURI(c.uri)
+				Expect(err).To(Equal(c.err))
+			})
+		}
+	})
+})
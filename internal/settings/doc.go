@@ -0,0 +1,3 @@
+// Package settings defines per-user preferences: preferred fiat currency balances are represented in, coins user has
+// opted out of and kinds of events user wants to be notified about
+package settings
@@ -0,0 +1,107 @@
+package settings
+
+import (
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+// Settings holds user preferences, users who haven't stored settings have default ones
+type Settings struct {
+	UserPhone string `gorm:"primary_key"`
+
+	// FiatCurrency is upper-cased currency balances are represented in if client doesn't specify one, nil if user
+	// has no preferred currency
+	FiatCurrency *string
+
+	// HiddenCoins short names of coins user has opted out of, wallets of such coins aren't created on registration
+	HiddenCoins pq.StringArray `gorm:"type:varchar(16)[]"`
+
+	// Notify* are the kinds of events user wants to be notified about
+	NotifyTxs      bool
+	NotifyRequests bool
+	NotifyInvoices bool
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Settings) TableName() string {
+	return "user_settings"
+}
+
+// IsCoinHidden checks whether user has opted out of the coin, coin name is case insensitive
+func (s *Settings) IsCoinHidden(coinName string) bool {
+	for _, hidden := range s.HiddenCoins {
+		if strings.EqualFold(hidden, coinName) {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferredFiat returns lower-cased preferred fiat currency, empty if there is no such
+func (s *Settings) PreferredFiat() string {
+	if s.FiatCurrency == nil {
+		return ""
+	}
+	return strings.ToLower(*s.FiatCurrency)
+}
+
+// Default returns settings of the user who hasn't stored any
+func Default(userPhone string) *Settings {
+	return &Settings{
+		UserPhone:      userPhone,
+		HiddenCoins:    pq.StringArray{},
+		NotifyTxs:      true,
+		NotifyRequests: true,
+		NotifyInvoices: true,
+	}
+}
+
+// UpdateParams describes settings changes, nil fields are left unchanged
+type UpdateParams struct {
+	// FiatCurrency preferred currency, empty value resets it
+	FiatCurrency *string
+
+	// HiddenCoins replaces the list of coins user has opted out of, empty non-nil list shows all coins
+	HiddenCoins []string
+
+	NotifyTxs      *bool
+	NotifyRequests *bool
+	NotifyInvoices *bool
+}
+
+// Apply changes settings according to params, hidden coins names are upper-cased and deduplicated
+func (p UpdateParams) Apply(s *Settings) {
+	if p.FiatCurrency != nil {
+		if *p.FiatCurrency == "" {
+			s.FiatCurrency = nil
+		} else {
+			fiatCurrency := strings.ToUpper(*p.FiatCurrency)
+			s.FiatCurrency = &fiatCurrency
+		}
+	}
+	if p.HiddenCoins != nil {
+		hidden := make(pq.StringArray, 0, len(p.HiddenCoins))
+		seen := make(map[string]struct{}, len(p.HiddenCoins))
+		for _, coinName := range p.HiddenCoins {
+			coinName = strings.ToUpper(coinName)
+			if _, ok := seen[coinName]; ok {
+				continue
+			}
+			seen[coinName] = struct{}{}
+			hidden = append(hidden, coinName)
+		}
+		s.HiddenCoins = hidden
+	}
+	if p.NotifyTxs != nil {
+		s.NotifyTxs = *p.NotifyTxs
+	}
+	if p.NotifyRequests != nil {
+		s.NotifyRequests = *p.NotifyRequests
+	}
+	if p.NotifyInvoices != nil {
+		s.NotifyInvoices = *p.NotifyInvoices
+	}
+}
@@ -0,0 +1,138 @@
+package settings
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/db"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/jinzhu/gorm"
+	ot "github.com/opentracing/opentracing-go"
+	"strings"
+	"time"
+)
+
+// fiatCheckCoin is the coin which rate is queried to validate fiat currency name
+const fiatCheckCoin = "BTC"
+
+// ISettings stores user preferences
+type ISettings interface {
+	// Get returns user settings, default ones are returned if user hasn't stored any. May return errs.ErrInvalidPhone.
+	Get(ctx context.Context, userPhone string) (settings *Settings, err error)
+
+	// Update changes user settings according to params. Returns convert.ErrFiatCurrencyName and errs.ErrNoSuchCoin
+	// as validation errors, may return errs.ErrInvalidPhone.
+	Update(ctx context.Context, userPhone string, params UpdateParams) (settings *Settings, err error)
+}
+
+// Store is ISettings implementation
+type Store struct {
+	database  *gorm.DB
+	converter convert.ICryptoCurrency
+}
+
+// New creates settings store, converter is used to validate preferred fiat currency
+func New(database *gorm.DB, converter convert.ICryptoCurrency) *Store {
+	return &Store{database: database, converter: converter}
+}
+
+// Get implements ISettings
+func (s *Store) Get(ctx context.Context, userPhone string) (settings *Settings, err error) {
+	err = trace.InsideSpanE(ctx, "get_user_settings", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return db.TransactionCtx(ctx, s.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			settings = new(Settings)
+			err := dbTx.Where("user_phone = ?", userPhone).First(settings).Error
+			if err == gorm.ErrRecordNotFound {
+				settings, err = Default(userPhone), nil
+			}
+			return err
+		})
+	})
+	return
+}
+
+// Update implements ISettings
+func (s *Store) Update(ctx context.Context, userPhone string, params UpdateParams) (settings *Settings, err error) {
+	err = trace.InsideSpanE(ctx, "update_user_settings", func(ctx context.Context, span ot.Span) error {
+		span.LogKV("user_phone", userPhone)
+
+		userPhone, err = wallets.CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		// gather validation errors
+		var validationErrs error
+		if params.FiatCurrency != nil && *params.FiatCurrency != "" {
+			span.LogKV("fiat_currency", *params.FiatCurrency)
+
+			// only currency name is validated, other converter errors don't prevent preference from being stored
+			_, rateErr := s.converter.GetRate(ctx, fiatCheckCoin, *params.FiatCurrency)
+			if rateErr == convert.ErrFiatCurrencyName {
+				validationErrs = merrors.Append(validationErrs, rateErr)
+			} else if rateErr != nil {
+				trace.LogErrorWithMsg(span, rateErr, "fiat currency validation skipped")
+			}
+		}
+
+		return db.TransactionCtx(ctx, s.database, func(ctx context.Context, dbTx *gorm.DB) error {
+			if len(params.HiddenCoins) > 0 {
+				known, err := knownCoins(dbTx, params.HiddenCoins)
+				if err != nil {
+					return err
+				}
+				if !known {
+					validationErrs = merrors.Append(validationErrs, errs.ErrNoSuchCoin)
+				}
+			}
+			if validationErrs != nil {
+				return validationErrs
+			}
+
+			// create settings row if there is no such, so it may be locked against concurrent updates
+			err := dbTx.Exec(
+				"insert into user_settings (user_phone) values (?) on conflict do nothing", userPhone,
+			).Error
+			if err != nil {
+				return err
+			}
+			settings = new(Settings)
+			err = dbTx.Set("gorm:query_option", "FOR UPDATE").Where("user_phone = ?", userPhone).First(settings).Error
+			if err != nil {
+				return err
+			}
+
+			params.Apply(settings)
+			settings.UpdatedAt = time.Now().UTC()
+			return dbTx.Save(settings).Error
+		})
+	})
+	return
+}
+
+// knownCoins checks that all coins with given short names exist, disabled coins are also known
+func knownCoins(dbTx *gorm.DB, coinNames []string) (bool, error) {
+	names := make([]string, 0, len(coinNames))
+	seen := make(map[string]struct{}, len(coinNames))
+	for _, coinName := range coinNames {
+		coinName = strings.ToUpper(coinName)
+		if _, ok := seen[coinName]; !ok {
+			seen[coinName] = struct{}{}
+			names = append(names, coinName)
+		}
+	}
+
+	var count int
+	err := dbTx.Model(&queries.Coin{}).Where("short_name in (?)", names).Count(&count).Error
+	return count == len(names), err
+}
@@ -0,0 +1,61 @@
+package settings_test
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/settings"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSettings(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Settings Suite")
+}
+
+var _ = Describe("testing settings update params", func() {
+	str := func(s string) *string {
+		return &s
+	}
+	boolean := func(b bool) *bool {
+		return &b
+	}
+
+	It("should leave settings unchanged if no params given", func() {
+		s := settings.Default("+79991112233")
+		settings.UpdateParams{}.Apply(s)
+		Expect(s).To(Equal(settings.Default("+79991112233")))
+	})
+
+	It("should set and reset preferred fiat currency", func() {
+		s := settings.Default("+79991112233")
+		Expect(s.PreferredFiat()).To(BeEmpty())
+
+		settings.UpdateParams{FiatCurrency: str("eur")}.Apply(s)
+		Expect(*s.FiatCurrency).To(Equal("EUR"))
+		Expect(s.PreferredFiat()).To(Equal("eur"))
+
+		settings.UpdateParams{FiatCurrency: str("")}.Apply(s)
+		Expect(s.FiatCurrency).To(BeNil())
+	})
+
+	It("should replace hidden coins deduplicating them", func() {
+		s := settings.Default("+79991112233")
+		settings.UpdateParams{HiddenCoins: []string{"eth", "BCH", "Eth"}}.Apply(s)
+		Expect([]string(s.HiddenCoins)).To(Equal([]string{"ETH", "BCH"}))
+		Expect(s.IsCoinHidden("eth")).To(BeTrue())
+		Expect(s.IsCoinHidden("BTC")).To(BeFalse())
+
+		settings.UpdateParams{HiddenCoins: []string{}}.Apply(s)
+		Expect(s.HiddenCoins).To(BeEmpty())
+		Expect(s.IsCoinHidden("eth")).To(BeFalse())
+	})
+
+	It("should change only given notifications preferences", func() {
+		s := settings.Default("+79991112233")
+		settings.UpdateParams{NotifyRequests: boolean(false)}.Apply(s)
+		Expect(s.NotifyTxs).To(BeTrue())
+		Expect(s.NotifyRequests).To(BeFalse())
+		Expect(s.NotifyInvoices).To(BeTrue())
+	})
+})
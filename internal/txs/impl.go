@@ -121,7 +121,9 @@ func addTxPreloads(q *gorm.DB) *gorm.DB {
 		"ToWallet.Coin",
 	).Preload(
 		"Status",
-	).Preload("External")
+	).Preload(
+		"External",
+	).Preload("FiatValues")
 }
 
 // applyFilters applies given filters onto q
@@ -0,0 +1,144 @@
+package wallets_test
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes/mocks"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+// historyAddresses returns wallet addresses history
+func historyAddresses(api *wallets.Api, phone string, walletID int64) []string {
+	history, err := api.GetAddresses(context.Background(), phone, walletID)
+	Expect(err).NotTo(HaveOccurred())
+
+	addresses := make([]string, 0, len(history))
+	for _, a := range history {
+		addresses = append(addresses, a.Address)
+	}
+	return addresses
+}
+
+var _ = Describe("testing wallets addresses", func() {
+	provideWalletsApi()
+
+	BeforeEachCInvoke(func(coordinator *mocks.ICoordinator) {
+		generator := &mocks.IGenerator{}
+		generator.On("Create", mock.Anything).Return("fresh-Address", "fresh-secret", nil).Once()
+		coordinator.On("Generator", testCoinName).Return(generator)
+	})
+
+	Context("when coin sends funds from the shared account", func() {
+		BeforeEachCInvoke(func(coordinator *mocks.ICoordinator) {
+			coordinator.GetTxsSender(testCoinName).SetSupportInternalTxs(true)
+		})
+
+		ItD("should rotate address keeping previous one in history", func(d *db.Db, api *wallets.Api) {
+			w := createWallet(d, userPhone, "first", false)
+
+			address, err := api.RotateAddress(context.Background(), userPhone, w.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(address.Address).To(Equal("fresh-Address"))
+
+			rotated, err := api.GetWallet(context.Background(), userPhone, w.ID, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rotated.Address).To(Equal("fresh-Address"))
+			Expect(rotated.Secret).To(Equal("fresh-secret"))
+			Expect(historyAddresses(api, userPhone, w.ID)).To(Equal([]string{w.Address, "fresh-Address"}))
+		})
+
+		ItD("should add deposit address keeping current one", func(d *db.Db, api *wallets.Api) {
+			w := createWallet(d, userPhone, "first", false)
+
+			address, err := api.NewDepositAddress(context.Background(), userPhone, w.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(address.Address).To(Equal("fresh-Address"))
+
+			current, err := api.GetWallet(context.Background(), userPhone, w.ID, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(current.Address).To(Equal(w.Address))
+			Expect(historyAddresses(api, userPhone, w.ID)).To(Equal([]string{w.Address, "fresh-Address"}))
+		})
+
+		ItD("should match wallet by any address of it's history", func(d *db.Db, api *wallets.Api) {
+			w := createWallet(d, userPhone, "first", false)
+			createWallet(d, otherPhone, "other", false)
+			_, err := api.RotateAddress(context.Background(), userPhone, w.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, address := range []string{w.Address, "fresh-Address"} {
+				names := walletsNames(d, queries.GetWalletFilters{ByAddress: address, WithArchived: true})
+				Expect(names).To(Equal([]string{"first"}), address)
+
+				custodial, err := queries.HasCustodialAddress(d, testCoinName, address)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(custodial).To(BeTrue(), address)
+			}
+
+			By("matching custodial addresses case insensitively")
+			custodial, err := queries.HasCustodialAddress(d, testCoinName, "FRESH-ADDRESS")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(custodial).To(BeTrue())
+		})
+
+		ItD("should not duplicate known address in history", func(d *db.Db, api *wallets.Api) {
+			w := createWallet(d, userPhone, "first", false)
+			for i := 0; i < 2; i++ {
+				_, err := queries.AddWalletAddress(d, w.ID, w.Address, w.Secret)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(historyAddresses(api, userPhone, w.ID)).To(Equal([]string{w.Address}))
+		})
+
+		for _, c := range []struct {
+			label    string
+			rotate   func(api *wallets.Api, walletID int64) error
+			expected error
+		}{
+			{
+				"should not rotate address of watch-only wallet",
+				func(api *wallets.Api, walletID int64) error {
+					_, err := api.RotateAddress(context.Background(), userPhone, walletID)
+					return err
+				},
+				errs.ErrAddressRotationNotSupported,
+			},
+			{
+				"should not add deposit address to watch-only wallet",
+				func(api *wallets.Api, walletID int64) error {
+					_, err := api.NewDepositAddress(context.Background(), userPhone, walletID)
+					return err
+				},
+				errs.ErrWatchOnlyWallet,
+			},
+		} {
+			c := c
+			ItD(c.label, func(d *db.Db, api *wallets.Api) {
+				w := createWallet(d, userPhone, "watched", true)
+				Expect(c.rotate(api, w.ID)).To(Equal(c.expected))
+			})
+		}
+	})
+
+	Context("when coin sends funds from wallet address", func() {
+		BeforeEachCInvoke(func(coordinator *mocks.ICoordinator) {
+			coordinator.GetTxsSender(testCoinName).SetSupportInternalTxs(false)
+		})
+
+		ItD("should not rotate address", func(d *db.Db, api *wallets.Api) {
+			w := createWallet(d, userPhone, "first", false)
+
+			_, err := api.RotateAddress(context.Background(), userPhone, w.ID)
+			Expect(err).To(Equal(errs.ErrAddressRotationNotSupported))
+			_, err = api.NewDepositAddress(context.Background(), userPhone, w.ID)
+			Expect(err).To(Equal(errs.ErrAddressRotationNotSupported))
+			Expect(historyAddresses(api, userPhone, w.ID)).To(BeEmpty())
+		})
+	})
+})
@@ -2,7 +2,6 @@ package wallets
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"git.zam.io/wallet-backend/common/pkg/merrors"
 	"git.zam.io/wallet-backend/common/pkg/types"
@@ -14,9 +13,11 @@ import (
 	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
 	"git.zam.io/wallet-backend/web-api/db"
 	"github.com/ericlagergren/decimal"
+	"github.com/jinzhu/gorm"
 	"github.com/opentracing/opentracing-go"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Api provides methods to create wallets both in blockchain and db and query them
@@ -25,11 +26,18 @@ type Api struct {
 	coordinator   nodes.ICoordinator
 	processingApi processing.IApi
 	balanceHelper helpers.IBalance
+	balanceCache  helpers.IBalanceCache
 }
 
 // NewApi create new api instance
-func NewApi(d *db.Db, coordinator nodes.ICoordinator, processingApi processing.IApi, balanceHelper helpers.IBalance) *Api {
-	return &Api{d, coordinator, processingApi, balanceHelper}
+func NewApi(
+	d *db.Db,
+	coordinator nodes.ICoordinator,
+	processingApi processing.IApi,
+	balanceHelper helpers.IBalance,
+	balanceCache helpers.IBalanceCache,
+) *Api {
+	return &Api{d, coordinator, processingApi, balanceHelper, balanceCache}
 }
 
 // CreateWallet creates wallet both in db and blockchain node and assigns actual address
@@ -48,15 +56,22 @@ func (api *Api) CreateWallet(ctx context.Context, userPhone string, coinName, wa
 	}
 
 	// coerce phone number
-	userPhone, err = coercePhoneNumber(userPhone)
+	userPhone, err = CoercePhone(userPhone)
 	if err != nil {
 		return
 	}
 
+	// use default wallet name if custom isn't specified
+	walletName = strings.TrimSpace(walletName)
+	if walletName == "" {
+		walletName = fmt.Sprintf("%s wallet", coinName)
+	}
+	span.LogKV("wallet_name", walletName)
+
 	generator := api.coordinator.Generator(coinName)
 
 	// since we wouldn't allow an user to create multiple wallets of
-	// same name here we relies onto unique user/name constraint
+	// same coin and name here we relies onto unique user/coin/name constraint
 	// so concurrent attempt to create next wallets with duplicated pairs
 	// will be locked until first occurred transaction will be committed (in such case
 	// constraint violation will occurs) or rollbacked (in such case wallet will be successfully
@@ -68,13 +83,22 @@ func (api *Api) CreateWallet(ctx context.Context, userPhone string, coinName, wa
 	//
 	// TODO commit may be failed due to connection issues (for example), so wallet address will be generated, but no appropriate record occurs
 	err = api.database.Tx(func(tx db.ITx) (err error) {
+		err = queries.LockUserPhones(tx, userPhone)
+		if err != nil {
+			return
+		}
+		err = CheckAccountOpen(tx, userPhone, errs.ErrAccountClosed)
+		if err != nil {
+			return
+		}
+
 		wallet.Wallet, err = queries.CreateWallet(
 			tx, queries.Wallet{
 				UserPhone: userPhone,
 				Coin: queries.Coin{
 					ShortName: coinName,
 				},
-				Name: fmt.Sprintf("%s wallet", coinName),
+				Name: walletName,
 			},
 		)
 		if err != nil {
@@ -102,7 +126,12 @@ func (api *Api) CreateWallet(ctx context.Context, userPhone string, coinName, wa
 				Address: &wallet.Address,
 				Secret:  &wallet.Secret,
 			})
+		if err != nil {
+			return
+		}
 
+		// also start wallet addresses history
+		_, err = queries.AddWalletAddress(tx, wallet.ID, wallet.Address, wallet.Secret)
 		return
 	})
 
@@ -116,11 +145,111 @@ func (api *Api) CreateWallet(ctx context.Context, userPhone string, coinName, wa
 	return
 }
 
-// GetWallet returns wallet of given id
-func (api *Api) GetWallet(ctx context.Context, userPhone string, walletID int64) (wallet WalletWithBalance, err error) {
+// ImportWatchOnlyWallet creates watch-only wallet which tracks external address balance, such wallet can't send funds
+// and never becomes default one. Returns ErrInvalidAddress if address is malformed, ErrWatchOnlyNotSupported if coin
+// can't observe external addresses, ErrCustodialAddress if address belongs to any wallet controlled by the service,
+// also may return ErrNoSuchCoin and ErrWalletCreationRejected.
+func (api *Api) ImportWatchOnlyWallet(ctx context.Context, userPhone string, coinName, walletName, address string) (
+	wallet WalletWithBalance, err error,
+) {
+	err = trace.InsideSpanE(ctx, "importing_watch_only_wallet", func(ctx context.Context, span opentracing.Span) error {
+		// uppercase coin name because everywhere coin short name used in such format
+		coinName = strings.ToUpper(coinName)
+		span.LogKV("user_phone", userPhone, "coin_name", coinName, "address", address)
+
+		// validate coin name
+		_, err := queries.GetCoin(api.database, coinName)
+		if err != nil {
+			return err
+		}
+
+		// coerce phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		err = api.validateCoinAddress(coinName, address)
+		if err != nil {
+			return err
+		}
+
+		// custodial addresses are stored without prefix, so other users addresses can't be imported either way
+		unprefixed := address
+		if sepPos := strings.IndexByte(address, ':'); sepPos >= 0 {
+			unprefixed = address[sepPos+1:]
+		}
+		custodial, err := queries.HasCustodialAddress(api.database, coinName, unprefixed)
+		if err != nil {
+			return err
+		}
+		if custodial {
+			return errs.ErrCustodialAddress
+		}
+
+		// use default wallet name if custom isn't specified
+		walletName = strings.TrimSpace(walletName)
+		if walletName == "" {
+			walletName = fmt.Sprintf("%s watch-only wallet", coinName)
+		}
+
+		// node may rescan blockchain to collect address history
+		err = trace.InsideSpanE(ctx, "watching_address", func(ctx context.Context, span opentracing.Span) error {
+			return api.coordinator.TxsObserver(coinName).WatchAddress(ctx, address)
+		})
+		switch err {
+		case nil:
+		case nodes.ErrCoinServiceNotImplemented:
+			return errs.ErrWatchOnlyNotSupported
+		case nodes.ErrAddressInvalid:
+			return errs.ErrInvalidAddress
+		default:
+			return err
+		}
+
+		err = api.database.Tx(func(tx db.ITx) (err error) {
+			err = queries.LockUserPhones(tx, userPhone)
+			if err != nil {
+				return
+			}
+			err = CheckAccountOpen(tx, userPhone, errs.ErrAccountClosed)
+			if err != nil {
+				return
+			}
+
+			wallet.Wallet, err = queries.CreateWallet(tx, queries.Wallet{
+				UserPhone: userPhone,
+				Coin:      queries.Coin{ShortName: coinName},
+				Name:      walletName,
+				Address:   address,
+				WatchOnly: true,
+			})
+			if err != nil {
+				return
+			}
+			_, err = queries.AddWalletAddress(tx, wallet.ID, address, "")
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		return trace.InsideSpanE(ctx, "querying_balance", func(ctx context.Context, span opentracing.Span) error {
+			var queryErr error
+			wallet.Balance, wallet.BalanceAsOf, queryErr = api.queryBalance(ctx, &wallet.Wallet, true)
+			return queryErr
+		})
+	})
+	return
+}
+
+// GetWallet returns wallet of given id. Wallet balance may be taken from cache unless fresh flag is set.
+func (api *Api) GetWallet(ctx context.Context, userPhone string, walletID int64, fresh bool) (
+	wallet WalletWithBalance, err error,
+) {
 	err = trace.InsideSpanE(ctx, "getting_wallet", func(ctx context.Context, span opentracing.Span) error {
 		// coerce phone number
-		userPhone, err = coercePhoneNumber(userPhone)
+		userPhone, err = CoercePhone(userPhone)
 		if err != nil {
 			return err
 		}
@@ -136,22 +265,29 @@ func (api *Api) GetWallet(ctx context.Context, userPhone string, walletID int64)
 		return trace.InsideSpanE(ctx, "querying_balance", func(ctx context.Context, span opentracing.Span) error {
 			// query actual balance
 			var queryErr error
-			wallet.Balance, queryErr = api.queryBalance(ctx, &wallet.Wallet)
+			wallet.Balance, wallet.BalanceAsOf, queryErr = api.queryBalance(ctx, &wallet.Wallet, fresh)
 			return queryErr
 		})
 	})
 	return
 }
 
-// GetWallets returns all wallets which belongs to a specific user applying filter and pagination params
-func (api *Api) GetWallets(ctx context.Context, userPhone string, onlyCoin string, fromID, count int64) (
+// GetWallets returns all wallets which belongs to a specific user applying filter and pagination params. Archived and
+// watch-only wallets are skipped unless filters say otherwise. Wallets balances may be taken from cache unless fresh
+// flag is set.
+func (api *Api) GetWallets(ctx context.Context, userPhone string, filters GetWalletsFilters, fresh bool) (
 	wts []WalletWithBalance, totalCount int64, hasNext bool, err error,
 ) {
 	err = trace.InsideSpanE(ctx, "getting_wallets", func(ctx context.Context, span opentracing.Span) error {
-		span.LogKV("user_phone", userPhone, "coin_name", onlyCoin)
+		span.LogKV(
+			"user_phone", userPhone,
+			"coin_name", filters.ByCoin,
+			"with_archived", filters.WithArchived,
+			"with_watch_only", filters.WithWatchOnly,
+		)
 
 		// coerce phone number
-		userPhone, err := coercePhoneNumber(userPhone)
+		userPhone, err := CoercePhone(userPhone)
 		if err != nil {
 			return err
 		}
@@ -159,11 +295,13 @@ func (api *Api) GetWallets(ctx context.Context, userPhone string, onlyCoin strin
 		var rawWts []queries.Wallet
 		err = api.database.Tx(func(tx db.ITx) error {
 			rawWts, totalCount, hasNext, err = queries.GetWallets(tx, queries.GetWalletFilters{
-				Enabled:   true,
-				UserPhone: userPhone,
-				ByCoin:    onlyCoin,
-				FromID:    fromID,
-				Count:     count,
+				Enabled:       true,
+				UserPhone:     userPhone,
+				ByCoin:        filters.ByCoin,
+				FromID:        filters.FromID,
+				Count:         filters.Count,
+				WithArchived:  filters.WithArchived,
+				WithWatchOnly: filters.WithWatchOnly,
 			})
 			return err
 		})
@@ -188,7 +326,7 @@ func (api *Api) GetWallets(ctx context.Context, userPhone string, onlyCoin strin
 
 					var queryErr error
 					wallet := WalletWithBalance{Wallet: rawWallet}
-					wallet.Balance, queryErr = api.queryBalance(ctx, &wallet.Wallet)
+					wallet.Balance, wallet.BalanceAsOf, queryErr = api.queryBalance(ctx, &wallet.Wallet, fresh)
 					if queryErr != nil {
 						errsChan <- queryErr
 						return
@@ -219,21 +357,479 @@ func (api *Api) ValidateCoin(coinName string) (err error) {
 	return
 }
 
+// ValidateAddress checks that address is well-formed address of the coin which wallet with given id belongs to.
+// Returns ErrInvalidAddress if it's not, may return ErrNoSuchWallet.
+func (api *Api) ValidateAddress(ctx context.Context, userPhone string, walletID int64, address string) (err error) {
+	err = trace.InsideSpanE(ctx, "validate_address", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID, "address", address)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		var wallet queries.Wallet
+		err = api.database.Tx(func(tx db.ITx) (err error) {
+			wallet, err = queries.GetWallet(tx, userPhone, walletID)
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		return api.validateCoinAddress(wallet.Coin.ShortName, address)
+	})
+	return
+}
+
+// SetDefaultWallet makes wallet default one among user wallets of the same coin, so it will receive incoming phone
+// transfers. Watch-only wallet can't receive such transfers, so ErrWatchOnlyWallet returned for it. May return
+// ErrNoSuchWallet.
+func (api *Api) SetDefaultWallet(ctx context.Context, userPhone string, walletID int64) (err error) {
+	err = trace.InsideSpanE(ctx, "set_default_wallet", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return api.database.Tx(func(tx db.ITx) error {
+			wallet, err := queries.GetWallet(tx, userPhone, walletID, true)
+			if err != nil {
+				return err
+			}
+			if wallet.WatchOnly {
+				return errs.ErrWatchOnlyWallet
+			}
+			if wallet.IsDefault {
+				return nil
+			}
+			return queries.SetDefaultWallet(tx, wallet)
+		})
+	})
+	return
+}
+
+// UpdateWallet renames wallet, changes it's display order and archives or unarchives it using non-nil params.
+// Archived wallet is hidden from wallets list but still receives funds. Wallet can't be archived while it has
+// held or pending txs, ErrWalletHasUnsettledTxs returned in such case. Also may return ErrNoSuchWallet and
+// ErrWalletUpdateRejected if wallet with such name already exists.
+func (api *Api) UpdateWallet(
+	ctx context.Context, userPhone string, walletID int64, name *string, displayOrder *int64, archived *bool,
+) (wallet WalletWithBalance, err error) {
+	err = trace.InsideSpanE(ctx, "update_wallet", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return api.database.Tx(func(tx db.ITx) error {
+			rawWallet, err := queries.GetWallet(tx, userPhone, walletID, true)
+			if err != nil {
+				return err
+			}
+
+			diff := queries.WalletDiff{Name: name, DisplayOrder: displayOrder}
+			if archived != nil && *archived != rawWallet.Archived {
+				span.LogKV("archived", *archived)
+				if *archived {
+					hasUnsettled, err := queries.HasUnsettledTxs(tx, rawWallet.ID)
+					if err != nil {
+						return err
+					}
+					if hasUnsettled {
+						return errs.ErrWalletHasUnsettledTxs
+					}
+				}
+				diff.Archived = archived
+			}
+
+			if diff.Name != nil || diff.DisplayOrder != nil || diff.Archived != nil {
+				err = queries.UpdateWallet(tx, rawWallet.ID, &diff)
+				if err != nil {
+					return err
+				}
+			}
+
+			wallet.Wallet, err = queries.GetWallet(tx, userPhone, walletID, false)
+			return err
+		})
+	})
+	if err != nil {
+		return
+	}
+
+	err = trace.InsideSpanE(ctx, "querying_balance", func(ctx context.Context, span opentracing.Span) error {
+		var queryErr error
+		wallet.Balance, wallet.BalanceAsOf, queryErr = api.queryBalance(ctx, &wallet.Wallet, false)
+		return queryErr
+	})
+	return
+}
+
+// RotateAddress derives fresh address for the wallet which becomes it's current address, previous addresses are
+// kept in wallet history and still credit deposits to the wallet. Rotation is allowed only for coins which don't send
+// funds from wallet address, otherwise ErrAddressRotationNotSupported returned. May return ErrNoSuchWallet.
+func (api *Api) RotateAddress(ctx context.Context, userPhone string, walletID int64) (
+	address queries.WalletAddress, err error,
+) {
+	err = trace.InsideSpanE(ctx, "rotate_wallet_address", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		// lock wallet row, so concurrent rotations will be serialized
+		return api.database.Tx(func(tx db.ITx) error {
+			wallet, err := queries.GetWallet(tx, userPhone, walletID, true)
+			if err != nil {
+				return err
+			}
+			err = CheckAccountOpen(tx, userPhone, errs.ErrAccountClosed)
+			if err != nil {
+				return err
+			}
+
+			// account based coins send funds from wallet address using it's secret, watch-only wallets address is
+			// external
+			if wallet.WatchOnly || !api.coordinator.TxsSender(wallet.Coin.ShortName).SupportInternalTxs() {
+				return errs.ErrAddressRotationNotSupported
+			}
+
+			var newAddress, newSecret string
+			err = trace.InsideSpanE(ctx, "wallet_generation", func(ctx context.Context, span opentracing.Span) error {
+				var err error
+				newAddress, newSecret, err = api.coordinator.Generator(wallet.Coin.ShortName).Create(ctx)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			span.LogKV("generated_address", newAddress)
+
+			// keep previous address in history, wallets created before history is introduced have no rows there
+			_, err = queries.AddWalletAddress(tx, wallet.ID, wallet.Address, wallet.Secret)
+			if err != nil {
+				return err
+			}
+			address, err = queries.AddWalletAddress(tx, wallet.ID, newAddress, newSecret)
+			if err != nil {
+				return err
+			}
+
+			return queries.UpdateWallet(tx, wallet.ID, &queries.WalletDiff{Address: &newAddress, Secret: &newSecret})
+		})
+	})
+	return
+}
+
+// NewDepositAddress derives additional address for the wallet which credits deposits to it, unlike RotateAddress the
+// wallet current address isn't changed. Used to distinguish payments, so it's allowed under the same conditions as the
+// rotation, otherwise ErrAddressRotationNotSupported returned. May return ErrNoSuchWallet and ErrWatchOnlyWallet.
+func (api *Api) NewDepositAddress(ctx context.Context, userPhone string, walletID int64) (
+	address queries.WalletAddress, err error,
+) {
+	err = trace.InsideSpanE(ctx, "new_deposit_address", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return api.database.Tx(func(tx db.ITx) error {
+			wallet, err := queries.GetWallet(tx, userPhone, walletID)
+			if err != nil {
+				return err
+			}
+			if wallet.WatchOnly {
+				return errs.ErrWatchOnlyWallet
+			}
+			err = CheckAccountOpen(tx, userPhone, errs.ErrAccountClosed)
+			if err != nil {
+				return err
+			}
+			if !api.coordinator.TxsSender(wallet.Coin.ShortName).SupportInternalTxs() {
+				return errs.ErrAddressRotationNotSupported
+			}
+
+			var newAddress, newSecret string
+			err = trace.InsideSpanE(ctx, "wallet_generation", func(ctx context.Context, span opentracing.Span) error {
+				var err error
+				newAddress, newSecret, err = api.coordinator.Generator(wallet.Coin.ShortName).Create(ctx)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			span.LogKV("generated_address", newAddress)
+
+			// wallets created before history is introduced have no rows there
+			_, err = queries.AddWalletAddress(tx, wallet.ID, wallet.Address, wallet.Secret)
+			if err != nil {
+				return err
+			}
+			address, err = queries.AddWalletAddress(tx, wallet.ID, newAddress, newSecret)
+			return err
+		})
+	})
+	return
+}
+
+// GetAddresses returns all addresses wallet owns or used to own ordered from oldest to newest. May return
+// ErrNoSuchWallet.
+func (api *Api) GetAddresses(ctx context.Context, userPhone string, walletID int64) (
+	addresses []queries.WalletAddress, err error,
+) {
+	err = trace.InsideSpanE(ctx, "get_wallet_addresses", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return api.database.Tx(func(tx db.ITx) error {
+			wallet, err := queries.GetWallet(tx, userPhone, walletID)
+			if err != nil {
+				return err
+			}
+			addresses, err = queries.GetWalletAddresses(tx, wallet.ID)
+			return err
+		})
+	})
+	return
+}
+
+// PaymentURI renders coin standard payment uri (BIP21, EIP-681, SEP-7, etc.) of the wallet address, amount and label
+// are optional. Returns ErrPaymentURINotSupported if coin has no payment uri scheme, ErrNonPositiveAmount if amount
+// isn't positive, may return ErrNoSuchWallet.
+func (api *Api) PaymentURI(ctx context.Context, userPhone string, walletID int64, amount *decimal.Big, label string) (
+	uri string, err error,
+) {
+	err = trace.InsideSpanE(ctx, "rendering_payment_uri", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID, "amount", amount)
+
+		if amount != nil && amount.Sign() <= 0 {
+			return errs.ErrNonPositiveAmount
+		}
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		var wallet queries.Wallet
+		err = api.database.Tx(func(tx db.ITx) (err error) {
+			wallet, err = queries.GetWallet(tx, userPhone, walletID)
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		uri, err = api.coordinator.PaymentURICodec(wallet.Coin.ShortName).EncodePaymentURI(nodes.PaymentURI{
+			Address: wallet.Address,
+			Amount:  amount,
+			Label:   label,
+		})
+		if err == nodes.ErrCoinServiceNotImplemented {
+			return errs.ErrPaymentURINotSupported
+		}
+		return err
+	})
+	return
+}
+
+// ParsePaymentURI decodes scanned payment uri into payment which prefills send request, coin is detected by uri
+// scheme and user default wallet of the coin is suggested as the sender. Returns ErrInvalidPaymentURI if uri scheme
+// is unknown or uri is malformed, ErrInvalidAddress if recipient address is invalid for the coin and
+// ErrNonPositiveAmount if amount is specified, but isn't positive.
+func (api *Api) ParsePaymentURI(ctx context.Context, userPhone string, rawURI string) (
+	payment ScannedPayment, err error,
+) {
+	err = trace.InsideSpanE(ctx, "parsing_payment_uri", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "uri", rawURI)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		var uri nodes.PaymentURI
+		payment.Coin, uri, err = api.decodePaymentURI(strings.TrimSpace(rawURI))
+		if err != nil {
+			return err
+		}
+		span.LogKV("coin", payment.Coin)
+
+		err = api.validateCoinAddress(payment.Coin, uri.Address)
+		if err != nil {
+			return err
+		}
+		if uri.Amount != nil && uri.Amount.Sign() <= 0 {
+			return errs.ErrNonPositiveAmount
+		}
+		payment.Recipient, payment.Amount, payment.Label = uri.Address, uri.Amount, uri.Label
+
+		// user may have no wallet of the coin, so sender is left empty
+		return api.database.Tx(func(tx db.ITx) error {
+			wts, _, _, err := queries.GetWallets(tx, queries.GetWalletFilters{
+				Enabled:     true,
+				UserPhone:   userPhone,
+				ByCoin:      payment.Coin,
+				OnlyDefault: true,
+			})
+			if err != nil {
+				return err
+			}
+			if len(wts) > 0 {
+				payment.WalletID = wts[0].ID
+			}
+			return nil
+		})
+	})
+	return
+}
+
+// decodePaymentURI finds coin which scheme payment uri belongs to and decodes it
+func (api *Api) decodePaymentURI(rawURI string) (coinName string, uri nodes.PaymentURI, err error) {
+	for _, coinName = range api.coordinator.Coins() {
+		uri, err = api.coordinator.PaymentURICodec(coinName).DecodePaymentURI(rawURI)
+		switch err {
+		case nil:
+			return
+		case nodes.ErrPaymentURIScheme, nodes.ErrCoinServiceNotImplemented:
+			continue
+		case nodes.ErrPaymentURIInvalid:
+			return "", uri, errs.ErrInvalidPaymentURI
+		default:
+			return "", uri, err
+		}
+	}
+	return "", uri, errs.ErrInvalidPaymentURI
+}
+
+// ChangePhone atomically re-keys user wallets and txs which await recipient from old phone to the new one, audit
+// record is written with given source. Txs which await new phone are delivered afterwards to moved default wallets.
+// Returns ErrPhoneChangeConflict if new phone already has wallets, ErrSamePhone if phones are equal after
+// normalization, ErrAccountClosed if old phone account is closed, may return ErrInvalidPhone.
+func (api *Api) ChangePhone(ctx context.Context, oldPhone, newPhone, source string) (
+	change queries.PhoneChange, err error,
+) {
+	err = trace.InsideSpanE(ctx, "change_user_phone", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("old_phone", oldPhone, "new_phone", newPhone, "source", source)
+
+		// coerce phone numbers
+		oldPhone, err = CoercePhone(oldPhone)
+		if err != nil {
+			return err
+		}
+		newPhone, err = CoercePhone(newPhone)
+		if err != nil {
+			return err
+		}
+		if oldPhone == newPhone {
+			return errs.ErrSamePhone
+		}
+
+		var movedWts []queries.Wallet
+		err = api.database.Tx(func(tx db.ITx) error {
+			// both phones are locked, so new phone wallets can't be created concurrently after the conflict check
+			err := queries.LockUserPhones(tx, oldPhone, newPhone)
+			if err != nil {
+				return err
+			}
+			err = CheckAccountOpen(tx, oldPhone, errs.ErrAccountClosed)
+			if err != nil {
+				return err
+			}
+
+			conflict, err := queries.HasWallets(tx, newPhone)
+			if err != nil {
+				return err
+			}
+			if conflict {
+				return errs.ErrPhoneChangeConflict
+			}
+			err = CheckAccountOpen(tx, newPhone, errs.ErrPhoneChangeConflict)
+			if err != nil {
+				return err
+			}
+
+			change, err = queries.ChangeUserPhone(tx, oldPhone, newPhone, source)
+			if err != nil {
+				return err
+			}
+
+			movedWts, _, _, err = queries.GetWallets(tx, queries.GetWalletFilters{
+				Enabled:      true,
+				UserPhone:    newPhone,
+				OnlyDefault:  true,
+				WithArchived: true,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		span.LogKV("change_id", change.ID, "wallets_num", change.WalletsNum, "txs_num", change.TxsNum)
+
+		// deliver transfers which have been waiting for the new phone, phone is changed already, so failed delivery
+		// doesn't fail the call, such txs still await recipient
+		for i := range movedWts {
+			nErr := api.processingApi.NotifyUserCreatesWallet(ctx, &movedWts[i])
+			if nErr != nil {
+				trace.LogErrorWithMsg(span, nErr, "delivering awaiting txs failed")
+			}
+		}
+		return nil
+	})
+	return
+}
+
 // SendToPhone sends internal transaction determining recipient wallet by source wallet and dest phone number. If
 // user not exists, transaction will be marked as "pending" and may be continued by `NotifyUserCreatesWallet` call.
+//
+// Transaction is sent to recipient default wallet of the source wallet coin unless non-zero toWalletID is given, such
+// wallet must belong to the recipient and be of the same coin, otherwise ErrNoSuchRecipientWallet returned. Closed
+// accounts can't send, ErrAccountClosed returned, and can't receive, ErrRecipientAccountClosed returned.
+//
+// If schedule is given, transaction is only scheduled and recipient is determined at scheduling time.
 // May return ErrNoSuchWallet.
 func (api *Api) SendToPhone(
 	ctx context.Context,
 	userPhone string,
 	walletID int64,
 	toUserPhone string,
+	toWalletID int64,
 	amount *decimal.Big,
+	schedule *processing.Schedule,
 ) (newTx *processing.Tx, err error) {
 	err = trace.InsideSpanE(ctx, "send_to_phone", func(ctx context.Context, span opentracing.Span) error {
-		span.LogKV("user_phone", userPhone, "wallet_id", walletID, "to_user_phone", toUserPhone)
+		span.LogKV(
+			"user_phone", userPhone,
+			"wallet_id", walletID,
+			"to_user_phone", toUserPhone,
+			"to_wallet_id", toWalletID,
+		)
 
 		// coerce user phone number
-		userPhone, err = coercePhoneNumber(userPhone)
+		userPhone, err = CoercePhone(userPhone)
 		if err != nil {
 			return err
 		}
@@ -241,7 +837,7 @@ func (api *Api) SendToPhone(
 		// gather validation errors
 		var validationErrs error
 		// coerce recipient phone number
-		toUserPhone, err = coercePhoneNumber(toUserPhone)
+		toUserPhone, err = CoercePhone(toUserPhone)
 		if err != nil {
 			validationErrs = merrors.Append(validationErrs, err)
 		}
@@ -271,33 +867,16 @@ func (api *Api) SendToPhone(
 			if err != nil {
 				return
 			}
-
-			// lookup destination user wallet
-			wts, _, _, err := queries.GetWallets(
-				tx,
-				queries.GetWalletFilters{Enabled: true, UserPhone: toUserPhone, ByCoin: fromWallet.Coin.ShortName},
-			)
-			if err != nil {
-				return err
+			if fromWallet.WatchOnly {
+				return errs.ErrWatchOnlyWallet
 			}
-
-			span.LogKV("dst_wallets_num", len(wts))
-
-			switch len(wts) {
-			case 0:
-				candidate = processing.NewPhoneRecipient(toUserPhone)
-				trace.LogMsg(span, "sending by phone due to recipient wallet not found")
-			case 1:
-				candidate = processing.NewWalletRecipient(&wts[0])
-				fbCandidates = append(fbCandidates, processing.NewAddressRecipient(wts[0].Address))
-				span.LogKV("dst_wallet_id", wts[0].ID)
-				trace.LogMsg(span, "sending to dst wallet")
-			default:
-				// we doesn't support multiple wallets of same coin which belongs to one user
-				return errors.New("wallets: not implemented: user same coin multi wallet not supported")
+			err = CheckAccountOpen(tx, userPhone, errs.ErrAccountClosed)
+			if err != nil {
+				return
 			}
 
-			return nil
+			candidate, fbCandidates, err = phoneRecipient(tx, span, &fromWallet, toUserPhone, toWalletID)
+			return
 		})
 		if err != nil {
 			trace.LogErrorWithMsg(span, err, "error occurs before sending")
@@ -307,28 +886,22 @@ func (api *Api) SendToPhone(
 		//logrus.Info(amount)
 		//logrus.Info(fromWallet.Secret)
 
-		return trace.InsideSpanE(ctx, "sending", func(ctx context.Context, span opentracing.Span) error {
-			var sendErr error
-			newTx, sendErr = api.processingApi.Send(
-				ctx,
-				&fromWallet,
-				candidate,
-				amount,
-				fbCandidates...,
-			)
-			return sendErr
-		})
+		var sendErr error
+		newTx, sendErr = api.sendOrSchedule(ctx, &fromWallet, candidate, amount, schedule, fbCandidates)
+		return sendErr
 	})
 	return
 }
 
-// SentToAddress
+// SentToAddress sends transaction to the address, if address belongs to some wallet in the system, transaction is
+// sent internally. If schedule is given, transaction is only scheduled.
 func (api *Api) SentToAddress(
 	ctx context.Context,
 	userPhone string,
 	walletID int64,
 	toAddress string,
 	amount *decimal.Big,
+	schedule *processing.Schedule,
 ) (newTx *processing.Tx, err error) {
 	err = trace.InsideSpanE(ctx, "send_to_address", func(ctx context.Context, span opentracing.Span) error {
 		var fromWallet queries.Wallet
@@ -336,7 +909,7 @@ func (api *Api) SentToAddress(
 		span.LogKV("user_phone", userPhone, "wallet_id", walletID, "to_address", toAddress, "amount", amount)
 
 		// coerce user phone number
-		userPhone, err = coercePhoneNumber(userPhone)
+		userPhone, err = CoercePhone(userPhone)
 		if err != nil {
 			return err
 		}
@@ -346,10 +919,10 @@ func (api *Api) SentToAddress(
 			return errs.ErrNonPositiveAmount
 		}
 
-		// decide recipient type: if an a wallet of such coin and destination address exists, hint suggest processing
-		// to use that
-		recipient := processing.NewAddressRecipient(toAddress)
-		var fbRecipients []processing.TxRecipientCandidate
+		var (
+			recipient    processing.TxRecipientCandidate
+			fbRecipients []processing.TxRecipientCandidate
+		)
 		err = api.database.Tx(func(tx db.ITx) error {
 			var err error
 			// query source wallet
@@ -357,27 +930,78 @@ func (api *Api) SentToAddress(
 			if err != nil {
 				return err
 			}
-
-			// first we need to check is that address belongs to some recipientWallet in the system so this transaction
-			// will be sent internally
-			// TODO this decision must be made inside processing but currently due to DDD principe this check stay here
-			wts, _, _, err := queries.GetWallets(
-				tx,
-				queries.GetWalletFilters{Enabled: true, ByCoin: fromWallet.Coin.ShortName, ByAddress: toAddress},
-			)
+			if fromWallet.WatchOnly {
+				return errs.ErrWatchOnlyWallet
+			}
+			err = CheckAccountOpen(tx, userPhone, errs.ErrAccountClosed)
 			if err != nil {
 				return err
 			}
 
-			// if some wallets found, use them to send internal tx
-			if len(wts) > 0 {
-				span.LogKV("to_wallet_id", wts[0].ID, "to_wallet_user_phone", wts[0].UserPhone)
-				if wts[0].UserPhone == userPhone {
-					return errs.ErrSelfTxForbidden
+			recipient, fbRecipients, err = api.addressRecipient(tx, span, &fromWallet, toAddress)
+			return err
+		})
+		if err != nil {
+			trace.LogErrorWithMsg(span, err, "error occurs before sending")
+			return err
+		}
+
+		var sendErr error
+		newTx, sendErr = api.sendOrSchedule(ctx, &fromWallet, recipient, amount, schedule, fbRecipients)
+		return sendErr
+	})
+	return
+}
+
+// SendBatch sends funds from the wallet to many recipients at once, recipient of each payout is determined like in
+// SendToPhone and SentToAddress. Payouts with invalid recipient or amount are rejected individually, their errors are
+// reported in the corresponding results, while others are sent by processing as single batch which total amount is
+// checked once. Batch is nil if all payouts are rejected.
+//
+// Closed accounts can't send, ErrAccountClosed returned. May return ErrNoSuchWallet.
+func (api *Api) SendBatch(ctx context.Context, userPhone string, walletID int64, payouts []BatchPayout) (
+	batch *processing.TxBatch, results []processing.BatchItemResult, err error,
+) {
+	err = trace.InsideSpanE(ctx, "send_batch", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "wallet_id", walletID, "payouts_num", len(payouts))
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		var (
+			fromWallet queries.Wallet
+			items      []processing.BatchItem
+			itemsIdx   []int
+		)
+		results = make([]processing.BatchItemResult, len(payouts))
+		err = api.database.Tx(func(tx db.ITx) (err error) {
+			// query source wallet
+			fromWallet, err = queries.GetWallet(tx, userPhone, walletID)
+			if err != nil {
+				return
+			}
+			if fromWallet.WatchOnly {
+				return errs.ErrWatchOnlyWallet
+			}
+			err = CheckAccountOpen(tx, userPhone, errs.ErrAccountClosed)
+			if err != nil {
+				return
+			}
+
+			for i, payout := range payouts {
+				item, itemErr := api.batchItem(tx, span, &fromWallet, payout)
+				if isPayoutRejected(itemErr) {
+					results[i].Err = itemErr
+					continue
+				}
+				if itemErr != nil {
+					return itemErr
 				}
-				recipient = processing.NewWalletRecipient(&wts[0])
-				// also provide fallback address recipients
-				fbRecipients = append(fbRecipients, processing.NewAddressRecipient(toAddress))
+				items = append(items, item)
+				itemsIdx = append(itemsIdx, i)
 			}
 			return nil
 		})
@@ -386,21 +1010,271 @@ func (api *Api) SentToAddress(
 			return err
 		}
 
-		return trace.InsideSpanE(ctx, "sending", func(ctx context.Context, span opentracing.Span) error {
-			var sendErr error
-			newTx, sendErr = api.processingApi.Send(ctx, &fromWallet, recipient, amount, fbRecipients...)
-			return sendErr
+		span.LogKV("rejected_num", len(payouts)-len(items))
+		if len(payouts) > 0 && len(items) == 0 {
+			return nil
+		}
+
+		var itemsResults []processing.BatchItemResult
+		batch, itemsResults, err = api.processingApi.SendBatch(ctx, &fromWallet, items)
+		if err != nil {
+			return err
+		}
+		for i, r := range itemsResults {
+			results[itemsIdx[i]] = r
+		}
+		return nil
+	})
+	return
+}
+
+// CancelScheduledTx cancels scheduled transaction sent by the user, held amount is released. Returns
+// processing.ErrNoSuchTx if user hasn't sent such tx and processing.ErrTxNotScheduled if tx isn't scheduled.
+func (api *Api) CancelScheduledTx(ctx context.Context, userPhone string, txID int64) (tx *processing.Tx, err error) {
+	err = trace.InsideSpanE(ctx, "cancel_scheduled_tx", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "tx_id", txID)
+
+		// coerce user phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		tx, err = api.processingApi.CancelScheduled(ctx, userPhone, txID)
+		return err
+	})
+	return
+}
+
+// sendOrSchedule sends transaction immediately or schedules it if schedule is given
+func (api *Api) sendOrSchedule(
+	ctx context.Context,
+	fromWallet *queries.Wallet,
+	candidate processing.TxRecipientCandidate,
+	amount *decimal.Big,
+	schedule *processing.Schedule,
+	fbCandidates []processing.TxRecipientCandidate,
+) (newTx *processing.Tx, err error) {
+	if schedule != nil {
+		err = trace.InsideSpanE(ctx, "scheduling", func(ctx context.Context, span opentracing.Span) error {
+			var scheduleErr error
+			newTx, scheduleErr = api.processingApi.Schedule(ctx, fromWallet, candidate, amount, *schedule, fbCandidates...)
+			return scheduleErr
 		})
+		return
+	}
+	err = trace.InsideSpanE(ctx, "sending", func(ctx context.Context, span opentracing.Span) error {
+		var sendErr error
+		newTx, sendErr = api.processingApi.Send(ctx, fromWallet, candidate, amount, fbCandidates...)
+		return sendErr
 	})
 	return
 }
 
-//
-func (api *Api) queryBalance(ctx context.Context, wallet *queries.Wallet) (balance *decimal.Big, err error) {
-	return api.balanceHelper.TotalWalletBalanceCtx(ctx, wallet)
+// batchItem validates payout and determines its recipient
+func (api *Api) batchItem(
+	tx db.ITx,
+	span opentracing.Span,
+	fromWallet *queries.Wallet,
+	payout BatchPayout,
+) (item processing.BatchItem, err error) {
+	item.Amount = payout.Amount
+	if payout.Amount == nil || payout.Amount.Sign() <= 0 {
+		err = errs.ErrNonPositiveAmount
+		return
+	}
+
+	if payout.ToAddress != "" {
+		item.Recipient, item.FallbackCandidates, err = api.addressRecipient(tx, span, fromWallet, payout.ToAddress)
+		return
+	}
+
+	toUserPhone, err := CoercePhone(payout.ToPhone)
+	if err != nil {
+		return
+	}
+	if toUserPhone == fromWallet.UserPhone {
+		err = errs.ErrSelfTxForbidden
+		return
+	}
+	item.Recipient, item.FallbackCandidates, err = phoneRecipient(tx, span, fromWallet, toUserPhone, 0)
+	return
+}
+
+// isPayoutRejected true if error is caused by the payout itself so only this payout is rejected
+func isPayoutRejected(err error) bool {
+	switch err {
+	case errs.ErrNonPositiveAmount,
+		errs.ErrInvalidPhone,
+		errs.ErrInvalidAddress,
+		errs.ErrSelfTxForbidden,
+		errs.ErrRecipientAccountClosed,
+		errs.ErrNoSuchRecipientWallet:
+		return true
+	}
+	return false
+}
+
+// phoneRecipient determines recipient of the phone transfer from the wallet: explicitly specified recipient wallet,
+// recipient default wallet of the same coin or phone itself if recipient has no such wallet. Closed accounts can't
+// receive, ErrRecipientAccountClosed returned.
+func phoneRecipient(
+	tx db.ITx,
+	span opentracing.Span,
+	fromWallet *queries.Wallet,
+	toUserPhone string,
+	toWalletID int64,
+) (candidate processing.TxRecipientCandidate, fbCandidates []processing.TxRecipientCandidate, err error) {
+	// closed accounts don't accept deposits by phone
+	err = CheckAccountOpen(tx, toUserPhone, errs.ErrRecipientAccountClosed)
+	if err != nil {
+		return
+	}
+
+	// use explicitly specified destination wallet
+	if toWalletID != 0 {
+		toWallet, err := queries.GetWallet(tx, toUserPhone, toWalletID)
+		if err == errs.ErrNoSuchWallet || (err == nil && (toWallet.CoinID != fromWallet.CoinID || toWallet.WatchOnly)) {
+			return candidate, nil, errs.ErrNoSuchRecipientWallet
+		}
+		if err != nil {
+			return candidate, nil, err
+		}
+
+		candidate = processing.NewWalletRecipient(&toWallet)
+		fbCandidates = append(fbCandidates, processing.NewAddressRecipient(toWallet.Address))
+		span.LogKV("dst_wallet_id", toWallet.ID)
+		trace.LogMsg(span, "sending to specified dst wallet")
+		return candidate, fbCandidates, nil
+	}
+
+	// lookup destination user default wallet
+	wts, _, _, err := queries.GetWallets(
+		tx,
+		queries.GetWalletFilters{
+			Enabled:      true,
+			UserPhone:    toUserPhone,
+			ByCoin:       fromWallet.Coin.ShortName,
+			OnlyDefault:  true,
+			WithArchived: true,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	span.LogKV("dst_wallets_num", len(wts))
+
+	if len(wts) == 0 {
+		candidate = processing.NewPhoneRecipient(toUserPhone)
+		trace.LogMsg(span, "sending by phone due to recipient wallet not found")
+		return
+	}
+
+	candidate = processing.NewWalletRecipient(&wts[0])
+	fbCandidates = append(fbCandidates, processing.NewAddressRecipient(wts[0].Address))
+	span.LogKV("dst_wallet_id", wts[0].ID)
+	trace.LogMsg(span, "sending to dst default wallet")
+	return
+}
+
+// addressRecipient determines recipient of the transfer to the address from the wallet: if a wallet of such coin and
+// destination address exists, hint suggest processing to use that, address itself is used otherwise
+func (api *Api) addressRecipient(
+	tx db.ITx,
+	span opentracing.Span,
+	fromWallet *queries.Wallet,
+	toAddress string,
+) (candidate processing.TxRecipientCandidate, fbCandidates []processing.TxRecipientCandidate, err error) {
+	// reject malformed addresses before processing will try to send something
+	err = api.validateCoinAddress(fromWallet.Coin.ShortName, toAddress)
+	if err != nil {
+		return
+	}
+
+	// first we need to check is that address belongs to some recipientWallet in the system so this transaction
+	// will be sent internally
+	// TODO this decision must be made inside processing but currently due to DDD principe this check stay here
+	wts, _, _, err := queries.GetWallets(
+		tx,
+		queries.GetWalletFilters{
+			Enabled:      true,
+			ByCoin:       fromWallet.Coin.ShortName,
+			ByAddress:    toAddress,
+			WithArchived: true,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	// if no wallets found, send external tx
+	if len(wts) == 0 {
+		candidate = processing.NewAddressRecipient(toAddress)
+		return
+	}
+
+	// otherwise use found wallet to send internal tx
+	span.LogKV("to_wallet_id", wts[0].ID, "to_wallet_user_phone", wts[0].UserPhone)
+	if wts[0].UserPhone == fromWallet.UserPhone {
+		err = errs.ErrSelfTxForbidden
+		return
+	}
+	err = CheckAccountOpen(tx, wts[0].UserPhone, errs.ErrRecipientAccountClosed)
+	if err != nil {
+		return
+	}
+	candidate = processing.NewWalletRecipient(&wts[0])
+	// also provide fallback address recipients
+	fbCandidates = append(fbCandidates, processing.NewAddressRecipient(toAddress))
+	return
+}
+
+// validateCoinAddress validates address using coin address validator
+func (api *Api) validateCoinAddress(coinName, address string) error {
+	err := api.coordinator.AddressValidator(coinName).ValidateAddress(address)
+	if err == nodes.ErrAddressInvalid {
+		return errs.ErrInvalidAddress
+	}
+	return err
+}
+
+// queryBalance queries wallet balance through the cache if it's provided
+func (api *Api) queryBalance(ctx context.Context, wallet *queries.Wallet, fresh bool) (
+	balance *decimal.Big, asOf time.Time, err error,
+) {
+	if api.balanceCache == nil {
+		balance, err = api.balanceHelper.TotalWalletBalanceCtx(ctx, wallet)
+		asOf = time.Now().UTC()
+		return
+	}
+	return api.balanceCache.WalletBalanceCtx(ctx, wallet, fresh)
+}
+
+// CheckAccountOpen returns given error if user account closure is started
+func CheckAccountOpen(tx db.ITx, userPhone string, closedErr error) error {
+	closed, err := queries.IsAccountClosed(tx, userPhone)
+	return accountOpenErr(closed, err, closedErr)
+}
+
+// CheckAccountOpenGorm is CheckAccountOpen for services which run gorm transactions
+func CheckAccountOpenGorm(dbTx *gorm.DB, userPhone string, closedErr error) error {
+	closed, err := queries.IsAccountClosedSQL(dbTx.CommonDB(), userPhone)
+	return accountOpenErr(closed, err, closedErr)
+}
+
+func accountOpenErr(closed bool, err, closedErr error) error {
+	if err != nil {
+		return err
+	}
+	if closed {
+		return closedErr
+	}
+	return nil
 }
 
-func coercePhoneNumber(userPhone string) (string, error) {
+// CoercePhone normalizes user phone number, returns ErrInvalidPhone if phone is invalid
+func CoercePhone(userPhone string) (string, error) {
 	userPhoneParsed, err := types.NewPhone(userPhone)
 	if err != nil {
 		err = errs.ErrInvalidPhone
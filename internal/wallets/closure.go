@@ -0,0 +1,250 @@
+package wallets
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/common/pkg/merrors"
+	"git.zam.io/wallet-backend/wallet-api/internal/processing"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"git.zam.io/wallet-backend/web-api/db"
+	"github.com/ericlagergren/decimal"
+	"github.com/opentracing/opentracing-go"
+	"strings"
+)
+
+// CloseAccount closes user account: since first call account can't send and receive funds by phone, txs which await
+// recipient are canceled, then funds of each wallet are withdrawn to the target address of wallet coin and finally
+// wallets are marked closed. Targets are keyed by coin short name.
+//
+// Closure is resumable: call may be repeated with the same or updated targets after failure, wallets which
+// withdrawal has been sent aren't swept again. If some wallet has funds, but no target given, ErrClosureTargetRequired
+// returned and such coins are listed in closure MissingTargets. Closure stays in progress until all sweeps are sent.
+// May return ErrNoSuchCoin and ErrInvalidAddress if targets are invalid.
+func (api *Api) CloseAccount(ctx context.Context, userPhone string, targets map[string]SweepTarget) (
+	closure AccountClosure, err error,
+) {
+	err = trace.InsideSpanE(ctx, "close_account", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone, "targets_num", len(targets))
+
+		// coerce phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		// validate targets before account will be blocked
+		upperTargets := make(map[string]SweepTarget, len(targets))
+		for coinName, target := range targets {
+			coinName = strings.ToUpper(coinName)
+			err = api.ValidateCoin(coinName)
+			if err != nil {
+				return err
+			}
+			err = api.validateCoinAddress(coinName, target.Address)
+			if err != nil {
+				return err
+			}
+			upperTargets[coinName] = target
+		}
+
+		var (
+			wts          []queries.Wallet
+			canceledFrom []int64
+		)
+		err = api.database.Tx(func(tx db.ITx) (err error) {
+			err = queries.LockUserPhones(tx, userPhone)
+			if err != nil {
+				return
+			}
+			closure.AccountClosure, err = queries.StartAccountClosure(tx, userPhone)
+			if err != nil || closure.Status == queries.AccountClosureCompleted {
+				return
+			}
+
+			canceledFrom, err = queries.CancelUserPendingTxs(tx, userPhone)
+			if err != nil {
+				return
+			}
+			err = queries.CancelUserRecurringPlans(tx, userPhone)
+			if err != nil {
+				return
+			}
+			err = queries.CancelUserPaymentRequests(tx, userPhone)
+			if err != nil {
+				return
+			}
+			err = queries.CancelMerchantInvoices(tx, userPhone)
+			if err != nil {
+				return
+			}
+
+			// txs sent by the failed attempt mustn't be sent twice
+			_, err = queries.RecoverClosureSweepsTxs(tx, closure.ID)
+			if err != nil {
+				return
+			}
+			closure.Sweeps, err = queries.GetClosureSweeps(tx, closure.ID)
+			if err != nil {
+				return
+			}
+
+			// wallets of disabled coins are also selected, so their funds won't be left unnoticed
+			wts, _, _, err = queries.GetWallets(tx, queries.GetWalletFilters{
+				UserPhone:    userPhone,
+				WithArchived: true,
+			})
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		span.LogKV("closure_id", closure.ID, "status", closure.Status, "canceled_txs_num", len(canceledFrom))
+		if closure.Status == queries.AccountClosureCompleted {
+			return nil
+		}
+		closure.CanceledTxsNum = len(canceledFrom)
+		api.invalidateBalances(ctx, canceledFrom...)
+
+		// failed sweep doesn't prevent others
+		var sweepErrs error
+		for i := range wts {
+			sweepErr := api.sweepWallet(ctx, &closure, &wts[i], upperTargets)
+			if sweepErr != nil {
+				trace.LogErrorWithMsg(span, sweepErr, "wallet sweep failed")
+				sweepErrs = merrors.Append(sweepErrs, sweepErr)
+			}
+		}
+
+		err = api.database.Tx(func(tx db.ITx) (err error) {
+			closure.Sweeps, err = queries.GetClosureSweeps(tx, closure.ID)
+			if err != nil || sweepErrs != nil {
+				return
+			}
+			return queries.CompleteAccountClosure(tx, &closure.AccountClosure)
+		})
+		if err != nil {
+			return err
+		}
+
+		span.LogKV("status", closure.Status, "sweeps_num", len(closure.Sweeps))
+		return sweepErrs
+	})
+	return
+}
+
+// GetAccountClosure returns account closure progress, returns ErrNoSuchAccountClosure if closure isn't started
+func (api *Api) GetAccountClosure(ctx context.Context, userPhone string) (closure AccountClosure, err error) {
+	err = trace.InsideSpanE(ctx, "get_account_closure", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("user_phone", userPhone)
+
+		// coerce phone number
+		userPhone, err = CoercePhone(userPhone)
+		if err != nil {
+			return err
+		}
+
+		return api.database.Tx(func(tx db.ITx) (err error) {
+			closure.AccountClosure, err = queries.GetAccountClosure(tx, userPhone)
+			if err != nil {
+				return
+			}
+			closure.Sweeps, err = queries.GetClosureSweeps(tx, closure.ID)
+			return
+		})
+	})
+	return
+}
+
+// sweepWallet withdraws wallet funds to the target of wallet coin unless wallet withdrawal has been sent already.
+// Sweep is recorded before tx is sent, so it may be recovered if sending result isn't saved.
+func (api *Api) sweepWallet(
+	ctx context.Context,
+	closure *AccountClosure,
+	wallet *queries.Wallet,
+	targets map[string]SweepTarget,
+) error {
+	return trace.InsideSpanE(ctx, "sweep_wallet", func(ctx context.Context, span opentracing.Span) error {
+		span.LogKV("wallet_id", wallet.ID, "coin", wallet.Coin.ShortName)
+
+		for _, s := range closure.Sweeps {
+			if s.WalletID == wallet.ID && s.IsSent() {
+				trace.LogMsg(span, "wallet has been swept already")
+				return nil
+			}
+		}
+
+		balance, _, err := api.queryBalance(ctx, wallet, true)
+		if err != nil {
+			return err
+		}
+
+		coinName := strings.ToUpper(wallet.Coin.ShortName)
+		target, hasTarget := targets[coinName]
+		amount := new(decimal.Big).Copy(balance)
+		if hasTarget && target.FeeReserve != nil {
+			amount.Sub(amount, target.FeeReserve)
+		}
+
+		span.LogKV("balance", balance, "amount", amount)
+
+		// nothing to withdraw, remaining dust isn't enough to pay the fee
+		if amount.Sign() <= 0 {
+			return nil
+		}
+		if !hasTarget {
+			closure.addMissingTarget(coinName)
+			return errs.ErrClosureTargetRequired
+		}
+
+		var sweep queries.ClosureSweep
+		err = api.database.Tx(func(tx db.ITx) (err error) {
+			sweep, err = queries.CreateClosureSweep(tx, closure.ID, wallet.ID, target.Address, amount)
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		newTx, sendErr := api.processingApi.Send(ctx, wallet, processing.NewAddressRecipient(target.Address), amount)
+		if newTx == nil {
+			return sendErr
+		}
+
+		// declined tx is also bound, so the next attempt will retry
+		err = api.database.Tx(func(tx db.ITx) error {
+			return queries.SetClosureSweepTx(tx, sweep.ID, newTx.ID)
+		})
+		if err != nil {
+			return err
+		}
+		return sendErr
+	})
+}
+
+// invalidateBalances drops cached balances of given wallets, errors are only logged because cached balances will be
+// outdated by ttl anyway
+func (api *Api) invalidateBalances(ctx context.Context, walletIDs ...int64) {
+	if api.balanceCache == nil || len(walletIDs) == 0 {
+		return
+	}
+
+	trace.InsideSpan(ctx, "invalidating_balances", func(ctx context.Context, span opentracing.Span) {
+		span.LogKV("wallets_ids", walletIDs)
+		err := api.balanceCache.Invalidate(ctx, walletIDs...)
+		if err != nil {
+			trace.LogErrorWithMsg(span, err, "balances invalidation failed")
+		}
+	})
+}
+
+// addMissingTarget lists coin among missing targets once
+func (closure *AccountClosure) addMissingTarget(coinName string) {
+	for _, c := range closure.MissingTargets {
+		if c == coinName {
+			return
+		}
+	}
+	closure.MissingTargets = append(closure.MissingTargets, coinName)
+}
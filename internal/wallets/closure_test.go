@@ -0,0 +1,164 @@
+package wallets_test
+
+import (
+	"context"
+	helpersmocks "git.zam.io/wallet-backend/wallet-api/internal/helpers/mocks"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+// insertSweepTx inserts external tx of the wallet to the address, returns tx id
+func insertSweepTx(d *db.Db, fromWalletID int64, address string, amount int64) (id int64) {
+	err := d.QueryRowx(
+		`insert into txs (from_wallet_id, to_address, type, amount, status_id)
+		values ($1, $2, 'external', $3, (select id from tx_statuses where name = 'pending'))
+		returning id`,
+		fromWalletID, address, amount,
+	).Scan(&id)
+	Expect(err).NotTo(HaveOccurred())
+	return
+}
+
+var _ = Describe("testing account closure", func() {
+	provideWalletsApi()
+
+	Context("when closing account which wallets are empty", func() {
+		ItD("should cancel txs which await recipient and complete closure", func(d *db.Db, api *wallets.Api) {
+			first := createWallet(d, userPhone, "first", false)
+			sender := createWallet(d, otherPhone, "sender", false)
+			sent := insertTxTo(d, first.ID, otherPhone, "pending")
+			received := insertTxTo(d, sender.ID, userPhone, "pending")
+			scheduled := insertTxTo(d, first.ID, otherPhone, "scheduled")
+			foreignScheduled := insertTxTo(d, sender.ID, userPhone, "scheduled")
+			succeed := insertTxTo(d, first.ID, otherPhone, "success")
+
+			closure, err := api.CloseAccount(context.Background(), userPhone, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(closure.Status).To(Equal(queries.AccountClosureCompleted))
+			Expect(closure.CanceledTxsNum).To(Equal(3))
+			Expect(closure.Sweeps).To(BeEmpty())
+			Expect(closure.MissingTargets).To(BeEmpty())
+
+			for id, status := range map[int64]string{
+				sent:             "cancel",
+				received:         "cancel",
+				scheduled:        "cancel",
+				foreignScheduled: "scheduled",
+				succeed:          "success",
+			} {
+				Expect(txStatus(d, id)).To(Equal(status))
+			}
+
+			closed, err := queries.IsAccountClosed(d, userPhone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(closed).To(BeTrue())
+
+			var walletClosed bool
+			Expect(d.QueryRowx(`select closed from wallets where id = $1`, first.ID).Scan(&walletClosed)).To(Succeed())
+			Expect(walletClosed).To(BeTrue())
+		})
+
+		ItD("should return the same closure when repeated", func(d *db.Db, api *wallets.Api) {
+			createWallet(d, userPhone, "first", false)
+
+			closure, err := api.CloseAccount(context.Background(), userPhone, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			repeated, err := api.CloseAccount(context.Background(), userPhone, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repeated.ID).To(Equal(closure.ID))
+			Expect(repeated.Status).To(Equal(queries.AccountClosureCompleted))
+
+			got, err := api.GetAccountClosure(context.Background(), userPhone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.ID).To(Equal(closure.ID))
+		})
+
+		ItD("should not find closure which isn't started", func(api *wallets.Api) {
+			_, err := api.GetAccountClosure(context.Background(), userPhone)
+			Expect(err).To(Equal(errs.ErrNoSuchAccountClosure))
+		})
+	})
+
+	Context("when closing account which wallet has funds", func() {
+		ItD("should require target and keep closure in progress", func(
+			d *db.Db, api *wallets.Api, balance *helpersmocks.IBalance,
+		) {
+			balance.ExpectedCalls = nil
+			balance.On("TotalWalletBalanceCtx", mock.Anything, mock.Anything).Return(decimal.New(1, 0), nil)
+			createWallet(d, userPhone, "first", false)
+			createWallet(d, userPhone, "second", false)
+
+			closure, err := api.CloseAccount(context.Background(), userPhone, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(closure.Status).To(Equal(queries.AccountClosureInProgress))
+			Expect(closure.MissingTargets).To(Equal([]string{testCoinName}))
+
+			closed, err := queries.IsAccountClosed(d, userPhone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(closed).To(BeTrue())
+		})
+	})
+
+	Context("when recovering closure sweeps txs", func() {
+		ItD("should bind only matching txs which aren't bound yet", func(d *db.Db) {
+			wallet := createWallet(d, userPhone, "first", false)
+			closure, err := queries.StartAccountClosure(d, userPhone)
+			Expect(err).NotTo(HaveOccurred())
+
+			bound, err := queries.CreateClosureSweep(d, closure.ID, wallet.ID, "target", decimal.New(1, 0))
+			Expect(err).NotTo(HaveOccurred())
+			boundTx := insertSweepTx(d, wallet.ID, "target", 1)
+			Expect(queries.SetClosureSweepTx(d, bound.ID, boundTx)).To(Succeed())
+
+			lost, err := queries.CreateClosureSweep(d, closure.ID, wallet.ID, "target", decimal.New(1, 0))
+			Expect(err).NotTo(HaveOccurred())
+			lostTx := insertSweepTx(d, wallet.ID, "target", 1)
+
+			_, err = queries.CreateClosureSweep(d, closure.ID, wallet.ID, "target", decimal.New(2, 0))
+			Expect(err).NotTo(HaveOccurred())
+
+			recovered, err := queries.RecoverClosureSweepsTxs(d, closure.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recovered).To(Equal(int64(1)))
+
+			sweeps, err := queries.GetClosureSweeps(d, closure.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sweeps).To(HaveLen(3))
+			Expect(*sweeps[0].TxID).To(Equal(boundTx))
+			Expect(sweeps[1].ID).To(Equal(lost.ID))
+			Expect(*sweeps[1].TxID).To(Equal(lostTx))
+			Expect(sweeps[1].TxStatus).To(Equal("pending"))
+			Expect(sweeps[2].TxID).To(BeNil())
+		})
+	})
+
+	Context("when checking whether sweep is sent", func() {
+		txID := int64(1)
+		for _, c := range []struct {
+			label  string
+			txID   *int64
+			status string
+			sent   bool
+		}{
+			{"should not count sweep without tx", nil, "", false},
+			{"should count pending sweep tx", &txID, "pending", true},
+			{"should count succeed sweep tx", &txID, "success", true},
+			{"should not count declined sweep tx", &txID, "decline", false},
+			{"should not count canceled sweep tx", &txID, "cancel", false},
+		} {
+			c := c
+			It(c.label, func() {
+				sweep := queries.ClosureSweep{TxID: c.txID, TxStatus: c.status}
+				Expect(sweep.IsSent()).To(Equal(c.sent))
+			})
+		}
+	})
+})
@@ -22,4 +22,56 @@ var (
 
 	// ErrNonPositiveAmount indicates invalid amount which less or equal to zero
 	ErrNonPositiveAmount = errors.New("wallets: non positive")
+
+	// ErrWalletUpdateRejected returned when wallet can't be updated due to unique values limitations
+	ErrWalletUpdateRejected = errors.New("wallets: wallet update rejected due to params")
+
+	// ErrWalletHasUnsettledTxs returned on attempt to archive wallet which has held or pending txs
+	ErrWalletHasUnsettledTxs = errors.New("wallets: wallet has unsettled txs")
+
+	// ErrAddressRotationNotSupported returned on attempt to derive new address for wallet of coin which sends funds
+	// from wallet address, so funds left on previous address would be stuck
+	ErrAddressRotationNotSupported = errors.New("wallets: address rotation isn't supported by the coin")
+
+	// ErrWatchOnlyWallet returned on attempt to send funds from watch-only wallet or make it default one
+	ErrWatchOnlyWallet = errors.New("wallets: watch-only wallet can't send funds")
+
+	// ErrWatchOnlyNotSupported returned on attempt to import watch-only wallet of coin which can't observe external
+	// addresses
+	ErrWatchOnlyNotSupported = errors.New("wallets: watch-only wallets aren't supported by the coin")
+
+	// ErrCustodialAddress returned on attempt to import watch-only wallet of address which belongs to the wallet
+	// controlled by the service
+	ErrCustodialAddress = errors.New("wallets: address belongs to custodial wallet")
+
+	// ErrPhoneChangeConflict returned on attempt to change user phone to the phone which already has wallets
+	ErrPhoneChangeConflict = errors.New("wallets: new phone already has wallets")
+
+	// ErrSamePhone returned on attempt to change user phone to the same one
+	ErrSamePhone = errors.New("wallets: new phone is the same as old one")
+
+	// ErrAccountClosed returned on attempt to operate with account which closure is started or completed
+	ErrAccountClosed = errors.New("wallets: account is closed")
+
+	// ErrRecipientAccountClosed returned on attempt to send funds to the user which account is closed
+	ErrRecipientAccountClosed = errors.New("wallets: recipient account is closed")
+
+	// ErrNoSuchAccountClosure returned when account closure isn't started
+	ErrNoSuchAccountClosure = errors.New("wallets: no such account closure")
+
+	// ErrClosureTargetRequired returned when wallet has funds, but no withdrawal address is given for it's coin
+	ErrClosureTargetRequired = errors.New("wallets: account closure withdrawal address required")
+
+	// ErrNoSuchRecipientWallet returned when specified recipient wallet doesn't belong to recipient or has the
+	// other coin
+	ErrNoSuchRecipientWallet = errors.New("wallets: no such recipient wallet")
+
+	// ErrInvalidAddress returned when address is malformed for wallet coin
+	ErrInvalidAddress = errors.New("wallets: invalid address")
+
+	// ErrPaymentURINotSupported returned on attempt to render payment uri for the coin which has no uri scheme
+	ErrPaymentURINotSupported = errors.New("wallets: payment uri isn't supported by the coin")
+
+	// ErrInvalidPaymentURI returned when payment uri is malformed or it's scheme doesn't belong to any coin
+	ErrInvalidPaymentURI = errors.New("wallets: invalid payment uri")
 )
@@ -3,6 +3,7 @@ package wallets
 import (
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
 	"github.com/ericlagergren/decimal"
+	"time"
 )
 
 // WalletWithBalance represents wallet with balance
@@ -11,4 +12,63 @@ type WalletWithBalance struct {
 
 	// Balances of the wallet represented using high-precision decimal type
 	Balance *decimal.Big
+
+	// BalanceAsOf is the moment at which balance has been calculated
+	BalanceAsOf time.Time
+}
+
+// GetWalletsFilters describes user wallets list filters and pagination params
+type GetWalletsFilters struct {
+	ByCoin string
+
+	// FromID is the cursor, wallets which follow wallet with such id in listing order are returned
+	FromID, Count int64
+
+	// WithArchived also returns archived wallets
+	WithArchived bool
+
+	// WithWatchOnly also returns watch-only wallets
+	WithWatchOnly bool
+}
+
+// SweepTarget describes where coin funds are withdrawn to on account closure
+type SweepTarget struct {
+	Address string
+
+	// FeeReserve is left on the wallet to pay sweep tx blockchain fee, may be nil
+	FeeReserve *decimal.Big
+}
+
+// AccountClosure describes account closure progress
+type AccountClosure struct {
+	queries.AccountClosure
+
+	// Sweeps are final withdrawals made during all closure attempts
+	Sweeps []queries.ClosureSweep
+
+	// CanceledTxsNum is number of txs which awaited recipient and have been canceled by the attempt
+	CanceledTxsNum int
+
+	// MissingTargets lists coins which wallets have funds, but no withdrawal target is given for
+	MissingTargets []string
+}
+
+// BatchPayout describes single batch item, either ToPhone or ToAddress must be given
+type BatchPayout struct {
+	ToPhone   string
+	ToAddress string
+	Amount    *decimal.Big
+}
+
+// ScannedPayment describes payment decoded from scanned payment uri, which prefills send request
+type ScannedPayment struct {
+	Coin      string
+	Recipient string
+
+	// Amount is nil if uri doesn't specify it
+	Amount *decimal.Big
+	Label  string
+
+	// WalletID is the user default wallet of the coin, zero if user has no wallet of the coin
+	WalletID int64
 }
@@ -0,0 +1,170 @@
+package wallets_test
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const newPhone = "+79105554433"
+
+// fiatCurrency returns user settings fiat currency, empty if user has no settings
+func fiatCurrency(d *db.Db, phone string) (currency string) {
+	err := d.QueryRowx(
+		`select coalesce((select fiat_currency from user_settings where user_phone = $1), '')`, phone,
+	).Scan(&currency)
+	Expect(err).NotTo(HaveOccurred())
+	return
+}
+
+var _ = Describe("testing phone change", func() {
+	provideWalletsApi()
+
+	Context("when moving user data to the new phone", func() {
+		ItD("should move wallets and txs which await the user", func(d *db.Db) {
+			first := createWallet(d, userPhone, "first", false)
+			createWallet(d, userPhone, "second", false)
+			sender := createWallet(d, otherPhone, "sender", false)
+
+			awaiting := insertTxTo(d, sender.ID, userPhone, "pending")
+			delivered := insertTxTo(d, sender.ID, userPhone, "success")
+			outgoing := insertTxTo(d, first.ID, otherPhone, "pending")
+
+			change, err := queries.ChangeUserPhone(d, userPhone, newPhone, "support")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(change.OldPhone).To(Equal(userPhone))
+			Expect(change.NewPhone).To(Equal(newPhone))
+			Expect(change.Source).To(Equal("support"))
+			Expect(change.WalletsNum).To(Equal(int64(2)))
+			Expect(change.TxsNum).To(Equal(int64(1)))
+
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone})).To(BeEmpty())
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: newPhone})).To(
+				Equal([]string{"first", "second"}),
+			)
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: otherPhone})).To(Equal([]string{"sender"}))
+
+			for _, c := range []struct {
+				id    int64
+				phone string
+			}{
+				{awaiting, newPhone},
+				{delivered, userPhone},
+				{outgoing, otherPhone},
+			} {
+				var toPhone string
+				Expect(d.QueryRowx(`select to_phone from txs where id = $1`, c.id).Scan(&toPhone)).To(Succeed())
+				Expect(toPhone).To(Equal(c.phone))
+			}
+		})
+
+		ItD("should move account closure", func(d *db.Db) {
+			_, err := queries.StartAccountClosure(d, userPhone)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = queries.ChangeUserPhone(d, userPhone, newPhone, "support")
+			Expect(err).NotTo(HaveOccurred())
+
+			closed, err := queries.IsAccountClosed(d, userPhone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(closed).To(BeFalse())
+
+			closed, err = queries.IsAccountClosed(d, newPhone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(closed).To(BeTrue())
+		})
+
+		for _, c := range []struct {
+			label       string
+			newSettings bool
+			oldCurrency string
+			newCurrency string
+		}{
+			{"should move settings to phone without settings", false, "", "eur"},
+			{"should keep settings of phone which has own ones", true, "eur", "rub"},
+		} {
+			c := c
+			ItD(c.label, func(d *db.Db) {
+				_, err := d.Exec(`insert into user_settings (user_phone, fiat_currency) values ($1, 'eur')`, userPhone)
+				Expect(err).NotTo(HaveOccurred())
+				if c.newSettings {
+					_, err = d.Exec(`insert into user_settings (user_phone, fiat_currency) values ($1, 'rub')`, newPhone)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				_, err = queries.ChangeUserPhone(d, userPhone, newPhone, "support")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fiatCurrency(d, userPhone)).To(Equal(c.oldCurrency))
+				Expect(fiatCurrency(d, newPhone)).To(Equal(c.newCurrency))
+			})
+		}
+	})
+
+	Context("when phone can't be changed", func() {
+		for _, c := range []struct {
+			label    string
+			oldPhone string
+			newPhone string
+			prepare  func(d *db.Db)
+			err      error
+		}{
+			{
+				label:    "should reject the same phone",
+				oldPhone: userPhone,
+				newPhone: userPhone,
+				err:      errs.ErrSamePhone,
+			},
+			{
+				label:    "should reject invalid phone",
+				oldPhone: userPhone,
+				newPhone: "not a phone",
+				err:      errs.ErrInvalidPhone,
+			},
+			{
+				label:    "should reject phone which has wallets",
+				oldPhone: userPhone,
+				newPhone: otherPhone,
+				prepare:  func(d *db.Db) { createWallet(d, otherPhone, "other", false) },
+				err:      errs.ErrPhoneChangeConflict,
+			},
+			{
+				label:    "should reject phone which account is closed",
+				oldPhone: userPhone,
+				newPhone: newPhone,
+				prepare: func(d *db.Db) {
+					_, err := queries.StartAccountClosure(d, newPhone)
+					Expect(err).NotTo(HaveOccurred())
+				},
+				err: errs.ErrPhoneChangeConflict,
+			},
+			{
+				label:    "should reject closed account",
+				oldPhone: userPhone,
+				newPhone: newPhone,
+				prepare: func(d *db.Db) {
+					_, err := queries.StartAccountClosure(d, userPhone)
+					Expect(err).NotTo(HaveOccurred())
+				},
+				err: errs.ErrAccountClosed,
+			},
+		} {
+			c := c
+			ItD(c.label, func(d *db.Db, api *wallets.Api) {
+				createWallet(d, userPhone, "first", false)
+				if c.prepare != nil {
+					c.prepare(d)
+				}
+
+				_, err := api.ChangePhone(context.Background(), c.oldPhone, c.newPhone, "support")
+				Expect(err).To(Equal(c.err))
+				Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone})).To(Equal([]string{"first"}))
+			})
+		}
+	})
+})
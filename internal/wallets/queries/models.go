@@ -1,6 +1,7 @@
 package queries
 
 import (
+	"github.com/ericlagergren/decimal/sql/postgres"
 	"time"
 )
 
@@ -26,6 +27,92 @@ type Wallet struct {
 
 	CreatedAt time.Time `db:"created_at"`
 
+	// IsDefault marks user wallet which receives incoming phone transfers of it's coin
+	IsDefault bool `db:"is_default"`
+
+	// DisplayOrder is user defined wallets order
+	DisplayOrder int64 `db:"display_order"`
+
+	// Archived wallets are hidden from wallets list by default, but still receive funds
+	Archived bool `db:"archived"`
+
+	// WatchOnly wallets track external address which isn't controlled by the system, they can't send funds
+	WatchOnly bool `db:"watch_only"`
+
+	// Closed wallets belong to closed account, they're kept only for history
+	Closed bool `db:"closed"`
+
 	Coin   Coin  `db:",prefix=coins_" gorm:"foreignkey:CoinID;association_autoupdate:false;association_autocreate:false"`
 	CoinID int64 `db:"coin_id"`
+
+	// Addresses wallet owns or used to own, loaded only on demand
+	Addresses []WalletAddress `db:"-" gorm:"foreignkey:WalletID;association_autoupdate:false;association_autocreate:false"`
+}
+
+// AllAddresses returns current wallet address followed by historical ones (if they're loaded) without duplicates
+func (w *Wallet) AllAddresses() []string {
+	addresses := make([]string, 0, len(w.Addresses)+1)
+	if w.Address != "" {
+		addresses = append(addresses, w.Address)
+	}
+	for _, a := range w.Addresses {
+		if a.Address != w.Address {
+			addresses = append(addresses, a.Address)
+		}
+	}
+	return addresses
+}
+
+// WalletAddress is address which wallet owns or used to own, wallet is credited with deposits on any of them
+type WalletAddress struct {
+	ID        int64     `db:"id"`
+	WalletID  int64     `db:"wallet_id"`
+	Address   string    `db:"address"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// PhoneChange is audit record of user wallets and pending transfers re-keying to the new phone number
+type PhoneChange struct {
+	ID         int64     `db:"id"`
+	OldPhone   string    `db:"old_phone"`
+	NewPhone   string    `db:"new_phone"`
+	WalletsNum int64     `db:"wallets_num"`
+	TxsNum     int64     `db:"txs_num"`
+	Source     string    `db:"source"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Account closure statuses
+const (
+	AccountClosureInProgress = "in_progress"
+	AccountClosureCompleted  = "completed"
+)
+
+// AccountClosure is user account closure record, account is blocked since closure is started
+type AccountClosure struct {
+	ID        int64     `db:"id"`
+	UserPhone string    `db:"user_phone"`
+	Status    string    `db:"status"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// ClosureSweep is final withdrawal of wallet funds made on account closure, sweep is recorded before tx is sent, so
+// TxID is empty until tx is created
+type ClosureSweep struct {
+	ID        int64             `db:"id"`
+	ClosureID int64             `db:"closure_id"`
+	WalletID  int64             `db:"wallet_id"`
+	Address   string            `db:"address"`
+	Amount    *postgres.Decimal `db:"amount"`
+	TxID      *int64            `db:"tx_id"`
+	CreatedAt time.Time         `db:"created_at"`
+
+	// TxStatus is sweep tx status name, empty if tx isn't created
+	TxStatus string `db:"tx_status"`
+}
+
+// IsSent checks whether sweep tx has been sent or is being sent, so wallet mustn't be swept again
+func (s *ClosureSweep) IsSent() bool {
+	return s.TxID != nil && s.TxStatus != "decline" && s.TxStatus != "cancel"
 }
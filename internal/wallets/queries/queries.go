@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
 	"git.zam.io/wallet-backend/web-api/db"
+	"github.com/ericlagergren/decimal"
+	"github.com/ericlagergren/decimal/sql/postgres"
 	"github.com/lib/pq"
+	"sort"
 	"strings"
 )
 
@@ -19,15 +22,20 @@ const (
 // CreateWallet creates wallet using wallet internal coin short name to lookup appropriate coin id, if there is no such
 // coin with given short name (note: coin short name is case insensitive), ErrNoSuchCoin will be returned.
 //
-// Also in attempt to create wallet which broke unique user_phone and coin_id constraint, ErrWalletCreationRejected
-// will be returned.
+// First user wallet of the coin becomes default one, watch-only wallets never become default.
+//
+// Also in attempt to create wallet which broke unique user_phone, coin_id and name constraint,
+// ErrWalletCreationRejected will be returned.
 func CreateWallet(tx db.ITx, wallet Wallet) (newWallet Wallet, err error) {
 	err = tx.QueryRowx(
-		`INSERT INTO wallets (name, user_phone, address, coin_id)
-         VALUES ($1, $2, $3, (SELECT id FROM coins WHERE short_name = $4 AND enabled = true))
-         RETURNING id, coin_id`,
-		wallet.Name, wallet.UserPhone, wallet.Address, strings.ToUpper(wallet.Coin.ShortName),
-	).Scan(&wallet.ID, &wallet.CoinID)
+		`WITH coin AS (SELECT id FROM coins WHERE short_name = $4 AND enabled = true)
+         INSERT INTO wallets (name, user_phone, address, coin_id, watch_only, is_default)
+         VALUES ($1, $2, $3, (SELECT id FROM coin), $5, NOT $5 AND NOT EXISTS(
+             SELECT 1 FROM wallets WHERE user_phone = $2 AND coin_id = (SELECT id FROM coin) AND is_default
+         ))
+         RETURNING id, coin_id, is_default`,
+		wallet.Name, wallet.UserPhone, wallet.Address, strings.ToUpper(wallet.Coin.ShortName), wallet.WatchOnly,
+	).Scan(&wallet.ID, &wallet.CoinID, &wallet.IsDefault)
 	if err != nil {
 		if pgErr, ok := err.(*pq.Error); ok {
 			switch {
@@ -50,6 +58,8 @@ func CreateWallet(tx db.ITx, wallet Wallet) (newWallet Wallet, err error) {
 type WalletDiff struct {
 	Name, Address, Secret *string
 	CoinID                *int64
+	IsDefault, Archived   *bool
+	DisplayOrder          *int64
 }
 
 const (
@@ -59,7 +69,7 @@ const (
 
 // UpdateWallet updates wallet db row which matched by passed id using non-nil field from diff argument.
 //
-// Returns ErrNoSuchWallet if wallet id is invalid
+// Returns ErrNoSuchWallet if wallet id is invalid, ErrWalletUpdateRejected if update breaks unique constraints
 func UpdateWallet(tx db.ITx, id int64, diff *WalletDiff) (err error) {
 	colNames := make([]string, 0, 4)
 	colArgs := make([]interface{}, 0, 4)
@@ -85,6 +95,21 @@ func UpdateWallet(tx db.ITx, id int64, diff *WalletDiff) (err error) {
 		colArgs = append(colArgs, *diff.CoinID)
 	}
 
+	if diff.IsDefault != nil {
+		colNames = append(colNames, "is_default")
+		colArgs = append(colArgs, *diff.IsDefault)
+	}
+
+	if diff.Archived != nil {
+		colNames = append(colNames, "archived")
+		colArgs = append(colArgs, *diff.Archived)
+	}
+
+	if diff.DisplayOrder != nil {
+		colNames = append(colNames, "display_order")
+		colArgs = append(colArgs, *diff.DisplayOrder)
+	}
+
 	// we don't really want query empty update statement
 	if len(colNames) == 0 {
 		return nil
@@ -115,6 +140,9 @@ func UpdateWallet(tx db.ITx, id int64, diff *WalletDiff) (err error) {
 		if err == sql.ErrNoRows {
 			panic(fmt.Errorf("update wallet: named query row retuns no rows"))
 		}
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == uniqueConstraintViolationErrCode {
+			err = errs.ErrWalletUpdateRejected
+		}
 	}
 	return
 }
@@ -129,6 +157,11 @@ SELECT
 	wallets.address,
 	wallets.secret,
 	wallets.created_at,
+	wallets.is_default,
+	wallets.display_order,
+	wallets.archived,
+	wallets.watch_only,
+	wallets.closed,
 	coins.id as coins_id,
     coins.name as coins_name,
     coins.short_name as coins_short_name,
@@ -136,7 +169,8 @@ SELECT
 FROM wallets
 INNER JOIN coins ON coins.id = wallets.coin_id`
 
-	appendixSelectWalletsRequest = " ORDER BY wallets.id ASC"
+	// wallets are listed in user defined order, wallets of the same order are listed by id
+	appendixSelectWalletsRequest = " ORDER BY wallets.display_order ASC, wallets.id ASC"
 )
 
 // GetWallet
@@ -178,9 +212,19 @@ type GetWalletFilters struct {
 	FromID    int64
 	ByCoin    string
 	ByAddress string
+
+	// OnlyDefault selects only default wallets
+	OnlyDefault bool
+
+	// WithArchived also selects archived wallets
+	WithArchived bool
+
+	// WithWatchOnly also selects watch-only wallets
+	WithWatchOnly bool
 }
 
-// GetWallets
+// GetWallets returns wallets matched by filters ordered by display order and id, FromID cursor selects wallets which
+// follow wallet with such id in this order
 func GetWallets(tx db.ITx, filters GetWalletFilters) (
 	wallets []Wallet, totalCount int64, hasNext bool, err error,
 ) {
@@ -203,15 +247,30 @@ func GetWallets(tx db.ITx, filters GetWalletFilters) (
 		whereArgs["coin_short_name"] = byCoin
 	}
 	if filters.FromID != 0 {
-		// apply pagination
-		whereParts = append(whereParts, "wallets.id > :wallet_id")
+		// apply pagination, wallets which follow the cursor wallet in listing order are selected
+		whereParts = append(whereParts, `(wallets.display_order, wallets.id) > (
+			SELECT cursor_wallet.display_order, cursor_wallet.id FROM wallets cursor_wallet
+			WHERE cursor_wallet.id = :wallet_id
+		)`)
 		whereArgs["wallet_id"] = filters.FromID
 	}
 	if filters.ByAddress != "" {
-		// apply address filter
-		whereParts = append(whereParts, "wallets.address = :address")
+		// apply address filter, historical addresses are also matched
+		whereParts = append(whereParts, `(wallets.address = :address OR EXISTS(
+			SELECT 1 FROM wallet_addresses
+			WHERE wallet_addresses.wallet_id = wallets.id AND wallet_addresses.address = :address
+		))`)
 		whereArgs["address"] = filters.ByAddress
 	}
+	if filters.OnlyDefault {
+		whereParts = append(whereParts, "wallets.is_default is TRUE")
+	}
+	if !filters.WithArchived {
+		whereParts = append(whereParts, "wallets.archived is FALSE")
+	}
+	if !filters.WithWatchOnly {
+		whereParts = append(whereParts, "wallets.watch_only is FALSE")
+	}
 	if filters.Count != 0 {
 		// apply limit
 		limitClause = " LIMIT :limit"
@@ -254,7 +313,8 @@ func GetWallets(tx db.ITx, filters GetWalletFilters) (
 	// detect has next flag by querying last wallet id with same where clause, if last id not equal to id of last select
 	// wallet that mean that there more to select
 	err = tx.NamedQueryRow(`WITH last_id_select AS (
-            SELECT wallets.id FROM wallets INNER JOIN coins ON coins.id = wallets.coin_id`+whereClause+` ORDER BY id DESC LIMIT 1
+            SELECT wallets.id FROM wallets INNER JOIN coins ON coins.id = wallets.coin_id`+whereClause+`
+            ORDER BY wallets.display_order DESC, wallets.id DESC LIMIT 1
 		)
 		SELECT COUNT(*) AS count, (SELECT id FROM last_id_select) AS last_id FROM wallets
 		INNER JOIN coins ON coins.id = wallets.coin_id`+whereClause+limitClause,
@@ -269,6 +329,360 @@ func GetWallets(tx db.ITx, filters GetWalletFilters) (
 	return
 }
 
+// SetDefaultWallet makes wallet with given id default one among user wallets of the same coin
+func SetDefaultWallet(tx db.ITx, wallet Wallet) (err error) {
+	// reset previous default wallet first, otherwise unique index will be violated
+	var resetCount int64
+	err = tx.QueryRowx(
+		`WITH reset AS (
+			UPDATE wallets SET is_default = false
+			WHERE user_phone = $1 AND coin_id = $2 AND is_default AND id <> $3
+			RETURNING id
+		)
+		SELECT COUNT(*) FROM reset`,
+		wallet.UserPhone, wallet.CoinID, wallet.ID,
+	).Scan(&resetCount)
+	if err != nil {
+		return
+	}
+
+	isDefault := true
+	return UpdateWallet(tx, wallet.ID, &WalletDiff{IsDefault: &isDefault})
+}
+
+// AddWalletAddress appends address to wallet addresses history, adding already known address is no-op
+func AddWalletAddress(tx db.ITx, walletID int64, address, secret string) (walletAddress WalletAddress, err error) {
+	err = tx.QueryRowx(
+		`INSERT INTO wallet_addresses (wallet_id, address, secret) VALUES ($1, $2, $3)
+		 ON CONFLICT ON CONSTRAINT wallet_addresses_unique_wallet_address_cst DO UPDATE SET address = EXCLUDED.address
+		 RETURNING id, wallet_id, address, created_at`,
+		walletID, address, secret,
+	).StructScan(&walletAddress)
+	return
+}
+
+// GetWalletAddresses returns all addresses wallet owns or used to own ordered from oldest to newest
+func GetWalletAddresses(tx db.ITx, walletID int64) (addresses []WalletAddress, err error) {
+	rows, err := tx.Queryx(
+		`SELECT id, wallet_id, address, created_at FROM wallet_addresses WHERE wallet_id = $1 ORDER BY id`,
+		walletID,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a WalletAddress
+		err = rows.StructScan(&a)
+		if err != nil {
+			return
+		}
+		addresses = append(addresses, a)
+	}
+	err = rows.Err()
+	return
+}
+
+// HasCustodialAddress checks whether address belongs to addresses history of any coin wallet which isn't watch-only,
+// addresses are compared case insensitively
+func HasCustodialAddress(tx db.ITx, coinShortName, address string) (has bool, err error) {
+	err = tx.QueryRowx(
+		`SELECT EXISTS(
+			SELECT 1 FROM wallet_addresses
+			INNER JOIN wallets ON wallets.id = wallet_addresses.wallet_id
+			INNER JOIN coins ON coins.id = wallets.coin_id
+			WHERE coins.short_name = $1 AND NOT wallets.watch_only AND lower(wallet_addresses.address) = lower($2)
+		)`,
+		strings.ToUpper(coinShortName), address,
+	).Scan(&has)
+	return
+}
+
+// LockUserPhones takes transaction-level locks of given phones, so checks of phone wallets existence and account state
+// aren't raced by concurrent wallet creations and phone changes. Locks are taken in the same order to avoid deadlocks.
+func LockUserPhones(tx db.ITx, userPhones ...string) (err error) {
+	sorted := append([]string(nil), userPhones...)
+	sort.Strings(sorted)
+	for _, phone := range sorted {
+		rows, err := tx.Queryx(`SELECT pg_advisory_xact_lock(hashtext('user_phone:' || $1))`, phone)
+		if err != nil {
+			return err
+		}
+		err = rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// HasWallets checks whether user has any wallets including archived and watch-only ones
+func HasWallets(tx db.ITx, userPhone string) (has bool, err error) {
+	err = tx.QueryRowx(`SELECT EXISTS(SELECT 1 FROM wallets WHERE user_phone = $1)`, userPhone).Scan(&has)
+	return
+}
+
+// ChangeUserPhone re-keys all user wallets, account closures, txs which await recipient, recurring plans, pending
+// payment requests, merchant invoices and user settings with old phone to the new phone and writes audit record.
+// Settings are kept if new phone already has ones.
+func ChangeUserPhone(tx db.ITx, oldPhone, newPhone, source string) (change PhoneChange, err error) {
+	err = tx.QueryRowx(
+		`WITH moved_wallets AS (
+			UPDATE wallets SET user_phone = $2 WHERE user_phone = $1 RETURNING id
+		), moved_txs AS (
+			UPDATE txs SET to_phone = $2
+			WHERE to_phone = $1 AND status_id = (SELECT id FROM tx_statuses WHERE name = 'pending')
+			RETURNING id
+		), moved_plans AS (
+			UPDATE recurring_plans SET user_phone = $2 WHERE user_phone = $1 RETURNING id
+		), moved_plans_recipients AS (
+			UPDATE recurring_plans SET recipient = $2
+			WHERE recipient_type = 'phone' AND recipient = $1 AND status IN ('active', 'paused')
+			RETURNING id
+		), moved_requests AS (
+			UPDATE payment_requests SET requester_phone = $2
+			WHERE requester_phone = $1 AND status = 'pending'
+			RETURNING id
+		), moved_requests_payers AS (
+			UPDATE payment_requests SET payer_phone = $2
+			WHERE payer_phone = $1 AND status = 'pending'
+			RETURNING id
+		), moved_invoices AS (
+			UPDATE invoices SET merchant_phone = $2
+			WHERE merchant_phone = $1
+			RETURNING id
+		), moved_closures AS (
+			UPDATE account_closures SET user_phone = $2 WHERE user_phone = $1 RETURNING id
+		), moved_settings AS (
+			UPDATE user_settings SET user_phone = $2
+			WHERE user_phone = $1 AND NOT EXISTS (SELECT 1 FROM user_settings WHERE user_phone = $2)
+			RETURNING user_phone
+		)
+		INSERT INTO phone_changes (old_phone, new_phone, wallets_num, txs_num, source)
+		VALUES ($1, $2, (SELECT count(*) FROM moved_wallets), (SELECT count(*) FROM moved_txs), $3)
+		RETURNING id, old_phone, new_phone, wallets_num, txs_num, source, created_at`,
+		oldPhone, newPhone, source,
+	).StructScan(&change)
+	return
+}
+
+// CancelUserRecurringPlans cancels user recurring payment plans which are still active or paused
+func CancelUserRecurringPlans(tx db.ITx, userPhone string) (err error) {
+	rows, err := tx.Queryx(
+		`UPDATE recurring_plans SET status = 'canceled', updated_at = (now() at time zone 'UTC')
+		 WHERE user_phone = $1 AND status IN ('active', 'paused')`,
+		userPhone,
+	)
+	if err != nil {
+		return
+	}
+	return rows.Close()
+}
+
+// CancelUserPaymentRequests cancels pending payment requests which user is requester or payer of
+func CancelUserPaymentRequests(tx db.ITx, userPhone string) (err error) {
+	rows, err := tx.Queryx(
+		`UPDATE payment_requests SET status = 'canceled', updated_at = (now() at time zone 'UTC')
+		 WHERE (requester_phone = $1 OR payer_phone = $1) AND status = 'pending'`,
+		userPhone,
+	)
+	if err != nil {
+		return
+	}
+	return rows.Close()
+}
+
+// CancelMerchantInvoices cancels pending invoices of the merchant
+func CancelMerchantInvoices(tx db.ITx, merchantPhone string) (err error) {
+	rows, err := tx.Queryx(
+		`UPDATE invoices SET status = 'canceled', updated_at = (now() at time zone 'UTC')
+		 WHERE merchant_phone = $1 AND status = 'pending'`,
+		merchantPhone,
+	)
+	if err != nil {
+		return
+	}
+	return rows.Close()
+}
+
+// StartAccountClosure creates user account closure record or returns existing one
+func StartAccountClosure(tx db.ITx, userPhone string) (closure AccountClosure, err error) {
+	err = tx.QueryRowx(
+		`INSERT INTO account_closures (user_phone) VALUES ($1)
+		 ON CONFLICT ON CONSTRAINT account_closures_unique_user_phone_cst DO UPDATE
+		 SET updated_at = (now() at time zone 'UTC')
+		 RETURNING id, user_phone, status, created_at, updated_at`,
+		userPhone,
+	).StructScan(&closure)
+	return
+}
+
+// GetAccountClosure returns user account closure record, returns ErrNoSuchAccountClosure if closure isn't started
+func GetAccountClosure(tx db.ITx, userPhone string) (closure AccountClosure, err error) {
+	err = tx.QueryRowx(
+		`SELECT id, user_phone, status, created_at, updated_at FROM account_closures WHERE user_phone = $1`,
+		userPhone,
+	).StructScan(&closure)
+	if err == sql.ErrNoRows {
+		err = errs.ErrNoSuchAccountClosure
+	}
+	return
+}
+
+// isAccountClosedQuery selects whether user account closure is started or completed
+const isAccountClosedQuery = `SELECT EXISTS(SELECT 1 FROM account_closures WHERE user_phone = $1)`
+
+// ISQLTx is plain sql transaction, gorm exposes it's own one by CommonDB
+type ISQLTx interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// IsAccountClosed checks whether user account closure is started or completed
+func IsAccountClosed(tx db.ITx, userPhone string) (closed bool, err error) {
+	err = tx.QueryRowx(isAccountClosedQuery, userPhone).Scan(&closed)
+	return
+}
+
+// IsAccountClosedSQL is IsAccountClosed for plain sql transaction
+func IsAccountClosedSQL(tx ISQLTx, userPhone string) (closed bool, err error) {
+	err = tx.QueryRow(isAccountClosedQuery, userPhone).Scan(&closed)
+	return
+}
+
+// CancelUserPendingTxs cancels txs which await recipient either sent by the user or sent to the user phone and txs
+// scheduled by the user, so their held amounts are released. Returns source wallets ids of canceled txs.
+func CancelUserPendingTxs(tx db.ITx, userPhone string) (fromWalletIDs []int64, err error) {
+	rows, err := tx.Queryx(
+		`UPDATE txs SET
+			status_id = (SELECT id FROM tx_statuses WHERE name = 'cancel'),
+			updated_at = (now() at time zone 'UTC')
+		WHERE (
+			status_id = (SELECT id FROM tx_statuses WHERE name = 'pending') AND
+			(from_wallet_id IN (SELECT id FROM wallets WHERE user_phone = $1) OR to_phone = $1)
+		) OR (
+			status_id = (SELECT id FROM tx_statuses WHERE name = 'scheduled') AND
+			from_wallet_id IN (SELECT id FROM wallets WHERE user_phone = $1)
+		)
+		RETURNING from_wallet_id`,
+		userPhone,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		fromWalletIDs = append(fromWalletIDs, id)
+	}
+	err = rows.Err()
+	return
+}
+
+// CreateClosureSweep records wallet sweep before it's tx will be sent
+func CreateClosureSweep(tx db.ITx, closureID, walletID int64, address string, amount *decimal.Big) (
+	sweep ClosureSweep, err error,
+) {
+	err = tx.QueryRowx(
+		`INSERT INTO account_closure_sweeps (closure_id, wallet_id, address, amount) VALUES ($1, $2, $3, $4)
+		 RETURNING id, closure_id, wallet_id, address, amount, tx_id, created_at, '' AS tx_status`,
+		closureID, walletID, address, &postgres.Decimal{V: amount},
+	).StructScan(&sweep)
+	return
+}
+
+// SetClosureSweepTx binds sent tx to the sweep
+func SetClosureSweepTx(tx db.ITx, sweepID, txID int64) (err error) {
+	err = tx.QueryRowx(
+		`UPDATE account_closure_sweeps SET tx_id = $2 WHERE id = $1 RETURNING id`,
+		sweepID, txID,
+	).Scan(&sweepID)
+	return
+}
+
+// RecoverClosureSweepsTxs binds txs to the closure sweeps which txs have been sent but weren't recorded due to failure,
+// tx is matched by wallet, recipient address and amount among txs which aren't bound to other sweeps
+func RecoverClosureSweepsTxs(tx db.ITx, closureID int64) (recoveredNum int64, err error) {
+	err = tx.QueryRowx(
+		`WITH recovered AS (
+			UPDATE account_closure_sweeps s SET tx_id = (
+				SELECT txs.id FROM txs
+				WHERE txs.from_wallet_id = s.wallet_id AND txs.to_address = s.address AND txs.amount = s.amount AND
+					NOT EXISTS(SELECT 1 FROM account_closure_sweeps o WHERE o.tx_id = txs.id)
+				ORDER BY txs.id DESC LIMIT 1
+			)
+			WHERE s.closure_id = $1 AND s.tx_id IS NULL
+			RETURNING s.tx_id
+		)
+		SELECT count(tx_id) FROM recovered`,
+		closureID,
+	).Scan(&recoveredNum)
+	return
+}
+
+// GetClosureSweeps returns closure sweeps with their txs statuses ordered from oldest to newest
+func GetClosureSweeps(tx db.ITx, closureID int64) (sweeps []ClosureSweep, err error) {
+	rows, err := tx.Queryx(
+		`SELECT s.id, s.closure_id, s.wallet_id, s.address, s.amount, s.tx_id, s.created_at,
+			coalesce(tx_statuses.name, '') AS tx_status
+		FROM account_closure_sweeps s
+		LEFT JOIN txs ON txs.id = s.tx_id
+		LEFT JOIN tx_statuses ON tx_statuses.id = txs.status_id
+		WHERE s.closure_id = $1 ORDER BY s.id`,
+		closureID,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sweep ClosureSweep
+		err = rows.StructScan(&sweep)
+		if err != nil {
+			return
+		}
+		sweeps = append(sweeps, sweep)
+	}
+	err = rows.Err()
+	return
+}
+
+// CompleteAccountClosure marks all user wallets closed and closure completed
+func CompleteAccountClosure(tx db.ITx, closure *AccountClosure) (err error) {
+	err = tx.QueryRowx(
+		`WITH closed_wallets AS (
+			UPDATE wallets SET closed = true WHERE user_phone = $2 RETURNING id
+		)
+		UPDATE account_closures SET status = $3, updated_at = (now() at time zone 'UTC')
+		WHERE id = $1 RETURNING status, updated_at`,
+		closure.ID, closure.UserPhone, AccountClosureCompleted,
+	).Scan(&closure.Status, &closure.UpdatedAt)
+	return
+}
+
+// HasUnsettledTxs checks whether wallet has txs which are scheduled, queued for batch, hold their amount or await
+// recipient, either as sender or as recipient
+func HasUnsettledTxs(tx db.ITx, walletID int64) (has bool, err error) {
+	err = tx.QueryRowx(
+		`SELECT EXISTS(
+			SELECT 1 FROM txs
+			INNER JOIN tx_statuses ON tx_statuses.id = txs.status_id
+			WHERE (txs.from_wallet_id = $1 OR txs.to_wallet_id = $1) AND
+				tx_statuses.name = ANY(
+					'{scheduled, validation, queued_for_batch, send_external, pending, waiting}' :: varchar(30) []
+				)
+		)`,
+		walletID,
+	).Scan(&has)
+	return
+}
+
 // GetCoin request coin by short name, returns ErrNoSuchCoin if coin doesn't exists. Coin short name argument are
 // case insensitive.
 func GetCoin(tx db.ITx, coinShortName string) (coin Coin, err error) {
@@ -321,6 +735,11 @@ func scanWalletRow(row scannable, wallet *Wallet) error {
 		&wallet.Address,
 		&wallet.Secret,
 		&wallet.CreatedAt,
+		&wallet.IsDefault,
+		&wallet.DisplayOrder,
+		&wallet.Archived,
+		&wallet.WatchOnly,
+		&wallet.Closed,
 		&wallet.Coin.ID,
 		&wallet.Coin.Name,
 		&wallet.Coin.ShortName,
@@ -0,0 +1,127 @@
+package wallets_test
+
+import (
+	"testing"
+
+	"git.zam.io/wallet-backend/wallet-api/internal/helpers"
+	helpersmocks "git.zam.io/wallet-backend/wallet-api/internal/helpers/mocks"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes"
+	"git.zam.io/wallet-backend/wallet-api/internal/services/nodes/mocks"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
+	"git.zam.io/wallet-backend/web-api/fixtures/database"
+	"git.zam.io/wallet-backend/web-api/fixtures/database/migrations"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWallets(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Wallets Suite")
+}
+
+const (
+	testCoinName     = "TEST"
+	testCoinFullName = "Testing"
+	userPhone        = "+79109998877"
+	otherPhone       = "+79101112233"
+)
+
+// provideWalletsApi provides migrated db with test coin, wallets api which balances are always zero and coordinator
+// mock, must be called inside container
+func provideWalletsApi() {
+	Init()
+	database.Init()
+	migrations.Init()
+
+	BeforeEachCProvide(func() (nodes.ICoordinator, *mocks.ICoordinator) {
+		c := &mocks.ICoordinator{}
+		return c, c
+	})
+
+	BeforeEachCProvide(func() (helpers.IBalance, *helpersmocks.IBalance) {
+		b := &helpersmocks.IBalance{}
+		b.On("TotalWalletBalanceCtx", mock.Anything, mock.Anything).Return(new(decimal.Big), nil)
+		return b, b
+	})
+
+	BeforeEachCProvide(func(d *db.Db, coordinator nodes.ICoordinator, balance helpers.IBalance) *wallets.Api {
+		return wallets.NewApi(d, coordinator, nil, balance, nil)
+	})
+
+	BeforeEachCInvoke(func(d *db.Db) {
+		_, err := d.Exec(
+			"insert into coins (name, short_name, enabled) values ($1, $2, true)", testCoinFullName, testCoinName,
+		)
+		Expect(err).NotTo(HaveOccurred())
+	})
+}
+
+// createWallet creates wallet of the test coin which address and secret are derived from the name
+func createWallet(d *db.Db, phone, name string, watchOnly bool) queries.Wallet {
+	w, err := queries.CreateWallet(d, queries.Wallet{
+		UserPhone: phone,
+		Name:      name,
+		Address:   phone + "-" + name,
+		WatchOnly: watchOnly,
+		Coin:      queries.Coin{ShortName: testCoinName},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	// secret is set after address generation
+	w.Secret = "secret-" + name
+	err = queries.UpdateWallet(d, w.ID, &queries.WalletDiff{Secret: &w.Secret})
+	Expect(err).NotTo(HaveOccurred())
+	return w
+}
+
+// walletsNames returns names of user wallets in listing order
+func walletsNames(d *db.Db, filters queries.GetWalletFilters) []string {
+	filters.Enabled = true
+	wts, _, _, err := queries.GetWallets(d, filters)
+	Expect(err).NotTo(HaveOccurred())
+
+	names := make([]string, 0, len(wts))
+	for _, w := range wts {
+		names = append(names, w.Name)
+	}
+	return names
+}
+
+// insertTx inserts internal tx of the wallet with given status which is sent to other phone
+func insertTx(d *db.Db, fromWalletID int64, status string) int64 {
+	return insertTxTo(d, fromWalletID, otherPhone, status)
+}
+
+// insertTxTo inserts internal tx of the wallet with given status which is sent to the phone, returns tx id
+func insertTxTo(d *db.Db, fromWalletID int64, toPhone, status string) (id int64) {
+	err := d.QueryRowx(
+		`insert into txs (from_wallet_id, to_phone, type, amount, status_id)
+		values ($1, $2, 'internal', 1, (select id from tx_statuses where name = $3))
+		returning id`,
+		fromWalletID, toPhone, status,
+	).Scan(&id)
+	Expect(err).NotTo(HaveOccurred())
+	return
+}
+
+// txStatus returns tx status name
+func txStatus(d *db.Db, id int64) (status string) {
+	err := d.QueryRowx(
+		`select s.name from txs t join tx_statuses s on s.id = t.status_id where t.id = $1`, id,
+	).Scan(&status)
+	Expect(err).NotTo(HaveOccurred())
+	return
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
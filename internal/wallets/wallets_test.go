@@ -1,9 +1,206 @@
 package wallets_test
 
 import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/errs"
+	"git.zam.io/wallet-backend/wallet-api/internal/wallets/queries"
+	"git.zam.io/wallet-backend/web-api/db"
+	. "git.zam.io/wallet-backend/web-api/fixtures"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("Wallets", func() {
+var _ = Describe("testing wallets", func() {
+	provideWalletsApi()
 
+	Context("when user has several wallets of the same coin", func() {
+		ItD("should make only the first wallet default", func(d *db.Db) {
+			first := createWallet(d, userPhone, "first", false)
+			second := createWallet(d, userPhone, "second", false)
+			other := createWallet(d, otherPhone, "first", false)
+
+			Expect(first.IsDefault).To(BeTrue())
+			Expect(second.IsDefault).To(BeFalse())
+			Expect(other.IsDefault).To(BeTrue())
+		})
+
+		for _, c := range []struct {
+			label    string
+			phone    string
+			name     string
+			expected error
+		}{
+			{"should reject wallet with the same name", userPhone, "first", errs.ErrWalletCreationRejected},
+			{"should accept wallet with other name", userPhone, "second", nil},
+			{"should accept wallet with the same name of other user", otherPhone, "first", nil},
+		} {
+			c := c
+			ItD(c.label, func(d *db.Db) {
+				createWallet(d, userPhone, "first", false)
+
+				_, err := queries.CreateWallet(d, queries.Wallet{
+					UserPhone: c.phone,
+					Name:      c.name,
+					Coin:      queries.Coin{ShortName: testCoinName},
+				})
+				Expect(err).To(Equal(c.expected))
+			})
+		}
+
+		ItD("should reject wallet of unknown coin", func(d *db.Db) {
+			_, err := queries.CreateWallet(d, queries.Wallet{
+				UserPhone: userPhone,
+				Name:      "first",
+				Coin:      queries.Coin{ShortName: "UNKN"},
+			})
+			Expect(err).To(Equal(errs.ErrNoSuchCoin))
+		})
+
+		ItD("should move default to the other wallet", func(d *db.Db, api *wallets.Api) {
+			first := createWallet(d, userPhone, "first", false)
+			second := createWallet(d, userPhone, "second", false)
+
+			Expect(api.SetDefaultWallet(context.Background(), userPhone, second.ID)).To(Succeed())
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone, OnlyDefault: true})).To(
+				Equal([]string{"second"}),
+			)
+
+			By("keeping default wallet if it's set again")
+			Expect(api.SetDefaultWallet(context.Background(), userPhone, second.ID)).To(Succeed())
+			Expect(api.SetDefaultWallet(context.Background(), userPhone, first.ID)).To(Succeed())
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone, OnlyDefault: true})).To(
+				Equal([]string{"first"}),
+			)
+		})
+
+		ItD("should not make watch-only wallet default", func(d *db.Db, api *wallets.Api) {
+			createWallet(d, userPhone, "first", false)
+			watched := createWallet(d, userPhone, "watched", true)
+
+			err := api.SetDefaultWallet(context.Background(), userPhone, watched.ID)
+			Expect(err).To(Equal(errs.ErrWatchOnlyWallet))
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone, OnlyDefault: true})).To(
+				Equal([]string{"first"}),
+			)
+		})
+
+		ItD("should not make default wallet of other user", func(d *db.Db, api *wallets.Api) {
+			createWallet(d, userPhone, "first", false)
+			other := createWallet(d, otherPhone, "other", false)
+
+			err := api.SetDefaultWallet(context.Background(), userPhone, other.ID)
+			Expect(err).To(Equal(errs.ErrNoSuchWallet))
+		})
+	})
+
+	Context("when updating wallets", func() {
+		ItD("should rename wallet unless name is taken", func(d *db.Db, api *wallets.Api) {
+			first := createWallet(d, userPhone, "first", false)
+			createWallet(d, userPhone, "second", false)
+
+			renamed, err := api.UpdateWallet(context.Background(), userPhone, first.ID, strPtr("renamed"), nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(renamed.Name).To(Equal("renamed"))
+
+			_, err = api.UpdateWallet(context.Background(), userPhone, first.ID, strPtr("second"), nil, nil)
+			Expect(err).To(Equal(errs.ErrWalletUpdateRejected))
+
+			_, err = api.UpdateWallet(context.Background(), otherPhone, first.ID, strPtr("other"), nil, nil)
+			Expect(err).To(Equal(errs.ErrNoSuchWallet))
+		})
+
+		for _, c := range []struct {
+			label    string
+			orders   map[string]int64
+			expected []string
+		}{
+			{"should list wallets by id if order isn't set", nil, []string{"a", "b", "c"}},
+			{"should list wallets by display order", map[string]int64{"a": 3, "b": 1, "c": 2}, []string{"b", "c", "a"}},
+			{"should list wallets of the same order by id", map[string]int64{"a": 1, "b": 1}, []string{"c", "a", "b"}},
+		} {
+			c := c
+			ItD(c.label, func(d *db.Db, api *wallets.Api) {
+				ids := map[string]int64{}
+				for _, name := range []string{"a", "b", "c"} {
+					ids[name] = createWallet(d, userPhone, name, false).ID
+				}
+				for name, order := range c.orders {
+					order := order
+					_, err := api.UpdateWallet(context.Background(), userPhone, ids[name], nil, &order, nil)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				wts, totalCount, hasNext, err := api.GetWallets(
+					context.Background(), userPhone, wallets.GetWalletsFilters{}, false,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(totalCount).To(BeEquivalentTo(3))
+				Expect(hasNext).To(BeFalse())
+				names := make([]string, 0, len(wts))
+				for _, w := range wts {
+					names = append(names, w.Name)
+				}
+				Expect(names).To(Equal(c.expected))
+
+				By("paginating in the same order")
+				first, _, hasNext, err := api.GetWallets(
+					context.Background(), userPhone, wallets.GetWalletsFilters{Count: 2}, false,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hasNext).To(BeTrue())
+				Expect(first).To(HaveLen(2))
+				rest, _, hasNext, err := api.GetWallets(
+					context.Background(), userPhone, wallets.GetWalletsFilters{FromID: first[1].ID, Count: 2}, false,
+				)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hasNext).To(BeFalse())
+				Expect(rest).To(HaveLen(1))
+				Expect(rest[0].Name).To(Equal(c.expected[2]))
+			})
+		}
+
+		ItD("should hide archived wallet from list", func(d *db.Db, api *wallets.Api) {
+			first := createWallet(d, userPhone, "first", false)
+			createWallet(d, userPhone, "second", false)
+
+			archived, err := api.UpdateWallet(context.Background(), userPhone, first.ID, nil, nil, boolPtr(true))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(archived.Archived).To(BeTrue())
+
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone})).To(Equal([]string{"second"}))
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone, WithArchived: true})).To(
+				Equal([]string{"first", "second"}),
+			)
+
+			By("unarchiving wallet")
+			_, err = api.UpdateWallet(context.Background(), userPhone, first.ID, nil, nil, boolPtr(false))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(walletsNames(d, queries.GetWalletFilters{UserPhone: userPhone})).To(
+				Equal([]string{"first", "second"}),
+			)
+		})
+
+		for _, status := range []string{"pending", "scheduled", "queued_for_batch", "validation"} {
+			status := status
+			ItD("should not archive wallet which has "+status+" tx", func(d *db.Db, api *wallets.Api) {
+				w := createWallet(d, userPhone, "first", false)
+				insertTx(d, w.ID, status)
+
+				_, err := api.UpdateWallet(context.Background(), userPhone, w.ID, nil, nil, boolPtr(true))
+				Expect(err).To(Equal(errs.ErrWalletHasUnsettledTxs))
+			})
+		}
+
+		for _, status := range []string{"success", "decline", "cancel"} {
+			status := status
+			ItD("should archive wallet which has "+status+" tx", func(d *db.Db, api *wallets.Api) {
+				w := createWallet(d, userPhone, "first", false)
+				insertTx(d, w.ID, status)
+
+				_, err := api.UpdateWallet(context.Background(), userPhone, w.ID, nil, nil, boolPtr(true))
+				Expect(err).NotTo(HaveOccurred())
+			})
+		}
+	})
 })
@@ -0,0 +1,2 @@
+// Package qr renders QR codes as data uris, which may be embedded into json responses
+package qr
@@ -0,0 +1,85 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// FormatPNG renders QR code as png image
+	FormatPNG = "png"
+
+	// FormatSVG renders QR code as svg image
+	FormatSVG = "svg"
+
+	// DefaultSize is the default image side size in pixels
+	DefaultSize = 256
+
+	// MinSize and MaxSize limits image side size in pixels
+	MinSize = 64
+	MaxSize = 1024
+)
+
+var (
+	// ErrInvalidFormat returned when unknown image format is requested
+	ErrInvalidFormat = errors.New("qr: invalid image format")
+
+	// ErrInvalidSize returned when image size is out of the limits
+	ErrInvalidSize = errors.New("qr: invalid image size")
+)
+
+// Render encodes content into QR code with medium error recovery level and renders it as base64 data uri of the
+// image of given format and side size in pixels
+func Render(content, format string, size int) (string, error) {
+	if size < MinSize || size > MaxSize {
+		return "", ErrInvalidSize
+	}
+
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		mimeType string
+		image    []byte
+	)
+	switch format {
+	case FormatPNG:
+		mimeType = "image/png"
+		image, err = code.PNG(size)
+		if err != nil {
+			return "", err
+		}
+	case FormatSVG:
+		mimeType = "image/svg+xml"
+		image = renderSVG(code.Bitmap(), size)
+	default:
+		return "", ErrInvalidFormat
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(image), nil
+}
+
+// renderSVG renders QR code bitmap (quiet zone included) as svg, each dark module is drawn as unit square of the single
+// path, so image scales without blur
+func renderSVG(bitmap [][]bool, size int) []byte {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(
+		&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, len(bitmap), len(bitmap),
+	)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
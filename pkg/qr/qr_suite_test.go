@@ -0,0 +1,92 @@
+package qr_test
+
+import (
+	"testing"
+
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"git.zam.io/wallet-backend/wallet-api/pkg/qr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"image/png"
+	"strings"
+)
+
+func TestQr(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "QR Suite")
+}
+
+// decodeDataURI splits data uri into mime type and decoded content
+func decodeDataURI(dataURI string) (mimeType string, content []byte) {
+	Expect(dataURI).To(HavePrefix("data:"))
+	sepPos := strings.Index(dataURI, ";base64,")
+	Expect(sepPos).To(BeNumerically(">", 0))
+
+	content, err := base64.StdEncoding.DecodeString(dataURI[sepPos+len(";base64,"):])
+	Expect(err).NotTo(HaveOccurred())
+	return dataURI[len("data:"):sepPos], content
+}
+
+var _ = Describe("testing QR codes rendering", func() {
+	const content = "bitcoin:1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu?amount=0.015"
+
+	for _, size := range []int{qr.MinSize, qr.DefaultSize, qr.MaxSize} {
+		size := size
+		It(fmt.Sprintf("should render png of %dpx size", size), func() {
+			dataURI, err := qr.Render(content, qr.FormatPNG, size)
+			Expect(err).NotTo(HaveOccurred())
+
+			mimeType, image := decodeDataURI(dataURI)
+			Expect(mimeType).To(Equal("image/png"))
+			config, err := png.DecodeConfig(bytes.NewReader(image))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Width).To(Equal(size))
+			Expect(config.Height).To(Equal(size))
+		})
+	}
+
+	It("should render scalable svg which view box is the modules grid", func() {
+		dataURI, err := qr.Render(content, qr.FormatSVG, qr.DefaultSize)
+		Expect(err).NotTo(HaveOccurred())
+
+		mimeType, image := decodeDataURI(dataURI)
+		Expect(mimeType).To(Equal("image/svg+xml"))
+		svg := string(image)
+		Expect(svg).To(HavePrefix(`<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256" viewBox="0 0 `))
+		Expect(svg).To(HaveSuffix(`"/></svg>`))
+		Expect(svg).To(ContainSubstring(`<path fill="#000000" d="M`))
+	})
+
+	It("should render the same content into the same image", func() {
+		first, err := qr.Render(content, qr.FormatSVG, qr.DefaultSize)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := qr.Render(content, qr.FormatSVG, qr.DefaultSize)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).To(Equal(first))
+
+		other, err := qr.Render(content+"1", qr.FormatSVG, qr.DefaultSize)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(other).NotTo(Equal(first))
+	})
+
+	for _, c := range []struct {
+		label  string
+		format string
+		size   int
+		err    error
+	}{
+		{"should reject too small image", qr.FormatPNG, qr.MinSize - 1, qr.ErrInvalidSize},
+		{"should reject too large image", qr.FormatSVG, qr.MaxSize + 1, qr.ErrInvalidSize},
+		{"should reject unknown format", "gif", qr.DefaultSize, qr.ErrInvalidFormat},
+		{"should reject empty format", "", qr.DefaultSize, qr.ErrInvalidFormat},
+	} {
+		c := c
+		It(c.label, func() {
+			dataURI, err := qr.Render(content, c.format, c.size)
+			Expect(err).To(Equal(c.err))
+			Expect(dataURI).To(BeEmpty())
+		})
+	}
+})
@@ -0,0 +1,237 @@
+package cache_test
+
+import (
+	"testing"
+
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/cache"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sync"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cache Suite")
+}
+
+// sourceConverter counts queries and serves the same rate of any coin, queries wait for release if it's set
+type sourceConverter struct {
+	mu       sync.Mutex
+	calls    int
+	err      error
+	release  chan struct{}
+	ctxErrs  []error
+	rateText string
+}
+
+func (s *sourceConverter) query(ctx context.Context) (rate convert.Rate, err error) {
+	s.mu.Lock()
+	s.calls++
+	release := s.release
+	s.mu.Unlock()
+
+	if release != nil {
+		<-release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctxErrs = append(s.ctxErrs, ctx.Err())
+	if s.err != nil {
+		return rate, s.err
+	}
+	value, _ := new(decimal.Big).SetString(s.rateText)
+	return convert.Rate(*value), nil
+}
+
+func (s *sourceConverter) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (
+	*convert.Rate, error,
+) {
+	rate, err := s.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (s *sourceConverter) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	convert.MultiRate, error,
+) {
+	rate, err := s.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mr := make(convert.MultiRate, len(coinNames))
+	for _, coinName := range coinNames {
+		mr[coinName] = rate
+	}
+	return mr, nil
+}
+
+func (s *sourceConverter) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *sourceConverter) callsNum() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func expectRate(rate *convert.Rate, expected string) {
+	Expect(rate).NotTo(BeNil())
+	Expect((*decimal.Big)(rate).String()).To(Equal(expected))
+}
+
+var _ = Describe("testing rates cache", func() {
+	var source *sourceConverter
+
+	BeforeEach(func() {
+		source = &sourceConverter{rateText: "1.5"}
+	})
+
+	It("should serve cached rate until ttl expires", func() {
+		c := cache.New(source, cache.Params{TTL: 50 * time.Millisecond})
+
+		for i := 0; i < 3; i++ {
+			rate, err := c.GetRate(context.Background(), "btc", "usd")
+			Expect(err).NotTo(HaveOccurred())
+			expectRate(rate, "1.5")
+		}
+		Expect(source.callsNum()).To(Equal(1))
+
+		time.Sleep(60 * time.Millisecond)
+		_, err := c.GetRate(context.Background(), "BTC", "USD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.callsNum()).To(Equal(2))
+		Expect(c.Stats()).To(Equal(cache.Stats{Hits: 2, Misses: 2}))
+	})
+
+	It("should apply pair ttl", func() {
+		c := cache.New(source, cache.Params{
+			TTL:      time.Minute,
+			PairsTTL: map[string]time.Duration{"btc/usd": 10 * time.Millisecond},
+		})
+
+		_, err := c.GetMultiRate(context.Background(), []string{"btc", "eth"}, "usd")
+		Expect(err).NotTo(HaveOccurred())
+		time.Sleep(20 * time.Millisecond)
+
+		_, err = c.GetRate(context.Background(), "eth", "usd")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.callsNum()).To(Equal(1))
+		_, err = c.GetRate(context.Background(), "btc", "usd")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.callsNum()).To(Equal(2))
+	})
+
+	Context("when source fails", func() {
+		var c *cache.CryptoCurrency
+
+		BeforeEach(func() {
+			c = cache.New(source, cache.Params{TTL: 10 * time.Millisecond, StaleTTL: time.Minute})
+			_, err := c.GetRate(context.Background(), "btc", "usd")
+			Expect(err).NotTo(HaveOccurred())
+
+			source.setErr(convert.ErrUnavailable)
+			time.Sleep(20 * time.Millisecond)
+		})
+
+		It("should serve stale rate within stale tracking context", func() {
+			ctx := convert.WithStaleTracking(context.Background())
+			rate, err := c.GetRate(ctx, "btc", "usd")
+			Expect(err).NotTo(HaveOccurred())
+			expectRate(rate, "1.5")
+			Expect(convert.IsStale(ctx)).To(BeTrue())
+			Expect(c.Stats().StaleHits).To(Equal(uint64(1)))
+			Expect(c.Stats().Errors).To(Equal(uint64(1)))
+		})
+
+		It("should return error if stale rates aren't allowed", func() {
+			_, err := c.GetRate(context.Background(), "btc", "usd")
+			Expect(err).To(Equal(convert.ErrUnavailable))
+		})
+
+		It("should return error if some pair has no stale rate", func() {
+			ctx := convert.WithStaleTracking(context.Background())
+			_, err := c.GetMultiRate(ctx, []string{"btc", "eth"}, "usd")
+			Expect(err).To(Equal(convert.ErrUnavailable))
+			Expect(convert.IsStale(ctx)).To(BeFalse())
+		})
+
+		It("should report unknown coin even if stale rate exists", func() {
+			source.setErr(convert.ErrCryptoCurrencyName)
+			_, err := c.GetRate(convert.WithStaleTracking(context.Background()), "btc", "usd")
+			Expect(err).To(Equal(convert.ErrCryptoCurrencyName))
+		})
+	})
+
+	Context("when concurrent queries occurs", func() {
+		const callersNum = 10
+
+		It("should coalesce them into single source query", func() {
+			source.release = make(chan struct{})
+			c := cache.New(source, cache.Params{TTL: time.Minute})
+
+			var wg sync.WaitGroup
+			errs := make(chan error, callersNum)
+			for i := 0; i < callersNum; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := c.GetRate(context.Background(), "btc", "usd")
+					errs <- err
+				}()
+			}
+			Eventually(source.callsNum).Should(Equal(1))
+			// let other callers join the query
+			time.Sleep(50 * time.Millisecond)
+			close(source.release)
+			wg.Wait()
+
+			close(errs)
+			for err := range errs {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(source.callsNum()).To(Equal(1))
+		})
+
+		It("should complete query for other callers if the first one gives up", func() {
+			source.release = make(chan struct{})
+			c := cache.New(source, cache.Params{TTL: time.Minute})
+
+			firstCtx, cancel := context.WithCancel(context.Background())
+			firstErr := make(chan error, 1)
+			go func() {
+				_, err := c.GetRate(firstCtx, "btc", "usd")
+				firstErr <- err
+			}()
+			Eventually(source.callsNum).Should(Equal(1))
+
+			secondRate := make(chan *convert.Rate, 1)
+			go func() {
+				defer GinkgoRecover()
+				rate, err := c.GetRate(context.Background(), "btc", "usd")
+				Expect(err).NotTo(HaveOccurred())
+				secondRate <- rate
+			}()
+			time.Sleep(50 * time.Millisecond)
+
+			cancel()
+			Eventually(firstErr).Should(Receive(Equal(context.Canceled)))
+
+			close(source.release)
+			var rate *convert.Rate
+			Eventually(secondRate).Should(Receive(&rate))
+			expectRate(rate, "1.5")
+			Expect(source.callsNum()).To(Equal(1))
+			Expect(source.ctxErrs).To(Equal([]error{nil}))
+		})
+	})
+})
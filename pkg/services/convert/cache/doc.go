@@ -0,0 +1,2 @@
+// Package cache implements converter which caches rates of another converter
+package cache
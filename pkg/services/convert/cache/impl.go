@@ -0,0 +1,471 @@
+package cache
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fetchTimeout limits converter query duration. Queries are shared by concurrent callers, so they don't inherit
+// deadline nor cancellation of the caller which started them.
+const fetchTimeout = time.Second * 30
+
+// Params of the cache
+type Params struct {
+	// TTL during which cached rate is served without querying converter
+	TTL time.Duration
+
+	// PairsTTL overrides TTL for specific pairs, keys are "coin/fiat" pairs in any case, e.g. "zam/usd"
+	PairsTTL map[string]time.Duration
+
+	// RefreshAhead is the period before rate expiration during which it's refreshed in background on request
+	RefreshAhead time.Duration
+
+	// StaleTTL is the max age of rate which may be served if converter fails, outdated rates are served only within
+	// context created by convert.WithStaleTracking
+	StaleTTL time.Duration
+}
+
+// Stats holds cache usage counters, each requested pair is counted separately
+type Stats struct {
+	// Hits number of pairs served from cache without querying converter
+	Hits uint64
+
+	// StaleHits number of outdated pairs served from cache since converter failed
+	StaleHits uint64
+
+	// Misses number of pairs queried from converter
+	Misses uint64
+
+	// Errors number of failed converter queries
+	Errors uint64
+}
+
+// HitRatio returns part of pairs served from cache, either fresh or stale
+func (s Stats) HitRatio() float64 {
+	served := s.Hits + s.StaleHits
+	if total := served + s.Misses; total > 0 {
+		return float64(served) / float64(total)
+	}
+	return 0
+}
+
+// IStats provides cache usage statistics
+type IStats interface {
+	// Stats returns counters accumulated since cache creation
+	Stats() Stats
+}
+
+type entry struct {
+	rate       convert.Rate
+	fetchedAt  time.Time
+	sourcesNum int
+	refreshing bool
+}
+
+// fetchResult holds converter query result along with the number of sources which agree on the rates
+type fetchResult struct {
+	rates      interface{}
+	sourcesNum int
+}
+
+// CryptoCurrency implements converter which caches rates of another converter, concurrent queries of the same pairs
+// are coalesced into single converter query
+type CryptoCurrency struct {
+	upstream convert.ICryptoCurrency
+	params   Params
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+	group   singleflight.Group
+
+	hits, staleHits, misses, errors uint64
+}
+
+// interfaces compile-time validations
+var (
+	_ IStats               = (*CryptoCurrency)(nil)
+	_ convert.IMatrixRates = (*CryptoCurrency)(nil)
+)
+
+// New creates caching converter on top of upstream one
+func New(upstream convert.ICryptoCurrency, params Params) *CryptoCurrency {
+	pairsTTL := make(map[string]time.Duration, len(params.PairsTTL))
+	for pair, ttl := range params.PairsTTL {
+		pairsTTL[strings.ToUpper(pair)] = ttl
+	}
+	params.PairsTTL = pairsTTL
+	return &CryptoCurrency{upstream: upstream, params: params, entries: make(map[string]*entry)}
+}
+
+// GetRate implements ICryptoCurrency
+func (c *CryptoCurrency) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (
+	rate *convert.Rate, err error,
+) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "cache_get_rate")
+	defer span.Finish()
+
+	mr, err := c.GetMultiRate(ctx, []string{coinName}, dstCurrencyName)
+	if err != nil {
+		return
+	}
+	rate = mr.CurrencyRate(coinName)
+	if rate == nil {
+		err = convert.ErrCryptoCurrencyName
+	}
+	return
+}
+
+// GetMultiRate implements ICryptoCurrency, only expired pairs are queried from converter
+func (c *CryptoCurrency) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	mr convert.MultiRate, err error,
+) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "cache_get_multi_rate")
+	defer span.Finish()
+
+	mr = make(convert.MultiRate, len(coinNames))
+	missing, ahead := c.lookup(ctx, coinNames, dstCurrencyName, mr, time.Now())
+	span.LogKV("hits_num", len(coinNames)-len(missing), "misses_num", len(missing))
+	if len(ahead) > 0 {
+		span.LogKV("refresh_ahead", strings.Join(ahead, ","))
+		go c.refresh(ahead, dstCurrencyName)
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	fetched, err := c.fetch(ctx, missing, dstCurrencyName)
+	if err == nil {
+		atomic.AddUint64(&c.misses, uint64(len(missing)))
+		for _, coinName := range missing {
+			if rate := fetched.CurrencyRate(coinName); rate != nil {
+				mr[strings.ToUpper(coinName)] = *rate
+			}
+		}
+		return
+	}
+	trace.LogError(span, err)
+
+	// serve outdated rates only if all missing pairs have them
+	stale, ok := c.staleRates(ctx, missing, dstCurrencyName, err, time.Now())
+	if !ok {
+		atomic.AddUint64(&c.misses, uint64(len(missing)))
+		return nil, err
+	}
+	for coinName, rate := range stale {
+		mr[coinName] = rate
+	}
+	atomic.AddUint64(&c.staleHits, uint64(len(missing)))
+	convert.MarkStale(ctx)
+	span.LogKV("stale", true)
+	return mr, nil
+}
+
+// GetRatesMatrix implements IMatrixRates, expired pairs of all currencies are queried by single converter call if
+// converter implements IMatrixRates, otherwise matrix is derived from cached rates using cross rates
+func (c *CryptoCurrency) GetRatesMatrix(ctx context.Context, coinNames []string, dstCurrencyNames []string) (
+	m convert.RatesMatrix, err error,
+) {
+	upstream, ok := c.upstream.(convert.IMatrixRates)
+	if !ok {
+		return convert.CrossRatesMatrix(c, ctx, coinNames, dstCurrencyNames)
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "cache_get_rates_matrix")
+	defer span.Finish()
+
+	var (
+		now            = time.Now()
+		missing        = make(map[string][]string)
+		missingCoinSet = make(map[string]struct{})
+		missingNum     int
+	)
+	m = make(convert.RatesMatrix, len(dstCurrencyNames))
+	for _, currency := range dstCurrencyNames {
+		currency = strings.ToUpper(currency)
+		mr := make(convert.MultiRate, len(coinNames))
+		currencyMissing, ahead := c.lookup(ctx, coinNames, currency, mr, now)
+		if len(ahead) > 0 {
+			go c.refresh(ahead, currency)
+		}
+		m[currency] = mr
+		if len(currencyMissing) > 0 {
+			missing[currency] = currencyMissing
+			missingNum += len(currencyMissing)
+			for _, coinName := range currencyMissing {
+				missingCoinSet[strings.ToUpper(coinName)] = struct{}{}
+			}
+		}
+	}
+	span.LogKV("misses_num", missingNum)
+	if len(missing) == 0 {
+		return
+	}
+
+	missingCoins := make([]string, 0, len(missingCoinSet))
+	for coinName := range missingCoinSet {
+		missingCoins = append(missingCoins, coinName)
+	}
+	missingCurrencies := make([]string, 0, len(missing))
+	for currency := range missing {
+		missingCurrencies = append(missingCurrencies, currency)
+	}
+
+	fetched, err := c.fetchMatrix(ctx, upstream, missingCoins, missingCurrencies)
+	if err == nil {
+		atomic.AddUint64(&c.misses, uint64(missingNum))
+		for currency, coins := range missing {
+			for _, coinName := range coins {
+				if rate := fetched.FiatRates(currency).CurrencyRate(coinName); rate != nil {
+					m[currency][strings.ToUpper(coinName)] = *rate
+				}
+			}
+		}
+		return
+	}
+	trace.LogError(span, err)
+
+	// serve outdated rates only if all missing pairs have them
+	for currency, coins := range missing {
+		stale, ok := c.staleRates(ctx, coins, currency, err, now)
+		if !ok {
+			atomic.AddUint64(&c.misses, uint64(missingNum))
+			return nil, err
+		}
+		for coinName, rate := range stale {
+			m[currency][coinName] = rate
+		}
+	}
+	atomic.AddUint64(&c.staleHits, uint64(missingNum))
+	convert.MarkStale(ctx)
+	span.LogKV("stale", true)
+	return m, nil
+}
+
+// Stats implements IStats
+func (c *CryptoCurrency) Stats() Stats {
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		StaleHits: atomic.LoadUint64(&c.staleHits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Errors:    atomic.LoadUint64(&c.errors),
+	}
+}
+
+// lookup fills rates of fresh pairs, returns names of coins which rates are missing or expired and ones which should
+// be refreshed in background. Number of sources which agree on filled rates is reported into the context.
+func (c *CryptoCurrency) lookup(
+	ctx context.Context, coinNames []string, dstCurrencyName string, mr convert.MultiRate, now time.Time,
+) (missing, ahead []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, coinName := range coinNames {
+		key := pairKey(coinName, dstCurrencyName)
+		e, ok := c.entries[key]
+		ttl := c.ttl(key)
+		if !ok || now.Sub(e.fetchedAt) >= ttl {
+			missing = append(missing, coinName)
+			continue
+		}
+		mr[strings.ToUpper(coinName)] = e.rate
+		convert.ReportSources(ctx, e.sourcesNum)
+		if !e.refreshing && now.Sub(e.fetchedAt) >= ttl-c.params.RefreshAhead {
+			e.refreshing = true
+			ahead = append(ahead, coinName)
+		}
+	}
+	atomic.AddUint64(&c.hits, uint64(len(coinNames)-len(missing)))
+	return
+}
+
+// staleRates returns outdated rates of all given coins if converter error allows to serve them
+func (c *CryptoCurrency) staleRates(
+	ctx context.Context, coinNames []string, dstCurrencyName string, err error, now time.Time,
+) (convert.MultiRate, bool) {
+	// unknown names must be reported even if rates are cached
+	cause := errors.Cause(err)
+	if cause == convert.ErrCryptoCurrencyName || cause == convert.ErrFiatCurrencyName || !convert.StaleAllowed(ctx) {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	mr := make(convert.MultiRate, len(coinNames))
+	for _, coinName := range coinNames {
+		e, ok := c.entries[pairKey(coinName, dstCurrencyName)]
+		if !ok || now.Sub(e.fetchedAt) > c.params.StaleTTL {
+			return nil, false
+		}
+		mr[strings.ToUpper(coinName)] = e.rate
+	}
+	for _, coinName := range coinNames {
+		convert.ReportSources(ctx, c.entries[pairKey(coinName, dstCurrencyName)].sourcesNum)
+	}
+	return mr, true
+}
+
+// refresh queries rates in background, so they don't expire while requested
+func (c *CryptoCurrency) refresh(coinNames []string, dstCurrencyName string) {
+	if _, err := c.fetch(context.Background(), coinNames, dstCurrencyName); err != nil {
+		// allow next request to retry
+		c.mu.Lock()
+		for _, coinName := range coinNames {
+			if e, ok := c.entries[pairKey(coinName, dstCurrencyName)]; ok {
+				e.refreshing = false
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// fetch queries rates from converter and stores them, concurrent fetches of the same coins set are coalesced
+func (c *CryptoCurrency) fetch(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	convert.MultiRate, error,
+) {
+	names := make([]string, len(coinNames))
+	for i, coinName := range coinNames {
+		names[i] = strings.ToUpper(coinName)
+	}
+	sort.Strings(names)
+	dstCurrencyName = strings.ToUpper(dstCurrencyName)
+
+	key := dstCurrencyName + ":" + strings.Join(names, ",")
+	res, err := c.do(ctx, key, func(ctx context.Context) (fetchResult, error) {
+		fetchedAt := time.Now()
+		ctx = convert.WithSourcesTracking(ctx)
+
+		var mr convert.MultiRate
+		if len(names) == 1 {
+			rate, err := c.upstream.GetRate(ctx, names[0], dstCurrencyName)
+			if err != nil {
+				return fetchResult{}, err
+			}
+			mr = convert.MultiRate{names[0]: *rate}
+		} else {
+			// copy coins array because it may be modified inside converter
+			upstreamNames := make([]string, len(names))
+			copy(upstreamNames, names)
+
+			var err error
+			mr, err = c.upstream.GetMultiRate(ctx, upstreamNames, dstCurrencyName)
+			if err != nil {
+				return fetchResult{}, err
+			}
+		}
+
+		sourcesNum := convert.SourcesNum(ctx)
+		c.store(mr, dstCurrencyName, fetchedAt, sourcesNum)
+		return fetchResult{rates: mr, sourcesNum: sourcesNum}, nil
+	})
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+		return nil, err
+	}
+	convert.ReportSources(ctx, res.sourcesNum)
+	return res.rates.(convert.MultiRate), nil
+}
+
+// fetchMatrix same as fetch, but queries rates to multiple currencies by single converter call
+func (c *CryptoCurrency) fetchMatrix(
+	ctx context.Context, upstream convert.IMatrixRates, coinNames []string, dstCurrencyNames []string,
+) (convert.RatesMatrix, error) {
+	names := make([]string, len(coinNames))
+	for i, coinName := range coinNames {
+		names[i] = strings.ToUpper(coinName)
+	}
+	sort.Strings(names)
+	currencies := make([]string, len(dstCurrencyNames))
+	for i, currency := range dstCurrencyNames {
+		currencies[i] = strings.ToUpper(currency)
+	}
+	sort.Strings(currencies)
+
+	// key differs from fetch ones, since results types differ
+	key := "matrix:" + strings.Join(currencies, ",") + ":" + strings.Join(names, ",")
+	res, err := c.do(ctx, key, func(ctx context.Context) (fetchResult, error) {
+		fetchedAt := time.Now()
+		ctx = convert.WithSourcesTracking(ctx)
+
+		// copy coins array because it may be modified inside converter
+		upstreamNames := make([]string, len(names))
+		copy(upstreamNames, names)
+
+		m, err := upstream.GetRatesMatrix(ctx, upstreamNames, currencies)
+		if err != nil {
+			return fetchResult{}, err
+		}
+		sourcesNum := convert.SourcesNum(ctx)
+		for currency, mr := range m {
+			c.store(mr, currency, fetchedAt, sourcesNum)
+		}
+		return fetchResult{rates: m, sourcesNum: sourcesNum}, nil
+	})
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+		return nil, err
+	}
+	convert.ReportSources(ctx, res.sourcesNum)
+	return res.rates.(convert.RatesMatrix), nil
+}
+
+// do runs converter query coalescing it with concurrent ones of the same key. Query runs within detached context
+// limited by fetchTimeout, so cancellation of the caller which started it doesn't fail other callers, caller only
+// stops waiting for the result.
+func (c *CryptoCurrency) do(
+	ctx context.Context, key string, query func(ctx context.Context) (fetchResult, error),
+) (fetchResult, error) {
+	resCh := c.group.DoChan(key, func() (interface{}, error) {
+		fetchCtx := context.Background()
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			fetchCtx = opentracing.ContextWithSpan(fetchCtx, span)
+		}
+		fetchCtx, cancel := context.WithTimeout(fetchCtx, fetchTimeout)
+		defer cancel()
+		res, err := query(fetchCtx)
+		return res, err
+	})
+	select {
+	case res := <-resCh:
+		if res.Err != nil {
+			return fetchResult{}, res.Err
+		}
+		return res.Val.(fetchResult), nil
+	case <-ctx.Done():
+		return fetchResult{}, ctx.Err()
+	}
+}
+
+// store puts fetched rates into cache
+func (c *CryptoCurrency) store(mr convert.MultiRate, dstCurrencyName string, fetchedAt time.Time, sourcesNum int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for coinName, rate := range mr {
+		c.entries[pairKey(coinName, dstCurrencyName)] = &entry{
+			rate: rate, fetchedAt: fetchedAt, sourcesNum: sourcesNum,
+		}
+	}
+}
+
+// ttl returns TTL of the pair
+func (c *CryptoCurrency) ttl(key string) time.Duration {
+	if ttl, ok := c.params.PairsTTL[key]; ok {
+		return ttl
+	}
+	return c.params.TTL
+}
+
+// pairKey returns cache key of the pair
+func pairKey(coinName, dstCurrencyName string) string {
+	return strings.ToUpper(coinName) + "/" + strings.ToUpper(dstCurrencyName)
+}
@@ -0,0 +1,118 @@
+package coingecko_test
+
+import (
+	"testing"
+
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/coingecko"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+func TestCoinGecko(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CoinGecko Suite")
+}
+
+func expectRate(rate convert.Rate, expected string) {
+	Expect((*decimal.Big)(&rate).String()).To(Equal(expected))
+}
+
+var _ = Describe("testing coingecko converter", func() {
+	var (
+		server     *httptest.Server
+		status     int
+		body       string
+		lastQuery  url.Values
+		lastPath   string
+		queriesNum int
+		c          *coingecko.CryptoCurrency
+	)
+
+	BeforeEach(func() {
+		status, body, lastQuery, lastPath, queriesNum = http.StatusOK, "", nil, "", 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			queriesNum++
+			lastPath, lastQuery = r.URL.Path, r.URL.Query()
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+
+		var err error
+		c, err = coingecko.New(server.URL, coingecko.DefaultCoinIDs)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should query all rates by single call", func() {
+		body = `{"bitcoin": {"usd": 6500.12, "eur": 5600}, "ethereum": {"usd": 200.5, "eur": 180}}`
+
+		m, err := c.GetRatesMatrix(context.Background(), []string{"btc", "ETH"}, []string{"USD", "eur"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(queriesNum).To(Equal(1))
+		Expect(lastPath).To(Equal("/api/v3/simple/price"))
+		Expect(lastQuery.Get("ids")).To(Equal("bitcoin,ethereum"))
+		Expect(lastQuery.Get("vs_currencies")).To(Equal("usd,eur"))
+
+		expectRate(m["USD"]["BTC"], "6500.12")
+		expectRate(m["EUR"]["BTC"], "5600")
+		expectRate(m["USD"]["ETH"], "200.5")
+		expectRate(m["EUR"]["ETH"], "180")
+	})
+
+	It("should get single rate", func() {
+		body = `{"bitcoin-cash": {"usd": 450.1}}`
+
+		rate, err := c.GetRate(context.Background(), "bch", "usd")
+		Expect(err).NotTo(HaveOccurred())
+		expectRate(*rate, "450.1")
+	})
+
+	It("should reject unknown coin without query", func() {
+		_, err := c.GetRate(context.Background(), "xyz", "usd")
+		Expect(err).To(Equal(convert.ErrCryptoCurrencyName))
+		Expect(queriesNum).To(BeZero())
+	})
+
+	It("should report currency omitted by service", func() {
+		body = `{"bitcoin": {}}`
+
+		_, err := c.GetRate(context.Background(), "btc", "xyz")
+		Expect(err).To(Equal(convert.ErrFiatCurrencyName))
+	})
+
+	It("should report coin omitted by service", func() {
+		body = `{}`
+
+		_, err := c.GetRate(context.Background(), "btc", "usd")
+		Expect(err).To(Equal(convert.ErrCryptoCurrencyName))
+	})
+
+	It("should report unavailable service on error status", func() {
+		status = http.StatusTooManyRequests
+
+		_, err := c.GetRate(context.Background(), "btc", "usd")
+		Expect(errors.Cause(err)).To(Equal(convert.ErrUnavailable))
+	})
+
+	It("should fail on malformed response", func() {
+		body = `{"bitcoin": `
+
+		_, err := c.GetRate(context.Background(), "btc", "usd")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should require host", func() {
+		_, err := coingecko.New("", coingecko.DefaultCoinIDs)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,2 @@
+// Package coingecko implements converter which uses CoinGecko-compatible simple price api
+package coingecko
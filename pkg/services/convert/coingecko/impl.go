@@ -0,0 +1,168 @@
+package coingecko
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const simplePricePath = "/api/v3/simple/price"
+
+// DefaultCoinIDs maps coins short names to CoinGecko coins ids
+var DefaultCoinIDs = map[string]string{
+	"BTC": "bitcoin",
+	"BCH": "bitcoin-cash",
+	"ETH": "ethereum",
+}
+
+// responseBody maps coin id to rates of lower-cased fiat currencies
+type responseBody map[string]map[string]json.Number
+
+// CryptoCurrency uses CoinGecko-compatible api to get currencies rates values
+type CryptoCurrency struct {
+	client  http.Client
+	host    string
+	coinIDs map[string]string
+}
+
+// interfaces compile-time validations
+var _ convert.IMatrixRates = (*CryptoCurrency)(nil)
+
+// New creates converter which uses api of given host, coin ids map coins short names to service coins ids
+func New(host string, coinIDs map[string]string) (*CryptoCurrency, error) {
+	if host == "" {
+		return nil, errors.New("coingecko converter: service host parameter is required")
+	}
+	if _, err := url.Parse(host); err != nil {
+		return nil, errors.Wrapf(err, "coingecko converter: service host parameter seems to be invalid: %s", host)
+	}
+
+	upperCoinIDs := make(map[string]string, len(coinIDs))
+	for coinName, id := range coinIDs {
+		upperCoinIDs[strings.ToUpper(coinName)] = id
+	}
+	return &CryptoCurrency{host: host, coinIDs: upperCoinIDs}, nil
+}
+
+// GetRate implements ICryptoCurrency
+func (c *CryptoCurrency) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (
+	rate *convert.Rate, err error,
+) {
+	mr, err := c.GetMultiRate(ctx, []string{coinName}, dstCurrencyName)
+	if err != nil {
+		return
+	}
+	return mr.CurrencyRate(coinName), nil
+}
+
+// GetMultiRate implements ICryptoCurrency
+func (c *CryptoCurrency) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	mr convert.MultiRate, err error,
+) {
+	if dstCurrencyName == "" {
+		return nil, convert.ErrFiatCurrencyName
+	}
+	m, err := c.GetRatesMatrix(ctx, coinNames, []string{dstCurrencyName})
+	if err != nil {
+		return
+	}
+	return m.FiatRates(dstCurrencyName), nil
+}
+
+// GetRatesMatrix implements IMatrixRates, all rates are queried by single service call
+func (c *CryptoCurrency) GetRatesMatrix(ctx context.Context, coinNames []string, dstCurrencyNames []string) (
+	m convert.RatesMatrix, err error,
+) {
+	err = trace.InsideSpanE(ctx, "coingecko_get_rates_matrix", func(ctx context.Context, span opentracing.Span) error {
+		if len(coinNames) == 0 {
+			return errors.New("coingecko converter: at least one coin in the list required")
+		}
+		if len(dstCurrencyNames) == 0 {
+			return errors.New("coingecko converter: at least one currency in the list required")
+		}
+		currencies := make([]string, len(dstCurrencyNames))
+		for i, currency := range dstCurrencyNames {
+			if currency == "" {
+				return convert.ErrFiatCurrencyName
+			}
+			currencies[i] = strings.ToLower(currency)
+		}
+
+		ids := make([]string, len(coinNames))
+		for i, coinName := range coinNames {
+			id, ok := c.coinIDs[strings.ToUpper(coinName)]
+			if !ok {
+				return convert.ErrCryptoCurrencyName
+			}
+			ids[i] = id
+		}
+
+		resp, err := c.doQuery(ctx, span, ids, currencies)
+		if err != nil {
+			return err
+		}
+
+		m = make(convert.RatesMatrix, len(currencies))
+		for _, currency := range currencies {
+			mr := make(convert.MultiRate, len(coinNames))
+			for i, coinName := range coinNames {
+				coinRates, ok := resp[ids[i]]
+				if !ok {
+					return convert.ErrCryptoCurrencyName
+				}
+				// unknown currencies are silently omitted by service
+				value, ok := coinRates[currency]
+				if !ok {
+					return convert.ErrFiatCurrencyName
+				}
+				rate, ok := new(decimal.Big).SetString(value.String())
+				if !ok {
+					return fmt.Errorf("coingecko converter: invalid rate value %q", value)
+				}
+				mr[strings.ToUpper(coinName)] = convert.Rate(*rate)
+			}
+			m[strings.ToUpper(currency)] = mr
+		}
+		return nil
+	})
+	return
+}
+
+func (c *CryptoCurrency) doQuery(ctx context.Context, span opentracing.Span, ids, currencies []string) (
+	resp responseBody, err error,
+) {
+	u, _ := url.Parse(c.host)
+	u.Path = simplePricePath
+	v := url.Values{}
+	v.Set("ids", strings.Join(ids, ","))
+	v.Set("vs_currencies", strings.Join(currencies, ","))
+	u.RawQuery = v.Encode()
+	span.LogKV("convert_url", u.String())
+
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	r, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "coingecko converter: service host unavailable")
+	}
+	defer r.Body.Close()
+
+	span.LogKV("resp_code", r.StatusCode)
+	if r.StatusCode != http.StatusOK {
+		return nil, errors.Wrapf(convert.ErrUnavailable, "coingecko converter: service response code %d", r.StatusCode)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err = decoder.Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "coingecko converter: error occurs while decoding response")
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package coingecko
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/providers"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultHost is the CoinGecko api host
+const defaultHost = "https://api.coingecko.com"
+
+type providerParams struct {
+	Host string
+	IDs  map[string]string
+}
+
+type provider struct{}
+
+// Create implements Provider interface, accepts optional host param and ids param which extends default coins ids
+func (provider) Create(logger logrus.FieldLogger, params map[string]interface{}) (convert.ICryptoCurrency, error) {
+	p := providerParams{Host: defaultHost}
+	if err := providers.DecodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	coinIDs := make(map[string]string, len(DefaultCoinIDs)+len(p.IDs))
+	for coinName, id := range DefaultCoinIDs {
+		coinIDs[coinName] = id
+	}
+	for coinName, id := range p.IDs {
+		coinIDs[coinName] = id
+	}
+
+	c, err := New(p.Host, coinIDs)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func init() {
+	providers.Register("coingecko", provider{})
+}
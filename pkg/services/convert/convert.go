@@ -5,6 +5,7 @@ import (
 	"github.com/ericlagergren/decimal"
 	"github.com/pkg/errors"
 	"strings"
+	"time"
 )
 
 var (
@@ -16,6 +17,9 @@ var (
 
 	// ErrUnavailable service not available, usually will be wrapped inside another error describing
 	ErrUnavailable = errors.New("convert: unavailable")
+
+	// ErrNoQuorum returned by aggregating converters when too few sources respond with agreed rates
+	ErrNoQuorum = errors.New("convert: rate sources quorum isn't reached")
 )
 
 // Rate used to perform conversion
@@ -56,3 +60,39 @@ type ICryptoCurrency interface {
 	// GetMultiRate same as GetRate, but generate rates for multiple source coins
 	GetMultiRate(ctx context.Context, coinNames[]string, dstCurrencyName string) (mr MultiRate, err error)
 }
+
+// RatesMatrix holds rates of multiple coins to multiple fiat currencies, keyed by upper-cased fiat currency name
+type RatesMatrix map[string]MultiRate
+
+// FiatRates returns coins rates to fiat currency, nil if no such exists
+func (m RatesMatrix) FiatRates(currency string) MultiRate {
+	return m[strings.ToUpper(currency)]
+}
+
+// IMatrixRates implemented by converters which are able to query rates of coins to multiple fiat currencies at once
+type IMatrixRates interface {
+	// GetRatesMatrix same as ICryptoCurrency GetMultiRate, but generates rates for multiple fiat currencies. If any of
+	// currencies names is invalid, returns ErrFiatCurrencyName.
+	GetRatesMatrix(ctx context.Context, coinNames []string, dstCurrencyNames []string) (m RatesMatrix, err error)
+}
+
+// OHLC holds open, high, low and close rates of the day
+type OHLC struct {
+	// Day is the UTC midnight of the day
+	Day   time.Time
+	Open  *decimal.Big
+	High  *decimal.Big
+	Low   *decimal.Big
+	Close *decimal.Big
+}
+
+// IHistoricalRates obtains rates which were actual at some moment in the past
+type IHistoricalRates interface {
+	// GetRateAt same as ICryptoCurrency GetRate, but returns rate which was actual at given moment
+	GetRateAt(ctx context.Context, coinName string, dstCurrencyName string, at time.Time) (rate *Rate, err error)
+
+	// GetOHLC returns daily rates of the coin to fiat currency for UTC days from the one which contains from time up
+	// to the one which contains to time inclusively, ordered by day. Days for which rates are unknown are omitted.
+	// Returns same errors as GetRateAt.
+	GetOHLC(ctx context.Context, coinName string, dstCurrencyName string, from, to time.Time) (ohlc []OHLC, err error)
+}
@@ -0,0 +1,187 @@
+package convert_test
+
+import (
+	"testing"
+
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+func TestConvert(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Convert Suite")
+}
+
+func d(s string) *decimal.Big {
+	v, ok := new(decimal.Big).SetString(s)
+	Expect(ok).To(BeTrue())
+	return v
+}
+
+// ratesTable serves rates of coins keyed by upper-cased fiat currency and coin names, counts converter calls
+type ratesTable struct {
+	rates      map[string]map[string]string
+	err        error
+	rateCalls  []string
+	multiCalls []string
+}
+
+func (t *ratesTable) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (*convert.Rate, error) {
+	t.rateCalls = append(t.rateCalls, strings.ToUpper(dstCurrencyName))
+	mr, err := t.GetMultiRate(ctx, []string{coinName}, dstCurrencyName)
+	t.multiCalls = t.multiCalls[:len(t.multiCalls)-1]
+	if err != nil {
+		return nil, err
+	}
+	return mr.CurrencyRate(coinName), nil
+}
+
+func (t *ratesTable) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	convert.MultiRate, error,
+) {
+	t.multiCalls = append(t.multiCalls, strings.ToUpper(dstCurrencyName))
+	if t.err != nil {
+		return nil, t.err
+	}
+	rates, ok := t.rates[strings.ToUpper(dstCurrencyName)]
+	if !ok {
+		return nil, convert.ErrFiatCurrencyName
+	}
+	mr := make(convert.MultiRate, len(coinNames))
+	for _, coinName := range coinNames {
+		mr[strings.ToUpper(coinName)] = convert.Rate(*d(rates[strings.ToUpper(coinName)]))
+	}
+	return mr, nil
+}
+
+// expectRates checks rates numerically since decimals of equal value may differ in scale
+func expectRates(mr convert.MultiRate, want map[string]string) {
+	Expect(mr).To(HaveLen(len(want)))
+	for coinName, rate := range want {
+		got := mr.CurrencyRate(coinName)
+		Expect(got).NotTo(BeNil(), "rate of %s", coinName)
+		Expect((*decimal.Big)(got).Cmp(d(rate))).To(BeZero(), "rate of %s is %s", coinName, (*decimal.Big)(got))
+	}
+}
+
+var _ = Describe("testing fiat to fiat cross rates matrix", func() {
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		label      string
+		rates      map[string]map[string]string
+		currencies []string
+		want       map[string]map[string]string
+		rateCalls  []string
+	}{
+		{
+			"should query single currency rates directly",
+			map[string]map[string]string{"USD": {"BTC": "6500", "ETH": "200"}},
+			[]string{"usd"},
+			map[string]map[string]string{"USD": {"BTC": "6500", "ETH": "200"}},
+			nil,
+		},
+		{
+			"should derive other currencies rates from reference coin",
+			map[string]map[string]string{
+				"USD": {"BTC": "6500", "ETH": "200"},
+				"EUR": {"BTC": "5850", "ETH": "180"},
+				"RUB": {"BTC": "422500", "ETH": "13000"},
+			},
+			[]string{"usd", "eur", "rub"},
+			map[string]map[string]string{
+				"USD": {"BTC": "6500", "ETH": "200"},
+				"EUR": {"BTC": "5850", "ETH": "180"},
+				"RUB": {"BTC": "422500", "ETH": "13000"},
+			},
+			[]string{"EUR", "RUB"},
+		},
+		{
+			"should use the only coin with non-zero rate as reference",
+			map[string]map[string]string{
+				"USD": {"BTC": "0", "ETH": "200"},
+				"EUR": {"BTC": "0", "ETH": "180"},
+			},
+			[]string{"usd", "eur"},
+			map[string]map[string]string{
+				"USD": {"BTC": "0", "ETH": "200"},
+				"EUR": {"BTC": "0", "ETH": "180"},
+			},
+			[]string{"EUR"},
+		},
+		{
+			"should leave other currencies empty if no coin has known rate",
+			map[string]map[string]string{
+				"USD": {"BTC": "0", "ETH": "0"},
+				"EUR": {"BTC": "5850", "ETH": "180"},
+			},
+			[]string{"usd", "eur"},
+			map[string]map[string]string{
+				"USD": {"BTC": "0", "ETH": "0"},
+				"EUR": {},
+			},
+			nil,
+		},
+		{
+			"should query duplicated currency once",
+			map[string]map[string]string{
+				"USD": {"BTC": "6500", "ETH": "200"},
+				"EUR": {"BTC": "5850", "ETH": "180"},
+			},
+			[]string{"usd", "eur", "EUR", "USD"},
+			map[string]map[string]string{
+				"USD": {"BTC": "6500", "ETH": "200"},
+				"EUR": {"BTC": "5850", "ETH": "180"},
+			},
+			[]string{"EUR"},
+		},
+	} {
+		c := c
+		It(c.label, func() {
+			converter := &ratesTable{rates: c.rates}
+			m, err := convert.CrossRatesMatrix(converter, ctx, []string{"btc", "eth"}, c.currencies)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(m).To(HaveLen(len(c.want)))
+			for currency, want := range c.want {
+				expectRates(m.FiatRates(currency), want)
+			}
+			Expect(converter.multiCalls).To(Equal([]string{"USD"}))
+			Expect(converter.rateCalls).To(Equal(c.rateCalls))
+		})
+	}
+
+	It("should require at least one currency", func() {
+		_, err := convert.CrossRatesMatrix(&ratesTable{}, ctx, []string{"btc"}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return base currency query error", func() {
+		queryErr := errors.New("query failed")
+		_, err := convert.CrossRatesMatrix(&ratesTable{err: queryErr}, ctx, []string{"btc"}, []string{"usd", "eur"})
+		Expect(err).To(Equal(queryErr))
+	})
+
+	It("should return invalid cross currency error", func() {
+		converter := &ratesTable{rates: map[string]map[string]string{"USD": {"BTC": "6500"}}}
+		m, err := convert.CrossRatesMatrix(converter, ctx, []string{"btc"}, []string{"usd", "xxx"})
+		Expect(err).To(Equal(convert.ErrFiatCurrencyName))
+		Expect(m).To(BeNil())
+	})
+
+	It("should replace invalid currency rates with fallback currency ones", func() {
+		converter := &ratesTable{rates: map[string]map[string]string{
+			"USD": {"BTC": "6500"},
+			"EUR": {"BTC": "5850"},
+		}}
+		m, err := convert.GetRatesMatrixDefaultFiat(converter, ctx, []string{"btc"}, []string{"eur", "xxx"}, "usd")
+		Expect(err).NotTo(HaveOccurred())
+		expectRates(m.FiatRates("eur"), map[string]string{"BTC": "5850"})
+		expectRates(m.FiatRates("xxx"), map[string]string{"BTC": "6500"})
+	})
+})
@@ -12,13 +12,20 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 //TODO Transfer ZamValue to Config
 const (
-	multiPricePath = "/data/pricemulti"
-	ZamValue       = 0.02
+	multiPricePath      = "/data/pricemulti"
+	historicalPricePath = "/data/pricehistorical"
+	dailyHistoryPath    = "/data/histoday"
+	ZamValue            = 0.02
+
+	// maxDailyHistoryLimit is the max number of days service returns by single query
+	maxDailyHistoryLimit = 2000
 )
 
 type queryParams struct {
@@ -28,14 +35,37 @@ type queryParams struct {
 
 type responseBody map[string]map[string]float64
 
+type dailyHistoryBody struct {
+	Response string `json:"Response"`
+	Message  string `json:"Message"`
+	Data     []struct {
+		Time  int64   `json:"time"`
+		Open  float64 `json:"open"`
+		High  float64 `json:"high"`
+		Low   float64 `json:"low"`
+		Close float64 `json:"close"`
+	} `json:"Data"`
+}
+
 // ICoinConverter uses cryptocompare.com shitty api for getting currencies rates values
 type CryptoCurrency struct {
 	client *http.Client
 	host   string
 }
 
+// interfaces compile-time validations
+var (
+	_ convert.IHistoricalRates = (*CryptoCurrency)(nil)
+	_ convert.IMatrixRates     = (*CryptoCurrency)(nil)
+)
+
 // New coin converter which uses icex.ch service, requires icex host parameter.
 func New(serviceHost string) (convert.ICryptoCurrency, error) {
+	return NewCryptoCurrency(serviceHost)
+}
+
+// NewCryptoCurrency same as New, but returns concrete type which also implements IHistoricalRates
+func NewCryptoCurrency(serviceHost string) (*CryptoCurrency, error) {
 	if serviceHost == "" {
 		return nil, fmt.Errorf("cryptocompare converter: service host parameter is required")
 	} else {
@@ -115,10 +145,164 @@ func (c *CryptoCurrency) GetMultiRate(ctx context.Context, coinNames []string, d
 	return
 }
 
-func (c *CryptoCurrency) doQuery(ctx context.Context, coinNames []string, dstCurrencyName string) (resp responseBody, err error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cyptocompare_do_query")
-	defer span.Finish()
+// GetRatesMatrix implements IMatrixRates, all rates are queried by single service call
+func (c *CryptoCurrency) GetRatesMatrix(
+	ctx context.Context, coinNames []string, dstCurrencyNames []string,
+) (m convert.RatesMatrix, err error) {
+	if len(dstCurrencyNames) == 0 {
+		err = errors.New("cryptocompare converter: empty dst currencies list")
+		return
+	}
+	currencies := make([]string, len(dstCurrencyNames))
+	for i, name := range dstCurrencyNames {
+		currencies[i] = strings.ToUpper(name)
+	}
+
+	// service accepts comma-separated list of dst currencies
+	resp, err := c.doQuery(ctx, coinNames, strings.Join(currencies, ","))
+	if err != nil {
+		return
+	}
+
+	m = make(convert.RatesMatrix, len(currencies))
+	for _, currency := range currencies {
+		mr := make(convert.MultiRate, len(coinNames))
+		for _, coinName := range coinNames {
+			coinVals, ok := resp[coinName]
+			if !ok {
+				// check if coin is ZAM token
+				if coinName != "ZAM" {
+					return nil, convert.ErrCryptoCurrencyName
+				}
+				val := decimal.Big{}
+				val.SetFloat64(ZamValue)
+				mr[coinName] = convert.Rate(val)
+				continue
+			}
+			// ignore missed currencies
+			if rateVal, ok := coinVals[currency]; ok {
+				val := decimal.Big{}
+				val.SetFloat64(rateVal)
+				mr[coinName] = convert.Rate(val)
+			}
+		}
+		if len(mr) == 0 {
+			return nil, convert.ErrFiatCurrencyName
+		}
+		m[currency] = mr
+	}
+	return
+}
+
+// GetRateAt implements IHistoricalRates, ZAM token has constant rate
+func (c *CryptoCurrency) GetRateAt(
+	ctx context.Context, coinName string, dstCurrencyName string, at time.Time,
+) (rate *convert.Rate, err error) {
+	coinName = strings.ToUpper(coinName)
+	dstCurrencyName = strings.ToUpper(dstCurrencyName)
+	if coinName == "ZAM" {
+		rate = new(convert.Rate)
+		(*decimal.Big)(rate).SetFloat64(ZamValue)
+		return
+	}
+	if dstCurrencyName == "" {
+		err = errors.New("cryptocompare converter: empty dst currency value")
+		return
+	}
+
+	v := url.Values{}
+	v.Set("fsym", coinName)
+	v.Set("tsyms", dstCurrencyName)
+	v.Set("ts", strconv.FormatInt(at.Unix(), 10))
+	var resp responseBody
+	err = c.do(ctx, historicalPricePath, v, &resp)
+	if err != nil {
+		return
+	}
+
+	coinVals, ok := resp[coinName]
+	if !ok {
+		err = convert.ErrCryptoCurrencyName
+		return
+	}
+	// service responds with zero rate for unknown pairs
+	rateVal, ok := coinVals[dstCurrencyName]
+	if !ok || rateVal == 0 {
+		err = convert.ErrFiatCurrencyName
+		return
+	}
+	rate = new(convert.Rate)
+	(*decimal.Big)(rate).SetFloat64(rateVal)
+	return
+}
+
+// GetOHLC implements IHistoricalRates, ZAM token has constant rate. Days which precede coin listing are omitted.
+func (c *CryptoCurrency) GetOHLC(
+	ctx context.Context, coinName string, dstCurrencyName string, from, to time.Time,
+) (ohlc []convert.OHLC, err error) {
+	coinName = strings.ToUpper(coinName)
+	dstCurrencyName = strings.ToUpper(dstCurrencyName)
+	from = from.UTC().Truncate(24 * time.Hour)
+	to = to.UTC().Truncate(24 * time.Hour)
+	if to.Before(from) {
+		return
+	}
+
+	if coinName == "ZAM" {
+		for day := from; !day.After(to); day = day.Add(24 * time.Hour) {
+			rate := new(decimal.Big).SetFloat64(ZamValue)
+			ohlc = append(ohlc, convert.OHLC{Day: day, Open: rate, High: rate, Low: rate, Close: rate})
+		}
+		return
+	}
+	if dstCurrencyName == "" {
+		err = errors.New("cryptocompare converter: empty dst currency value")
+		return
+	}
+
+	// service returns limit+1 days up to toTs inclusively, so long ranges are queried from the end by chunks
+	for end := to; !end.Before(from); end = end.Add(-maxDailyHistoryLimit * 24 * time.Hour) {
+		limit := int(end.Sub(from) / (24 * time.Hour))
+		if limit >= maxDailyHistoryLimit {
+			limit = maxDailyHistoryLimit - 1
+		}
+
+		v := url.Values{}
+		v.Set("fsym", coinName)
+		v.Set("tsym", dstCurrencyName)
+		v.Set("toTs", strconv.FormatInt(end.Unix(), 10))
+		v.Set("limit", strconv.Itoa(limit))
+		var resp dailyHistoryBody
+		err = c.do(ctx, dailyHistoryPath, v, &resp)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Response != "Success" {
+			// service doesn't distinguish unknown coins and currencies
+			return nil, errors.Wrapf(convert.ErrCryptoCurrencyName, "cryptocompare converter: %s", resp.Message)
+		}
+
+		chunk := make([]convert.OHLC, 0, len(resp.Data))
+		for _, d := range resp.Data {
+			day := time.Unix(d.Time, 0).UTC()
+			// service pads days before coin listing with zero rates
+			if day.Before(from) || day.After(end) || d.Close == 0 {
+				continue
+			}
+			chunk = append(chunk, convert.OHLC{
+				Day:   day,
+				Open:  new(decimal.Big).SetFloat64(d.Open),
+				High:  new(decimal.Big).SetFloat64(d.High),
+				Low:   new(decimal.Big).SetFloat64(d.Low),
+				Close: new(decimal.Big).SetFloat64(d.Close),
+			})
+		}
+		ohlc = append(chunk, ohlc...)
+	}
+	return
+}
 
+func (c *CryptoCurrency) doQuery(ctx context.Context, coinNames []string, dstCurrencyName string) (resp responseBody, err error) {
 	// uppercase all currencies
 	for i, name := range coinNames {
 		coinNames[i] = strings.ToUpper(name)
@@ -129,11 +313,20 @@ func (c *CryptoCurrency) doQuery(ctx context.Context, coinNames []string, dstCur
 	}
 	dstCurrencyName = strings.ToUpper(dstCurrencyName)
 
-	u, _ := url.Parse(c.host)
-	u.Path = multiPricePath
 	v := url.Values{}
 	v.Set("fsyms", strings.Join(coinNames, ","))
 	v.Set("tsyms", dstCurrencyName)
+	err = c.do(ctx, multiPricePath, v, &resp)
+	return
+}
+
+// do queries given api path with given params
+func (c *CryptoCurrency) do(ctx context.Context, path string, v url.Values, resp interface{}) (err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "cyptocompare_do_query")
+	defer span.Finish()
+
+	u, _ := url.Parse(c.host)
+	u.Path = path
 	u.RawQuery = v.Encode()
 	req, _ := http.NewRequest("GET", u.String(), nil)
 
@@ -159,6 +352,6 @@ func (c *CryptoCurrency) doQuery(ctx context.Context, coinNames []string, dstCur
 	}
 
 	// unmarshal response
-	err = json.NewDecoder(bytes.NewReader(data)).Decode(&resp)
+	err = json.NewDecoder(bytes.NewReader(data)).Decode(resp)
 	return
 }
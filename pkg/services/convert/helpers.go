@@ -1,6 +1,11 @@
 package convert
 
-import "context"
+import (
+	"context"
+	"github.com/ericlagergren/decimal"
+	"github.com/pkg/errors"
+	"strings"
+)
 
 // GetRateDefaultFiat helper which retries GetRate call in case of wrong fiat currency name with fallback argument
 func GetRateDefaultFiat(
@@ -28,3 +33,103 @@ func GetMultiRateDefaultFiat(
 	}
 	return
 }
+
+// GetRatesMatrix helper which queries rates of coins to multiple fiat currencies by single converter call if converter
+// implements IMatrixRates, otherwise rates are derived using CrossRatesMatrix
+func GetRatesMatrix(
+	converter ICryptoCurrency,
+	ctx context.Context, coinNames []string,
+	dstCurrencyNames []string,
+) (m RatesMatrix, err error) {
+	if mc, ok := converter.(IMatrixRates); ok {
+		return mc.GetRatesMatrix(ctx, copyNames(coinNames), dstCurrencyNames)
+	}
+	return CrossRatesMatrix(converter, ctx, coinNames, dstCurrencyNames)
+}
+
+// CrossRatesMatrix helper which builds rates matrix for converters which can't query multiple fiat currencies at once.
+// Rates of all coins are queried only to the first currency, rates to other currencies are calculated using fiat to
+// fiat cross rates derived from the rates of a single reference coin, so converter is queried once per currency for
+// one coin only.
+func CrossRatesMatrix(
+	converter ICryptoCurrency,
+	ctx context.Context, coinNames []string,
+	dstCurrencyNames []string,
+) (m RatesMatrix, err error) {
+	if len(dstCurrencyNames) == 0 {
+		return nil, errors.New("convert: at least one currency in the list required")
+	}
+	baseCurrency := strings.ToUpper(dstCurrencyNames[0])
+	base, err := converter.GetMultiRate(ctx, copyNames(coinNames), baseCurrency)
+	if err != nil {
+		return
+	}
+	m = RatesMatrix{baseCurrency: base}
+
+	// any coin with known non-zero rate is suitable to derive cross rates
+	var (
+		refCoin string
+		refBase *decimal.Big
+	)
+	for coinName, rate := range base {
+		if value := (*decimal.Big)(&rate); value.Sign() > 0 {
+			refCoin, refBase = coinName, new(decimal.Big).Copy(value)
+			break
+		}
+	}
+
+	for _, currency := range dstCurrencyNames[1:] {
+		currency = strings.ToUpper(currency)
+		if _, ok := m[currency]; ok {
+			continue
+		}
+		if refCoin == "" {
+			m[currency] = MultiRate{}
+			continue
+		}
+
+		refRate, err := converter.GetRate(ctx, refCoin, currency)
+		if err != nil {
+			return nil, err
+		}
+		cross := new(decimal.Big).Quo((*decimal.Big)(refRate), refBase)
+
+		mr := make(MultiRate, len(base))
+		for coinName, rate := range base {
+			mr[coinName] = Rate(*new(decimal.Big).Mul((*decimal.Big)(&rate), cross))
+		}
+		m[currency] = mr
+	}
+	return
+}
+
+// GetRatesMatrixDefaultFiat helper same as GetRatesMatrix, but in case of wrong fiat currency name rates to such
+// currency are replaced with rates to fallback currency
+func GetRatesMatrixDefaultFiat(
+	converter ICryptoCurrency,
+	ctx context.Context, coinNames []string,
+	dstCurrencyNames []string, fallbackFiatCurrency string,
+) (m RatesMatrix, err error) {
+	m, err = GetRatesMatrix(converter, ctx, coinNames, dstCurrencyNames)
+	if errors.Cause(err) != ErrFiatCurrencyName {
+		return
+	}
+
+	// find out invalid currencies querying them one by one
+	m = make(RatesMatrix, len(dstCurrencyNames))
+	for _, currency := range dstCurrencyNames {
+		mr, err := GetMultiRateDefaultFiat(converter, ctx, copyNames(coinNames), currency, fallbackFiatCurrency)
+		if err != nil {
+			return nil, err
+		}
+		m[strings.ToUpper(currency)] = mr
+	}
+	return
+}
+
+// copyNames copies coins names since they may be modified inside converter
+func copyNames(coinNames []string) []string {
+	names := make([]string, len(coinNames))
+	copy(names, coinNames)
+	return names
+}
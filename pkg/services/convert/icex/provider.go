@@ -0,0 +1,29 @@
+package icex
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/providers"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultHost is the icex.ch api host
+const defaultHost = "https://api.icex.ch"
+
+type providerParams struct {
+	Host string
+}
+
+type provider struct{}
+
+// Create implements Provider interface, accepts optional host param
+func (provider) Create(logger logrus.FieldLogger, params map[string]interface{}) (convert.ICryptoCurrency, error) {
+	p := providerParams{Host: defaultHost}
+	if err := providers.DecodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	return New(p.Host)
+}
+
+func init() {
+	providers.Register("icex", provider{})
+}
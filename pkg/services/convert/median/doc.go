@@ -0,0 +1,3 @@
+// Package median implements converter which aggregates rates of multiple sources, so single bad source can't
+// affect the rate
+package median
@@ -0,0 +1,233 @@
+package median
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/trace"
+	"github.com/ericlagergren/decimal"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Source is named rates source
+type Source struct {
+	Name      string
+	Converter convert.ICryptoCurrency
+}
+
+// AggregatedRate is the median rate of sources which agree with each other
+type AggregatedRate struct {
+	Rate *convert.Rate
+
+	// SourcesNum is the number of sources which rates have been taken into account
+	SourcesNum int
+}
+
+// CryptoCurrency implements converter which queries all sources in parallel, drops rates which deviate from the
+// median of all rates by more then max deviation and returns median of the rest if quorum is reached
+type CryptoCurrency struct {
+	sources      []Source
+	quorum       int
+	maxDeviation *decimal.Big
+}
+
+// New creates median converter, quorum is the min number of sources which rates must agree, max deviation is relative
+// to the median of all rates (0.05 is 5%)
+func New(sources []Source, quorum int, maxDeviation *decimal.Big) (*CryptoCurrency, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("median converter: at least one source required")
+	}
+	if quorum < 1 || quorum > len(sources) {
+		return nil, errors.Errorf("median converter: quorum must be in range [1, %d]", len(sources))
+	}
+	if maxDeviation == nil || maxDeviation.Sign() < 0 {
+		return nil, errors.New("median converter: max deviation must be non-negative")
+	}
+	return &CryptoCurrency{sources: sources, quorum: quorum, maxDeviation: maxDeviation}, nil
+}
+
+// GetRate implements ICryptoCurrency
+func (c *CryptoCurrency) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (
+	rate *convert.Rate, err error,
+) {
+	aggregated, err := c.GetAggregatedRate(ctx, coinName, dstCurrencyName)
+	if err != nil {
+		return
+	}
+	return aggregated.Rate, nil
+}
+
+// GetAggregatedRate same as GetRate, but also returns number of sources which agree on the rate, the number is also
+// reported into the context (see convert.WithSourcesTracking). Returns convert.ErrNoQuorum if too few sources agree,
+// name errors are returned only if all sources reject the name.
+func (c *CryptoCurrency) GetAggregatedRate(ctx context.Context, coinName string, dstCurrencyName string) (
+	aggregated AggregatedRate, err error,
+) {
+	err = trace.InsideSpanE(ctx, "median_get_rate", func(ctx context.Context, span opentracing.Span) error {
+		responses := c.query(ctx, span, func(ctx context.Context, converter convert.ICryptoCurrency) (
+			convert.MultiRate, error,
+		) {
+			rate, err := converter.GetRate(ctx, coinName, dstCurrencyName)
+			if err != nil {
+				return nil, err
+			}
+			return convert.MultiRate{strings.ToUpper(coinName): *rate}, nil
+		})
+
+		var err error
+		aggregated, err = c.aggregate(span, responses, coinName)
+		if err != nil {
+			return err
+		}
+		convert.ReportSources(ctx, aggregated.SourcesNum)
+		return nil
+	})
+	return
+}
+
+// GetMultiRate implements ICryptoCurrency, quorum is required for each coin. The least number of sources which agree
+// on coins rates is reported into the context.
+func (c *CryptoCurrency) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	mr convert.MultiRate, err error,
+) {
+	err = trace.InsideSpanE(ctx, "median_get_multi_rate", func(ctx context.Context, span opentracing.Span) error {
+		responses := c.query(ctx, span, func(ctx context.Context, converter convert.ICryptoCurrency) (
+			convert.MultiRate, error,
+		) {
+			// copy coins array because it may be modified inside converter
+			nCoinNames := make([]string, len(coinNames))
+			copy(nCoinNames, coinNames)
+			return converter.GetMultiRate(ctx, nCoinNames, dstCurrencyName)
+		})
+
+		mr = make(convert.MultiRate, len(coinNames))
+		sourcesNum := 0
+		for _, coinName := range coinNames {
+			aggregated, err := c.aggregate(span, responses, coinName)
+			if err != nil {
+				return err
+			}
+			mr[strings.ToUpper(coinName)] = *aggregated.Rate
+			if sourcesNum == 0 || aggregated.SourcesNum < sourcesNum {
+				sourcesNum = aggregated.SourcesNum
+			}
+		}
+		// report only if all coins succeed, so failed query doesn't affect rates served by fallback converter
+		convert.ReportSources(ctx, sourcesNum)
+		return nil
+	})
+	return
+}
+
+// response is the source reply
+type response struct {
+	source string
+	rates  convert.MultiRate
+	err    error
+}
+
+// query calls all sources in parallel
+func (c *CryptoCurrency) query(
+	ctx context.Context,
+	span opentracing.Span,
+	f func(ctx context.Context, converter convert.ICryptoCurrency) (convert.MultiRate, error),
+) []response {
+	responses := make([]response, len(c.sources))
+	wg := sync.WaitGroup{}
+	for i, source := range c.sources {
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			rates, err := f(ctx, source.Converter)
+			responses[i] = response{source: source.Name, rates: rates, err: err}
+		}(i, source)
+	}
+	wg.Wait()
+
+	for _, resp := range responses {
+		if resp.err != nil {
+			span.LogKV("source", resp.source, "error", resp.err.Error())
+		}
+	}
+	return responses
+}
+
+// aggregate calculates median rate of the coin from sources responses
+func (c *CryptoCurrency) aggregate(span opentracing.Span, responses []response, coinName string) (
+	aggregated AggregatedRate, err error,
+) {
+	var (
+		rates     []*decimal.Big
+		nameErr   error
+		nameErrsN int
+	)
+	for _, resp := range responses {
+		cause := errors.Cause(resp.err)
+		if cause == convert.ErrCryptoCurrencyName || cause == convert.ErrFiatCurrencyName {
+			nameErr = cause
+			nameErrsN++
+			continue
+		}
+		if resp.err != nil {
+			continue
+		}
+		if rate := resp.rates.CurrencyRate(coinName); rate != nil {
+			rates = append(rates, (*decimal.Big)(rate))
+		}
+	}
+	// name is invalid only if all sources reject it
+	if nameErrsN == len(responses) {
+		return aggregated, nameErr
+	}
+
+	agreed := Agreed(rates, c.maxDeviation)
+	span.LogKV("coin", coinName, "responded_num", len(rates), "agreed_num", len(agreed))
+	if len(agreed) < c.quorum {
+		return aggregated, errors.Wrapf(
+			convert.ErrNoQuorum, "median converter: %d of %d required sources agree on %s rate",
+			len(agreed), c.quorum, strings.ToUpper(coinName),
+		)
+	}
+	return AggregatedRate{Rate: (*convert.Rate)(Median(agreed)), SourcesNum: len(agreed)}, nil
+}
+
+// Median returns median of non-empty values list, mean of two middle values is taken for even number of values
+func Median(values []*decimal.Big) *decimal.Big {
+	sorted := make([]*decimal.Big, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Cmp(sorted[j]) < 0
+	})
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return new(decimal.Big).Copy(sorted[middle])
+	}
+	sum := new(decimal.Big).Add(sorted[middle-1], sorted[middle])
+	return sum.Quo(sum, decimal.New(2, 0))
+}
+
+// Agreed drops values which deviate from the median of all values by more then max deviation relative to the median
+func Agreed(values []*decimal.Big, maxDeviation *decimal.Big) []*decimal.Big {
+	if len(values) == 0 {
+		return nil
+	}
+	median := Median(values)
+	if median.Sign() <= 0 {
+		return nil
+	}
+
+	agreed := make([]*decimal.Big, 0, len(values))
+	for _, value := range values {
+		deviation := new(decimal.Big).Sub(value, median)
+		deviation.Abs(deviation)
+		deviation.Quo(deviation, median)
+		if deviation.Cmp(maxDeviation) <= 0 {
+			agreed = append(agreed, value)
+		}
+	}
+	return agreed
+}
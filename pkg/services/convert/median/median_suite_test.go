@@ -0,0 +1,262 @@
+package median_test
+
+import (
+	"testing"
+
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/median"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/providers"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+func TestMedian(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Median Suite")
+}
+
+func d(s string) *decimal.Big {
+	v, ok := new(decimal.Big).SetString(s)
+	Expect(ok).To(BeTrue())
+	return v
+}
+
+func ds(values ...string) []*decimal.Big {
+	res := make([]*decimal.Big, len(values))
+	for i, v := range values {
+		res[i] = d(v)
+	}
+	return res
+}
+
+func strs(values []*decimal.Big) []string {
+	res := make([]string, len(values))
+	for i, v := range values {
+		res[i] = v.String()
+	}
+	return res
+}
+
+// fixedConverter serves the same rate of any coin or fails with the error
+type fixedConverter struct {
+	rate string
+	err  error
+}
+
+func (c fixedConverter) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (
+	*convert.Rate, error,
+) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return (*convert.Rate)(d(c.rate)), nil
+}
+
+func (c fixedConverter) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	convert.MultiRate, error,
+) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	mr := make(convert.MultiRate, len(coinNames))
+	for _, coinName := range coinNames {
+		mr[strings.ToUpper(coinName)] = convert.Rate(*d(c.rate))
+	}
+	return mr, nil
+}
+
+func sources(converters ...fixedConverter) []median.Source {
+	res := make([]median.Source, len(converters))
+	for i, c := range converters {
+		res[i] = median.Source{Name: strconv.Itoa(i), Converter: c}
+	}
+	return res
+}
+
+// fixedProvider creates fixedConverter which rate is taken from params
+type fixedProvider struct{}
+
+func (fixedProvider) Create(logger logrus.FieldLogger, params map[string]interface{}) (convert.ICryptoCurrency, error) {
+	rate, ok := params["rate"].(string)
+	if !ok {
+		return nil, errors.New("rate param required")
+	}
+	return fixedConverter{rate: rate}, nil
+}
+
+var _ = Describe("testing median converter", func() {
+	Context("when calculating median", func() {
+		for _, c := range []struct {
+			values   []string
+			expected string
+		}{
+			{[]string{"5"}, "5"},
+			{[]string{"3", "1", "2"}, "2"},
+			{[]string{"10", "1", "7", "3", "5"}, "5"},
+			{[]string{"2", "1"}, "1.5"},
+			{[]string{"4", "1", "3", "2"}, "2.5"},
+			{[]string{"100", "1", "2", "3", "4", "5"}, "3.5"},
+		} {
+			c := c
+			It("should calculate median of "+strings.Join(c.values, ","), func() {
+				Expect(median.Median(ds(c.values...)).String()).To(Equal(c.expected))
+			})
+		}
+
+		It("should keep values order", func() {
+			values := ds("3", "1", "2")
+			median.Median(values)
+			Expect(strs(values)).To(Equal([]string{"3", "1", "2"}))
+		})
+	})
+
+	Context("when dropping outliers", func() {
+		for _, c := range []struct {
+			values       []string
+			maxDeviation string
+			expected     []string
+		}{
+			{[]string{"100", "101", "99"}, "0.05", []string{"100", "101", "99"}},
+			{[]string{"100", "101", "150", "99"}, "0.05", []string{"100", "101", "99"}},
+			{[]string{"100", "105", "95"}, "0.05", []string{"100", "105", "95"}},
+			{[]string{"100", "106", "94"}, "0.05", []string{"100"}},
+			{[]string{"100", "100.01"}, "0", []string{}},
+			{[]string{"0", "0"}, "0.05", nil},
+			{[]string{}, "0.05", nil},
+		} {
+			c := c
+			It("should agree "+strings.Join(c.values, ",")+" with deviation "+c.maxDeviation, func() {
+				agreed := median.Agreed(ds(c.values...), d(c.maxDeviation))
+				if c.expected == nil {
+					Expect(agreed).To(BeNil())
+					return
+				}
+				Expect(strs(agreed)).To(Equal(c.expected))
+			})
+		}
+	})
+
+	Context("when aggregating sources", func() {
+		unavailable := fixedConverter{err: convert.ErrUnavailable}
+
+		for _, c := range []struct {
+			name       string
+			converters []fixedConverter
+			quorum     int
+			expected   string
+			sourcesNum int
+		}{
+			{"odd number of sources", []fixedConverter{{rate: "100"}, {rate: "102"}, {rate: "101"}}, 2, "101", 3},
+			{"even number of sources", []fixedConverter{{rate: "100"}, {rate: "102"}}, 2, "101", 2},
+			{"outlier source", []fixedConverter{{rate: "100"}, {rate: "200"}, {rate: "101"}}, 2, "100.5", 2},
+			{"failed source", []fixedConverter{{rate: "100"}, unavailable, {rate: "102"}}, 2, "101", 2},
+		} {
+			c := c
+			It("should aggregate rates of "+c.name, func() {
+				conv, err := median.New(sources(c.converters...), c.quorum, d("0.05"))
+				Expect(err).NotTo(HaveOccurred())
+
+				aggregated, err := conv.GetAggregatedRate(context.Background(), "btc", "usd")
+				Expect(err).NotTo(HaveOccurred())
+				Expect((*decimal.Big)(aggregated.Rate).String()).To(Equal(c.expected))
+				Expect(aggregated.SourcesNum).To(Equal(c.sourcesNum))
+
+				ctx := convert.WithSourcesTracking(context.Background())
+				mr, err := conv.GetMultiRate(ctx, []string{"btc", "eth"}, "usd")
+				Expect(err).NotTo(HaveOccurred())
+				Expect((*decimal.Big)(mr.CurrencyRate("eth")).String()).To(Equal(c.expected))
+				Expect(convert.SourcesNum(ctx)).To(Equal(c.sourcesNum))
+			})
+		}
+
+		for _, c := range []struct {
+			name       string
+			converters []fixedConverter
+		}{
+			{"too few sources respond", []fixedConverter{{rate: "100"}, unavailable, unavailable}},
+			{"sources disagree", []fixedConverter{{rate: "100"}, {rate: "120"}, {rate: "140"}}},
+		} {
+			c := c
+			It("should fail with no quorum if "+c.name, func() {
+				conv, err := median.New(sources(c.converters...), 2, d("0.05"))
+				Expect(err).NotTo(HaveOccurred())
+
+				ctx := convert.WithSourcesTracking(context.Background())
+				_, err = conv.GetRate(ctx, "btc", "usd")
+				Expect(errors.Cause(err)).To(Equal(convert.ErrNoQuorum))
+				Expect(convert.SourcesNum(ctx)).To(BeZero())
+			})
+		}
+
+		It("should report invalid name only if all sources reject it", func() {
+			invalid := fixedConverter{err: errors.Wrap(convert.ErrCryptoCurrencyName, "source")}
+
+			conv, err := median.New(sources(invalid, invalid), 1, d("0.05"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = conv.GetRate(context.Background(), "xyz", "usd")
+			Expect(err).To(Equal(convert.ErrCryptoCurrencyName))
+
+			conv, err = median.New(sources(invalid, fixedConverter{rate: "1"}), 1, d("0.05"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = conv.GetRate(context.Background(), "xyz", "usd")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should validate params", func() {
+			_, err := median.New(nil, 1, d("0.05"))
+			Expect(err).To(HaveOccurred())
+			_, err = median.New(sources(fixedConverter{rate: "1"}), 2, d("0.05"))
+			Expect(err).To(HaveOccurred())
+			_, err = median.New(sources(fixedConverter{rate: "1"}), 1, d("-0.05"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when creating converter by registry", func() {
+		providers.Register("median-test-fixed", fixedProvider{})
+		providers.Register("median-test-other", fixedProvider{})
+
+		It("should create sources using params of their types", func() {
+			conv, err := providers.Create("median", logrus.New(), map[string]map[string]interface{}{
+				"median":            {"sources": []string{"median-test-fixed", "median-test-other"}, "quorum": 2},
+				"median-test-fixed": {"rate": "10"},
+				"median-test-other": {"rate": "10.2"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx := convert.WithSourcesTracking(context.Background())
+			rate, err := conv.GetRate(ctx, "btc", "usd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect((*decimal.Big)(rate).String()).To(Equal("10.1"))
+			Expect(convert.SourcesNum(ctx)).To(Equal(2))
+		})
+
+		It("should reject median source", func() {
+			_, err := providers.Create("median", logrus.New(), map[string]map[string]interface{}{
+				"median": {"sources": []string{"median-test-fixed", "median"}, "quorum": 1},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject unknown source type", func() {
+			_, err := providers.Create("median", logrus.New(), map[string]map[string]interface{}{
+				"median": {"sources": []string{"median-test-unknown"}, "quorum": 1},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject invalid max deviation", func() {
+			_, err := providers.Create("median", logrus.New(), map[string]map[string]interface{}{
+				"median":            {"sources": []string{"median-test-fixed"}, "quorum": 1, "max_deviation": "x"},
+				"median-test-fixed": {"rate": "10"},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
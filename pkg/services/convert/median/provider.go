@@ -0,0 +1,71 @@
+package median
+
+import (
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/providers"
+	"github.com/ericlagergren/decimal"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// providerType is the converter type the provider is registered for
+const providerType = "median"
+
+type providerParams struct {
+	// Sources types, any registered types except median are accepted
+	Sources []string
+
+	// Quorum is the min number of sources which rates must agree
+	Quorum int
+
+	// MaxDeviation of the source rate from the median of all sources rates relative to the median
+	MaxDeviation string `mapstructure:"max_deviation"`
+}
+
+// defaultSources are used if sources aren't configured
+var defaultSources = []string{"icex", "cryptocompare"}
+
+type provider struct{}
+
+// Create implements Provider interface, sources are created without params
+func (p provider) Create(logger logrus.FieldLogger, params map[string]interface{}) (convert.ICryptoCurrency, error) {
+	return p.CreateComposite(logger, map[string]map[string]interface{}{providerType: params})
+}
+
+// CreateComposite implements CompositeProvider interface, accepts optional sources, quorum and max_deviation params,
+// sources are created using params of their types
+func (provider) CreateComposite(
+	logger logrus.FieldLogger, params map[string]map[string]interface{},
+) (convert.ICryptoCurrency, error) {
+	// sources defaults are applied after decoding, since decoder overwrites slice items in place
+	p := providerParams{Quorum: 2, MaxDeviation: "0.05"}
+	if err := providers.DecodeParams(params[providerType], &p); err != nil {
+		return nil, err
+	}
+	if len(p.Sources) == 0 {
+		p.Sources = defaultSources
+	}
+	maxDeviation, ok := new(decimal.Big).SetString(p.MaxDeviation)
+	if !ok {
+		return nil, errors.Errorf("median converter: invalid max deviation %q", p.MaxDeviation)
+	}
+
+	sources := make([]Source, 0, len(p.Sources))
+	for _, t := range p.Sources {
+		if strings.EqualFold(t, providerType) {
+			return nil, errors.New("median converter: median source isn't allowed")
+		}
+		logger.WithField("source_type", t).Info("creating median source converter")
+		c, err := providers.Create(t, logger.WithField("source_type", t), params)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, Source{Name: strings.ToLower(t), Converter: c})
+	}
+	return New(sources, p.Quorum, maxDeviation)
+}
+
+func init() {
+	providers.Register(providerType, provider{})
+}
@@ -0,0 +1,2 @@
+// Package providers holds registry of converters providers, converter packages register themselves on import
+package providers
@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"fmt"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// Provider creates converter of the specific type
+type Provider interface {
+	// Create converter using type-specific params, params may be nil if they aren't configured
+	Create(logger logrus.FieldLogger, params map[string]interface{}) (convert.ICryptoCurrency, error)
+}
+
+// CompositeProvider implemented by providers of converters which aggregate converters of other registered types, such
+// providers receive params of all types to create aggregated converters
+type CompositeProvider interface {
+	Provider
+
+	// CreateComposite creates converter using per-type params of all types, params of provider own type are under
+	// provider type key
+	CreateComposite(logger logrus.FieldLogger, params map[string]map[string]interface{}) (convert.ICryptoCurrency, error)
+}
+
+// registry is registry of per-type converter Provider
+var registry map[string]Provider
+
+// Register Provider for a specific converter type, must be called before app main
+func Register(converterType string, p Provider) {
+	converterType = strings.ToLower(converterType)
+	if registry == nil {
+		registry = make(map[string]Provider)
+	}
+
+	registry[converterType] = p
+}
+
+// Get provider for converter type
+func Get(converterType string) (Provider, bool) {
+	p, ok := registry[strings.ToLower(converterType)]
+	return p, ok
+}
+
+// Create creates converter of the type using provider registered for it, params are per-type params, provider receives
+// params of it's own type unless it's CompositeProvider
+func Create(
+	converterType string, logger logrus.FieldLogger, params map[string]map[string]interface{},
+) (convert.ICryptoCurrency, error) {
+	converterType = strings.ToLower(converterType)
+	p, ok := Get(converterType)
+	if !ok {
+		return nil, fmt.Errorf("converter providers: unexpected converter type %s", converterType)
+	}
+	if cp, ok := p.(CompositeProvider); ok {
+		return cp.CreateComposite(logger, params)
+	}
+	return p.Create(logger, params[converterType])
+}
+
+// DecodeParams decodes converter params into dst struct using field names (case insensitive) or mapstructure tags,
+// durations may be given as strings
+func DecodeParams(params map[string]interface{}, dst interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           dst,
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(decoder.Decode(params), "converter providers: invalid params")
+}
@@ -0,0 +1,44 @@
+package convert
+
+import (
+	"context"
+	"sync"
+)
+
+type sourcesTrackerKey struct{}
+
+type sourcesTracker struct {
+	mu  sync.Mutex
+	num int
+}
+
+// WithSourcesTracking returns context within which aggregating converters report number of sources which agree on
+// served rates, use SourcesNum to get it
+func WithSourcesTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sourcesTrackerKey{}, new(sourcesTracker))
+}
+
+// ReportSources reports number of sources which agree on served rate, the least reported number is kept since it
+// describes the weakest of served rates. Does nothing if sources aren't tracked or number isn't positive.
+func ReportSources(ctx context.Context, num int) {
+	tracker, ok := ctx.Value(sourcesTrackerKey{}).(*sourcesTracker)
+	if !ok || num <= 0 {
+		return
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if tracker.num == 0 || num < tracker.num {
+		tracker.num = num
+	}
+}
+
+// SourcesNum returns the least number of sources which agree on rates served within the context, zero if it's unknown
+func SourcesNum(ctx context.Context) int {
+	tracker, ok := ctx.Value(sourcesTrackerKey{}).(*sourcesTracker)
+	if !ok {
+		return 0
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return tracker.num
+}
@@ -0,0 +1,38 @@
+package convert
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type staleTrackerKey struct{}
+
+type staleTracker struct {
+	stale int32
+}
+
+// WithStaleTracking returns context within which caching converters may serve outdated rates if rates can't be
+// queried, use IsStale to check whether some of served rates are outdated. Rates which lock money (e.g. quotes)
+// should never be obtained within such context.
+func WithStaleTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, staleTrackerKey{}, new(staleTracker))
+}
+
+// StaleAllowed checks whether outdated rates may be served within the context
+func StaleAllowed(ctx context.Context) bool {
+	_, ok := ctx.Value(staleTrackerKey{}).(*staleTracker)
+	return ok
+}
+
+// MarkStale marks that outdated rate has been served within the context, does nothing if staleness isn't tracked
+func MarkStale(ctx context.Context) {
+	if tracker, ok := ctx.Value(staleTrackerKey{}).(*staleTracker); ok {
+		atomic.StoreInt32(&tracker.stale, 1)
+	}
+}
+
+// IsStale checks whether outdated rate has been served within the context
+func IsStale(ctx context.Context) bool {
+	tracker, ok := ctx.Value(staleTrackerKey{}).(*staleTracker)
+	return ok && atomic.LoadInt32(&tracker.stale) != 0
+}
@@ -0,0 +1,3 @@
+// Package static implements converter which serves fixed rates loaded from yaml or json file, intended for
+// development and tests
+package static
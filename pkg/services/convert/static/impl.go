@@ -0,0 +1,168 @@
+package static
+
+import (
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"github.com/ericlagergren/decimal"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rates maps uppercased coin name to rates of uppercased fiat currencies
+type Rates map[string]map[string]*decimal.Big
+
+// CryptoCurrency implements converter which serves rates from the file, file is reloaded once it's modification time
+// or size is changed
+type CryptoCurrency struct {
+	path           string
+	reloadInterval time.Duration
+	logger         logrus.FieldLogger
+
+	mu        sync.Mutex
+	rates     Rates
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// interfaces compile-time validations
+var _ convert.IMatrixRates = (*CryptoCurrency)(nil)
+
+// New creates static converter which loads rates from the file of format
+//
+//	btc:
+//	  usd: 6500.12
+//	  eur: 5600
+//
+// json is accepted as well. File changes are checked on request at most once per reload interval, invalid file
+// changes are logged and ignored, so previous rates are kept.
+func New(path string, reloadInterval time.Duration, logger logrus.FieldLogger) (*CryptoCurrency, error) {
+	c := &CryptoCurrency{path: path, reloadInterval: reloadInterval, logger: logger}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "static converter: can't stat rates file")
+	}
+	if err = c.load(info); err != nil {
+		return nil, err
+	}
+	c.checkedAt = time.Now()
+	return c, nil
+}
+
+// GetRate implements ICryptoCurrency
+func (c *CryptoCurrency) GetRate(ctx context.Context, coinName string, dstCurrencyName string) (
+	rate *convert.Rate, err error,
+) {
+	coinRates, ok := c.current()[strings.ToUpper(coinName)]
+	if !ok {
+		return nil, convert.ErrCryptoCurrencyName
+	}
+	value, ok := coinRates[strings.ToUpper(dstCurrencyName)]
+	if !ok {
+		return nil, convert.ErrFiatCurrencyName
+	}
+	return (*convert.Rate)(new(decimal.Big).Copy(value)), nil
+}
+
+// GetMultiRate implements ICryptoCurrency
+func (c *CryptoCurrency) GetMultiRate(ctx context.Context, coinNames []string, dstCurrencyName string) (
+	mr convert.MultiRate, err error,
+) {
+	mr = make(convert.MultiRate, len(coinNames))
+	for _, coinName := range coinNames {
+		rate, err := c.GetRate(ctx, coinName, dstCurrencyName)
+		if err != nil {
+			return nil, err
+		}
+		mr[strings.ToUpper(coinName)] = *rate
+	}
+	return
+}
+
+// GetRatesMatrix implements IMatrixRates
+func (c *CryptoCurrency) GetRatesMatrix(ctx context.Context, coinNames []string, dstCurrencyNames []string) (
+	m convert.RatesMatrix, err error,
+) {
+	m = make(convert.RatesMatrix, len(dstCurrencyNames))
+	for _, currency := range dstCurrencyNames {
+		mr, err := c.GetMultiRate(ctx, coinNames, currency)
+		if err != nil {
+			return nil, err
+		}
+		m[strings.ToUpper(currency)] = mr
+	}
+	return
+}
+
+// current returns actual rates reloading file if it has been changed
+func (c *CryptoCurrency) current() Rates {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.checkedAt) < c.reloadInterval {
+		return c.rates
+	}
+	c.checkedAt = now
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		c.logger.WithError(err).Error("static converter: can't stat rates file, previous rates are kept")
+		return c.rates
+	}
+	if info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.rates
+	}
+
+	previous := c.rates
+	if err := c.load(info); err != nil {
+		c.logger.WithError(err).Error("static converter: rates file reload failed, previous rates are kept")
+		return previous
+	}
+	c.logger.Info("static converter: rates file reloaded")
+	return c.rates
+}
+
+// load reads rates file, rates are replaced only if file is valid
+func (c *CryptoCurrency) load(info os.FileInfo) error {
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return errors.Wrap(err, "static converter: can't read rates file")
+	}
+	loaded, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	c.rates, c.modTime, c.size = loaded, info.ModTime(), info.Size()
+	return nil
+}
+
+// Parse parses rates file content, coins and currencies names are case insensitive, rates must be positive
+func Parse(data []byte) (Rates, error) {
+	var raw map[string]map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "static converter: invalid rates file")
+	}
+
+	parsed := make(Rates, len(raw))
+	for coinName, coinRates := range raw {
+		coinName = strings.ToUpper(coinName)
+		if parsed[coinName] == nil {
+			parsed[coinName] = make(map[string]*decimal.Big, len(coinRates))
+		}
+		for currency, value := range coinRates {
+			rate, ok := new(decimal.Big).SetString(value)
+			if !ok || rate.Sign() <= 0 {
+				return nil, errors.Errorf("static converter: invalid %s/%s rate %q", coinName, currency, value)
+			}
+			parsed[coinName][strings.ToUpper(currency)] = rate
+		}
+	}
+	return parsed, nil
+}
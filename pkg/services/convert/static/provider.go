@@ -0,0 +1,41 @@
+package static
+
+import (
+	"errors"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/providers"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// defaultReloadInterval is the default period between rates file changes checks
+const defaultReloadInterval = time.Second * 5
+
+type providerParams struct {
+	Path           string
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+type provider struct{}
+
+// Create implements Provider interface, requires path param, accepts optional reload_interval param
+func (provider) Create(logger logrus.FieldLogger, params map[string]interface{}) (convert.ICryptoCurrency, error) {
+	p := providerParams{ReloadInterval: defaultReloadInterval}
+	if err := providers.DecodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Path == "" {
+		return nil, errors.New("static converter: rates file path param is required")
+	}
+	logger = logger.WithField("path", p.Path)
+	logger.Info("loading static rates")
+	c, err := New(p.Path, p.ReloadInterval, logger)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func init() {
+	providers.Register("static", provider{})
+}
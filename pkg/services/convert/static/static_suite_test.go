@@ -0,0 +1,124 @@
+package static_test
+
+import (
+	"testing"
+
+	"context"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert"
+	"git.zam.io/wallet-backend/wallet-api/pkg/services/convert/static"
+	"github.com/ericlagergren/decimal"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestStatic(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Static Suite")
+}
+
+func expectRate(rate *convert.Rate, expected string) {
+	Expect(rate).NotTo(BeNil())
+	Expect((*decimal.Big)(rate).String()).To(Equal(expected))
+}
+
+var _ = Describe("testing static converter", func() {
+	Context("when parsing rates file", func() {
+		It("should parse yaml with case insensitive names", func() {
+			rates, err := static.Parse([]byte("btc:\n  usd: 6500.12\n  EUR: 5600\nEth:\n  usd: '200'\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rates).To(HaveLen(2))
+			Expect(rates["BTC"]["USD"].String()).To(Equal("6500.12"))
+			Expect(rates["BTC"]["EUR"].String()).To(Equal("5600"))
+			Expect(rates["ETH"]["USD"].String()).To(Equal("200"))
+		})
+
+		It("should parse json", func() {
+			rates, err := static.Parse([]byte(`{"btc": {"usd": 6500.5}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rates["BTC"]["USD"].String()).To(Equal("6500.5"))
+		})
+
+		for _, c := range []struct {
+			name string
+			data string
+		}{
+			{"invalid syntax", "btc: [usd"},
+			{"invalid structure", "btc: 6500"},
+			{"invalid rate", "btc:\n  usd: abc\n"},
+			{"zero rate", "btc:\n  usd: 0\n"},
+			{"negative rate", "btc:\n  usd: -1\n"},
+		} {
+			c := c
+			It("should reject "+c.name, func() {
+				_, err := static.Parse([]byte(c.data))
+				Expect(err).To(HaveOccurred())
+			})
+		}
+	})
+
+	Context("when serving rates", func() {
+		var (
+			dir  string
+			path string
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "static-rates")
+			Expect(err).NotTo(HaveOccurred())
+			path = filepath.Join(dir, "rates.yaml")
+			Expect(ioutil.WriteFile(path, []byte("btc:\n  usd: 6500\n"), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should fail if file is missing or invalid", func() {
+			_, err := static.New(filepath.Join(dir, "missing.yaml"), 0, logrus.New())
+			Expect(err).To(HaveOccurred())
+
+			Expect(ioutil.WriteFile(path, []byte("btc:\n  usd: abc\n"), 0644)).To(Succeed())
+			_, err = static.New(path, 0, logrus.New())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should report unknown names", func() {
+			c, err := static.New(path, 0, logrus.New())
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = c.GetRate(context.Background(), "eth", "usd")
+			Expect(err).To(Equal(convert.ErrCryptoCurrencyName))
+			_, err = c.GetRate(context.Background(), "btc", "rub")
+			Expect(err).To(Equal(convert.ErrFiatCurrencyName))
+		})
+
+		It("should reload changed file keeping previous rates if it's invalid", func() {
+			c, err := static.New(path, 0, logrus.New())
+			Expect(err).NotTo(HaveOccurred())
+
+			rate, err := c.GetRate(context.Background(), "BTC", "USD")
+			Expect(err).NotTo(HaveOccurred())
+			expectRate(rate, "6500")
+
+			Expect(ioutil.WriteFile(path, []byte("btc:\n  usd: broken\n"), 0644)).To(Succeed())
+			rate, err = c.GetRate(context.Background(), "btc", "usd")
+			Expect(err).NotTo(HaveOccurred())
+			expectRate(rate, "6500")
+
+			Expect(os.Remove(path)).To(Succeed())
+			rate, err = c.GetRate(context.Background(), "btc", "usd")
+			Expect(err).NotTo(HaveOccurred())
+			expectRate(rate, "6500")
+
+			Expect(ioutil.WriteFile(path, []byte("btc:\n  usd: 7000.5\n"), 0644)).To(Succeed())
+			rate, err = c.GetRate(context.Background(), "btc", "usd")
+			Expect(err).NotTo(HaveOccurred())
+			expectRate(rate, "7000.5")
+		})
+	})
+})